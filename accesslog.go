@@ -0,0 +1,163 @@
+package nra
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AccessLogFormat is the line format of a AccessLogger.
+type AccessLogFormat int
+
+const (
+	// CommonLogFormat writes lines in the Common Log Format:
+	//
+	//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /rpc/add HTTP/1.1" 200 2 "add"
+	CommonLogFormat AccessLogFormat = iota
+
+	// CombinedLogFormat adds the referer and user agent:
+	//
+	//	127.0.0.1 - - [10/Oct/2000:13:55:36 -0700] "POST /rpc/add HTTP/1.1" 200 2 "-" "curl/8.0" "add"
+	CombinedLogFormat
+)
+
+// clfTime is the time layout of the Common Log Format.
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLogger writes a classic access log line for every request
+// served by a Registry, see Registry.SetAccessLogger. The name of the
+// called function is added as last field, or "-" if the request didn't
+// call a function. It is safe to use from multiple goroutines.
+type AccessLogger struct {
+	mtx    sync.Mutex
+	w      io.Writer
+	format AccessLogFormat
+	now    func() time.Time
+}
+
+// NewAccessLogger creates a logger writing lines in the format to w.
+func NewAccessLogger(w io.Writer, format AccessLogFormat) *AccessLogger {
+	return &AccessLogger{w: w, format: format, now: time.Now}
+}
+
+// Rotate makes the logger write to w from now on, e.g. after the log
+// file was moved by logrotate and a new one was opened. The previous
+// writer is closed if it is a io.Closer. Lines are never split between
+// the old and the new writer.
+func (l *AccessLogger) Rotate(w io.Writer) error {
+	l.mtx.Lock()
+	previous := l.w
+	l.w = w
+	l.mtx.Unlock()
+
+	if c, ok := previous.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// log writes the line of a request.
+func (l *AccessLogger) log(request *http.Request, w *statusWriter, name string) {
+	var buf strings.Builder
+
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		host = request.RemoteAddr
+	}
+
+	user := "-"
+	if u, _, ok := request.BasicAuth(); ok && u != "" {
+		user = u
+	}
+
+	size := "-"
+	if w.size > 0 {
+		size = strconv.FormatInt(w.size, 10)
+	}
+
+	buf.WriteString(clfField(host))
+	buf.WriteString(" - ")
+	buf.WriteString(clfField(user))
+	buf.WriteString(" [" + l.now().Format(clfTime) + "] ")
+	buf.WriteString(clfQuote(request.Method + " " + request.URL.RequestURI() + " " + request.Proto))
+	buf.WriteString(" " + strconv.Itoa(w.statusCode()) + " " + size)
+
+	if l.format == CombinedLogFormat {
+		buf.WriteString(" " + clfQuote(request.Referer()))
+		buf.WriteString(" " + clfQuote(request.UserAgent()))
+	}
+
+	buf.WriteString(" " + clfQuote(name))
+	if id := RequestIDOf(request.Context()); id != "" {
+		buf.WriteString(" " + clfQuote(id))
+	}
+	buf.WriteString("\n")
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	_, _ = io.WriteString(l.w, buf.String())
+}
+
+// clfField returns the value or "-" if it is empty. Spaces are
+// escaped so the field can't be mistaken for multiple ones.
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, " ", "%20")
+}
+
+// clfQuote quotes the value the way web servers do in access logs,
+// empty values are written as "-".
+func clfQuote(value string) string {
+	if value == "" {
+		return `"-"`
+	}
+
+	return strconv.Quote(value)
+}
+
+// statusWriter records the status code, the number of bytes
+// and the error written to a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+	err    *Error
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+// recordError implements errorRecorder.
+func (w *statusWriter) recordError(err *Error) {
+	w.err = err
+}
+
+// statusCode returns the status code of the response, which
+// is 200 if the handler didn't write anything.
+func (w *statusWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,89 @@
+package nra
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// closingBuffer records if it was closed.
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestAccessLogger(t *testing.T) {
+	at := time.Date(2000, 10, 10, 13, 55, 36, 0, time.FixedZone("", -7*60*60))
+
+	for _, test := range []struct {
+		Name     string
+		Format   AccessLogFormat
+		Path     string
+		Expected string
+	}{
+		{
+			Name:     "common",
+			Format:   CommonLogFormat,
+			Path:     "/rpc/add",
+			Expected: `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "POST /rpc/add HTTP/1.1" 200 2 "add"` + "\n",
+		},
+		{
+			Name:     "combined",
+			Format:   CombinedLogFormat,
+			Path:     "/rpc/add",
+			Expected: `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "POST /rpc/add HTTP/1.1" 200 2 "-" "test \"agent\"" "add"` + "\n",
+		},
+		{
+			Name:     "unknown",
+			Format:   CommonLogFormat,
+			Path:     "/rpc/sub?x=1",
+			Expected: `192.0.2.1 - - [10/Oct/2000:13:55:36 -0700] "POST /rpc/sub?x=1 HTTP/1.1" 404 66 "-"` + "\n",
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			l := NewAccessLogger(&out, test.Format)
+			l.now = func() time.Time { return at }
+
+			r := NewRegistry()
+			r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+			r.SetAccessLogger(l)
+
+			req := httptest.NewRequest("POST", test.Path, strings.NewReader("[1, 2]"))
+			req.Header.Set("User-Agent", `test "agent"`)
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, test.Expected, out.String())
+		})
+	}
+}
+
+func TestAccessLoggerRotate(t *testing.T) {
+	first := &closingBuffer{}
+	second := &closingBuffer{}
+
+	r := NewRegistry()
+	r.MustRegister("ping", func() error { return nil })
+
+	l := NewAccessLogger(first, CommonLogFormat)
+	r.SetAccessLogger(l)
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/ping", nil))
+	assert.NoError(t, l.Rotate(second))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/ping", nil))
+
+	assert.True(t, first.closed)
+	assert.False(t, second.closed)
+	assert.Equal(t, 1, strings.Count(first.String(), "\n"))
+	assert.Equal(t, 1, strings.Count(second.String(), "\n"))
+	assert.Contains(t, second.String(), `" 200 - "ping"`)
+}
@@ -0,0 +1,160 @@
+package nra
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// APIVersionHeader selects the version of the called function
+// if the path doesn't contain one, see WithVersion.
+const APIVersionHeader = "X-API-Version"
+
+// WithVersion registers the function as the given version, so a
+// changed function can be registered under the same name next to the
+// old one. Functions without it are version 1:
+//
+//	r.MustRegister("add", add)
+//	r.MustRegister("add", addV2, nra.WithVersion(2))
+//
+// Clients select the version with a path segment, e.g. /rpc/v2/add,
+// or the APIVersionHeader. Calls without a version get the lowest
+// registered version, so adding a version doesn't break old clients.
+func WithVersion(version int) Option {
+	return func(o *options) {
+		if version < 1 {
+			version = 1
+		}
+		o.version = version
+	}
+}
+
+// WithDeprecated marks the function, or the version of it, as
+// deprecated. Calls through a Registry still succeed, but the response
+// has a "Deprecation: true" and a Warning header, so clients notice
+// before the function is removed.
+func WithDeprecated() Option {
+	return func(o *options) {
+		o.deprecated = true
+	}
+}
+
+// apiVersion returns the version set by WithVersion.
+func (o *options) apiVersion() int {
+	if o.version < 1 {
+		return 1
+	}
+	return o.version
+}
+
+// versionedPath matches a path that selects a version, e.g. v2/add.
+var versionedPath = regexp.MustCompile(`^v([0-9]{1,9})/`)
+
+// route returns the name of the function the request calls, and the
+// version it selects in the path or the header, or 0 for the default.
+func (r *Registry) route(request *http.Request) (string, int) {
+	name, version := splitVersion(strings.TrimPrefix(request.URL.Path, r.prefix))
+	if version > 0 {
+		return name, version
+	}
+
+	version, _ = strconv.Atoi(request.Header.Get(APIVersionHeader))
+	if version < 0 {
+		version = 0
+	}
+	return name, version
+}
+
+// splitVersion splits a path like v2/add into the name
+// of the function and the version, which is 0 if missing.
+func splitVersion(path string) (string, int) {
+	m := versionedPath.FindStringSubmatch(path)
+	if m == nil {
+		return path, 0
+	}
+	version, _ := strconv.Atoi(m[1])
+	return path[len(m[0]):], version
+}
+
+// versionedName is the name with the version as path
+// segment, e.g. v2/add, or just the name for version 1.
+func versionedName(name string, version int) string {
+	if version <= 1 {
+		return name
+	}
+	return "v" + strconv.Itoa(version) + "/" + name
+}
+
+// lookup returns the version of the function, or its default version
+// if version is 0.
+func (r *Registry) lookup(name string, version int) (*binding, *Error) {
+	r.mtx.RLock()
+	b, ok := r.functions[name]
+	if ok && version != 0 {
+		b, ok = r.versions[name][version]
+		if !ok {
+			r.mtx.RUnlock()
+			return nil, &Error{Message: "function '" + name + "' has no version " + strconv.Itoa(version), Type: ErrorTypeNotFound}
+		}
+	}
+	r.mtx.RUnlock()
+
+	// the suggestions take the lock themselves.
+	if !ok {
+		return nil, r.notFoundError(name)
+	}
+	return b, nil
+}
+
+// addFunction adds the binding as its version of the function name,
+// replacing and closing the binding of the same version. The lowest
+// version is the default. The caller has to hold the lock.
+func (r *Registry) addFunction(name string, b *binding) {
+	version := b.options.apiVersion()
+	if r.versions[name] == nil {
+		r.versions[name] = map[int]*binding{}
+	}
+	if old, ok := r.versions[name][version]; ok {
+		old.close()
+	}
+	r.versions[name][version] = b
+
+	if current, ok := r.functions[name]; !ok || version <= current.options.apiVersion() {
+		r.functions[name] = b
+	}
+}
+
+// registered checks if the version of the function name is
+// registered. The caller has to hold the lock.
+func (r *Registry) registered(name string, version int) error {
+	if _, ok := r.versions[name][version]; !ok {
+		return nil
+	}
+	if version > 1 {
+		return errors.New("function with the name '" + name + "' is already registered in version " + strconv.Itoa(version))
+	}
+	return errors.New("function with the name '" + name + "' is already registered")
+}
+
+// versionsOf returns the sorted versions of the function name.
+func (r *Registry) versionsOf(name string) []int {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	versions := make([]int, 0, len(r.versions[name]))
+	for v := range r.versions[name] {
+		versions = append(versions, v)
+	}
+	sort.Ints(versions)
+	return versions
+}
+
+// writeDeprecation adds the headers that tell the client
+// the called version of the function is deprecated.
+func writeDeprecation(writer http.ResponseWriter, name string, b *binding) {
+	writer.Header().Set("Deprecation", "true")
+	writer.Header().Add("Warning", `299 - "version `+strconv.Itoa(b.options.apiVersion())+` of function '`+name+`' is deprecated"`)
+}
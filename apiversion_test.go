@@ -0,0 +1,92 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersions(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil }, WithDeprecated())
+	r.MustRegister("add", func(a, b, c int) (int, error) { return a + b + c, nil }, WithVersion(3))
+	r.MustRegister("add", func(a, b int) (int, error) { return (a + b) * 10, nil }, WithVersion(2))
+
+	assert.EqualError(t, r.Register("add", func() error { return nil }), "function with the name 'add' is already registered")
+	assert.EqualError(t, r.Register("add", func() error { return nil }, WithVersion(2)), "function with the name 'add' is already registered in version 2")
+	assert.Equal(t, []string{"add"}, r.Names())
+
+	tests := []struct {
+		name       string
+		path       string
+		header     string
+		body       string
+		status     int
+		result     string
+		deprecated bool
+	}{
+		{"default", "/rpc/add", "", "[1, 2]", http.StatusOK, "3\n", true},
+		{"path", "/rpc/v2/add", "", "[1, 2]", http.StatusOK, "30\n", false},
+		{"header", "/rpc/add", "3", "[1, 2, 3]", http.StatusOK, "6\n", false},
+		{"path over header", "/rpc/v2/add", "3", "[1, 2]", http.StatusOK, "30\n", false},
+		{"explicit first", "/rpc/v1/add", "", "[1, 2]", http.StatusOK, "3\n", true},
+		{"invalid header", "/rpc/add", "latest", "[1, 2]", http.StatusOK, "3\n", true},
+		{"unknown version", "/rpc/v4/add", "", "[1, 2]", http.StatusNotFound, "", false},
+		{"unknown function", "/rpc/v2/sub", "", "[1, 2]", http.StatusNotFound, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", test.path, strings.NewReader(test.body))
+			if test.header != "" {
+				req.Header.Set(APIVersionHeader, test.header)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.status, rec.Code)
+			if test.result != "" {
+				assert.Equal(t, test.result, rec.Body.String())
+			}
+			if test.deprecated {
+				assert.Equal(t, "true", rec.Header().Get("Deprecation"))
+				assert.Equal(t, `299 - "version 1 of function 'add' is deprecated"`, rec.Header().Get("Warning"))
+			} else {
+				assert.Empty(t, rec.Header().Get("Deprecation"))
+			}
+		})
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/v4/add", nil))
+	assert.Contains(t, rec.Body.String(), "function 'add' has no version 4")
+
+	methods := r.Methods()
+	if assert.Len(t, methods, 1) {
+		assert.True(t, methods[0].Deprecated)
+		assert.Equal(t, []int{1, 2, 3}, methods[0].Versions)
+	}
+
+	var buf bytes.Buffer
+	assert.NoError(t, r.Describe(&buf, DescribeTable))
+	assert.Contains(t, buf.String(), "versions 1 2 3")
+}
+
+func TestVersionsLowestIsDefault(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("get", func() (int, error) { return 2, nil }, WithVersion(2))
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/get", nil))
+	assert.Equal(t, "2\n", rec.Body.String())
+
+	r.MustRegister("get", func() (int, error) { return 1, nil })
+
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/get", nil))
+	assert.Equal(t, "1\n", rec.Body.String())
+}
@@ -0,0 +1,229 @@
+package nra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the entry of the audit trail for a single call.
+type AuditRecord struct {
+	// Time is when the call started.
+	Time time.Time `json:"time"`
+
+	// Function is the name of the called function.
+	Function string `json:"function"`
+
+	// Identity is the caller as returned by the authenticator,
+	// see AuditIdentity. It is empty for anonymous calls.
+	Identity string `json:"identity,omitempty"`
+
+	// RequestID is the id of the request, if enabled.
+	RequestID string `json:"request_id,omitempty"`
+
+	// ArgsDigest is the hex encoded SHA-256 of the redacted arguments
+	// as JSON, so calls with the same arguments can be matched without
+	// storing them. It is empty if the arguments weren't decoded.
+	ArgsDigest string `json:"args_digest,omitempty"`
+
+	// Args are the redacted arguments, if Audit.IncludeArgs is set.
+	Args json.RawMessage `json:"args,omitempty"`
+
+	// Status is the status code of the response and ErrorType
+	// the type of the error, if the call failed.
+	Status    int       `json:"status"`
+	ErrorType ErrorType `json:"error_type,omitempty"`
+
+	// Duration is how long the call took.
+	Duration time.Duration `json:"duration"`
+}
+
+// AuditSink stores the audit trail, e.g. in a file or a database.
+// Audit is called after the response of each call was written.
+type AuditSink interface {
+	Audit(ctx context.Context, record AuditRecord) error
+}
+
+// AuditSinkFunc is a function used as AuditSink.
+type AuditSinkFunc func(ctx context.Context, record AuditRecord) error
+
+// Audit implements AuditSink.
+func (f AuditSinkFunc) Audit(ctx context.Context, record AuditRecord) error {
+	return f(ctx, record)
+}
+
+// Audit configures the audit trail, see Registry.EnableAudit.
+type Audit struct {
+	// Sink stores the records.
+	Sink AuditSink
+
+	// Redact are the names of object fields whose values are replaced
+	// by "[redacted]" before the arguments are hashed or included,
+	// wherever they appear in the arguments. Names are compared case
+	// insensitive. Functions can add their own with WithAuditRedact.
+	Redact []string
+
+	// IncludeArgs adds the redacted arguments to the records.
+	IncludeArgs bool
+
+	// OnError is called if the sink fails. Errors are
+	// ignored if it isn't set, as the call already happened.
+	OnError func(err error)
+}
+
+// EnableAudit records every call of the registry, including rejected
+// ones, with the caller, a digest of the arguments, the status and the
+// timing to the sink of the audit trail:
+//
+//	file, _ := os.OpenFile("audit.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+//	r.EnableAudit(nra.Audit{
+//		Sink:   nra.NewAuditWriter(file),
+//		Redact: []string{"password", "token"},
+//	})
+func (r *Registry) EnableAudit(config Audit) {
+	r.audit = &auditor{registry: r, config: config}
+}
+
+// WithAuditRedact redacts the object fields with the given names
+// in the arguments of the function, in addition to Audit.Redact.
+func WithAuditRedact(fields ...string) Option {
+	return func(o *options) {
+		o.auditRedact = append(o.auditRedact, fields...)
+	}
+}
+
+// redacted replaces the values of redacted fields.
+const redacted = "[redacted]"
+
+// auditor is the Observer that writes the audit trail of a registry.
+type auditor struct {
+	registry *Registry
+	config   Audit
+}
+
+// StartCall implements Observer.
+func (a *auditor) StartCall(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+	ctx := request.Context()
+	record := AuditRecord{Time: time.Now(), Function: name, RequestID: RequestIDOf(ctx)}
+
+	// the arguments are only known once they were decoded.
+	if call, ok := ctx.Value(callRecordKey{}).(*callRecord); ok {
+		call.onArgs = func(ctx context.Context, args []interface{}) {
+			record.Identity = AuditIdentity(IdentityOf(ctx))
+			a.addArgs(&record, args)
+		}
+	}
+
+	return request, func(info CallInfo) {
+		record.Status = info.Status
+		record.Duration = info.Duration
+		if info.Error != nil {
+			record.ErrorType = info.Error.Type
+		}
+
+		if err := a.config.Sink.Audit(context.Background(), record); err != nil && a.config.OnError != nil {
+			a.config.OnError(err)
+		}
+	}
+}
+
+// addArgs adds the digest and, if configured, the
+// redacted arguments of the call to the record.
+func (a *auditor) addArgs(record *AuditRecord, args []interface{}) {
+	fields := map[string]bool{}
+	for _, name := range a.config.Redact {
+		fields[strings.ToLower(name)] = true
+	}
+	if b, ok := a.registry.function(record.Function); ok {
+		for _, name := range b.options.auditRedact {
+			fields[strings.ToLower(name)] = true
+		}
+	}
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	data, err := json.Marshal(redact(args, fields))
+	if err != nil {
+		return
+	}
+
+	digest := sha256.Sum256(data)
+	record.ArgsDigest = hex.EncodeToString(digest[:])
+	if a.config.IncludeArgs {
+		record.Args = data
+	}
+}
+
+// redact returns a copy of the generic JSON value v with
+// the values of the fields replaced.
+func redact(v interface{}, fields map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			if fields[strings.ToLower(key)] {
+				copied[key] = redacted
+			} else {
+				copied[key] = redact(value, fields)
+			}
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(v))
+		for i, value := range v {
+			copied[i] = redact(value, fields)
+		}
+		return copied
+	}
+	return v
+}
+
+// AuditIdentity returns the name of a identity as it is recorded in
+// the audit trail: strings as they are, the subject of claims, or the
+// formatted value of everything else.
+func AuditIdentity(identity interface{}) string {
+	switch id := identity.(type) {
+	case nil:
+		return ""
+	case string:
+		return id
+	case interface{ Subject() string }:
+		return id.Subject()
+	case fmt.Stringer:
+		return id.String()
+	}
+	return fmt.Sprint(identity)
+}
+
+// auditWriter is a AuditSink writing JSON lines.
+type auditWriter struct {
+	mtx sync.Mutex
+	w   io.Writer
+}
+
+// NewAuditWriter creates a AuditSink that writes every
+// record as a line of JSON to w, e.g. a file.
+func NewAuditWriter(w io.Writer) AuditSink {
+	return &auditWriter{w: w}
+}
+
+// Audit implements AuditSink.
+func (s *auditWriter) Audit(ctx context.Context, record AuditRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
@@ -0,0 +1,97 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type loginRequest struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	PIN      int    `json:"pin"`
+}
+
+func TestAudit(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRegistry()
+	r.EnableRequestIDs()
+	r.SetAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		if request.Header.Get("X-User") == "" {
+			return nil, ErrUnauthenticated
+		}
+		return Claims{"sub": request.Header.Get("X-User")}, nil
+	}))
+	r.EnableAudit(Audit{Sink: NewAuditWriter(&out), Redact: []string{"Password"}, IncludeArgs: true})
+	r.MustRegister("login", func(req loginRequest) error { return nil }, WithAuditRedact("pin"))
+	r.MustRegister("fail", func() error { return errors.New("failed") })
+
+	call := func(name, user, body string) AuditRecord {
+		out.Reset()
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		req.Header.Set(RequestIDHeader, "abc")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+
+		var record AuditRecord
+		assert.NoError(t, json.Unmarshal(out.Bytes(), &record))
+		return record
+	}
+
+	record := call("login", "alice", `[{"user": "alice", "password": "secret", "pin": 1234}]`)
+	assert.Equal(t, "login", record.Function)
+	assert.Equal(t, "alice", record.Identity)
+	assert.Equal(t, "abc", record.RequestID)
+	assert.Equal(t, http.StatusOK, record.Status)
+	assert.Empty(t, record.ErrorType)
+	assert.JSONEq(t, `[{"user": "alice", "password": "[redacted]", "pin": "[redacted]"}]`, string(record.Args))
+	assert.Len(t, record.ArgsDigest, 64)
+	assert.False(t, record.Time.IsZero())
+
+	// the digest doesn't depend on the redacted values.
+	other := call("login", "alice", `[{"user": "alice", "password": "other", "pin": 1}]`)
+	assert.Equal(t, record.ArgsDigest, other.ArgsDigest)
+	other = call("login", "alice", `[{"user": "bob", "password": "secret", "pin": 1234}]`)
+	assert.NotEqual(t, record.ArgsDigest, other.ArgsDigest)
+
+	record = call("fail", "bob", "")
+	assert.Equal(t, "bob", record.Identity)
+	assert.Equal(t, ErrorTypeApplication, record.ErrorType)
+	assert.Equal(t, http.StatusBadRequest, record.Status)
+	assert.JSONEq(t, `[]`, string(record.Args))
+
+	// rejected calls are recorded too.
+	record = call("fail", "", "")
+	assert.Empty(t, record.Identity)
+	assert.Empty(t, record.ArgsDigest)
+	assert.Equal(t, http.StatusUnauthorized, record.Status)
+}
+
+func TestAuditSinkError(t *testing.T) {
+	var failed error
+	r := NewRegistry()
+	r.EnableAudit(Audit{
+		Sink:    AuditSinkFunc(func(ctx context.Context, record AuditRecord) error { return errors.New("disk full") }),
+		OnError: func(err error) { failed = err },
+	})
+	r.MustRegister("ping", func() error { return nil })
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/ping", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.EqualError(t, failed, "disk full")
+}
+
+func TestAuditIdentity(t *testing.T) {
+	assert.Equal(t, "", AuditIdentity(nil))
+	assert.Equal(t, "alice", AuditIdentity("alice"))
+	assert.Equal(t, "bob", AuditIdentity(Claims{"sub": "bob"}))
+	assert.Equal(t, "42", AuditIdentity(42))
+}
@@ -0,0 +1,210 @@
+package nra
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Authenticator checks who is calling a function. It runs before the
+// arguments are decoded, so unauthenticated calls are rejected early.
+//
+// The returned identity, e.g. a user or the name of a API key, is
+// attached to the context of the call and can be retrieved with
+// IdentityOf. Returning ErrForbidden or a error wrapping it rejects
+// the call with status 403, all other errors reject it with 401. A
+// *Error is sent to the client as it is.
+type Authenticator interface {
+	Authenticate(request *http.Request) (identity interface{}, err error)
+}
+
+// AuthenticatorFunc is a function used as Authenticator.
+type AuthenticatorFunc func(request *http.Request) (interface{}, error)
+
+// Authenticate implements Authenticator.
+func (f AuthenticatorFunc) Authenticate(request *http.Request) (interface{}, error) {
+	return f(request)
+}
+
+var (
+	// ErrUnauthenticated is returned by authenticators if
+	// the call doesn't have valid credentials.
+	ErrUnauthenticated = errors.New("authentication required")
+
+	// ErrForbidden is returned by authenticators if the caller
+	// is known but isn't allowed to call the function.
+	ErrForbidden = errors.New("access denied")
+)
+
+// Anonymous is a Authenticator that accepts every call without a
+// identity. Pass it to WithAuthenticator to make a function public
+// even though its registry requires authentication, e.g. a login.
+var Anonymous Authenticator = anonymous{}
+
+// anonymous is the type of Anonymous.
+type anonymous struct{}
+
+// Authenticate implements Authenticator.
+func (anonymous) Authenticate(*http.Request) (interface{}, error) {
+	return nil, nil
+}
+
+// WithAuthenticator makes every call of the function authenticate
+// with a. It replaces the authenticator of the registry, if set.
+func WithAuthenticator(a Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = a
+	}
+}
+
+// SetAuthenticator makes the calls of all functions of the registry
+// authenticate with a, except for functions that have their own one
+// set with WithAuthenticator. The Javascript client and other endpoints
+// served by the registry stay public.
+//
+// Functions run with WithSubprocess don't see the identity, pass
+// the authenticator to them with WithAuthenticator instead.
+func (r *Registry) SetAuthenticator(a Authenticator) {
+	r.authenticator = a
+}
+
+// identityKey is the context key of the identity of a call.
+type identityKey struct{}
+
+// IdentityOf returns the identity the Authenticator returned
+// for the call ctx belongs to, or nil if there is none.
+//
+//	func DeletePost(ctx context.Context, id int) error {
+//		user := nra.IdentityOf(ctx).(*User)
+//		...
+//	}
+func IdentityOf(ctx context.Context) interface{} {
+	return ctx.Value(identityKey{})
+}
+
+// authenticatedKey marks calls whose function authenticator
+// already ran, so the handler doesn't run it again.
+type authenticatedKey struct{}
+
+// authenticate runs a for the request and returns the request with
+// the identity attached. If the call is rejected the error response is
+// written and nil is returned.
+func authenticate(a Authenticator, writer http.ResponseWriter, request *http.Request) *http.Request {
+	identity, err := a.Authenticate(request)
+	if err == nil {
+		if identity == nil {
+			return request
+		}
+		if record, ok := request.Context().Value(callRecordKey{}).(*callRecord); ok {
+			record.identity = identity
+		}
+		return request.WithContext(context.WithValue(request.Context(), identityKey{}, identity))
+	}
+
+	status, errType := http.StatusUnauthorized, ErrorTypeUnauthorized
+	if errors.Is(err, ErrForbidden) {
+		status, errType = http.StatusForbidden, ErrorTypeForbidden
+	}
+
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Message: err.Error(), Type: errType}
+	}
+
+	if status == http.StatusUnauthorized {
+		if c, ok := a.(interface{ challenge() string }); ok && c.challenge() != "" {
+			writer.Header().Set("WWW-Authenticate", c.challenge())
+		}
+	}
+	writeError(writer, status, e)
+	return nil
+}
+
+// APIKeys authenticates calls by a static API key sent in the
+// header, e.g. "X-API-Key". keys maps every valid key to the identity
+// of its owner.
+func APIKeys(header string, keys map[string]interface{}) Authenticator {
+	return &keyAuthenticator{keys: keys, key: func(request *http.Request) string {
+		return request.Header.Get(header)
+	}}
+}
+
+// BearerTokens authenticates calls by a static token sent as
+// "Authorization: Bearer <token>". tokens maps every valid token to
+// the identity of its owner. Use BearerAuth for tokens that have to
+// be verified, e.g. JWTs.
+func BearerTokens(tokens map[string]interface{}) Authenticator {
+	return &keyAuthenticator{keys: tokens, key: bearerToken, bearer: true}
+}
+
+// BearerAuth authenticates calls by a token sent as
+// "Authorization: Bearer <token>" that is checked by verify.
+// verify returns the identity the token belongs to.
+func BearerAuth(verify func(ctx context.Context, token string) (interface{}, error)) Authenticator {
+	return bearerAuthenticator(verify)
+}
+
+// keyAuthenticator looks up a static key.
+type keyAuthenticator struct {
+	keys   map[string]interface{}
+	key    func(request *http.Request) string
+	bearer bool
+}
+
+// Authenticate implements Authenticator.
+func (a *keyAuthenticator) Authenticate(request *http.Request) (interface{}, error) {
+	key := a.key(request)
+	if key == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	// all keys are compared so the time doesn't
+	// tell how many keys were checked.
+	var identity interface{}
+	found := false
+	for k, id := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(k), []byte(key)) == 1 {
+			identity, found = id, true
+		}
+	}
+	if !found {
+		return nil, errors.New("invalid credentials")
+	}
+	return identity, nil
+}
+
+// challenge returns the WWW-Authenticate header of rejected calls.
+func (a *keyAuthenticator) challenge() string {
+	if a.bearer {
+		return "Bearer"
+	}
+	return ""
+}
+
+// bearerAuthenticator verifies bearer tokens.
+type bearerAuthenticator func(ctx context.Context, token string) (interface{}, error)
+
+// Authenticate implements Authenticator.
+func (a bearerAuthenticator) Authenticate(request *http.Request) (interface{}, error) {
+	token := bearerToken(request)
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+	return a(request.Context(), token)
+}
+
+// challenge returns the WWW-Authenticate header of rejected calls.
+func (a bearerAuthenticator) challenge() string {
+	return "Bearer"
+}
+
+// bearerToken returns the token of the Authorization header.
+func bearerToken(request *http.Request) string {
+	scheme, token, ok := strings.Cut(request.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
@@ -0,0 +1,104 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticators(t *testing.T) {
+	whoami := func(ctx context.Context) (interface{}, error) { return IdentityOf(ctx), nil }
+
+	bearer := BearerAuth(func(ctx context.Context, token string) (interface{}, error) {
+		switch token {
+		case "alice":
+			return "alice", nil
+		case "bob":
+			return nil, ErrForbidden
+		}
+		return nil, errors.New("invalid token")
+	})
+
+	tests := []struct {
+		name     string
+		auth     Authenticator
+		header   string
+		value    string
+		code     int
+		body     string
+		response string
+	}{
+		{name: "api key", auth: APIKeys("X-API-Key", map[string]interface{}{"secret": "ci"}), header: "X-API-Key", value: "secret", code: http.StatusOK, body: "\"ci\"\n"},
+		{name: "wrong api key", auth: APIKeys("X-API-Key", map[string]interface{}{"secret": "ci"}), header: "X-API-Key", value: "wrong", code: http.StatusUnauthorized, body: `{"error":{"message":"invalid credentials","type":"unauthorized"}}` + "\n"},
+		{name: "missing api key", auth: APIKeys("X-API-Key", map[string]interface{}{"secret": "ci"}), code: http.StatusUnauthorized, body: `{"error":{"message":"authentication required","type":"unauthorized"}}` + "\n"},
+		{name: "static bearer", auth: BearerTokens(map[string]interface{}{"token": "ci"}), header: "Authorization", value: "Bearer token", code: http.StatusOK, body: "\"ci\"\n"},
+		{name: "wrong scheme", auth: BearerTokens(map[string]interface{}{"token": "ci"}), header: "Authorization", value: "Basic token", code: http.StatusUnauthorized, response: "Bearer"},
+		{name: "bearer", auth: bearer, header: "Authorization", value: "bearer alice", code: http.StatusOK, body: "\"alice\"\n"},
+		{name: "forbidden", auth: bearer, header: "Authorization", value: "Bearer bob", code: http.StatusForbidden, body: `{"error":{"message":"access denied","type":"forbidden"}}` + "\n"},
+		{name: "invalid", auth: bearer, header: "Authorization", value: "Bearer eve", code: http.StatusUnauthorized, response: "Bearer"},
+		{name: "anonymous", auth: Anonymous, code: http.StatusOK, body: "null\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := MustBind(whoami, WithAuthenticator(test.auth))
+
+			req := httptest.NewRequest("POST", "/", nil)
+			if test.header != "" {
+				req.Header.Set(test.header, test.value)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			if test.body != "" {
+				assert.Equal(t, test.body, rec.Body.String())
+			}
+			assert.Equal(t, test.response, rec.Header().Get("WWW-Authenticate"))
+		})
+	}
+}
+
+func TestRegistryAuthenticator(t *testing.T) {
+	decoded := false
+	r := NewRegistry()
+	r.MustRegister("whoami", func(request *http.Request, s string) (interface{}, error) {
+		decoded = true
+		return IdentityOf(request.Context()), nil
+	})
+	r.MustRegister("login", func(name string) (interface{}, error) { return "welcome", nil }, WithAuthenticator(Anonymous))
+	r.SetAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		if request.Header.Get("X-User") == "" {
+			return nil, &Error{Message: "please log in", Type: ErrorTypeUnauthorized, Hint: "call login first"}
+		}
+		return request.Header.Get("X-User"), nil
+	}))
+
+	call := func(name, user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(`["x"]`))
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("whoami", "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `{"error":{"message":"please log in","type":"unauthorized","hint":"call login first"}}`+"\n", rec.Body.String())
+	assert.False(t, decoded)
+
+	rec = call("whoami", "alice")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+
+	rec = call("login", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\"welcome\"\n", rec.Body.String())
+}
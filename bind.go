@@ -3,13 +3,14 @@
 package nra
 
 import (
+	"context"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
-	"fmt"
+	"io"
 	"net/http"
 	"reflect"
-
-	"github.com/mitchellh/mapstructure"
+	"strconv"
 )
 
 // Bind creates a http.HandlerFunc from a function.
@@ -26,14 +27,127 @@ import (
 // The return must be a error.
 //
 // a valid function would be:
-//   func CallMe(a int, b string) (string, error) {
-//     if(a == 0) {
-//       return "", fmt.Errorf("something went wrong")
-//     }
-//     return "hello world", nil
-//   }
 //
-func Bind(fn interface{}) (http.HandlerFunc, error) {
+//	func CallMe(a int, b string) (string, error) {
+//	  if(a == 0) {
+//	    return "", fmt.Errorf("something went wrong")
+//	  }
+//	  return "hello world", nil
+//	}
+func Bind(fn interface{}, opts ...Option) (http.HandlerFunc, error) {
+	b, err := newBinding(fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// the subprocess needs to find the function by its
+	// name, which only exists in a registry.
+	if b.options.subprocess {
+		return nil, errors.New("WithSubprocess can only be used with a Registry")
+	}
+
+	// the responses are kept by the registry.
+	if b.options.idempotencyKey {
+		return nil, errors.New("WithIdempotencyKey can only be used with a Registry")
+	}
+
+	// the keys to decrypt are set on the registry.
+	if len(b.encrypted) > 0 {
+		return nil, errors.New("encrypted fields can only be used with a Registry")
+	}
+
+	return b.handler, nil
+}
+
+// binding is a function that has been checked and
+// wrapped into a http.HandlerFunc. Besides the handler
+// it keeps the types of the arguments and the return value
+// so that clients and docs can be generated from it.
+type binding struct {
+	fnType   reflect.Type
+	params   []reflect.Type
+	variadic bool
+	minArgs  int
+	decoders []argDecoder
+	result   reflect.Type
+
+	// encrypted are the encrypted fields in the arguments.
+	encrypted []encryptedPath
+
+	// validators check the decoded arguments, nil
+	// for parameters without validate rules.
+	validators []validator
+
+	// schema checks the arguments before they are
+	// decoded, see WithSchemaValidation.
+	schema *argumentsValidator
+
+	// upload is set if the only argument is a *Upload.
+	upload bool
+
+	// cache keeps the results if the function is cached.
+	cache *resultCache
+
+	// pool runs the calls if the function is bound
+	// WithOSThreadPool.
+	pool *threadPool
+
+	options *options
+	handler http.HandlerFunc
+}
+
+// paramType returns the type the i-th argument has to be converted to.
+// All arguments passed for the variadic parameter have its element type.
+func (b *binding) paramType(i int) reflect.Type {
+	if b.variadic && i >= len(b.params)-1 {
+		return b.params[len(b.params)-1].Elem()
+	}
+	return b.params[i]
+}
+
+// close releases the threads of the function once it is
+// no longer registered. Running calls still finish.
+func (b *binding) close() {
+	if b.pool != nil {
+		b.pool.close()
+	}
+}
+
+// decoder returns the decoder of the i-th argument.
+func (b *binding) decoder(i int) argDecoder {
+	if i >= len(b.decoders) {
+		return b.decoders[len(b.decoders)-1]
+	}
+	return b.decoders[i]
+}
+
+// validator returns the validator of the i-th argument.
+func (b *binding) validator(i int) validator {
+	if i >= len(b.validators) {
+		return b.validators[len(b.validators)-1]
+	}
+	return b.validators[i]
+}
+
+// optional reports if the i-th parameter can be omitted.
+func (b *binding) optional(i int) bool {
+	return i >= b.minArgs
+}
+
+// expectedArgs describes how many arguments the function expects.
+func (b *binding) expectedArgs() string {
+	switch {
+	case b.variadic:
+		return "at least " + strconv.Itoa(b.minArgs)
+	case b.minArgs < len(b.params):
+		return strconv.Itoa(b.minArgs) + " to " + strconv.Itoa(len(b.params))
+	}
+	return strconv.Itoa(len(b.params))
+}
+
+// newBinding checks fn and creates the binding for it.
+// See Bind for which functions are valid.
+func newBinding(fn interface{}, opts ...Option) (*binding, error) {
 	// get the type and value via reflection.
 	fnType := reflect.TypeOf(fn)
 	fnValue := reflect.ValueOf(fn)
@@ -59,180 +173,587 @@ func Bind(fn interface{}) (http.HandlerFunc, error) {
 	}
 
 	passRequest := false
+	passContext := false
 	argNum := fnType.NumIn()
 	argOffset := 0
 
-	// Check if *http.Request should be passed to target function.
+	// Check if *http.Request or the context of the call
+	// should be passed to target function.
 	if argNum > 0 && fnType.In(0) == reflect.TypeOf(new(http.Request)) {
 		passRequest = true
 		argNum--
 		argOffset++
+	} else if argNum > 0 && fnType.In(0) == contextType {
+		passContext = true
+		argNum--
+		argOffset++
+	}
+
+	// the claims and the session of the call are passed as
+	// the next parameters if the function asks for them.
+	var injected []reflect.Type
+	for argNum > 0 && !(fnType.IsVariadic() && argNum == 1) && isInjected(fnType.In(argOffset)) {
+		injected = append(injected, fnType.In(argOffset))
+		argNum--
+		argOffset++
+	}
+
+	b := &binding{fnType: fnType, options: newOptions(opts), variadic: fnType.IsVariadic()}
+	if len(injected) > 0 && b.options.static != nil {
+		return nil, errors.New("WithStatic can't be used with a claims or session parameter")
+	}
+
+	// the child of a subprocess only lives for a single call, so state
+	// kept across calls has to be kept by the parent, and the identity
+	// and session of the call only exist in the parent.
+	if b.options.subprocess {
+		switch {
+		case len(injected) > 0:
+			return nil, errors.New("WithSubprocess can't be used with a claims or session parameter")
+		case b.options.rateLimit != nil:
+			return nil, errors.New("WithSubprocess can't be used with WithRateLimit")
+		case b.options.maxConcurrent > 0:
+			return nil, errors.New("WithSubprocess can't be used with WithMaxConcurrent")
+		case b.options.cacheTTL > 0:
+			return nil, errors.New("WithSubprocess can't be used with WithCache")
+		}
+	}
+	exec, pool := newExecutor(b.options)
+	b.pool = pool
+	limit := newConcurrencyLimit(b.options)
+	rateLimit := newRateLimiter(b.options.rateLimit)
+	b.cache = newResultCache(b.options)
+	if b.cache != nil {
+		for _, t := range injected {
+			b.cache.session = b.cache.session || t == sessionType
+		}
+	}
+	for i := argOffset; i < fnType.NumIn(); i++ {
+		b.params = append(b.params, fnType.In(i))
+	}
+
+	// the arguments of a variadic function can be omitted
+	// completely, the same goes for trailing pointer arguments.
+	fixedArgs := argNum
+	if b.variadic {
+		fixedArgs--
+	}
+	b.minArgs = fixedArgs
+	for b.minArgs > 0 && b.params[b.minArgs-1].Kind() == reflect.Ptr {
+		b.minArgs--
+	}
+
+	// invalid defaults should fail now and not on the first call.
+	seen := map[reflect.Type]bool{}
+	for _, p := range b.params {
+		if err := checkDefaults(p, seen); err != nil {
+			return nil, err
+		}
+	}
+
+	// the conversion of each argument is worked out once
+	// here instead of on every call.
+	for i := range b.params {
+		b.decoders = append(b.decoders, decoderOf(b.paramType(i)))
+
+		v, err := validatorFor(b.paramType(i))
+		if err != nil {
+			return nil, err
+		}
+		b.validators = append(b.validators, v)
+	}
+
+	var err error
+	if b.encrypted, err = encryptedFieldsOf(b); err != nil {
+		return nil, err
 	}
+	if len(b.encrypted) > 0 && b.options.static != nil {
+		return nil, errors.New("WithStatic can't be used with encrypted fields")
+	}
+
+	// uploads are streamed, so they can't be mixed with arguments
+	// that are sent at once or with anything that needs the whole body.
+	for _, p := range b.params {
+		if !isUpload(p) {
+			continue
+		}
+		switch {
+		case len(b.params) != 1 || b.variadic:
+			return nil, errors.New("a *Upload has to be the only argument")
+		case b.options.safe:
+			return nil, errors.New("WithSafe can't be used with a *Upload argument")
+		case b.options.static != nil:
+			return nil, errors.New("WithStatic can't be used with a *Upload argument")
+		case b.cache != nil:
+			return nil, errors.New("WithCache can't be used with a *Upload argument")
+		case b.options.schemaValidation:
+			return nil, errors.New("WithSchemaValidation can't be used with a *Upload argument")
+		case b.options.idempotencyKey:
+			// the key identifies the whole body, which
+			// would have to be kept in memory.
+			return nil, errors.New("WithIdempotencyKey can't be used with a *Upload argument")
+		}
+		b.upload = true
+	}
+
+	if errReturnIndex == 1 {
+		b.result = fnType.Out(0)
+	}
+
+	if b.options.schemaValidation {
+		b.schema = newArgumentsValidator(b)
+	}
+
+	methods := "POST"
+	if b.options.safe {
+		methods = "GET, POST"
+	}
+
+	b.handler = func(writer http.ResponseWriter, request *http.Request) {
+		if b.options.compression > 0 {
+			var done func()
+			writer, done = withCompression(writer, request, b.options.compression)
+			defer done()
+		}
+
+		if b.options.cors != nil && b.options.cors.handle(writer, request, methods) {
+			return
+		}
 
-	return func(writer http.ResponseWriter, request *http.Request) {
 		// nra only accepts POST requests because it
 		// will get the arguments to call fn from the
 		// post data.
-		if request.Method != "POST" {
-			http.Error(writer, "\"only POST requests are permitted\"", http.StatusBadRequest)
+		//
+		// safe functions can also be called via GET with
+		// the arguments in the query string.
+		if request.Method != "POST" && !(b.options.safe && request.Method == "GET") {
+			message := "only POST requests are permitted"
+			if b.options.safe {
+				message = "only GET and POST requests are permitted"
+			}
+
+			writeError(writer, http.StatusBadRequest, &Error{Message: message, Type: ErrorTypeRequest})
 			return
 		}
 
+		// calls over the rate limit are rejected before the body is read.
+		if ok, wait := rateLimit.allow("", request); !ok {
+			writeRateLimited(writer, wait)
+			return
+		}
+
+		if b.options.authenticator != nil && !signed(request.Context()) && request.Context().Value(authenticatedKey{}) == nil {
+			if request = authenticate(b.options.authenticator, writer, request); request == nil {
+				return
+			}
+		}
+
 		// on the Javascript side the arguments will
 		// be encoded as a array that contains variable types.
 		// So we just generically decode it into a []interface{}.
-		// first.
-		var args []interface{}
-		if err := json.NewDecoder(request.Body).Decode(&args); err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
+		// first. The Content-Type selects the wire format, JSON
+		// is used if it is missing or unknown.
+		//
+		// an empty body is treated the same as a `null` or `[]`
+		// body, so functions without arguments can be called
+		// without having to send anything.
+		//
+		// with a memory budget the body counts towards it, as the
+		// decoded arguments take at least as much memory.
+		if b.options.memoryBudget > 0 {
+			request.Body = &budgetReader{ReadCloser: request.Body, remaining: b.options.memoryBudget}
+		}
+
+		if b.options.maxBodyBytes > 0 {
+			request.Body = http.MaxBytesReader(writer, request.Body, b.options.maxBodyBytes)
+		}
+
+		// the limits apply to the body as it was sent.
+		if err := transformBody(request, b.options.transforms); err != nil {
+			writeError(writer, http.StatusBadRequest, err)
 			return
 		}
 
-		if err := request.Body.Close(); err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
+		// in process calls can pass their arguments as they are.
+		local := inProcessOf(request.Context())
+
+		// cached results are found by the body as it was decoded.
+		if b.cache != nil && !local.isDirect() {
+			served, w, done := b.cache.serve(writer, request)
+			if served {
+				return
+			}
+			writer = w
+			defer done()
+		}
+
+		c := codecOf(request.Header.Get("Content-Type"))
+
+		// generated handlers decode the raw JSON arguments themselves.
+		static := b.options.static != nil && request.Method == "POST" && c == jsonCodec && !local.isDirect()
+
+		var args []interface{}
+		var raw []json.RawMessage
+		var err error
+		switch {
+		case local.isDirect():
+			args = local.args
+		case b.upload:
+			args, err = b.startUpload(request)
+		case request.Method == "GET":
+			args, err = b.queryArguments(request.URL.Query())
+		case c == protobufCodec:
+			args, err = b.decodeProtobuf(request.Body)
+		case static:
+			err = json.NewDecoder(request.Body).Decode(&raw)
+		case c == jsonCodec:
+			pooled, decodeErr := decodeJSONArgs(request.Body)
+			defer putArgs(pooled)
+			args, err = *pooled, decodeErr
+		default:
+			args, err = c.Decode(request.Body)
+		}
+
+		if err != nil && err != io.EOF {
+			if errors.Is(err, errMemoryBudget) {
+				writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: err.Error(), Type: ErrorTypeLimit})
+				return
+			}
+
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+				return
+			}
+
+			writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
 			return
 		}
 
+		// the chunks of a upload are read by the function.
+		if !b.upload {
+			if err := request.Body.Close(); err != nil {
+				writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+				return
+			}
+		}
+
+		argCount := len(args)
+		if static {
+			argCount = len(raw)
+		}
+
 		// check if number of arguments match the fn function.
-		if len(args) != argNum {
-			http.Error(writer, "\"number of arguments mismatch\"", http.StatusBadRequest)
+		if argCount < b.minArgs || (!b.variadic && argCount > argNum) {
+			writeError(writer, http.StatusBadRequest, &Error{Message: "number of arguments mismatch. got=" + strconv.Itoa(argCount) + " expected=" + b.expectedArgs(), Type: ErrorTypeValidation})
 			return
 		}
+		recordArgs(request, args, raw)
+
+		// the arguments are checked as they were sent, before
+		// anything could convert them. Direct in process calls
+		// and protobuf messages are already typed.
+		if b.schema != nil && !local.isDirect() && c != protobufCodec {
+			var e *Error
+			if static {
+				e = b.schema.validateRaw(raw)
+			} else {
+				e = b.schema.validate(args)
+			}
+			if e != nil {
+				writeError(writer, http.StatusBadRequest, e)
+				return
+			}
+		}
+
+		// encrypted fields are only decrypted now, so everything
+		// that saw the arguments so far only saw the encrypted values.
+		if len(b.encrypted) > 0 {
+			var e *Error
+			if args, e = decryptArgs(request, args, b.encrypted); e != nil {
+				status := http.StatusBadRequest
+				if e.Type == ErrorTypeInternal {
+					status = http.StatusInternalServerError
+				}
+				writeError(writer, status, e)
+				return
+			}
+		}
 
 		// now we need to check each argument if it
 		// matches the argument of the fn function, or
 		// can be dynamically converted to the right type.
-		var callValues []reflect.Value
+		// the first value is left for the request or context.
+		callValues := make([]reflect.Value, argOffset, argOffset+len(args)+fixedArgs)
+		for i, t := range injected {
+			value, err := injectedValue(t, request)
+			if err != nil {
+				status := http.StatusUnauthorized
+				if err.Type == ErrorTypeInternal {
+					status = http.StatusInternalServerError
+				}
+				writeError(writer, status, err)
+				return
+			}
+			callValues[argOffset-len(injected)+i] = value
+		}
 		for i := range args {
-			argType := reflect.TypeOf(args[i])
-
-			// check if the argument was null on the javascript side.
-			if argType == nil {
-				// check if the argument in fn can be nil. if it
-				// can be we will create a nil value for the type.
-				switch fnType.In(i + argOffset).Kind() {
-				case reflect.Ptr:
-					fallthrough
-				case reflect.Uintptr:
-					fallthrough
-				case reflect.Map:
-					fallthrough
-				case reflect.Array:
-					fallthrough
-				case reflect.Slice:
-					callValues = append(callValues, reflect.New(fnType.In(i+argOffset)).Elem())
-					continue
+			value, err := b.decoder(i)(i+1, args[i])
+			if err != nil {
+				writeError(writer, http.StatusBadRequest, err)
+				return
+			}
+			if v := b.validator(i); v != nil {
+				if err := validateArg(i+1, value, v); err != nil {
+					writeError(writer, http.StatusBadRequest, err)
+					return
 				}
+			}
+			callValues = append(callValues, value)
+		}
 
-				// otherwise we return a error because the argument couldn't
-				// be a nil value.
-				http.Error(writer, fmt.Sprintf("\"%d. can't be null\"", i+1), http.StatusBadRequest)
+		// omitted optional arguments are nil.
+		for i := len(args); i < fixedArgs && !static; i++ {
+			callValues = append(callValues, reflect.Zero(b.params[i]))
+		}
+
+		// the function gets a context that is cancelled when the
+		// client disconnects or the call has to be aborted. Goroutines
+		// started with Go on it belong to the call.
+		parent := request.Context()
+		if b.options.timeout > 0 {
+			var cancel context.CancelFunc
+			parent, cancel = context.WithTimeout(parent, b.options.timeout)
+			defer cancel()
+		}
+
+		ctx, group := newCallContext(parent)
+		defer group.cancel()
+		request = request.WithContext(ctx)
+
+		// call our fn function with the collected values.
+		var result interface{}
+		var callErr error
+		call := func() {
+			switch {
+			case static:
+				result, callErr = b.options.static(ctx, raw)
 				return
+			case passRequest:
+				callValues[0] = reflect.ValueOf(request)
+			case passContext:
+				callValues[0] = reflect.ValueOf(ctx)
 			}
+			res := fnValue.Call(callValues)
 
-			// if our target argument of the fn function is a struct and
-			// the argument on the javascript side was a object the decoded
-			// argument will always be the type map[string]interface{}.
-			//
-			// we can dynamically create the struct we want and decode the
-			// map[string]interface{} to the struct with the help of the
-			// mapstructure package.
-			//
-			// same works with converting a javascript array to a golang
-			// slice.
-			if fnType.In(i+argOffset).Kind() == reflect.Struct && argType.Kind() == reflect.Map || fnType.In(i+argOffset).Kind() == reflect.Slice && argType.Kind() == reflect.Slice {
-				s := reflect.New(fnType.In(i + argOffset))
-
-				// Create a decoder that honors the json tags
-				decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
-					Metadata: nil,
-					TagName:  "json",
-					Result:   s.Interface(),
-				})
-
-				if err != nil {
-					http.Error(writer, fmt.Sprintf("\"error while creating decoder: %v\"", err), http.StatusBadRequest)
-				}
+			if errReturnIndex == 1 {
+				result = res[0].Interface()
+			}
+			if err, ok := res[errReturnIndex].Interface().(error); ok {
+				callErr = err
+			}
+		}
 
-				if err := decoder.Decode(args[i]); err != nil {
-					http.Error(writer, err.Error(), http.StatusBadRequest)
-					return
+		// functions calling into native code get their panics
+		// and memory faults turned into a error response.
+		if b.options.native {
+			call = panicOnFault(call)
+		}
+
+		// the CPU time is measured on the thread running the call.
+		if record, ok := request.Context().Value(callRecordKey{}).(*callRecord); ok {
+			call = record.measureCPU(call, b.options.lockOSThread || b.options.threadPool > 0)
+		}
+
+		// with a memory budget the call is aborted as soon as
+		// it allocated more than the budget.
+		var failure *Error
+		run := func() { exec(call) }
+		if b.options.memoryBudget > 0 {
+			run = func() {
+				if !runWithMemoryBudget(b.options.memoryBudget, func() { exec(call) }) {
+					failure = &Error{Message: "call exceeded the memory budget", Type: ErrorTypeLimit}
 				}
+			}
+		}
 
-				callValues = append(callValues, s.Elem())
-				continue
-			}
-
-			// check if the argument types mismatch.
-			if fnType.In(i+argOffset).Kind() != argType.Kind() {
-				// numbers that are generically decoded from JSON will
-				// always be float64. In case fn wants some other number
-				// type we can just convert it to the target type.
-				if argType.Kind() == reflect.Float64 {
-					switch fnType.In(i + argOffset).Kind() {
-					case reflect.Int:
-						fallthrough
-					case reflect.Int8:
-						fallthrough
-					case reflect.Int16:
-						fallthrough
-					case reflect.Int32:
-						fallthrough
-					case reflect.Int64:
-						fallthrough
-					case reflect.Uint8:
-						fallthrough
-					case reflect.Uint16:
-						fallthrough
-					case reflect.Uint32:
-						fallthrough
-					case reflect.Uint64:
-						fallthrough
-					case reflect.Float32:
-						callValues = append(callValues, reflect.ValueOf(args[i]).Convert(fnType.In(i+argOffset)))
-						continue
-					}
+		// the call is only done when all goroutines it started are.
+		var groupErr error
+		execute := func() {
+			defer limit.release()
+
+			if b.options.native {
+				if err := recoverCall(run); err != nil {
+					failure = err
 				}
+			} else {
+				run()
+			}
 
-				// otherwise we return a error as no conversion was applicable.
-				http.Error(writer, fmt.Sprintf("\"mismatching argument type of %d. argument. got=%s expected=%s\"", i+1, argType.Kind().String(), fnType.In(i+argOffset).Kind().String()), http.StatusBadRequest)
-				return
+			// goroutines of a aborted call are cancelled,
+			// otherwise the call would wait for them forever.
+			if failure != nil {
+				group.cancel()
 			}
+			groupErr = group.wait()
+		}
 
-			// otherwise the arguments have the same type so no conversion is needed.
-			callValues = append(callValues, reflect.ValueOf(args[i]))
+		// with a concurrency limit the call waits for a free slot, which
+		// is only given back once the call is done. A call that ignores
+		// its timeout keeps its slot.
+		if !limit.acquire(request.Context()) {
+			writer.Header().Set("Retry-After", "1")
+			writeError(writer, http.StatusTooManyRequests, &Error{Message: "too many concurrent calls", Type: ErrorTypeLimit})
+			return
 		}
 
-		// call our fn function with the collected values.
-		var res []reflect.Value
-		if passRequest {
-			res = fnValue.Call(append([]reflect.Value{reflect.ValueOf(request)}, callValues...))
+		// with a timeout the response doesn't wait for
+		// functions that ignore their context.
+		if b.options.timeout > 0 {
+			if !runWithTimeout(b.options.timeout, execute) {
+				writeError(writer, http.StatusGatewayTimeout, timeoutError(b.options.timeout))
+				return
+			}
 		} else {
-			res = fnValue.Call(callValues)
+			execute()
+		}
+
+		// the session may have to set its cookie,
+		// so it is saved before anything is written.
+		if err := commitSession(writer, request); err != nil {
+			writeError(writer, http.StatusInternalServerError, &Error{Message: "can't save session: " + err.Error(), Type: ErrorTypeInternal})
+			return
+		}
+
+		if failure != nil {
+			writeError(writer, http.StatusInternalServerError, failure)
+			return
+		}
+
+		// functions that gave up because of the
+		// timeout are reported the same way.
+		timedOut := b.options.timeout > 0 && parent.Err() == context.DeadlineExceeded
+		if timedOut && (errors.Is(callErr, context.DeadlineExceeded) || errors.Is(groupErr, context.DeadlineExceeded)) {
+			writeError(writer, http.StatusGatewayTimeout, timeoutError(b.options.timeout))
+			return
 		}
 
 		// check if error is present and return it.
-		if res[errReturnIndex].Interface() != nil {
-			err := res[errReturnIndex].Interface().(error)
-			if err != nil {
-				http.Error(writer, fmt.Sprintf("\"%s\"", err.Error()), http.StatusBadRequest)
-				return
-			}
+		if callErr != nil {
+			e := asError(callErr)
+			writeError(writer, statusOf(e), e)
+			return
+		}
+
+		if groupErr != nil {
+			e := asError(groupErr)
+			writeError(writer, statusOf(e), e)
+			return
 		}
 
 		// if the functions has a return value besides the error
 		// JSON encode the returned value and write it to the response.
 		if errReturnIndex == 1 {
-			_ = json.NewEncoder(writer).Encode(res[0].Interface())
+			out := acceptedCodec(request.Header.Get("Accept"), c)
+			if out == protobufCodec && !isProtoMessage(b.result) {
+				out = defaultCodec()
+			}
+
+			if version, ok := versionOf(result); ok {
+				writer.Header().Set("ETag", strconv.Quote(version))
+			}
+			status := resultStatus(result)
+
+			// in process calls get the result as it is.
+			if local.isDirect() {
+				local.result = result
+				writer.WriteHeader(status)
+				return
+			}
+
+			// files are sent as they are, e.g. for downloads.
+			if f, ok := result.(*File); ok && f != nil {
+				writeFile(writer, status, f)
+				return
+			}
+
+			// a field mask strips the result down to the selected
+			// fields. Protobuf messages always have all fields.
+			if request.URL.RawQuery != "" && out != protobufCodec {
+				if mask := request.URL.Query().Get(FieldsName); mask != "" {
+					fields := parseFieldMask(mask)
+					if result, err = resolveFields(ctx, result, fields); err != nil {
+						e := asError(err)
+						writeError(writer, statusOf(e), e)
+						return
+					}
+					if result, err = selectFields(result, fields); err != nil {
+						writeError(writer, http.StatusInternalServerError, &Error{Message: "can't encode result: " + err.Error(), Type: ErrorTypeInternal})
+						return
+					}
+				}
+			}
+			writeResult(writer, status, out, result)
+		}
+	}
+
+	// the child of a subprocess function only runs the call,
+	// the middleware already ran in the parent.
+	if !b.options.subprocess {
+		b.handler = withMiddleware(b.handler, b.options.middleware)
+	}
+
+	return b, nil
+}
+
+// isInjected reports if a parameter of type t
+// is provided by the call instead of the client.
+func isInjected(t reflect.Type) bool {
+	return t == sessionType || isClaims(t)
+}
+
+// injectedValue returns the value of a injected parameter of type t.
+func injectedValue(t reflect.Type, request *http.Request) (reflect.Value, *Error) {
+	if t == sessionType {
+		s, err := SessionOf(request.Context())
+		if err != nil {
+			return reflect.Value{}, &Error{Message: "can't load session: " + err.Error(), Type: ErrorTypeInternal}
 		}
-	}, nil
+		return reflect.ValueOf(s), nil
+	}
+	return claimsValue(t, IdentityOf(request.Context()))
+}
+
+// isBytes reports if t is a byte slice.
+func isBytes(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
+
+// base64Hook is a mapstructure decode hook that decodes
+// base64 strings for byte slices inside of structs.
+func base64Hook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if from.Kind() != reflect.String || !isBytes(to) {
+		return data, nil
+	}
+	return base64.StdEncoding.DecodeString(data.(string))
+}
+
+// isNumber reports if the kind is a integer or float.
+func isNumber(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
 }
 
 // MustBind is the same as Bind but can't return a error.
 // this can be used if you want to directly pass the result
 // to http.HandleFunc.
-func MustBind(fn interface{}) http.HandlerFunc {
-	h, err := Bind(fn)
+func MustBind(fn interface{}, opts ...Option) http.HandlerFunc {
+	h, err := Bind(fn, opts...)
 	if err != nil {
 		panic("nra: bind failed with: " + err.Error())
 	}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -44,7 +46,7 @@ var tests = []testCase{
 	{
 		Name:     "only_error_fail",
 		Input:    "[1, \"test_string\", 123.2451]",
-		Expected: "\"error\"\n",
+		Expected: "{\"error\":{\"message\":\"error\",\"type\":\"application\"}}\n",
 		Code:     http.StatusBadRequest,
 		Function: func(a int, b string, c float64) error {
 			return fmt.Errorf("error")
@@ -88,21 +90,184 @@ var tests = []testCase{
 	{
 		Name:     "not_nilable",
 		Input:    "[null, null, null]",
-		Expected: "\"1. can't be null\"\n",
+		Expected: "{\"error\":{\"message\":\"1. can't be null\",\"type\":\"validation\",\"argument\":1}}\n",
 		Code:     http.StatusBadRequest,
 		Function: func(a int, b string, c float64) (interface{}, error) {
 			return nil, nil
 		},
 	},
+	{
+		Name:     "niladic_empty_body",
+		Input:    "",
+		Expected: "\"ok\"\n",
+		Code:     http.StatusOK,
+		Function: func() (string, error) {
+			return "ok", nil
+		},
+	},
+	{
+		Name:     "niladic_null",
+		Input:    "null",
+		Expected: "\"ok\"\n",
+		Code:     http.StatusOK,
+		Function: func() (string, error) {
+			return "ok", nil
+		},
+	},
+	{
+		Name:     "niladic_empty_array",
+		Input:    "[]",
+		Expected: "\"ok\"\n",
+		Code:     http.StatusOK,
+		Function: func(r *http.Request) (string, error) {
+			return "ok", nil
+		},
+	},
+	{
+		Name:     "empty_body_with_arguments",
+		Input:    "",
+		Expected: "{\"error\":{\"message\":\"number of arguments mismatch. got=0 expected=1\",\"type\":\"validation\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a int) (string, error) {
+			return "ok", nil
+		},
+	},
 	{
 		Name:     "wrong_type",
 		Input:    "[{\"a\":1233,\"b\":{\"c\":\"hello\"}}]",
-		Expected: "\"mismatching argument type of 1. argument. got=map expected=int\"\n",
+		Expected: "{\"error\":{\"message\":\"mismatching argument type of 1. argument. got=object expected=int\",\"type\":\"conversion\",\"argument\":1,\"received\":\"{\\\"a\\\":1233,\\\"b\\\":{\\\"c\\\":\\\"hello\\\"}}\",\"expected\":\"int\",\"hint\":\"pass a JSON number, it will be converted to int\"}}\n",
 		Code:     http.StatusBadRequest,
 		Function: func(a int) (interface{}, error) {
 			return nil, nil
 		},
 	},
+	{
+		Name:     "custom_error_type",
+		Input:    "[1]",
+		Expected: "{\"error\":{\"message\":\"a must be positive\",\"type\":\"validation\",\"argument\":1}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a int) error {
+			return fmt.Errorf("wrapped: %w", &Error{Message: "a must be positive", Type: ErrorTypeValidation, Argument: 1})
+		},
+	},
+	{
+		Name:     "variadic",
+		Input:    "[\"-\", \"a\", \"b\", \"c\"]",
+		Expected: "\"a-b-c\"\n",
+		Code:     http.StatusOK,
+		Function: func(sep string, items ...string) (string, error) {
+			return strings.Join(items, sep), nil
+		},
+	},
+	{
+		Name:     "variadic_omitted",
+		Input:    "[\"-\"]",
+		Expected: "0\n",
+		Code:     http.StatusOK,
+		Function: func(sep string, items ...int) (int, error) {
+			return len(items), nil
+		},
+	},
+	{
+		Name:     "variadic_wrong_type",
+		Input:    "[1, \"b\"]",
+		Expected: "{\"error\":{\"message\":\"mismatching argument type of 2. argument. got=string expected=int\",\"type\":\"conversion\",\"argument\":2,\"received\":\"\\\"b\\\"\",\"expected\":\"int\",\"hint\":\"pass a JSON number, it will be converted to int\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(items ...int) error {
+			return nil
+		},
+	},
+	{
+		Name:     "variadic_too_few",
+		Input:    "[]",
+		Expected: "{\"error\":{\"message\":\"number of arguments mismatch. got=0 expected=at least 1\",\"type\":\"validation\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(sep string, items ...string) error {
+			return nil
+		},
+	},
+	{
+		Name:     "optional_omitted",
+		Input:    "[2]",
+		Expected: "2\n",
+		Code:     http.StatusOK,
+		Function: func(a int, b *int) (int, error) {
+			if b != nil {
+				return a * *b, nil
+			}
+			return a, nil
+		},
+	},
+	{
+		Name:     "optional_passed",
+		Input:    "[2, 3]",
+		Expected: "6\n",
+		Code:     http.StatusOK,
+		Function: func(a int, b *int) (int, error) {
+			if b != nil {
+				return a * *b, nil
+			}
+			return a, nil
+		},
+	},
+	{
+		Name:     "optional_too_many",
+		Input:    "[1, 2, 3]",
+		Expected: "{\"error\":{\"message\":\"number of arguments mismatch. got=3 expected=1 to 2\",\"type\":\"validation\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a int, b *int) error {
+			return nil
+		},
+	},
+	{
+		Name:     "bytes",
+		Input:    "[\"aGVsbG8=\", {\"data\": \"d29ybGQ=\"}]",
+		Expected: "\"aGVsbG8gd29ybGQ=\"\n",
+		Code:     http.StatusOK,
+		Function: func(a []byte, b struct {
+			Data []byte `json:"data"`
+		}) ([]byte, error) {
+			return append(append(a, ' '), b.Data...), nil
+		},
+	},
+	{
+		Name:     "bytes_invalid",
+		Input:    "[\"not base64\"]",
+		Expected: "{\"error\":{\"message\":\"can't convert 1. argument to []uint8: illegal base64 data at input byte 3\",\"type\":\"conversion\",\"argument\":1,\"received\":\"\\\"not base64\\\"\",\"expected\":\"[]uint8\",\"hint\":\"pass a base64 encoded JSON string\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a []byte) error {
+			return nil
+		},
+	},
+	{
+		Name:     "wrong_type_bool",
+		Input:    "[\"true\"]",
+		Expected: "{\"error\":{\"message\":\"mismatching argument type of 1. argument. got=string expected=bool\",\"type\":\"conversion\",\"argument\":1,\"received\":\"\\\"true\\\"\",\"expected\":\"bool\",\"hint\":\"pass true or false, strings and numbers are not converted to booleans\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a bool) error {
+			return nil
+		},
+	},
+	{
+		Name:     "typed_map",
+		Input:    "[{\"a\":{\"limit\":2}},{\"b\":[1,2]},null]",
+		Expected: "\"2 [1 2] true\"\n",
+		Code:     http.StatusOK,
+		Function: func(a map[string]struct {
+			Limit int `json:"limit"`
+		}, b map[string][]int, c map[string]int) (string, error) {
+			return fmt.Sprint(a["a"].Limit, b["b"], c == nil), nil
+		},
+	},
+	{
+		Name:     "typed_map_invalid",
+		Input:    "[{\"a\":\"b\"}]",
+		Expected: "{\"error\":{\"message\":\"can't convert 1. argument to map[string][]int: 1 error(s) decoding:\\n\\n* '[a]': source data must be an array or slice, got string\",\"type\":\"conversion\",\"argument\":1,\"received\":\"{\\\"a\\\":\\\"b\\\"}\",\"expected\":\"map[string][]int\",\"hint\":\"pass a JSON object of []int\"}}\n",
+		Code:     http.StatusBadRequest,
+		Function: func(a map[string][]int) error {
+			return nil
+		},
+	},
 }
 
 func TestBind(t *testing.T) {
@@ -128,3 +293,51 @@ func TestBind(t *testing.T) {
 		})
 	}
 }
+
+func TestTruncatedJSON(t *testing.T) {
+	assert.Equal(t, "\"short\"", truncatedJSON("short"))
+	assert.Equal(t, maxReceivedLength+3, len(truncatedJSON(strings.Repeat("a", 100))))
+
+	// multi byte characters aren't split.
+	truncated := truncatedJSON(strings.Repeat("ä", 100))
+	assert.True(t, utf8.ValidString(truncated), truncated)
+	assert.Equal(t, "\""+strings.Repeat("ä", (maxReceivedLength-1)/2)+"...", truncated)
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	join := func(items []string) (int, error) {
+		return len(items), nil
+	}
+
+	large := "[[" + strings.Repeat(`"abcdefgh",`, 100) + `"x"]]`
+
+	r := NewRegistry()
+	r.MustRegister("join", join, WithMaxBodyBytes(64))
+	r.MustRegister("join_all", join)
+	r.SetMaxBodyBytes(512)
+
+	tests := []struct {
+		Name  string
+		Path  string
+		Input string
+		Code  int
+	}{
+		{Name: "small", Path: "/rpc/join", Input: `[["a", "b"]]`, Code: http.StatusOK},
+		{Name: "binding_limit", Path: "/rpc/join", Input: `[["` + strings.Repeat("a", 100) + `"]]`, Code: http.StatusRequestEntityTooLarge},
+		{Name: "registry_small", Path: "/rpc/join_all", Input: `[["` + strings.Repeat("a", 100) + `"]]`, Code: http.StatusOK},
+		{Name: "registry_limit", Path: "/rpc/join_all", Input: large, Code: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, httptest.NewRequest("POST", test.Path, strings.NewReader(test.Input)))
+
+			assert.Equal(t, test.Code, rr.Code)
+			if test.Code == http.StatusRequestEntityTooLarge {
+				assert.Contains(t, rr.Body.String(), `"type":"limit"`)
+				assert.Contains(t, rr.Body.String(), "request body exceeds the limit of")
+			}
+		})
+	}
+}
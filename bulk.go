@@ -0,0 +1,99 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// BulkItem is the outcome of a single item of a bulk call. Either
+// Result or Error is set.
+type BulkItem[R any] struct {
+	// Index is the 0-based index of the item in the passed slice.
+	Index  int    `json:"index"`
+	Result R      `json:"result,omitempty"`
+	Error  *Error `json:"error,omitempty"`
+}
+
+// BulkResult is returned by functions created with Bulk. It is sent
+// with status 200 if all items succeeded and with 207 (Multi-Status)
+// if some of them failed.
+type BulkResult[R any] struct {
+	Items     []BulkItem[R] `json:"items"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+}
+
+// bulkResult is implemented by all BulkResult types.
+type bulkResult interface {
+	bulkStatus() int
+}
+
+var bulkResultType = reflect.TypeOf((*bulkResult)(nil)).Elem()
+
+// bulkStatus implements bulkResult.
+func (r BulkResult[R]) bulkStatus() int {
+	if r.Failed > 0 {
+		return http.StatusMultiStatus
+	}
+	return http.StatusOK
+}
+
+// Bulk turns a function processing a single item into one processing
+// a slice of items. Every item is processed on its own, so a failing
+// item doesn't fail the whole call. Instead its error is reported at
+// its index:
+//
+//	r.MustRegister("users.import", nra.Bulk(func(ctx context.Context, u User) (int, error) {
+//		return db.Insert(ctx, u)
+//	}))
+//
+// The items are processed in order. Once the context of the call is
+// done the remaining items fail with its error.
+func Bulk[T, R any](fn func(ctx context.Context, item T) (R, error)) func(ctx context.Context, items []T) (*BulkResult[R], error) {
+	return func(ctx context.Context, items []T) (*BulkResult[R], error) {
+		res := &BulkResult[R]{Items: make([]BulkItem[R], len(items))}
+		for i, item := range items {
+			res.Items[i].Index = i
+
+			err := ctx.Err()
+			if err == nil {
+				res.Items[i].Result, err = fn(ctx, item)
+			}
+
+			if err != nil {
+				res.Items[i].Error = asError(err)
+				res.Failed++
+				continue
+			}
+			res.Succeeded++
+		}
+		return res, nil
+	}
+}
+
+// isBulk reports if t is a BulkResult or a pointer to one.
+func isBulk(t reflect.Type) bool {
+	return t != nil && t.Implements(bulkResultType)
+}
+
+// bulkItemResult returns the type R of the BulkResult t.
+func bulkItemResult(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	items, _ := t.FieldByName("Items")
+	result, _ := items.Type.Elem().FieldByName("Result")
+	return result.Type
+}
+
+// resultStatus returns the status code a successful call
+// with the result is answered with.
+func resultStatus(result interface{}) int {
+	if r, ok := result.(bulkResult); ok {
+		if rv := reflect.ValueOf(r); rv.Kind() != reflect.Ptr || !rv.IsNil() {
+			return r.bulkStatus()
+		}
+	}
+	return http.StatusOK
+}
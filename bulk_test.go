@@ -0,0 +1,71 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bulkDouble(ctx context.Context, n int) (int, error) {
+	if n < 0 {
+		return 0, errors.New("negative number")
+	}
+	return n * 2, nil
+}
+
+func TestBulk(t *testing.T) {
+	h := MustBind(Bulk(bulkDouble))
+
+	tests := []struct {
+		Name     string
+		Input    string
+		Code     int
+		Expected string
+	}{
+		{Name: "empty", Input: "[[]]", Code: http.StatusOK, Expected: `{"items":[],"succeeded":0,"failed":0}` + "\n"},
+		{Name: "succeeded", Input: "[[1, 2]]", Code: http.StatusOK, Expected: `{"items":[{"index":0,"result":2},{"index":1,"result":4}],"succeeded":2,"failed":0}` + "\n"},
+		{Name: "partial", Input: "[[1, -1, 3]]", Code: http.StatusMultiStatus, Expected: `{"items":[{"index":0,"result":2},{"index":1,"error":{"message":"negative number","type":"application"}},{"index":2,"result":6}],"succeeded":2,"failed":1}` + "\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("POST", "/", strings.NewReader(test.Input)))
+
+			assert.Equal(t, test.Code, rr.Code)
+			assert.Equal(t, test.Expected, rr.Body.String())
+		})
+	}
+}
+
+func TestBulkCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	res, err := Bulk(bulkDouble)(ctx, []int{1, 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, res.Failed)
+	assert.Equal(t, context.Canceled.Error(), res.Items[1].Error.Message)
+}
+
+func TestBulkClients(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("double", Bulk(bulkDouble))
+
+	assert.True(t, r.Methods()[0].Bulk)
+
+	var ts bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&ts, r))
+	assert.Contains(t, ts.String(), "export interface BulkResult<T> {")
+	assert.Contains(t, ts.String(), "export function double(arg1: Array<number>): Promise<BulkResult<number> | null> {")
+
+	doc := openAPIOf(r, OpenAPIInfo{Title: "test", Version: "1"}, r.Prefix())
+	_, ok := doc.Paths["/rpc/double"].Post.Responses["207"]
+	assert.True(t, ok)
+}
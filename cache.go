@@ -0,0 +1,285 @@
+package nra
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is how many results of a
+// function are cached at most.
+const DefaultCacheSize = 1024
+
+// WithCache caches the results of the function for ttl. Calls with
+// the same arguments by the same identity, see IdentityOf, get the
+// cached result without calling the function again. All results get
+// a ETag, so clients sending it back in If-None-Match get status 304
+// without a body if the result didn't change. Only successful results
+// are cached, and only functions whose result depends on nothing but
+// their arguments and the caller should be cached. Functions taking a
+// *Session are cached per session, calls without a stored session
+// aren't cached. See also Registry.PurgeCache.
+func WithCache(ttl time.Duration) Option {
+	return func(o *options) {
+		o.cacheTTL = ttl
+	}
+}
+
+// resultCache keeps the results of a function by the hash
+// of the request they were the response to.
+type resultCache struct {
+	ttl time.Duration
+	now func() time.Time
+
+	// session is set if the function takes the session,
+	// so its results are cached per session.
+	session bool
+
+	mtx     sync.Mutex
+	entries map[string]cachedResult
+}
+
+// cachedResult is a result kept by a resultCache.
+type cachedResult struct {
+	contentType string
+	etag        string
+	body        []byte
+	expires     time.Time
+}
+
+// newResultCache creates the cache for the
+// options, or nil if they don't ask for one.
+func newResultCache(o *options) *resultCache {
+	if o.cacheTTL <= 0 {
+		return nil
+	}
+	return &resultCache{ttl: o.cacheTTL, now: time.Now, entries: map[string]cachedResult{}}
+}
+
+// key returns the key of the request. It covers everything the
+// response depends on besides the function itself. It returns false
+// if the result can't be cached, because the function takes the
+// session and the request has none.
+func (c *resultCache) key(request *http.Request, body []byte) (string, bool) {
+	identity, _ := json.Marshal(IdentityOf(request.Context()))
+
+	var session string
+	if c.session {
+		s, err := SessionOf(request.Context())
+		if err != nil || s.ID() == "" {
+			return "", false
+		}
+		session = s.ID()
+	}
+
+	h := sha256.New()
+	for _, part := range []string{request.Method, request.URL.RawQuery, request.Header.Get("Content-Type"), request.Header.Get("Accept"), string(identity), session} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil)), true
+}
+
+// get returns the cached result of the key if it didn't expire.
+func (c *resultCache) get(key string) (cachedResult, bool) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok || !c.now().Before(e.expires) {
+		return cachedResult{}, false
+	}
+	return e, true
+}
+
+// put caches the result. If the cache is full the expired
+// entries are removed, or any one if none has expired.
+func (c *resultCache) put(key string, e cachedResult) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= DefaultCacheSize {
+		now := c.now()
+		for k, old := range c.entries {
+			if !now.Before(old.expires) {
+				delete(c.entries, k)
+			}
+		}
+		for k := range c.entries {
+			if len(c.entries) < DefaultCacheSize {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = e
+}
+
+// purge removes all results.
+func (c *resultCache) purge() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.entries = map[string]cachedResult{}
+}
+
+// serve answers the request from the cache. Otherwise it returns the
+// writer the call has to write its response to and a function that
+// has to be called once it did, which caches the result and sends it.
+func (c *resultCache) serve(writer http.ResponseWriter, request *http.Request) (bool, http.ResponseWriter, func()) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+			return true, nil, nil
+		}
+		writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+		return true, nil, nil
+	}
+	_ = request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	key, ok := c.key(request, body)
+	if !ok {
+		return false, writer, func() {}
+	}
+	if e, ok := c.get(key); ok {
+		c.write(writer, request, e)
+		return true, nil, nil
+	}
+
+	w := &bufferWriter{ResponseWriter: writer}
+	return false, w, func() {
+		if w.statusCode() != http.StatusOK {
+			w.flush()
+			return
+		}
+
+		// results of Versioned entities already have their version as ETag.
+		etag := writer.Header().Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(w.body.Bytes())
+			etag = strconv.Quote(hex.EncodeToString(sum[:16]))
+		}
+
+		e := cachedResult{
+			contentType: writer.Header().Get("Content-Type"),
+			etag:        etag,
+			body:        append([]byte(nil), w.body.Bytes()...),
+			expires:     c.now().Add(c.ttl),
+		}
+		c.put(key, e)
+		c.write(writer, request, e)
+	}
+}
+
+// write sends the cached result, or status 304 if
+// the client already has it.
+func (c *resultCache) write(writer http.ResponseWriter, request *http.Request, e cachedResult) {
+	maxAge := int(math.Ceil(e.expires.Sub(c.now()).Seconds()))
+	writer.Header().Set("ETag", e.etag)
+	writer.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(maxAge))
+
+	if matchesETag(request.Header.Get("If-None-Match"), e.etag) {
+		writer.Header().Del("Content-Type")
+		writer.Header().Del("Content-Length")
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	writer.Header().Set("Content-Type", e.contentType)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(e.body)))
+	writer.WriteHeader(http.StatusOK)
+	_, _ = writer.Write(e.body)
+}
+
+// matchesETag reports if the If-None-Match header contains the etag.
+// Weak tags match as well, as the comparison is only about the content.
+func matchesETag(header string, etag string) bool {
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferWriter holds back the status and body written to
+// it until flush. Headers go to the writer below right away.
+type bufferWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *bufferWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (w *bufferWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// recordError passes the error on to the writer below,
+// so observers still see it.
+func (w *bufferWriter) recordError(err *Error) {
+	if r, ok := w.ResponseWriter.(errorRecorder); ok {
+		r.recordError(err)
+	}
+}
+
+// statusCode returns the status code of the response.
+func (w *bufferWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// flush sends the response as it was written.
+func (w *bufferWriter) flush() {
+	if w.status == 0 && w.body.Len() == 0 {
+		return
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode())
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
+
+// PurgeCache removes the cached results of all versions
+// of the function, e.g. after the data they came from
+// changed. See WithCache.
+func (r *Registry) PurgeCache(name string) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	if b, ok := r.functions[name]; ok && b.cache != nil {
+		b.cache.purge()
+	}
+	for _, b := range r.versions[name] {
+		if b.cache != nil {
+			b.cache.purge()
+		}
+	}
+}
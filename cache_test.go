@@ -0,0 +1,155 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBindCache(t *testing.T) {
+	calls := 0
+	h := MustBind(func(a, b int) (int, error) {
+		calls++
+		if a < 0 {
+			return 0, errors.New("negative")
+		}
+		return a + b, nil
+	}, WithCache(time.Minute))
+
+	call := func(body string, etag string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+		if etag != "" {
+			req.Header.Set("If-None-Match", etag)
+		}
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	rr := call("[1, 2]", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3\n", rr.Body.String())
+	assert.Equal(t, "private, max-age=60", rr.Header().Get("Cache-Control"))
+	etag := rr.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	rr = call("[1, 2]", "")
+	assert.Equal(t, "3\n", rr.Body.String())
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, etag, rr.Header().Get("ETag"))
+	assert.Equal(t, 1, calls)
+
+	rr = call("[1, 2]", "W/"+etag)
+	assert.Equal(t, http.StatusNotModified, rr.Code)
+	assert.Empty(t, rr.Body.String())
+	assert.Equal(t, 1, calls)
+
+	// other arguments are other results.
+	rr = call("[2, 2]", etag)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "4\n", rr.Body.String())
+	assert.NotEqual(t, etag, rr.Header().Get("ETag"))
+	assert.Equal(t, 2, calls)
+
+	// errors aren't cached.
+	assert.Equal(t, http.StatusBadRequest, call("[-1, 2]", "").Code)
+	assert.Equal(t, http.StatusBadRequest, call("[-1, 2]", "").Code)
+	assert.Equal(t, 4, calls)
+}
+
+func TestRegistryCacheExpiry(t *testing.T) {
+	calls := 0
+	r := NewRegistry()
+	r.SetAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		return request.Header.Get("X-User"), nil
+	}))
+	r.MustRegister("me", func(ctx context.Context) (interface{}, error) {
+		calls++
+		return IdentityOf(ctx), nil
+	}, WithCache(time.Minute))
+
+	now := time.Now()
+	b, _ := r.function("me")
+	b.cache.now = func() time.Time { return now }
+
+	call := func(user string) string {
+		req := httptest.NewRequest("POST", "/rpc/me", nil)
+		req.Header.Set("X-User", user)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr.Body.String()
+	}
+
+	// every identity has its own results.
+	assert.Equal(t, "\"alice\"\n", call("alice"))
+	assert.Equal(t, "\"bob\"\n", call("bob"))
+	assert.Equal(t, "\"alice\"\n", call("alice"))
+	assert.Equal(t, 2, calls)
+
+	now = now.Add(time.Minute)
+	call("alice")
+	assert.Equal(t, 3, calls)
+
+	r.PurgeCache("me")
+	call("alice")
+	assert.Equal(t, 4, calls)
+}
+
+func TestMatchesETag(t *testing.T) {
+	assert.True(t, matchesETag(`"a", "b"`, `"b"`))
+	assert.True(t, matchesETag(`W/"b"`, `"b"`))
+	assert.True(t, matchesETag(`*`, `"b"`))
+	assert.False(t, matchesETag(`"a"`, `"b"`))
+	assert.False(t, matchesETag(``, `"b"`))
+}
+
+func TestCacheSession(t *testing.T) {
+	calls := 0
+
+	r := NewRegistry()
+	r.EnableSessions(Sessions{Store: NewMemoryStore(), TTL: time.Hour})
+	r.MustRegister("login", func(s *Session, name string) error {
+		return s.Set("user", name)
+	})
+	r.MustRegister("whoami", func(s *Session) (string, error) {
+		calls++
+		var name string
+		s.Get("user", &name)
+		return name, nil
+	}, WithCache(time.Minute))
+
+	call := func(cookie *http.Cookie, name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+	login := func(name string) *http.Cookie {
+		cookies := call(nil, "login", `["`+name+`"]`).Result().Cookies()
+		if !assert.Len(t, cookies, 1) {
+			t.FailNow()
+		}
+		return cookies[0]
+	}
+	alice, bob := login("alice"), login("bob")
+
+	// results are cached per session.
+	assert.Equal(t, "\"alice\"\n", call(alice, "whoami", "").Body.String())
+	assert.Equal(t, "\"bob\"\n", call(bob, "whoami", "").Body.String())
+	assert.Equal(t, "\"alice\"\n", call(alice, "whoami", "").Body.String())
+	assert.Equal(t, 2, calls)
+
+	// calls without a session aren't cached.
+	assert.Equal(t, "\"\"\n", call(nil, "whoami", "").Body.String())
+	assert.Equal(t, "\"\"\n", call(nil, "whoami", "").Body.String())
+	assert.Equal(t, 4, calls)
+}
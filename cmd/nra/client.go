@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+
+	"github.com/BigJk/nra"
+)
+
+// runClient generates clients from a schema file written by
+// nra.Registry.ExportSchema, without the Go code of the backend:
+//
+//	nra client [-js client.js] [-ts client.ts] [-forms] <schema>
+//
+// -js writes the Javascript client, -ts the TypeScript client and
+// -forms adds the form descriptors to it, see nra.WithTypeScriptForms.
+// At least one of -js and -ts has to be given.
+func runClient(args []string) error {
+	flags := flag.NewFlagSet("client", flag.ContinueOnError)
+	js := flags.String("js", "", "file to write the Javascript client to")
+	ts := flags.String("ts", "", "file to write the TypeScript client to")
+	forms := flags.Bool("forms", false, "add form descriptors to the TypeScript client")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 || (*js == "" && *ts == "") {
+		return errors.New("usage: nra client [-js client.js] [-ts client.ts] [-forms] <schema>")
+	}
+
+	r, err := nra.ImportSchema(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if *js != "" {
+		var buf bytes.Buffer
+		if err := nra.GenerateJavaScript(&buf, r); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*js, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	if *ts != "" {
+		var opts []nra.TypeScriptOption
+		if *forms {
+			opts = append(opts, nra.WithTypeScriptForms())
+		}
+
+		var buf bytes.Buffer
+		if err := nra.GenerateTypeScript(&buf, r, opts...); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(*ts, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
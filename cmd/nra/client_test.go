@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BigJk/nra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	r := nra.NewRegistry()
+	r.MustRegister("move", func(p Point) (Point, error) { return p, nil })
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil }, nra.WithSafe())
+
+	dir := t.TempDir()
+	schema := filepath.Join(dir, "schema.json")
+	if !assert.NoError(t, r.ExportSchema(schema)) {
+		return
+	}
+
+	js, ts := filepath.Join(dir, "client.js"), filepath.Join(dir, "client.ts")
+	if !assert.NoError(t, runClient([]string{"-js", js, "-ts", ts, "-forms", schema})) {
+		return
+	}
+
+	data, err := ioutil.ReadFile(js)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `rpc["move"] = bind("move", false);`)
+
+	data, err = ioutil.ReadFile(ts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), "export interface Point {\n  x: number;\n}")
+	assert.Contains(t, string(data), "export function move(arg1: Point): Promise<Point> {")
+	assert.Contains(t, string(data), "export const forms")
+
+	assert.Error(t, runClient([]string{schema}))
+	assert.Error(t, runClient([]string{"-ts", ts}))
+	assert.Error(t, runClient([]string{"-ts", ts, filepath.Join(dir, "missing.json")}))
+}
@@ -0,0 +1,327 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// compatibility collects the differences between two versions
+// of a function, split into breaking and compatible ones. A change
+// is breaking if a client built against the old version can fail
+// with the new one.
+type compatibility struct {
+	breaking   []string
+	compatible []string
+}
+
+// report adds a difference found at where.
+func (c *compatibility) report(breaking bool, where string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if where != "" {
+		message = where + ": " + message
+	}
+
+	if breaking {
+		c.breaking = append(c.breaking, message)
+	} else {
+		c.compatible = append(c.compatible, message)
+	}
+}
+
+// compareFunctions compares the path items of a function
+// whose references to component schemas were inlined.
+func (c *compatibility) compareFunctions(old, changed map[string]interface{}) {
+	switch {
+	case old["get"] != nil && changed["get"] == nil:
+		c.report(true, "", "can't be called via GET anymore")
+	case old["get"] == nil && changed["get"] != nil:
+		c.report(false, "", "can be called via GET")
+	}
+
+	c.compareArguments(argumentsOf(old), argumentsOf(changed))
+
+	oldResult, newResult := resultOf(old), resultOf(changed)
+	switch {
+	case oldResult != nil && newResult == nil:
+		c.report(true, "", "doesn't return a result anymore")
+	case oldResult == nil && newResult != nil:
+		c.report(false, "", "returns a result")
+	case oldResult != nil:
+		c.compareSchemas("result", oldResult, newResult, false)
+	}
+
+	// everything else, e.g. descriptions, can't break clients.
+	if len(c.breaking) == 0 && len(c.compatible) == 0 {
+		c.report(false, "", "changed")
+	}
+}
+
+// argumentsOf returns the schema of the arguments array of a function.
+func argumentsOf(item map[string]interface{}) map[string]interface{} {
+	return lookup(item, "post", "requestBody", "content", "application/json", "schema")
+}
+
+// resultOf returns the schema of the result of a function, or
+// nil if the function only returns a error.
+func resultOf(item map[string]interface{}) map[string]interface{} {
+	return lookup(item, "post", "responses", "200", "content", "application/json", "schema")
+}
+
+// lookup follows the keys through nested objects.
+func lookup(v map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		next, ok := v[key].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = next
+	}
+	return v
+}
+
+// compareArguments compares the arguments arrays of a function.
+func (c *compatibility) compareArguments(old, changed map[string]interface{}) {
+	oldMin, newMin := intOf(old["minItems"]), intOf(changed["minItems"])
+	oldMax, oldBounded := old["maxItems"]
+	newMax, newBounded := changed["maxItems"]
+
+	switch {
+	case newMin > oldMin:
+		c.report(true, "", "requires %d arguments instead of %d", newMin, oldMin)
+	case newMin < oldMin:
+		c.report(false, "", "requires %d arguments instead of %d", newMin, oldMin)
+	}
+
+	switch {
+	case newBounded && (!oldBounded || intOf(newMax) < intOf(oldMax)):
+		c.report(true, "", "accepts at most %d arguments", intOf(newMax))
+	case oldBounded && (!newBounded || intOf(newMax) > intOf(oldMax)):
+		c.report(false, "", "accepts more arguments")
+	}
+
+	oldItems, _ := old["prefixItems"].([]interface{})
+	newItems, _ := changed["prefixItems"].([]interface{})
+	for i := 0; i < len(oldItems) && i < len(newItems); i++ {
+		c.compareSchemas(fmt.Sprintf("argument %d", i+1), oldItems[i], newItems[i], true)
+	}
+
+	// variadic arguments.
+	oldRest, newRest := old["items"], changed["items"]
+	if oldRest != nil && newRest != nil {
+		c.compareSchemas(fmt.Sprintf("argument %d...", len(oldItems)+1), oldRest, newRest, true)
+	}
+}
+
+// compareSchemas compares two schemas of a value at where. Values
+// sent by the client (input) may accept more than before, values
+// returned to it may only contain less.
+func (c *compatibility) compareSchemas(where string, old, changed interface{}, input bool) {
+	if reflect.DeepEqual(old, changed) {
+		return
+	}
+
+	o, oldNull := withoutNull(old)
+	n, newNull := withoutNull(changed)
+
+	switch {
+	case input && oldNull && !newNull:
+		c.report(true, where, "doesn't accept null anymore")
+	case !input && newNull && !oldNull:
+		c.report(true, where, "can be null")
+	case oldNull != newNull:
+		c.report(false, where, "nullability changed")
+	}
+
+	oldTypes, newTypes := typesOf(o), typesOf(n)
+	widened := len(newTypes) == 0 || (len(oldTypes) > 0 && subset(oldTypes, newTypes))
+	narrowed := len(oldTypes) == 0 || (len(newTypes) > 0 && subset(newTypes, oldTypes))
+	if !reflect.DeepEqual(oldTypes, newTypes) {
+		if (input && !widened) || (!input && !narrowed) {
+			c.report(true, where, "type changed from %s to %s", typeName(oldTypes), typeName(newTypes))
+			return
+		}
+		c.report(false, where, "type changed from %s to %s", typeName(oldTypes), typeName(newTypes))
+	}
+
+	oldFormat, _ := o["format"].(string)
+	newFormat, _ := n["format"].(string)
+	if oldFormat != newFormat {
+		// wider numbers can still hold everything the old ones could.
+		wider := widerFormats[oldFormat] == newFormat
+		narrower := widerFormats[newFormat] == oldFormat
+		c.report((input && !wider) || (!input && !narrower), where, "format changed from %q to %q", oldFormat, newFormat)
+	}
+
+	if !reflect.DeepEqual(o["default"], n["default"]) {
+		c.report(false, where, "default changed")
+	}
+
+	if !reflect.DeepEqual(o["minItems"], n["minItems"]) || !reflect.DeepEqual(o["maxItems"], n["maxItems"]) {
+		c.report(true, where, "length changed")
+	}
+
+	if o["items"] != nil && n["items"] != nil {
+		c.compareSchemas(where+"[]", o["items"], n["items"], input)
+	}
+	if o["additionalProperties"] != nil && n["additionalProperties"] != nil {
+		c.compareSchemas(where+"{}", o["additionalProperties"], n["additionalProperties"], input)
+	}
+
+	c.compareProperties(where, o, n, input)
+}
+
+// compareProperties compares the fields of two object schemas.
+func (c *compatibility) compareProperties(where string, old, changed map[string]interface{}, input bool) {
+	oldProps, _ := old["properties"].(map[string]interface{})
+	newProps, _ := changed["properties"].(map[string]interface{})
+	oldRequired, newRequired := requiredOf(old), requiredOf(changed)
+
+	for _, name := range sortedKeys(oldProps) {
+		field := where + "." + name
+		if _, ok := newProps[name]; !ok {
+			// clients can still send it, it is just ignored.
+			c.report(!input, where, "field %q was removed", name)
+			continue
+		}
+
+		switch {
+		case input && newRequired[name] && !oldRequired[name]:
+			c.report(true, where, "field %q is required", name)
+		case !input && oldRequired[name] && !newRequired[name]:
+			c.report(true, where, "field %q can be missing", name)
+		}
+		c.compareSchemas(field, oldProps[name], newProps[name], input)
+	}
+
+	for _, name := range sortedKeys(newProps) {
+		if _, ok := oldProps[name]; ok {
+			continue
+		}
+		if input && newRequired[name] {
+			c.report(true, where, "new required field %q", name)
+		} else {
+			c.report(false, where, "new field %q", name)
+		}
+	}
+}
+
+// widerFormats maps number formats to the format that
+// can hold all of their values.
+var widerFormats = map[string]string{
+	"int32": "int64",
+	"float": "double",
+}
+
+// withoutNull returns the schema without null as allowed
+// value and reports if null was allowed.
+func withoutNull(v interface{}) (map[string]interface{}, bool) {
+	s, _ := v.(map[string]interface{})
+	if s == nil {
+		return map[string]interface{}{}, false
+	}
+
+	// nullable references are a anyOf with a null schema.
+	if anyOf, ok := s["anyOf"].([]interface{}); ok && len(anyOf) == 2 {
+		if null, ok := anyOf[1].(map[string]interface{}); ok && null["type"] == "null" {
+			inner, _ := anyOf[0].(map[string]interface{})
+			return inner, true
+		}
+	}
+
+	types, ok := s["type"].([]interface{})
+	if !ok {
+		return s, false
+	}
+
+	copied := make(map[string]interface{}, len(s))
+	for k, v := range s {
+		copied[k] = v
+	}
+
+	var rest []interface{}
+	nullable := false
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	if len(rest) == 1 {
+		copied["type"] = rest[0]
+	} else {
+		copied["type"] = rest
+	}
+	return copied, nullable
+}
+
+// typesOf returns the sorted types a schema allows. It
+// is empty if the schema allows any value.
+func typesOf(s map[string]interface{}) []string {
+	var types []string
+	switch t := s["type"].(type) {
+	case string:
+		types = append(types, t)
+	case []interface{}:
+		for _, v := range t {
+			if name, ok := v.(string); ok {
+				types = append(types, name)
+			}
+		}
+	}
+	sort.Strings(types)
+	return types
+}
+
+// subset reports if all types of a are allowed by b. Integers
+// are allowed by numbers.
+func subset(a, b []string) bool {
+	allowed := map[string]bool{}
+	for _, t := range b {
+		allowed[t] = true
+	}
+	for _, t := range a {
+		if !allowed[t] && !(t == "integer" && allowed["number"]) {
+			return false
+		}
+	}
+	return true
+}
+
+// typeName describes the types for the report.
+func typeName(types []string) string {
+	if len(types) == 0 {
+		return "any"
+	}
+	return strings.Join(types, "|")
+}
+
+// requiredOf returns the required fields of a object schema.
+func requiredOf(s map[string]interface{}) map[string]bool {
+	required := map[string]bool{}
+	names, _ := s["required"].([]interface{})
+	for _, name := range names {
+		if name, ok := name.(string); ok {
+			required[name] = true
+		}
+	}
+	return required
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// intOf returns the JSON number v as int.
+func intOf(v interface{}) int {
+	f, _ := v.(float64)
+	return int(f)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/BigJk/nra"
+	"github.com/stretchr/testify/assert"
+)
+
+type User struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+type UserV2 struct {
+	Name string `json:"name"`
+	Age  int    `json:"age,omitempty"`
+}
+
+type UserV3 struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// schemaOf returns the schema of a registry with fn registered as "fn".
+func schemaOf(t *testing.T, fn interface{}, opts ...nra.Option) *openAPISchema {
+	r := nra.NewRegistry()
+	r.MustRegister("fn", fn, opts...)
+
+	var buf bytes.Buffer
+	assert.NoError(t, nra.GenerateOpenAPI(&buf, r, nra.OpenAPIInfo{}))
+
+	var doc openAPISchema
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc))
+	return &doc
+}
+
+func TestCompatibility(t *testing.T) {
+	for _, test := range []struct {
+		Name       string
+		Old        interface{}
+		OldOpts    []nra.Option
+		New        interface{}
+		NewOpts    []nra.Option
+		Breaking   []string
+		Compatible []string
+	}{
+		{
+			Name:       "new argument",
+			Old:        func(a int) error { return nil },
+			New:        func(a, b int) error { return nil },
+			Breaking:   []string{"requires 2 arguments instead of 1"},
+			Compatible: []string{"accepts more arguments"},
+		},
+		{
+			Name:       "new optional argument",
+			Old:        func(a int) error { return nil },
+			New:        func(a int, b *int) error { return nil },
+			Compatible: []string{"accepts more arguments"},
+		},
+		{
+			Name:       "wider argument",
+			Old:        func(a int32) error { return nil },
+			New:        func(a int64) error { return nil },
+			Compatible: []string{`argument 1: format changed from "int32" to "int64"`},
+		},
+		{
+			Name:     "narrower argument",
+			Old:      func(a float64) error { return nil },
+			New:      func(a int) error { return nil },
+			Breaking: []string{"argument 1: type changed from number to integer"},
+		},
+		{
+			Name:     "argument not nullable",
+			Old:      func(a *User) error { return nil },
+			New:      func(a User) error { return nil },
+			Breaking: []string{"requires 1 arguments instead of 0", "argument 1: doesn't accept null anymore"},
+		},
+		{
+			Name:       "argument fields",
+			Old:        func(a User) error { return nil },
+			New:        func(a UserV2) error { return nil },
+			Compatible: []string{`argument 1: field "email" was removed`, `argument 1: new field "age"`},
+		},
+		{
+			Name:     "argument field required",
+			Old:      func(a User) error { return nil },
+			New:      func(a UserV3) error { return nil },
+			Breaking: []string{`argument 1: field "email" is required`},
+		},
+		{
+			Name:       "result fields",
+			Old:        func() (User, error) { return User{}, nil },
+			New:        func() (UserV2, error) { return UserV2{}, nil },
+			Breaking:   []string{`result: field "email" was removed`},
+			Compatible: []string{`result: new field "age"`},
+		},
+		{
+			Name:     "result nullable",
+			Old:      func() ([]int, error) { return nil, nil },
+			New:      func() ([]float64, error) { return nil, nil },
+			Breaking: []string{"result[]: type changed from integer to number"},
+		},
+		{
+			Name:     "result removed",
+			Old:      func() (int, error) { return 0, nil },
+			New:      func() error { return nil },
+			Breaking: []string{"doesn't return a result anymore"},
+		},
+		{
+			Name:     "not safe",
+			Old:      func() error { return nil },
+			OldOpts:  []nra.Option{nra.WithSafe()},
+			New:      func() error { return nil },
+			Breaking: []string{"can't be called via GET anymore"},
+		},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			changes := diffSchemas(schemaOf(t, test.Old, test.OldOpts...), schemaOf(t, test.New, test.NewOpts...))
+			if !assert.Len(t, changes, 1) {
+				return
+			}
+			assert.Equal(t, test.Breaking, changes[0].breaking)
+			assert.Equal(t, test.Compatible, changes[0].compatible)
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// bindDirective marks a function that handlers should be generated for:
+//
+//	//nra:bind add safe
+//	func Add(a, b int) (int, error) { ... }
+//
+// The first word is the name the function is registered under and
+// defaults to the name of the function. It can be followed by the
+// options safe, idempotent and native.
+const bindDirective = "//nra:bind"
+
+// bindOptions maps the options of a directive to the nra option.
+var bindOptions = map[string]string{
+	"safe":       "nra.WithSafe()",
+	"idempotent": "nra.WithIdempotent()",
+	"native":     "nra.WithNative()",
+}
+
+// runHandlers generates a file containing a function that registers
+// all annotated functions of a package at a nra.Registry together with
+// a nra.WithStatic handler, so the functions are called without
+// reflect.Call. It is meant to be run by go generate:
+//
+//	//go:generate go run github.com/BigJk/nra/cmd/nra handlers
+func runHandlers(args []string) error {
+	flags := flag.NewFlagSet("handlers", flag.ContinueOnError)
+	dir := flags.String("dir", ".", "directory of the package to scan")
+	out := flags.String("o", "nra_handlers.go", "file to write the handlers to, relative to dir")
+	funcName := flags.String("func", "RegisterHandlers", "name of the generated register function")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	output := *out
+	if !filepath.IsAbs(output) {
+		output = filepath.Join(*dir, output)
+	}
+
+	src, err := generateHandlers(*dir, filepath.Base(output), *funcName)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(output, src, 0644)
+}
+
+// handlerFunc is a annotated function.
+type handlerFunc struct {
+	name        string
+	fn          string
+	options     []string
+	params      []string
+	passContext bool
+	variadic    bool
+	hasResult   bool
+}
+
+// generateHandlers scans the package in dir and returns the
+// formatted source of the generated file. The file named
+// skip is ignored, as it is the previously generated file.
+func generateHandlers(dir string, skip string, funcName string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go") && info.Name() != skip
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected a single package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+
+	// sort the files so the output doesn't depend on map order.
+	var names []string
+	for name := range pkg.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var funcs []handlerFunc
+	imports := map[string]string{}
+	for _, name := range names {
+		file := pkg.Files[name]
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Doc == nil {
+				continue
+			}
+
+			h, ok, err := parseHandlerFunc(fset, file, fn, imports)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", fset.Position(fn.Pos()), err)
+			}
+			if ok {
+				funcs = append(funcs, h)
+			}
+		}
+	}
+
+	if len(funcs) == 0 {
+		return nil, errors.New("no functions annotated with " + bindDirective + " found")
+	}
+
+	return renderHandlers(pkg.Name, funcName, funcs, imports)
+}
+
+// parseHandlerFunc parses the annotated function fn. The imports that
+// are needed for the types of its parameters are added to imports.
+func parseHandlerFunc(fset *token.FileSet, file *ast.File, fn *ast.FuncDecl, imports map[string]string) (handlerFunc, bool, error) {
+	var directive []string
+	for _, c := range fn.Doc.List {
+		if c.Text == bindDirective || strings.HasPrefix(c.Text, bindDirective+" ") {
+			directive = strings.Fields(strings.TrimPrefix(c.Text, bindDirective))
+			break
+		}
+	}
+	if directive == nil {
+		return handlerFunc{}, false, nil
+	}
+
+	if fn.Recv != nil {
+		return handlerFunc{}, false, errors.New("methods can't be bound")
+	}
+
+	if fn.Type.TypeParams != nil {
+		return handlerFunc{}, false, errors.New("generic functions can't be bound")
+	}
+
+	h := handlerFunc{name: fn.Name.Name, fn: fn.Name.Name}
+	if len(directive) > 0 {
+		h.name = directive[0]
+	}
+	for i := 1; i < len(directive); i++ {
+		opt := directive[i]
+		expr, ok := bindOptions[opt]
+		if !ok {
+			return handlerFunc{}, false, errors.New("unknown option '" + opt + "'")
+		}
+		h.options = append(h.options, expr)
+	}
+
+	results := 0
+	if fn.Type.Results != nil {
+		results = fn.Type.Results.NumFields()
+	}
+	if results != 1 && results != 2 {
+		return handlerFunc{}, false, errors.New("function doesn't return 1 or 2 values")
+	}
+	h.hasResult = results == 2
+
+	for _, field := range fn.Type.Params.List {
+		typ := field.Type
+		if ellipsis, ok := typ.(*ast.Ellipsis); ok {
+			h.variadic = true
+			typ = ellipsis.Elt
+		}
+
+		var buf bytes.Buffer
+		if err := printer.Fprint(&buf, fset, typ); err != nil {
+			return handlerFunc{}, false, err
+		}
+
+		if err := collectImports(file, typ, imports); err != nil {
+			return handlerFunc{}, false, err
+		}
+
+		count := len(field.Names)
+		if count == 0 {
+			count = 1
+		}
+		for i := 0; i < count; i++ {
+			h.params = append(h.params, buf.String())
+		}
+	}
+
+	// a leading context.Context gets the context of the request, the
+	// same as in the reflective handler. It can't be the variadic one.
+	if len(h.params) > 0 && !(h.variadic && len(h.params) == 1) {
+		switch h.params[0] {
+		case "context.Context":
+			h.passContext = true
+			h.params = h.params[1:]
+		case "*http.Request":
+			return handlerFunc{}, false, errors.New("functions taking *http.Request can't be generated")
+		}
+	}
+
+	// the claims and the session are only passed by the reflective
+	// handler, generated ones can use nra.IdentityOf and nra.SessionOf.
+	if len(h.params) > 0 && h.params[0] == "nra.Claims" {
+		return handlerFunc{}, false, errors.New("functions taking nra.Claims can't be generated, use nra.IdentityOf(ctx) instead")
+	}
+	if len(h.params) > 0 && h.params[0] == "*nra.Session" {
+		return handlerFunc{}, false, errors.New("functions taking *nra.Session can't be generated, use nra.SessionOf(ctx) instead")
+	}
+
+	return h, true, nil
+}
+
+var versionSuffix = regexp.MustCompile(`^v[0-9]+$`)
+
+// collectImports adds the imports of file that are referenced in
+// the type expression typ to imports, keyed by their name.
+func collectImports(file *ast.File, typ ast.Expr, imports map[string]string) error {
+	var err error
+	ast.Inspect(typ, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+
+		ident, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := importName(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+
+			if name == ident.Name {
+				imports[name] = path
+				return false
+			}
+		}
+
+		err = errors.New("can't find the import of " + ident.Name)
+		return false
+	})
+	return err
+}
+
+// importName guesses the package name of the import path.
+func importName(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 1 && versionSuffix.MatchString(parts[len(parts)-1]) {
+		return parts[len(parts)-2]
+	}
+	return parts[len(parts)-1]
+}
+
+// renderHandlers writes the generated file and formats it.
+func renderHandlers(pkg string, funcName string, funcs []handlerFunc, imports map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by nra handlers. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+
+	// imports of the standard library go into the first group.
+	std := []string{`"context"`, `"encoding/json"`}
+	other := []string{`"github.com/BigJk/nra"`}
+	var names []string
+	for name := range imports {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		spec := strconv.Quote(imports[name])
+		if importName(imports[name]) != name {
+			spec = name + " " + spec
+		}
+
+		switch {
+		case imports[name] == "context" || imports[name] == "encoding/json" || imports[name] == "github.com/BigJk/nra":
+		case strings.Contains(strings.Split(imports[name], "/")[0], "."):
+			other = append(other, spec)
+		default:
+			std = append(std, spec)
+		}
+	}
+
+	buf.WriteString("import (\n")
+	for _, spec := range std {
+		fmt.Fprintf(&buf, "\t%s\n", spec)
+	}
+	buf.WriteString("\n")
+	for _, spec := range other {
+		fmt.Fprintf(&buf, "\t%s\n", spec)
+	}
+	buf.WriteString(")\n\n")
+
+	fmt.Fprintf(&buf, "// %s registers all functions annotated with nra:bind at r. They\n", funcName)
+	buf.WriteString("// are called by generated handlers instead of reflect.Call. The opts\n// are added to the options of every function.\n")
+	fmt.Fprintf(&buf, "func %s(r *nra.Registry, opts ...nra.Option) error {\n", funcName)
+	for _, h := range funcs {
+		options := append([]string{"nra.WithStatic(nraStatic" + h.fn + ")"}, h.options...)
+		fmt.Fprintf(&buf, "\tif err := r.Register(%q, %s, append([]nra.Option{%s}, opts...)...); err != nil {\n\t\treturn err\n\t}\n", h.name, h.fn, strings.Join(options, ", "))
+	}
+	buf.WriteString("\treturn nil\n}\n")
+
+	for _, h := range funcs {
+		fmt.Fprintf(&buf, "\nfunc nraStatic%s(ctx context.Context, args []json.RawMessage) (interface{}, error) {\n", h.fn)
+
+		var callArgs []string
+		if h.passContext {
+			callArgs = append(callArgs, "ctx")
+		}
+
+		fixed := len(h.params)
+		if h.variadic {
+			fixed--
+		}
+		for i := 0; i < fixed; i++ {
+			fmt.Fprintf(&buf, "\tvar a%d %s\n\tif err := nra.DecodeArgument(args, %d, &a%d); err != nil {\n\t\treturn nil, err\n\t}\n", i, h.params[i], i, i)
+			callArgs = append(callArgs, fmt.Sprintf("a%d", i))
+		}
+
+		if h.variadic {
+			fmt.Fprintf(&buf, "\tvar rest []%s\n\tfor i := %d; i < len(args); i++ {\n", h.params[fixed], fixed)
+			fmt.Fprintf(&buf, "\t\tvar v %s\n\t\tif err := nra.DecodeArgument(args, i, &v); err != nil {\n\t\t\treturn nil, err\n\t\t}\n\t\trest = append(rest, v)\n\t}\n", h.params[fixed])
+			callArgs = append(callArgs, "rest...")
+		}
+
+		call := h.fn + "(" + strings.Join(callArgs, ", ") + ")"
+		if h.hasResult {
+			fmt.Fprintf(&buf, "\treturn %s\n}\n", call)
+		} else {
+			fmt.Fprintf(&buf, "\treturn nil, %s\n}\n", call)
+		}
+	}
+
+	return format.Source(buf.Bytes())
+}
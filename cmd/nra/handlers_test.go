@@ -0,0 +1,96 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const handlersSource = `package api
+
+import (
+	"context"
+	"time"
+)
+
+type Point struct {
+	X int ` + "`json:\"x\"`" + `
+}
+
+//nra:bind
+func Add(a, b int) (int, error) {
+	return a + b, nil
+}
+
+// Sum sums up all numbers.
+//
+//nra:bind sum safe
+func Sum(ctx context.Context, start int, n ...float64) (float64, error) {
+	return 0, nil
+}
+
+//nra:bind wait idempotent
+func Wait(d time.Duration, p *Point) error {
+	return nil
+}
+
+func NotBound(a int) error {
+	return nil
+}
+`
+
+func writeSource(t *testing.T, source string) string {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "api.go"), []byte(source), 0644))
+	return dir
+}
+
+func TestGenerateHandlers(t *testing.T) {
+	dir := writeSource(t, handlersSource)
+
+	src, err := generateHandlers(dir, "nra_handlers.go", "RegisterHandlers")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	code := string(src)
+	assert.Contains(t, code, "// Code generated by nra handlers. DO NOT EDIT.")
+	assert.Contains(t, code, "package api")
+	assert.Contains(t, code, "\t\"time\"\n")
+	assert.Contains(t, code, "func RegisterHandlers(r *nra.Registry, opts ...nra.Option) error {")
+	assert.Contains(t, code, `r.Register("Add", Add, append([]nra.Option{nra.WithStatic(nraStaticAdd)}, opts...)...)`)
+	assert.Contains(t, code, `r.Register("sum", Sum, append([]nra.Option{nra.WithStatic(nraStaticSum), nra.WithSafe()}, opts...)...)`)
+	assert.Contains(t, code, `r.Register("wait", Wait, append([]nra.Option{nra.WithStatic(nraStaticWait), nra.WithIdempotent()}, opts...)...)`)
+	assert.Contains(t, code, "return Add(a0, a1)")
+	assert.Contains(t, code, "var rest []float64")
+	assert.Contains(t, code, "return Sum(ctx, a0, rest...)")
+	assert.Contains(t, code, "var a1 *Point")
+	assert.Contains(t, code, "return nil, Wait(a0, a1)")
+	assert.NotContains(t, code, "NotBound")
+}
+
+func TestGenerateHandlersErrors(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Source string
+		Error  string
+	}{
+		{Name: "none", Source: "package api\n", Error: "no functions annotated"},
+		{Name: "unknown_option", Source: "package api\n\n//nra:bind add fast\nfunc Add(a int) error { return nil }\n", Error: "unknown option 'fast'"},
+		{Name: "method", Source: "package api\n\ntype T struct{}\n\n//nra:bind\nfunc (T) Add(a int) error { return nil }\n", Error: "methods can't be bound"},
+		{Name: "returns", Source: "package api\n\n//nra:bind\nfunc Add(a int) {}\n", Error: "doesn't return 1 or 2 values"},
+		{Name: "request", Source: "package api\n\nimport \"net/http\"\n\n//nra:bind\nfunc Add(r *http.Request, a int) error { return nil }\n", Error: "*http.Request"},
+		{Name: "claims", Source: "package api\n\nimport \"github.com/BigJk/nra\"\n\n//nra:bind\nfunc Add(c nra.Claims, a int) error { return nil }\n", Error: "nra.Claims"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			_, err := generateHandlers(writeSource(t, test.Source), "nra_handlers.go", "RegisterHandlers")
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), test.Error)
+			}
+		})
+	}
+}
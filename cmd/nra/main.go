@@ -0,0 +1,67 @@
+// Command nra contains the tooling around nra.
+//
+// Usage:
+//
+//	nra handlers [-dir .] [-o nra_handlers.go] [-func RegisterHandlers]
+//	nra new [-module example.com/app] <dir>
+//	nra schema diff <old> <new>
+//	nra client [-js client.js] [-ts client.ts] [-forms] <schema>
+//
+// handlers generates reflection free handlers for all functions of the
+// package in dir that are annotated with a nra:bind comment, see
+// the documentation of runHandlers.
+//
+// new creates a project that serves a registry together with a
+// embedded frontend, see the documentation of runNew.
+//
+// schema diff compares two versions of the schema of a registry, see
+// the documentation of runSchema.
+//
+// client generates the Javascript or TypeScript client from a schema
+// exported by Registry.ExportSchema, see the documentation of runClient.
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+const usage = `usage: nra <command> [arguments]
+
+commands:
+  handlers   generate reflection free handlers for annotated functions
+  new        create a new project with a registry and a embedded frontend
+  schema     compare versions of the schema of a registry
+  client     generate clients from a exported schema
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "handlers":
+		err = runHandlers(os.Args[2:])
+	case "new":
+		err = runNew(os.Args[2:])
+	case "schema":
+		err = runSchema(os.Args[2:])
+	case "client":
+		err = runClient(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	if errors.Is(err, errBreakingChanges) {
+		os.Exit(3)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "nra:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// projectFile is a file of a new project. Go files
+// are formatted after the template is executed.
+type projectFile struct {
+	path     string
+	template string
+}
+
+// projectFiles is the layout of a new project:
+//
+//	api/        the functions and the registry
+//	tsgen/      writes the TypeScript client for the frontend
+//	web/        the frontend, embedded into the binary
+//	main.go     serves the registry and the frontend
+var projectFiles = []projectFile{
+	{path: "go.mod", template: `module {{.Module}}
+
+go 1.19
+`},
+	{path: "main.go", template: `// Command {{.Name}} serves the functions of the api package
+// together with the frontend in web as a single binary.
+package main
+
+import (
+	"embed"
+	"flag"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/BigJk/nra"
+
+	"{{.Module}}/api"
+)
+
+// regenerate the handlers and the TypeScript client after
+// changing the functions with "go generate".
+//go:generate go run github.com/BigJk/nra/cmd/nra handlers -dir api
+//go:generate go run ./tsgen -o web/client.ts
+
+//go:embed web
+var web embed.FS
+
+func main() {
+	addr := flag.String("addr", ":8080", "address to listen on")
+	flag.Parse()
+
+	r := api.NewRegistry()
+	r.EnableClientJS()
+	if err := r.Describe(os.Stdout, nra.DescribeTable); err != nil {
+		log.Fatal(err)
+	}
+
+	files, err := fs.Sub(web, "web")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(r.Prefix(), r)
+	mux.Handle("/", http.FileServer(http.FS(files)))
+
+	log.Printf("listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, mux))
+}
+`},
+	{path: "api/api.go", template: `// Package api contains the functions the frontend can call.
+// Every function annotated with nra:bind is registered by the
+// generated RegisterHandlers.
+package api
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/BigJk/nra"
+)
+
+// NewRegistry creates the registry with all functions.
+func NewRegistry() *nra.Registry {
+	r := nra.NewRegistry()
+	if err := RegisterHandlers(r); err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Add adds up two numbers. As it doesn't change
+// anything it is safe and can be called via GET.
+//
+//nra:bind add safe
+func Add(a, b int) (int, error) {
+	return a + b, nil
+}
+
+// Greeting is returned by Greet.
+type Greeting struct {
+	Message string    ` + "`json:\"message\"`" + `
+	At      time.Time ` + "`json:\"at\"`" + `
+}
+
+// Greet greets someone by name.
+//
+//nra:bind greet
+func Greet(ctx context.Context, name string) (*Greeting, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, &nra.Error{Message: "name can't be empty", Type: nra.ErrorTypeValidation, Argument: 1}
+	}
+	return &Greeting{Message: "Hello " + name + "!", At: time.Now()}, nil
+}
+`},
+	{path: "tsgen/main.go", template: `// Command tsgen writes the TypeScript client for the functions
+// of the api package, see the go:generate directives in main.go.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/BigJk/nra"
+
+	"{{.Module}}/api"
+)
+
+func main() {
+	out := flag.String("o", "web/client.ts", "file to write the client to")
+	flag.Parse()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := nra.GenerateTypeScript(f, api.NewRegistry()); err != nil {
+		log.Fatal(err)
+	}
+}
+`},
+	{path: "web/index.html", template: `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <meta name="viewport" content="width=device-width, initial-scale=1">
+  <title>{{.Name}}</title>
+</head>
+<body>
+  <h1>{{.Name}}</h1>
+
+  <p>
+    <input id="a" type="number" value="1"> + <input id="b" type="number" value="2">
+    <button id="add">=</button> <span id="sum"></span>
+  </p>
+
+  <p>
+    <input id="name" placeholder="Your name">
+    <button id="greet">Greet</button> <span id="greeting"></span>
+  </p>
+
+  <!-- the Javascript client is served by the registry. Frontends with
+       a bundler can import the typed client from client.ts instead. -->
+  <script src="/rpc/client.js"></script>
+  <script>
+    function show(id) {
+      return function(value) { document.getElementById(id).textContent = value; };
+    }
+    function showError(id) {
+      return function(err) { document.getElementById(id).textContent = 'Error: ' + err.message; };
+    }
+
+    document.getElementById('add').onclick = function() {
+      var a = Number(document.getElementById('a').value);
+      var b = Number(document.getElementById('b').value);
+      rpc.add(a, b).then(show('sum'), showError('sum'));
+    };
+
+    document.getElementById('greet').onclick = function() {
+      rpc.greet(document.getElementById('name').value).then(function(greeting) {
+        show('greeting')(greeting.message);
+      }, showError('greeting'));
+    };
+  </script>
+</body>
+</html>
+`},
+	{path: "README.md", template: `# {{.Name}}
+
+Created with ` + "`nra new`" + `. The functions in ` + "`api`" + ` are callable from the frontend in ` + "`web`" + `, which is embedded into the binary.
+
+` + "```" + `
+go mod tidy
+go generate
+go build -o {{.Name}} .
+./{{.Name}} -addr :8080
+` + "```" + `
+
+After adding or changing a function annotated with ` + "`//nra:bind`" + ` run ` + "`go generate`" + ` again, it updates ` + "`api/nra_handlers.go`" + ` and the TypeScript client in ` + "`web/client.ts`" + `.
+`},
+}
+
+// validModule matches plausible module paths.
+var validModule = regexp.MustCompile(`^[A-Za-z0-9._~-]+(/[A-Za-z0-9._~-]+)*$`)
+
+// runNew creates a new project that serves a registry together
+// with a embedded frontend:
+//
+//	nra new [-module example.com/app] app
+func runNew(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := flags.String("module", "", "module path of the project, defaults to the name of the directory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 1 {
+		return errors.New("usage: nra new [-module path] <dir>")
+	}
+	dir := flags.Arg(0)
+
+	if err := generateProject(dir, *module); err != nil {
+		return err
+	}
+
+	fmt.Printf("created %s, to start it run:\n\n  cd %s\n  go mod tidy\n  go run .\n", dir, dir)
+	return nil
+}
+
+// generateProject writes a new project to dir, which
+// has to be empty or not exist yet.
+func generateProject(dir string, module string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(abs)
+	if module == "" {
+		module = name
+	}
+	if !validModule.MatchString(module) {
+		return fmt.Errorf("invalid module path '%s'", module)
+	}
+
+	if entries, err := ioutil.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s already exists and isn't empty", dir)
+	}
+
+	data := struct {
+		Module string
+		Name   string
+	}{Module: module, Name: name}
+
+	for _, f := range projectFiles {
+		var buf bytes.Buffer
+		if err := template.Must(template.New(f.path).Parse(f.template)).Execute(&buf, data); err != nil {
+			return err
+		}
+
+		src := buf.Bytes()
+		if strings.HasSuffix(f.path, ".go") {
+			if src, err = format.Source(src); err != nil {
+				return fmt.Errorf("%s: %v", f.path, err)
+			}
+		}
+
+		path := filepath.Join(dir, filepath.FromSlash(f.path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(path, src, 0644); err != nil {
+			return err
+		}
+	}
+
+	// the handlers are generated right away,
+	// so the project builds without go generate.
+	api := filepath.Join(dir, "api")
+	src, err := generateHandlers(api, "nra_handlers.go", "RegisterHandlers")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(api, "nra_handlers.go"), src, 0644)
+}
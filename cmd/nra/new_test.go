@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateProject(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "app")
+	if !assert.NoError(t, generateProject(dir, "example.com/app")) {
+		return
+	}
+
+	read := func(path string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, path))
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	assert.Equal(t, "module example.com/app\n\ngo 1.19\n", read("go.mod"))
+	assert.Contains(t, read("main.go"), "\t\"example.com/app/api\"\n")
+	assert.Contains(t, read("main.go"), "//go:embed web\n")
+	assert.Contains(t, read("tsgen/main.go"), "nra.GenerateTypeScript(f, api.NewRegistry())")
+	assert.Contains(t, read("web/index.html"), "<title>app</title>")
+	assert.Contains(t, read("api/nra_handlers.go"), `r.Register("greet", Greet, append([]nra.Option{nra.WithStatic(nraStaticGreet)}, opts...)...)`)
+	assert.Contains(t, read("README.md"), "go build -o app .")
+}
+
+func TestGenerateProjectErrors(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644))
+
+	err := generateProject(dir, "")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "isn't empty")
+	}
+
+	err = generateProject(filepath.Join(dir, "app"), "example.com/my app")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "invalid module path")
+	}
+}
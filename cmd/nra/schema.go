@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// runSchema runs the schema commands:
+//
+//	nra schema diff <old> <new>
+//
+// diff compares two versions of the schema of a registry and lists the
+// functions that were added (+), removed (-) or changed (~). Each version
+// can be a file or a URL, and either a OpenAPI document, e.g. served by
+// a registry under /rpc/openapi.json, or a nra.SchemaDocument as it is
+// published by Registry.PublishSchema.
+//
+// Every change is classified as breaking, if clients built against the
+// old version can fail with the new one, or compatible. If there are
+// breaking changes errBreakingChanges is returned, which makes the
+// command exit with status 3 so deploys can be gated on it in CI.
+func runSchema(args []string) error {
+	if len(args) != 3 || args[0] != "diff" {
+		return errors.New("usage: nra schema diff <old> <new>")
+	}
+
+	old, err := loadSchema(args[1])
+	if err != nil {
+		return err
+	}
+	changed, err := loadSchema(args[2])
+	if err != nil {
+		return err
+	}
+
+	changes := diffSchemas(old, changed)
+	if err := writeSchemaDiff(os.Stdout, changes); err != nil {
+		return err
+	}
+
+	for _, c := range changes {
+		if len(c.breaking) > 0 {
+			return errBreakingChanges
+		}
+	}
+	return nil
+}
+
+// errBreakingChanges is returned by runSchema if the
+// new version of a schema breaks existing clients.
+var errBreakingChanges = errors.New("schema has breaking changes")
+
+// openAPISchema is the part of a OpenAPI document the diff looks at.
+type openAPISchema struct {
+	Paths      map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]interface{} `json:"schemas"`
+	} `json:"components"`
+}
+
+// loadSchema reads the OpenAPI document from the file or URL.
+func loadSchema(source string) (*openAPISchema, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = fetchSchema(source)
+	} else {
+		data, err = ioutil.ReadFile(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// a published document wraps the OpenAPI document.
+	var published struct {
+		OpenAPI json.RawMessage `json:"openapi"`
+	}
+	if err := json.Unmarshal(data, &published); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if len(published.OpenAPI) > 0 && published.OpenAPI[0] == '{' {
+		data = published.OpenAPI
+	}
+
+	var doc openAPISchema
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%s: %w", source, err)
+	}
+	if doc.Paths == nil {
+		return nil, fmt.Errorf("%s: isn't a OpenAPI document", source)
+	}
+	return &doc, nil
+}
+
+// fetchSchema downloads the schema from url.
+func fetchSchema(url string) ([]byte, error) {
+	response, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s responded with %s", url, response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// schemaChange is a function that differs between two schemas.
+type schemaChange struct {
+	kind byte
+	path string
+	compatibility
+}
+
+// diffSchemas compares the functions of both schemas. Functions are
+// compared with the component schemas they reference, so changing a
+// struct changes all functions that use it.
+func diffSchemas(old, changed *openAPISchema) []schemaChange {
+	var changes []schemaChange
+	for path, item := range old.Paths {
+		other, ok := changed.Paths[path]
+		switch {
+		case !ok:
+			c := schemaChange{kind: '-', path: path}
+			c.report(true, "", "was removed")
+			changes = append(changes, c)
+		default:
+			oldItem, _ := inlineRefs(item, old, nil).(map[string]interface{})
+			newItem, _ := inlineRefs(other, changed, nil).(map[string]interface{})
+			if !reflect.DeepEqual(oldItem, newItem) {
+				c := schemaChange{kind: '~', path: path}
+				c.compareFunctions(oldItem, newItem)
+				changes = append(changes, c)
+			}
+		}
+	}
+	for path := range changed.Paths {
+		if _, ok := old.Paths[path]; !ok {
+			changes = append(changes, schemaChange{kind: '+', path: path})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes
+}
+
+// schemaRefPrefix is the prefix of references to component schemas.
+const schemaRefPrefix = "#/components/schemas/"
+
+// inlineRefs replaces the references to component schemas in v by the
+// schemas themselves. Recursive references are kept as they are.
+func inlineRefs(v interface{}, doc *openAPISchema, resolving map[string]bool) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if ref, ok := v["$ref"].(string); ok && strings.HasPrefix(ref, schemaRefPrefix) {
+			name := strings.TrimPrefix(ref, schemaRefPrefix)
+			if target, ok := doc.Components.Schemas[name]; ok && !resolving[name] {
+				nested := map[string]bool{name: true}
+				for k := range resolving {
+					nested[k] = true
+				}
+				return inlineRefs(target, doc, nested)
+			}
+		}
+
+		inlined := make(map[string]interface{}, len(v))
+		for k, value := range v {
+			inlined[k] = inlineRefs(value, doc, resolving)
+		}
+		return inlined
+	case []interface{}:
+		inlined := make([]interface{}, len(v))
+		for i, value := range v {
+			inlined[i] = inlineRefs(value, doc, resolving)
+		}
+		return inlined
+	}
+	return v
+}
+
+// writeSchemaDiff writes a line per changed function, followed
+// by its breaking and compatible changes, and a summary.
+func writeSchemaDiff(w io.Writer, changes []schemaChange) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintln(w, "no changes")
+		return err
+	}
+
+	var buf strings.Builder
+	breaking := 0
+	for _, c := range changes {
+		fmt.Fprintf(&buf, "%c %s\n", c.kind, c.path)
+		for _, message := range c.breaking {
+			fmt.Fprintf(&buf, "    breaking: %s\n", message)
+		}
+		for _, message := range c.compatible {
+			fmt.Fprintf(&buf, "    compatible: %s\n", message)
+		}
+		breaking += len(c.breaking)
+	}
+
+	if breaking > 0 {
+		fmt.Fprintf(&buf, "\n%d breaking changes\n", breaking)
+	} else {
+		buf.WriteString("\nno breaking changes\n")
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/BigJk/nra"
+	"github.com/stretchr/testify/assert"
+)
+
+type Point struct {
+	X int `json:"x"`
+}
+
+type PointV2 struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func TestSchemaDiff(t *testing.T) {
+	old := nra.NewRegistry()
+	old.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	old.MustRegister("move", func(p Point) error { return nil })
+	old.MustRegister("sub", func(a, b int) (int, error) { return a - b, nil })
+
+	changed := nra.NewRegistry()
+	changed.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	changed.MustRegister("move", func(p PointV2) error { return nil })
+	changed.MustRegister("mul", func(a, b int) (int, error) { return a * b, nil })
+
+	// the old version is a published file, the new one is served.
+	doc, err := old.SchemaDocument(nra.OpenAPIInfo{Title: "calc", Version: "1"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	data, _ := json.Marshal(doc)
+	oldPath := filepath.Join(t.TempDir(), "old.json")
+	assert.NoError(t, ioutil.WriteFile(oldPath, data, 0644))
+
+	changed.EnableOpenAPI(nra.OpenAPIInfo{Title: "calc", Version: "2"})
+	server := httptest.NewServer(changed)
+	defer server.Close()
+
+	oldSchema, err := loadSchema(oldPath)
+	assert.NoError(t, err)
+	newSchema, err := loadSchema(server.URL + "/rpc/openapi.json")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, writeSchemaDiff(&out, diffSchemas(oldSchema, newSchema)))
+	assert.Equal(t, "~ /rpc/move\n    breaking: argument 1: new required field \"y\"\n+ /rpc/mul\n- /rpc/sub\n    breaking: was removed\n\n2 breaking changes\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, writeSchemaDiff(&out, diffSchemas(oldSchema, oldSchema)))
+	assert.Equal(t, "no changes\n", out.String())
+
+	_, err = loadSchema(server.URL + "/rpc/missing.json")
+	assert.Error(t, err)
+	_, err = loadSchema(server.URL + "/rpc/_methods")
+	assert.Error(t, err)
+}
@@ -0,0 +1,209 @@
+package nra
+
+import (
+	"encoding/json"
+	"io"
+	"mime"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// Codec is a wire format for the arguments and results of calls.
+// Codecs are selected by the Content-Type and Accept headers of
+// a request, see RegisterCodec.
+type Codec interface {
+	// ContentType is the MIME type that is set on responses
+	// encoded with the codec.
+	ContentType() string
+
+	// Decode decodes the array of arguments from r. The arguments
+	// should be generic values the same way encoding/json decodes
+	// into a interface{}, e.g. objects as map[string]interface{},
+	// so they can be converted to the parameters of the function.
+	// A empty body should result in io.EOF or no arguments.
+	Decode(r io.Reader) ([]interface{}, error)
+
+	// Encode writes the result of a call to w.
+	Encode(w io.Writer, result interface{}) error
+}
+
+// codec is a Codec made from the decode and encode
+// functions of a format.
+type codec struct {
+	contentType string
+	decode      func(r io.Reader, v interface{}) error
+	encode      func(w io.Writer, v interface{}) error
+}
+
+// ContentType implements Codec.
+func (c *codec) ContentType() string {
+	return c.contentType
+}
+
+// Decode implements Codec.
+func (c *codec) Decode(r io.Reader) ([]interface{}, error) {
+	var args []interface{}
+	err := c.decode(r, &args)
+	return args, err
+}
+
+// Encode implements Codec.
+func (c *codec) Encode(w io.Writer, result interface{}) error {
+	return c.encode(w, result)
+}
+
+// jsonCodec is the default wire format.
+var jsonCodec = &codec{
+	contentType: "application/json",
+	decode: func(r io.Reader, v interface{}) error {
+		return json.NewDecoder(r).Decode(v)
+	},
+	encode: func(w io.Writer, v interface{}) error {
+		return json.NewEncoder(w).Encode(v)
+	},
+}
+
+// msgpackCodec encodes as MessagePack. Structs use their json
+// tags, so the same struct works with both formats.
+var msgpackCodec = &codec{
+	contentType: "application/msgpack",
+	decode: func(r io.Reader, v interface{}) error {
+		dec := msgpack.NewDecoder(r)
+		dec.SetCustomStructTag("json")
+		return dec.Decode(v)
+	},
+	encode: func(w io.Writer, v interface{}) error {
+		enc := msgpack.NewEncoder(w)
+		enc.SetCustomStructTag("json")
+		return enc.Encode(v)
+	},
+}
+
+// cborDecMode and cborEncMode hold the options of cborCodec.
+// Maps are decoded with string keys, the same as JSON objects.
+var (
+	cborDecMode, _ = cbor.DecOptions{DefaultMapType: reflect.TypeOf(map[string]interface{}(nil))}.DecMode()
+	cborEncMode, _ = cbor.EncOptions{Time: cbor.TimeRFC3339Nano}.EncMode()
+)
+
+// cborCodec encodes as CBOR. Structs without cbor tags use
+// their json tags.
+var cborCodec = &codec{
+	contentType: "application/cbor",
+	decode: func(r io.Reader, v interface{}) error {
+		return cborDecMode.NewDecoder(r).Decode(v)
+	},
+	encode: func(w io.Writer, v interface{}) error {
+		return cborEncMode.NewEncoder(w).Encode(v)
+	},
+}
+
+// codecs maps the supported content types to their codec.
+var (
+	codecsMtx sync.RWMutex
+	codecs    = map[string]Codec{
+		"application/json":      jsonCodec,
+		"application/msgpack":   msgpackCodec,
+		"application/x-msgpack": msgpackCodec,
+		"application/cbor":      cborCodec,
+
+		"application/x-protobuf": protobufCodec,
+		"application/protobuf":   protobufCodec,
+	}
+)
+
+// RegisterCodec makes the codec available for requests with the given
+// MIME type as Content-Type or Accept header. Registering a codec for a
+// MIME type that already has one replaces it, so e.g. a faster JSON
+// implementation can be used for "application/json". RegisterCodec
+// should be called before the server is started.
+func RegisterCodec(mimeType string, c Codec) {
+	codecsMtx.Lock()
+	defer codecsMtx.Unlock()
+
+	codecs[mimeType] = c
+}
+
+// lookupCodec returns the codec registered for the MIME type.
+func lookupCodec(mimeType string) (Codec, bool) {
+	codecsMtx.RLock()
+	defer codecsMtx.RUnlock()
+
+	c, ok := codecs[mimeType]
+	return c, ok
+}
+
+// defaultCodec returns the codec used if the request
+// doesn't specify a supported format.
+func defaultCodec() Codec {
+	c, _ := lookupCodec("application/json")
+	return c
+}
+
+// codecOf returns the codec for the content type of a request.
+// Requests without or with a unknown content type are JSON, as
+// that is what nra always expected.
+func codecOf(contentType string) Codec {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return defaultCodec()
+	}
+
+	if c, ok := lookupCodec(mediaType); ok {
+		return c
+	}
+	return defaultCodec()
+}
+
+// acceptedCodec picks the codec for the response based on the Accept
+// header of the request. Without a Accept header or if any type is
+// accepted the response uses the same codec as the request, if none
+// of the accepted types is supported it falls back to JSON.
+func acceptedCodec(accept string, request Codec) Codec {
+	if strings.TrimSpace(accept) == "" {
+		return request
+	}
+
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+
+	var types []accepted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err != nil {
+			continue
+		}
+
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(v, 64); err != nil {
+				continue
+			}
+		}
+		if q > 0 {
+			types = append(types, accepted{mediaType: mediaType, q: q})
+		}
+	}
+
+	sort.SliceStable(types, func(i, j int) bool {
+		return types[i].q > types[j].q
+	})
+
+	for _, t := range types {
+		if t.mediaType == "*/*" || t.mediaType == "application/*" {
+			return request
+		}
+		if c, ok := lookupCodec(t.mediaType); ok {
+			return c
+		}
+	}
+	return defaultCodec()
+}
@@ -0,0 +1,200 @@
+package nra
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type codecItem struct {
+	Name  string `json:"name"`
+	Count uint16 `json:"count"`
+	Data  []byte `json:"data"`
+}
+
+func TestBindMsgpack(t *testing.T) {
+	h := MustBind(func(a int64, b float32, items []codecItem) ([]codecItem, error) {
+		for i := range items {
+			items[i].Count += uint16(a)
+			items[i].Name += "!"
+		}
+		return items, nil
+	})
+
+	body, err := msgpack.Marshal([]interface{}{int64(1) << 40, 1.5, []map[string]interface{}{
+		{"name": "a", "count": 1, "data": []byte{0, 1, 2}},
+	}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/msgpack")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+		return
+	}
+	assert.Equal(t, "application/msgpack", rr.Header().Get("Content-Type"))
+
+	var items []map[string]interface{}
+	if assert.NoError(t, msgpack.Unmarshal(rr.Body.Bytes(), &items)) {
+		assert.Equal(t, []map[string]interface{}{{"name": "a!", "count": uint16(1), "data": []byte{0, 1, 2}}}, items)
+	}
+}
+
+func TestBindMsgpackError(t *testing.T) {
+	h := MustBind(func(a bool) error { return nil })
+
+	body, _ := msgpack.Marshal([]interface{}{int8(3)})
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-msgpack")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), `"message":"mismatching argument type of 1. argument. got=number expected=bool"`)
+}
+
+func TestBindCBOR(t *testing.T) {
+	h := MustBind(func(a uint8, items []codecItem, limit *int) (map[string]interface{}, error) {
+		return map[string]interface{}{"a": a, "items": items, "limit": limit}, nil
+	})
+
+	body, err := cbor.Marshal([]interface{}{200, []map[string]interface{}{{"name": "a", "count": 3}}})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/cbor")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+		return
+	}
+	assert.Equal(t, "application/cbor", rr.Header().Get("Content-Type"))
+
+	var result map[string]interface{}
+	if assert.NoError(t, cbor.Unmarshal(rr.Body.Bytes(), &result)) {
+		assert.Equal(t, map[string]interface{}{
+			"a":     uint64(200),
+			"items": []interface{}{map[interface{}]interface{}{"name": "a", "count": uint64(3), "data": nil}},
+			"limit": nil,
+		}, result)
+	}
+}
+
+func TestCodecOf(t *testing.T) {
+	assert.Equal(t, jsonCodec, codecOf(""))
+	assert.Equal(t, jsonCodec, codecOf("text/plain"))
+	assert.Equal(t, jsonCodec, codecOf("application/json; charset=utf-8"))
+	assert.Equal(t, msgpackCodec, codecOf("application/msgpack"))
+	assert.Equal(t, msgpackCodec, codecOf("application/x-msgpack"))
+	assert.Equal(t, cborCodec, codecOf("application/cbor"))
+}
+
+func TestAcceptedCodec(t *testing.T) {
+	for _, c := range []struct {
+		accept   string
+		request  Codec
+		expected Codec
+	}{
+		{"", msgpackCodec, msgpackCodec},
+		{"*/*", cborCodec, cborCodec},
+		{"application/json", msgpackCodec, jsonCodec},
+		{"application/msgpack", jsonCodec, msgpackCodec},
+		{"application/json;q=0.5, application/cbor", jsonCodec, cborCodec},
+		{"text/html, application/*;q=0.8", cborCodec, cborCodec},
+		{"application/cbor;q=0, text/html", cborCodec, jsonCodec},
+		{"image/png", msgpackCodec, jsonCodec},
+	} {
+		assert.Equal(t, c.expected.ContentType(), acceptedCodec(c.accept, c.request).ContentType(), c.accept)
+	}
+}
+
+func TestBindAccept(t *testing.T) {
+	h := MustBind(func(a, b int) (int, error) { return a + b, nil })
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader([]byte("[1, 2]")))
+	req.Header.Set("Accept", "application/msgpack")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+		return
+	}
+	assert.Equal(t, "application/msgpack", rr.Header().Get("Content-Type"))
+
+	var result int
+	if assert.NoError(t, msgpack.Unmarshal(rr.Body.Bytes(), &result)) {
+		assert.Equal(t, 3, result)
+	}
+
+	// protobuf can only be used for message results.
+	req = httptest.NewRequest("POST", "/", bytes.NewReader([]byte("[1, 2]")))
+	req.Header.Set("Accept", "application/x-protobuf")
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "3\n", rr.Body.String())
+}
+
+// lineCodec is a test codec that passes every line of
+// the body as string argument.
+type lineCodec struct{}
+
+func (lineCodec) ContentType() string {
+	return "text/plain"
+}
+
+func (lineCodec) Decode(r io.Reader) ([]interface{}, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []interface{}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		args = append(args, line)
+	}
+	return args, nil
+}
+
+func (lineCodec) Encode(w io.Writer, result interface{}) error {
+	_, err := fmt.Fprintln(w, result)
+	return err
+}
+
+func TestRegisterCodec(t *testing.T) {
+	RegisterCodec("text/plain", lineCodec{})
+	defer func() {
+		codecsMtx.Lock()
+		delete(codecs, "text/plain")
+		codecsMtx.Unlock()
+	}()
+
+	h := MustBind(func(a, b string) (string, error) { return a + b, nil })
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader("hello\nworld\n"))
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "text/plain", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "helloworld\n", rr.Body.String())
+}
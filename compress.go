@@ -0,0 +1,210 @@
+package nra
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressionMinSize is the size in bytes from which responses
+// are compressed if no other size is given. Compressing smaller
+// responses costs more time than it saves.
+const DefaultCompressionMinSize = 1024
+
+// Compressor wraps w so everything written to the returned
+// writer ends up compressed in w. Close has to flush all
+// buffered data but must not close w.
+type Compressor func(w io.Writer) io.WriteCloser
+
+// gzipPool holds the gzip writers, as each one
+// allocates quite a lot of memory on creation.
+var gzipPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// pooledGzip puts its writer back into the pool on Close.
+type pooledGzip struct {
+	*gzip.Writer
+}
+
+// Close implements io.Closer.
+func (g pooledGzip) Close() error {
+	err := g.Writer.Close()
+	g.Writer.Reset(nil)
+	gzipPool.Put(g.Writer)
+	return err
+}
+
+// gzipCompressor is the Compressor of the gzip encoding.
+func gzipCompressor(w io.Writer) io.WriteCloser {
+	zw := gzipPool.Get().(*gzip.Writer)
+	zw.Reset(w)
+	return pooledGzip{zw}
+}
+
+// compressors maps the supported content codings to their
+// compressor, in the order they are preferred.
+var (
+	compressorsMtx   sync.RWMutex
+	compressors      = map[string]Compressor{"gzip": gzipCompressor}
+	compressorsOrder = []string{"gzip"}
+)
+
+// RegisterCompressor makes the compressor available for responses to
+// clients that accept the content coding, e.g. "br" for brotli:
+//
+//	nra.RegisterCompressor("br", func(w io.Writer) io.WriteCloser {
+//	  return brotli.NewWriter(w)
+//	})
+//
+// If the client accepts more than one coding equally the ones registered
+// later are preferred, gzip is always available. RegisterCompressor
+// should be called before the server is started.
+func RegisterCompressor(encoding string, c Compressor) {
+	compressorsMtx.Lock()
+	defer compressorsMtx.Unlock()
+
+	encoding = strings.ToLower(encoding)
+	if _, ok := compressors[encoding]; !ok {
+		compressorsOrder = append([]string{encoding}, compressorsOrder...)
+	}
+	compressors[encoding] = c
+}
+
+// acceptedCompressor picks the compressor for the Accept-Encoding header
+// of a request. It returns a empty encoding if the client doesn't
+// accept any of the registered ones.
+func acceptedCompressor(accept string) (string, Compressor) {
+	if strings.TrimSpace(accept) == "" {
+		return "", nil
+	}
+
+	compressorsMtx.RLock()
+	defer compressorsMtx.RUnlock()
+
+	q := map[string]float64{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		encoding := strings.ToLower(strings.TrimSpace(params[0]))
+		weight := 1.0
+		for _, p := range params[1:] {
+			if v := strings.TrimSpace(p); strings.HasPrefix(v, "q=") {
+				weight, _ = strconv.ParseFloat(v[2:], 64)
+			}
+		}
+		q[encoding] = weight
+	}
+
+	var candidates []string
+	for _, encoding := range compressorsOrder {
+		weight, ok := q[encoding]
+		if !ok {
+			weight, ok = q["*"]
+		}
+		if ok && weight > 0 {
+			candidates = append(candidates, encoding)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", nil
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return q[candidates[i]] > q[candidates[j]]
+	})
+	return candidates[0], compressors[candidates[0]]
+}
+
+// compressible reports if responses of the content
+// type get smaller by compressing them.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "json") ||
+		strings.HasSuffix(mediaType, "javascript") ||
+		strings.HasSuffix(mediaType, "xml") ||
+		mediaType == "application/msgpack" || mediaType == "application/cbor"
+}
+
+// compressWriter compresses the response if it is compressible and
+// its Content-Length is at least minSize. Responses without a length
+// are streamed and sent as they are.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding   string
+	compressor Compressor
+	minSize    int
+
+	wroteHeader bool
+	w           io.WriteCloser
+}
+
+// withCompression wraps the writer into a compressWriter if the client
+// accepts compressed responses. The returned function has to be called
+// once the response is complete.
+func withCompression(writer http.ResponseWriter, request *http.Request, minSize int) (http.ResponseWriter, func()) {
+	writer.Header().Add("Vary", "Accept-Encoding")
+
+	encoding, c := acceptedCompressor(request.Header.Get("Accept-Encoding"))
+	if c == nil {
+		return writer, func() {}
+	}
+
+	w := &compressWriter{ResponseWriter: writer, encoding: encoding, compressor: c, minSize: minSize}
+	return w, w.close
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *compressWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	length, err := strconv.Atoi(h.Get("Content-Length"))
+	if err == nil && length >= w.minSize && code != http.StatusNoContent && code != http.StatusNotModified &&
+		h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Del("Content-Length")
+		h.Set("Content-Encoding", w.encoding)
+		w.w = w.compressor(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *compressWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.w != nil {
+		return w.w.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+// recordError passes the error on to the writer below,
+// so observers still see it.
+func (w *compressWriter) recordError(err *Error) {
+	if r, ok := w.ResponseWriter.(errorRecorder); ok {
+		r.recordError(err)
+	}
+}
+
+// close writes out the rest of the compressed response.
+func (w *compressWriter) close() {
+	if w.w != nil {
+		_ = w.w.Close()
+		w.w = nil
+	}
+}
@@ -0,0 +1,99 @@
+package nra
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedCompressor(t *testing.T) {
+	for accept, expected := range map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"GZIP":                    "gzip",
+		"deflate, gzip;q=0.5":     "gzip",
+		"gzip;q=0":                "",
+		"*":                       "gzip",
+		"*, gzip;q=0":             "",
+		"br, gzip;q=0.8, deflate": "gzip",
+	} {
+		encoding, _ := acceptedCompressor(accept)
+		assert.Equal(t, expected, encoding, accept)
+	}
+}
+
+func TestBindCompression(t *testing.T) {
+	h := MustBind(func(n int) ([]string, error) {
+		return make([]string, n), nil
+	}, WithCompression(0))
+
+	call := func(n int, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", strings.NewReader("["+strconv.Itoa(n)+"]"))
+		req.Header.Set("Accept-Encoding", accept)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	// large results are compressed.
+	rr := call(1000, "gzip")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", rr.Header().Get("Vary"))
+	assert.Empty(t, rr.Header().Get("Content-Length"))
+
+	zr, err := gzip.NewReader(rr.Body)
+	assert.NoError(t, err)
+	var result []string
+	assert.NoError(t, json.NewDecoder(zr).Decode(&result))
+	assert.Len(t, result, 1000)
+
+	// small results and clients without gzip get them as they are.
+	rr = call(2, "gzip")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "[\"\",\"\"]\n", rr.Body.String())
+
+	rr = call(1000, "")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Len(t, rr.Body.String(), 3002)
+}
+
+func TestRegistryCompression(t *testing.T) {
+	r := NewRegistry()
+	r.SetCompression(10)
+	r.MustRegister("file", func() (*File, error) {
+		return &File{Name: "a.png", ContentType: "image/png", Data: make([]byte, 100)}, nil
+	})
+	r.MustRegister("text", func() (string, error) {
+		return strings.Repeat("a", 100), nil
+	}, WithCompression(50))
+
+	call := func(name string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// images don't get smaller.
+	rr := call("file")
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Len(t, rr.Body.Bytes(), 100)
+
+	// the registry doesn't compress again what the function did.
+	rr = call("text")
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+	zr, err := gzip.NewReader(rr.Body)
+	assert.NoError(t, err)
+	data, _ := io.ReadAll(zr)
+	assert.Equal(t, "\""+strings.Repeat("a", 100)+"\"\n", string(data))
+}
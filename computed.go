@@ -0,0 +1,224 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// resolver computes a field of a struct result.
+type resolver struct {
+	index []int
+	call  func(ctx context.Context, parent reflect.Value) (reflect.Value, error)
+}
+
+// resolvers maps struct types to the resolvers of their fields.
+type resolvers map[reflect.Type]map[string]resolver
+
+// Resolve registers fn to compute the field of results of type T, which
+// is named by its json name. The field is only computed if the field mask
+// of a call selects it, see FieldsName, so expensive joins are only done
+// for clients that need them. Without a mask the field keeps the value
+// the function returned, so it should usually be omitempty:
+//
+//	type User struct {
+//		ID     int     `json:"id"`
+//		Orders []Order `json:"orders,omitempty"`
+//	}
+//
+//	nra.Resolve(r, "orders", func(ctx context.Context, u User) ([]Order, error) {
+//		return orders.OfUser(ctx, u.ID)
+//	})
+//
+// The field has to be a field of T or a embedded struct of it, and V
+// has to be assignable to it.
+func Resolve[T any, V any](r *Registry, field string, fn func(ctx context.Context, parent T) (V, error)) error {
+	t := reflect.TypeOf((*T)(nil)).Elem()
+	if t.Kind() != reflect.Struct {
+		return errors.New("computed fields can only be added to structs, got " + t.String())
+	}
+
+	index, ok := jsonFieldIndex(t, field)
+	if !ok {
+		return errors.New(t.String() + " has no field '" + field + "'")
+	}
+
+	ft := t.FieldByIndex(index).Type
+	if vt := reflect.TypeOf((*V)(nil)).Elem(); !vt.AssignableTo(ft) {
+		return errors.New("resolver of " + t.String() + "." + field + " returns " + vt.String() + " instead of " + ft.String())
+	}
+
+	if r.resolvers == nil {
+		r.resolvers = resolvers{}
+	}
+	if r.resolvers[t] == nil {
+		r.resolvers[t] = map[string]resolver{}
+	}
+
+	r.resolvers[t][field] = resolver{
+		index: index,
+		call: func(ctx context.Context, parent reflect.Value) (reflect.Value, error) {
+			value, err := fn(ctx, parent.Interface().(T))
+			return reflect.ValueOf(&value).Elem(), err
+		},
+	}
+	return nil
+}
+
+// MustResolve is the same as Resolve but panics
+// if the resolver can't be registered.
+func MustResolve[T any, V any](r *Registry, field string, fn func(ctx context.Context, parent T) (V, error)) {
+	if err := Resolve(r, field, fn); err != nil {
+		panic("nra: resolve failed with: " + err.Error())
+	}
+}
+
+// jsonFieldIndex returns the index of the field of the struct t that
+// encoding/json encodes under name. Fields of embedded structs are
+// found as well, as long as they aren't embedded as pointer.
+func jsonFieldIndex(t reflect.Type, name string) ([]int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		tagName, _, _ := strings.Cut(tag, ",")
+
+		if f.Anonymous && tagName == "" && f.Type.Kind() == reflect.Struct {
+			if index, ok := jsonFieldIndex(f.Type, name); ok {
+				return append([]int{i}, index...), true
+			}
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+		if tagName == "" {
+			tagName = f.Name
+		}
+		if tagName == name {
+			return []int{i}, true
+		}
+	}
+	return nil, false
+}
+
+// resolversKey is the context key of the resolvers of a registry.
+type resolversKey struct{}
+
+// withResolvers makes the resolvers of the registry available to the call.
+func (r *Registry) withResolvers(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), resolversKey{}, r.resolvers))
+}
+
+// resolveFields computes the fields of the result that are selected by
+// mask and have a resolver. The values on the way to a computed field
+// are copied, so the result the function returned isn't changed.
+func resolveFields(ctx context.Context, result interface{}, mask fieldMask) (interface{}, error) {
+	rs, ok := ctx.Value(resolversKey{}).(resolvers)
+	if !ok || result == nil {
+		return result, nil
+	}
+
+	v, err := rs.resolve(ctx, reflect.ValueOf(result), mask)
+	if err != nil {
+		return nil, err
+	}
+	return v.Interface(), nil
+}
+
+// resolve returns v with the selected computed fields resolved.
+func (rs resolvers) resolve(ctx context.Context, v reflect.Value, mask fieldMask) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return v, nil
+		}
+		return rs.resolve(ctx, v.Elem(), mask)
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v, nil
+		}
+		elem, err := rs.resolve(ctx, v.Elem(), mask)
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(elem.Type())
+		p.Elem().Set(elem)
+		return p, nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() || isBytes(v.Type()) {
+			return v, nil
+		}
+
+		out := reflect.New(v.Type()).Elem()
+		if v.Kind() == reflect.Slice {
+			out = reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		}
+		for i := 0; i < v.Len(); i++ {
+			elem, err := rs.resolve(ctx, v.Index(i), mask)
+			if err != nil {
+				return v, err
+			}
+			out.Index(i).Set(elem)
+		}
+		return out, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return v, nil
+		}
+
+		out := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			elem, err := rs.resolve(ctx, iter.Value(), mask)
+			if err != nil {
+				return v, err
+			}
+			out.SetMapIndex(iter.Key(), elem)
+		}
+		return out, nil
+	case reflect.Struct:
+		if v.Type() == timeType {
+			return v, nil
+		}
+		return rs.resolveStruct(ctx, v, mask)
+	}
+	return v, nil
+}
+
+// resolveStruct resolves the selected computed fields of the
+// struct v and continues with the nested masks of its fields.
+func (rs resolvers) resolveStruct(ctx context.Context, v reflect.Value, mask fieldMask) (reflect.Value, error) {
+	t := v.Type()
+	out := reflect.New(t).Elem()
+	out.Set(v)
+
+	for name, sub := range mask {
+		var field reflect.Value
+		if r, ok := rs[t][name]; ok {
+			value, err := r.call(ctx, v)
+			if err != nil {
+				return v, err
+			}
+			field = out.FieldByIndex(r.index)
+			field.Set(value)
+		} else if index, ok := jsonFieldIndex(t, name); ok && sub != nil {
+			field = out.FieldByIndex(index)
+		}
+
+		if field.IsValid() && sub != nil {
+			value, err := rs.resolve(ctx, field, sub)
+			if err != nil {
+				return v, err
+			}
+			field.Set(value)
+		}
+	}
+	return out, nil
+}
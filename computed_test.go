@@ -0,0 +1,90 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type computedOrder struct {
+	ID    int     `json:"id"`
+	Total float64 `json:"total"`
+}
+
+type computedUser struct {
+	ID     int             `json:"id"`
+	Name   string          `json:"name"`
+	Orders []computedOrder `json:"orders,omitempty"`
+	Score  int             `json:"score,omitempty"`
+}
+
+type computedTeam struct {
+	Name    string          `json:"name"`
+	Members []*computedUser `json:"members"`
+}
+
+func TestResolve(t *testing.T) {
+	users := []computedUser{{ID: 1, Name: "alice"}, {ID: 2, Name: "bob"}}
+
+	resolved := 0
+	r := NewRegistry()
+	r.MustRegister("users", func() ([]computedUser, error) { return users, nil })
+	r.MustRegister("team", func() (computedTeam, error) {
+		return computedTeam{Name: "core", Members: []*computedUser{&users[0]}}, nil
+	})
+	MustResolve(r, "orders", func(ctx context.Context, u computedUser) ([]computedOrder, error) {
+		resolved++
+		return []computedOrder{{ID: u.ID * 10, Total: 9.5}}, nil
+	})
+	MustResolve(r, "score", func(ctx context.Context, u computedUser) (int, error) {
+		if u.ID == 2 {
+			return 0, &Error{Message: "no score for bob", Type: ErrorTypeApplication}
+		}
+		return 42, nil
+	})
+
+	call := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", path, nil))
+		return rec
+	}
+
+	// without the field in the mask nothing is resolved.
+	rec := call("/rpc/users")
+	assert.Equal(t, `[{"id":1,"name":"alice"},{"id":2,"name":"bob"}]`+"\n", rec.Body.String())
+	rec = call("/rpc/users?_fields=id")
+	assert.Equal(t, `[{"id":1},{"id":2}]`+"\n", rec.Body.String())
+	assert.Equal(t, 0, resolved)
+
+	rec = call("/rpc/users?_fields=id,orders.id")
+	assert.Equal(t, `[{"id":1,"orders":[{"id":10}]},{"id":2,"orders":[{"id":20}]}]`+"\n", rec.Body.String())
+	assert.Equal(t, 2, resolved)
+
+	// nested structs behind pointers are resolved too.
+	rec = call("/rpc/team?_fields=name,members.name,members.orders")
+	assert.Equal(t, `{"members":[{"name":"alice","orders":[{"id":10,"total":9.5}]}],"name":"core"}`+"\n", rec.Body.String())
+
+	// the values the function returned stay untouched.
+	assert.Nil(t, users[0].Orders)
+
+	rec = call("/rpc/users?_fields=score")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"error":{"message":"no score for bob","type":"application"}}`+"\n", rec.Body.String())
+}
+
+func TestResolveErrors(t *testing.T) {
+	r := NewRegistry()
+
+	err := Resolve(r, "orders", func(ctx context.Context, u int) (int, error) { return 0, nil })
+	assert.EqualError(t, err, "computed fields can only be added to structs, got int")
+
+	err = Resolve(r, "missing", func(ctx context.Context, u computedUser) (int, error) { return 0, nil })
+	assert.EqualError(t, err, "nra.computedUser has no field 'missing'")
+
+	err = Resolve(r, "score", func(ctx context.Context, u computedUser) (string, error) { return "", errors.New("") })
+	assert.EqualError(t, err, "resolver of nra.computedUser.score returns string instead of int")
+}
@@ -0,0 +1,74 @@
+package nra
+
+import (
+	"context"
+	"time"
+)
+
+// WithMaxConcurrent limits the number of calls of the function that are
+// executed at the same time to n, e.g. because every call holds a
+// expensive native resource. A call that arrives while all slots are
+// taken waits up to wait for a free one. If none becomes free in time,
+// or right away with a wait of 0, the call is answered with status 429
+// and the type ErrorTypeLimit.
+func WithMaxConcurrent(n int, wait time.Duration) Option {
+	return func(o *options) {
+		if n < 1 {
+			n = 1
+		}
+		o.maxConcurrent = n
+		o.concurrencyWait = wait
+	}
+}
+
+// concurrencyLimit is a semaphore limiting the calls of a function.
+// A nil concurrencyLimit doesn't limit anything.
+type concurrencyLimit struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+// newConcurrencyLimit returns the limit matching the options.
+func newConcurrencyLimit(o *options) *concurrencyLimit {
+	if o.maxConcurrent == 0 {
+		return nil
+	}
+	return &concurrencyLimit{slots: make(chan struct{}, o.maxConcurrent), wait: o.concurrencyWait}
+}
+
+// acquire takes a slot and reports if one was free in time.
+// Waiting stops early if ctx is done.
+func (l *concurrencyLimit) acquire(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	default:
+	}
+
+	if l.wait <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// release frees the slot taken by acquire.
+func (l *concurrencyLimit) release() {
+	if l != nil {
+		<-l.slots
+	}
+}
@@ -0,0 +1,59 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMaxConcurrent(t *testing.T) {
+	for _, test := range []struct {
+		Name string
+		Wait time.Duration
+		Code int
+	}{
+		{Name: "reject", Wait: 0, Code: http.StatusTooManyRequests},
+		{Name: "queue_timeout", Wait: 20 * time.Millisecond, Code: http.StatusTooManyRequests},
+		{Name: "queue", Wait: time.Second, Code: http.StatusOK},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			started := make(chan struct{})
+			release := make(chan struct{})
+			h := MustBind(func(block bool) error {
+				if block {
+					started <- struct{}{}
+					<-release
+				}
+				return nil
+			}, WithMaxConcurrent(1, test.Wait))
+
+			done := make(chan int)
+			go func() {
+				rr := httptest.NewRecorder()
+				h(rr, httptest.NewRequest("POST", "/", strings.NewReader("[true]")))
+				done <- rr.Code
+			}()
+			<-started
+
+			// the blocking call is released while the second one waits.
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				close(release)
+			}()
+
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("POST", "/", strings.NewReader("[false]")))
+
+			assert.Equal(t, test.Code, rr.Code)
+			if test.Code == http.StatusTooManyRequests {
+				assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+				assert.Contains(t, rr.Body.String(), `"type":"limit"`)
+			}
+			assert.Equal(t, http.StatusOK, <-done)
+		})
+	}
+}
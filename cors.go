@@ -0,0 +1,105 @@
+package nra
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORS configures Cross-Origin Resource Sharing, so browser apps hosted
+// on another origin can call the functions. See Registry.EnableCORS and
+// WithCORS.
+type CORS struct {
+	// AllowedOrigins are the origins that can call the functions,
+	// e.g. "https://app.example.com". "*" allows all origins.
+	AllowedOrigins []string
+
+	// AllowedHeaders are the request headers the browser may send
+	// besides Content-Type and Accept, e.g. "Authorization".
+	AllowedHeaders []string
+
+	// ExposedHeaders are the response headers the browser app
+	// may read, e.g. "ETag".
+	ExposedHeaders []string
+
+	// AllowCredentials allows requests with cookies or HTTP
+	// authentication. The origin is then always sent back
+	// explicitly, even if all origins are allowed.
+	AllowCredentials bool
+
+	// MaxAge is how long browsers may cache the result of a
+	// preflight request. 0 leaves it to the browser.
+	MaxAge time.Duration
+}
+
+// WithCORS answers preflight requests of the function and adds the
+// CORS headers to its responses. Use Registry.EnableCORS for all
+// functions of a registry.
+func WithCORS(cors CORS) Option {
+	return func(o *options) {
+		o.cors = &cors
+	}
+}
+
+// defaultCORSHeaders are the request headers that are always allowed.
+var defaultCORSHeaders = []string{"Content-Type", "Accept"}
+
+// allowedOrigin returns the value of the Access-Control-Allow-Origin
+// header for the origin, or a empty string if it isn't allowed.
+func (c *CORS) allowedOrigin(origin string) string {
+	for _, allowed := range c.AllowedOrigins {
+		switch {
+		case allowed == "*" && !c.AllowCredentials:
+			return "*"
+		case allowed == "*" || strings.EqualFold(allowed, origin):
+			return origin
+		}
+	}
+	return ""
+}
+
+// handle adds the CORS headers for the request. Preflight requests are
+// answered completely, in that case handle returns true. methods are
+// the http methods the functions can be called with.
+func (c *CORS) handle(writer http.ResponseWriter, request *http.Request, methods string) bool {
+	origin := request.Header.Get("Origin")
+	preflight := request.Method == "OPTIONS" && request.Header.Get("Access-Control-Request-Method") != ""
+	if origin == "" {
+		return false
+	}
+
+	header := writer.Header()
+	header.Add("Vary", "Origin")
+
+	allowed := c.allowedOrigin(origin)
+	if allowed == "" {
+		if preflight {
+			writeError(writer, http.StatusForbidden, &Error{Message: "origin '" + origin + "' is not allowed", Type: ErrorTypeRequest})
+		}
+		return preflight
+	}
+
+	header.Set("Access-Control-Allow-Origin", allowed)
+	if c.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if !preflight {
+		if len(c.ExposedHeaders) > 0 {
+			header.Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	header.Add("Vary", "Access-Control-Request-Method")
+	header.Add("Vary", "Access-Control-Request-Headers")
+	header.Set("Access-Control-Allow-Methods", methods)
+	header.Set("Access-Control-Allow-Headers", strings.Join(append(append([]string{}, defaultCORSHeaders...), c.AllowedHeaders...), ", "))
+	if c.MaxAge > 0 {
+		header.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+	}
+
+	writer.WriteHeader(http.StatusNoContent)
+	return true
+}
@@ -0,0 +1,95 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryCORS(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.EnableCORS(CORS{
+		AllowedOrigins:   []string{"https://app.example.com"},
+		AllowedHeaders:   []string{"Authorization"},
+		ExposedHeaders:   []string{"ETag"},
+		AllowCredentials: true,
+		MaxAge:           time.Hour,
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/rpc/add", nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "true", rec.Header().Get("Access-Control-Allow-Credentials"))
+		assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Content-Type, Accept, Authorization", rec.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "3600", rec.Header().Get("Access-Control-Max-Age"))
+		assert.Contains(t, rec.Header().Values("Vary"), "Origin")
+	})
+
+	t.Run("disallowed preflight", func(t *testing.T) {
+		req := httptest.NewRequest("OPTIONS", "/rpc/add", nil)
+		req.Header.Set("Origin", "https://evil.example.com")
+		req.Header.Set("Access-Control-Request-Method", "POST")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("call", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]"))
+		req.Header.Set("Origin", "https://app.example.com")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "3\n", rec.Body.String())
+		assert.Equal(t, "https://app.example.com", rec.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "ETag", rec.Header().Get("Access-Control-Expose-Headers"))
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Methods"))
+	})
+
+	t.Run("disallowed call", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]"))
+		req.Header.Set("Origin", "https://evil.example.com")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		// the browser hides the response without the headers.
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Empty(t, rec.Header().Get("Access-Control-Allow-Origin"))
+	})
+}
+
+func TestWithCORS(t *testing.T) {
+	h := MustBind(func(a, b int) (int, error) { return a + b, nil }, WithCORS(CORS{AllowedOrigins: []string{"*"}}), WithSafe())
+
+	req := httptest.NewRequest("OPTIONS", "/", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	rec := httptest.NewRecorder()
+	h(rec, req)
+
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "*", rec.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST", rec.Header().Get("Access-Control-Allow-Methods"))
+	assert.Empty(t, rec.Header().Get("Access-Control-Max-Age"))
+
+	// requests without a origin aren't cross origin.
+	req = httptest.NewRequest("OPTIONS", "/", nil)
+	rec = httptest.NewRecorder()
+	h(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
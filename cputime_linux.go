@@ -0,0 +1,22 @@
+//go:build linux
+// +build linux
+
+package nra
+
+import (
+	"syscall"
+	"time"
+)
+
+// rusageThread is RUSAGE_THREAD, which syscall doesn't define.
+const rusageThread = 1
+
+// threadCPUTime returns the user and system CPU time
+// the current thread used so far.
+func threadCPUTime() (time.Duration, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(rusageThread, &usage); err != nil {
+		return 0, false
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano()), true
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+// +build !linux
+
+package nra
+
+import "time"
+
+// threadCPUTime returns the CPU time of the current thread,
+// which is only supported on Linux.
+func threadCPUTime() (time.Duration, bool) {
+	return 0, false
+}
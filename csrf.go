@@ -0,0 +1,134 @@
+package nra
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRF configures the protection of a registry against cross-site
+// request forgery, see Registry.EnableCSRF.
+type CSRF struct {
+	// Header is the request header every call has to send. Pages of
+	// other origins can only send custom headers after a CORS
+	// preflight, so a forged form or request can't set it. Defaults
+	// to DefaultCSRFHeader.
+	Header string
+
+	// Cookie enables the double-submit pattern. The registry sets a
+	// random token in the cookie of that name and the header has to
+	// contain the same token. Without a cookie any value of the header
+	// is accepted.
+	Cookie string
+
+	// TrustedOrigins are the origins besides the one of the registry
+	// itself that calls can come from, e.g. "https://app.example.com".
+	TrustedOrigins []string
+}
+
+// DefaultCSRFHeader is the header calls have to send
+// if CSRF.Header isn't set.
+const DefaultCSRFHeader = "X-CSRF-Token"
+
+// EnableCSRF protects the functions of the registry against cross-site
+// request forgery, which is needed if they are authenticated by cookies.
+// Calls have to send the CSRF header and, if the browser sends a Origin
+// or Referer header, come from the origin of the registry or a trusted
+// one. Otherwise they are rejected with a forbidden error. GET calls of
+// safe functions aren't checked, as they can't change anything.
+//
+// The generated clients send the header on their own. With a cookie
+// they read the token from it, so the cookie isn't HttpOnly.
+func (r *Registry) EnableCSRF(csrf CSRF) {
+	if csrf.Header == "" {
+		csrf.Header = DefaultCSRFHeader
+	}
+	r.csrf = &csrf
+}
+
+// CSRFToken returns the double-submit token of the request and sets
+// the cookie if the request doesn't have one yet. It can be used to
+// pass the token to a page that is served outside of the registry.
+// Without CSRF protection or a cookie it returns a empty string.
+func (r *Registry) CSRFToken(writer http.ResponseWriter, request *http.Request) string {
+	if r.csrf == nil || r.csrf.Cookie == "" {
+		return ""
+	}
+
+	if cookie, err := request.Cookie(r.csrf.Cookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	var token [32]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		return ""
+	}
+
+	value := base64.RawURLEncoding.EncodeToString(token[:])
+	http.SetCookie(writer, &http.Cookie{
+		Name:     r.csrf.Cookie,
+		Value:    value,
+		Path:     "/",
+		Secure:   request.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return value
+}
+
+// checkCSRF returns the error for a call that doesn't
+// pass the CSRF protection, or nil if it does.
+func (r *Registry) checkCSRF(request *http.Request) *Error {
+	if request.Method == "GET" {
+		return nil
+	}
+
+	if origin := originOf(request); origin != "" && !r.trustedOrigin(request, origin) {
+		return &Error{Message: "csrf check failed: origin '" + origin + "' is not trusted", Type: ErrorTypeForbidden}
+	}
+
+	value := request.Header.Get(r.csrf.Header)
+	if value == "" {
+		return &Error{Message: "csrf check failed: missing " + r.csrf.Header + " header", Type: ErrorTypeForbidden}
+	}
+
+	if r.csrf.Cookie != "" {
+		cookie, err := request.Cookie(r.csrf.Cookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(value)) != 1 {
+			return &Error{Message: "csrf check failed: token doesn't match the " + r.csrf.Cookie + " cookie", Type: ErrorTypeForbidden}
+		}
+	}
+
+	return nil
+}
+
+// originOf returns the origin a browser request comes from, taken
+// from the Origin or otherwise the Referer header.
+func originOf(request *http.Request) string {
+	if origin := request.Header.Get("Origin"); origin != "" {
+		return origin
+	}
+
+	referer, err := url.Parse(request.Header.Get("Referer"))
+	if err != nil || referer.Host == "" {
+		return ""
+	}
+	return referer.Scheme + "://" + referer.Host
+}
+
+// trustedOrigin checks if the origin is the one
+// of the registry itself or a trusted one.
+func (r *Registry) trustedOrigin(request *http.Request, origin string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && strings.EqualFold(u.Host, request.Host) {
+		return true
+	}
+
+	for _, trusted := range r.csrf.TrustedOrigins {
+		if strings.EqualFold(strings.TrimSuffix(trusted, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryCSRF(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("get", func() (int, error) { return 1, nil }, WithSafe())
+	r.EnableCSRF(CSRF{TrustedOrigins: []string{"https://app.example.com"}})
+
+	tests := []struct {
+		name    string
+		method  string
+		fn      string
+		headers map[string]string
+		code    int
+	}{
+		{name: "header", method: "POST", fn: "add", headers: map[string]string{"X-CSRF-Token": "1"}, code: http.StatusOK},
+		{name: "missing header", method: "POST", fn: "add", code: http.StatusForbidden},
+		{name: "same origin", method: "POST", fn: "add", headers: map[string]string{"X-CSRF-Token": "1", "Origin": "http://example.com"}, code: http.StatusOK},
+		{name: "trusted origin", method: "POST", fn: "add", headers: map[string]string{"X-CSRF-Token": "1", "Origin": "https://app.example.com"}, code: http.StatusOK},
+		{name: "foreign origin", method: "POST", fn: "add", headers: map[string]string{"X-CSRF-Token": "1", "Origin": "https://evil.example.com"}, code: http.StatusForbidden},
+		{name: "foreign referer", method: "POST", fn: "add", headers: map[string]string{"X-CSRF-Token": "1", "Referer": "https://evil.example.com/page"}, code: http.StatusForbidden},
+		{name: "safe get", method: "GET", fn: "get", code: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/rpc/"+test.fn, strings.NewReader("[1, 2]"))
+			for k, v := range test.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			if test.code == http.StatusForbidden {
+				assert.Contains(t, rec.Body.String(), `"type":"forbidden"`)
+			}
+		})
+	}
+}
+
+func TestRegistryCSRFCookie(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.EnableCSRF(CSRF{Cookie: "csrf"})
+
+	// the first response hands out the token.
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]")))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	cookies := rec.Result().Cookies()
+	if assert.Len(t, cookies, 1) {
+		assert.Equal(t, "csrf", cookies[0].Name)
+		assert.False(t, cookies[0].HttpOnly)
+	}
+	token := cookies[0].Value
+
+	call := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]"))
+		req.AddCookie(cookies[0])
+		req.Header.Set("X-CSRF-Token", header)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec = call(token)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Result().Cookies())
+
+	rec = call("wrong")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "doesn't match the csrf cookie")
+}
+
+func TestCSRFClients(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.EnableCSRF(CSRF{Cookie: "csrf"})
+
+	var js bytes.Buffer
+	assert.NoError(t, GenerateJavaScript(&js, r))
+	assert.Contains(t, js.String(), `var csrf = {"cookie":"csrf","header":"X-CSRF-Token"};`)
+
+	var ts bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&ts, r))
+	assert.Contains(t, ts.String(), `csrfHeader: "X-CSRF-Token",`)
+	assert.Contains(t, ts.String(), `csrfCookie: "csrf",`)
+}
@@ -0,0 +1,218 @@
+package nra
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+// argDecoder converts a generically decoded argument to the type it
+// was created for. The index is the 1-based index used in errors.
+//
+// All inspection of the target type happens when the decoder is
+// created, so a call only has to look at the argument itself.
+type argDecoder func(index int, arg interface{}) (reflect.Value, *Error)
+
+// decoderCache caches the argDecoder of each type.
+var decoderCache sync.Map
+
+// decoderOf returns the argDecoder for the target type.
+func decoderOf(target reflect.Type) argDecoder {
+	if d, ok := decoderCache.Load(target); ok {
+		return d.(argDecoder)
+	}
+
+	d, _ := decoderCache.LoadOrStore(target, newArgDecoder(target))
+	return d.(argDecoder)
+}
+
+// decodeHook is used by mapstructure for structs and slices.
+var decodeHook = mapstructure.ComposeDecodeHookFunc(defaultsHook, base64Hook, timeHook)
+
+// valueDecoder converts a argument that isn't null and
+// doesn't have the target type already.
+type valueDecoder func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error)
+
+// newArgDecoder creates the argDecoder for the target type.
+func newArgDecoder(target reflect.Type) argDecoder {
+	// check if the argument in fn can be nil. if it
+	// can be a null argument gets a nil value for the type.
+	nullable := false
+	switch target.Kind() {
+	case reflect.Ptr, reflect.Uintptr, reflect.Map, reflect.Array, reflect.Slice:
+		nullable = true
+	}
+
+	var convert valueDecoder
+	if target.Kind() == reflect.Ptr {
+		convert = pointerDecoder(target)
+	} else {
+		convert = plainDecoder(target)
+		if target.Kind() == reflect.Struct || target.Kind() == reflect.Slice || target.Kind() == reflect.Map {
+			convert = mappedDecoder(target, convert)
+		}
+		if isBytes(target) {
+			convert = bytesDecoder(target, convert)
+		}
+		if target == timeType || target == durationType {
+			convert = timeDecoder(target, convert)
+		}
+	}
+
+	return func(index int, arg interface{}) (reflect.Value, *Error) {
+		argType := reflect.TypeOf(arg)
+
+		// arguments that were decoded into the target type directly,
+		// like protobuf messages, don't need any conversion.
+		if argType == target {
+			return reflect.ValueOf(arg), nil
+		}
+
+		// check if the argument was null on the javascript side.
+		if argType == nil {
+			if nullable {
+				return reflect.Zero(target), nil
+			}
+
+			// otherwise we return a error because the argument couldn't
+			// be a nil value.
+			return reflect.Value{}, &Error{Message: fmt.Sprintf("%d. can't be null", index), Type: ErrorTypeValidation, Argument: index}
+		}
+
+		return convert(index, arg, argType)
+	}
+}
+
+// pointerDecoder gives a pointer argument that isn't null a
+// pointer to the argument converted to the type it points to.
+func pointerDecoder(target reflect.Type) valueDecoder {
+	elem := target.Elem()
+	return func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error) {
+		value, err := decoderOf(elem)(index, arg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+
+		p := reflect.New(elem)
+		p.Elem().Set(value)
+		return p, nil
+	}
+}
+
+// timeDecoder converts times and durations passed as strings.
+func timeDecoder(target reflect.Type, next valueDecoder) valueDecoder {
+	return func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error) {
+		value, ok, err := convertTime(arg, target)
+		if !ok {
+			return next(index, arg, argType)
+		}
+		if err != nil {
+			return reflect.Value{}, wrapConversionError(index, arg, target, err)
+		}
+		return reflect.ValueOf(value), nil
+	}
+}
+
+// bytesDecoder converts binary data that is passed as base64
+// encoded string, the same way encoding/json encodes []byte results.
+func bytesDecoder(target reflect.Type, next valueDecoder) valueDecoder {
+	return func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error) {
+		if argType.Kind() != reflect.String {
+			return next(index, arg, argType)
+		}
+
+		data, err := base64.StdEncoding.DecodeString(arg.(string))
+		if err != nil {
+			return reflect.Value{}, wrapConversionError(index, arg, target, err)
+		}
+		return reflect.ValueOf(data).Convert(target), nil
+	}
+}
+
+// mappedDecoder converts objects to structs and maps and arrays to slices.
+//
+// if our target argument of the fn function is a struct and
+// the argument on the javascript side was a object the decoded
+// argument will always be the type map[string]interface{}.
+//
+// we can dynamically create the struct we want and decode the
+// map[string]interface{} to the struct with the help of the
+// mapstructure package.
+//
+// same works with converting a javascript array to a golang
+// slice.
+func mappedDecoder(target reflect.Type, next valueDecoder) valueDecoder {
+	from := reflect.Map
+	if target.Kind() == reflect.Slice {
+		from = reflect.Slice
+	}
+
+	// the decoder honors the json tags. Only the result
+	// differs between calls.
+	config := mapstructure.DecoderConfig{
+		TagName:    "json",
+		DecodeHook: decodeHook,
+	}
+
+	return func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error) {
+		if argType.Kind() != from {
+			return next(index, arg, argType)
+		}
+
+		s := reflect.New(target)
+
+		config := config
+		config.Result = s.Interface()
+		decoder, err := mapstructure.NewDecoder(&config)
+		if err != nil {
+			return reflect.Value{}, &Error{Message: fmt.Sprintf("error while creating decoder: %v", err), Type: ErrorTypeConversion, Argument: index}
+		}
+
+		if err := decoder.Decode(arg); err != nil {
+			return reflect.Value{}, wrapConversionError(index, arg, target, err)
+		}
+
+		return s.Elem(), nil
+	}
+}
+
+// plainDecoder converts arguments that are already of the kind of
+// the target type. Numbers are converted to the target number type.
+func plainDecoder(target reflect.Type) valueDecoder {
+	number := isNumber(target.Kind())
+	return func(index int, arg interface{}, argType reflect.Type) (reflect.Value, *Error) {
+		// check if the argument types mismatch.
+		if target.Kind() != argType.Kind() {
+			// numbers that are generically decoded from JSON will
+			// always be float64, MessagePack keeps integers as integers.
+			// In case fn wants some other number type we can just
+			// convert it to the target type.
+			if number && isNumber(argType.Kind()) {
+				return reflect.ValueOf(arg).Convert(target), nil
+			}
+
+			// otherwise we return a error as no conversion was applicable.
+			return reflect.Value{}, conversionError(index, arg, target)
+		}
+
+		// arguments of the same kind but another type, e.g. a object
+		// for a map of other values, can't be passed as they are.
+		if !argType.AssignableTo(target) {
+			return reflect.Value{}, conversionError(index, arg, target)
+		}
+
+		// otherwise the arguments have the same type so no conversion is needed.
+		return reflect.ValueOf(arg), nil
+	}
+}
+
+// wrapConversionError is conversionError with the reason why
+// the conversion failed added to the message.
+func wrapConversionError(index int, arg interface{}, target reflect.Type, err error) *Error {
+	e := conversionError(index, arg, target)
+	e.Message = fmt.Sprintf("can't convert %d. argument to %s: %v", index, e.Expected, err)
+	return e
+}
@@ -0,0 +1,62 @@
+package nra
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecoderOf(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+	}
+
+	x := 5
+	tests := []struct {
+		Name     string
+		Target   interface{}
+		Arg      interface{}
+		Expected interface{}
+		Error    string
+	}{
+		{Name: "same_type", Target: "", Arg: "abc", Expected: "abc"},
+		{Name: "number", Target: int16(0), Arg: 12.0, Expected: int16(12)},
+		{Name: "null_slice", Target: []int(nil), Arg: nil, Expected: []int(nil)},
+		{Name: "null_int", Target: 0, Arg: nil, Error: "1. can't be null"},
+		{Name: "pointer", Target: (*int)(nil), Arg: 5.0, Expected: &x},
+		{Name: "struct", Target: point{}, Arg: map[string]interface{}{"x": 3.0}, Expected: point{X: 3}},
+		{Name: "struct_pointer", Target: (*point)(nil), Arg: map[string]interface{}{"x": 3.0}, Expected: &point{X: 3}},
+		{Name: "null_struct_pointer", Target: (*point)(nil), Arg: nil, Expected: (*point)(nil)},
+		{Name: "struct_pointer_slice", Target: []*point(nil), Arg: []interface{}{map[string]interface{}{"x": 1.0}, nil}, Expected: []*point{{X: 1}, nil}},
+		{Name: "struct_pointer_mismatch", Target: (*point)(nil), Arg: "abc", Error: "mismatching argument type of 1. argument"},
+		{Name: "slice", Target: []int(nil), Arg: []interface{}{1.0, 2.0}, Expected: []int{1, 2}},
+		{Name: "bytes", Target: []byte(nil), Arg: "aGk=", Expected: []byte("hi")},
+		{Name: "duration", Target: time.Duration(0), Arg: "2s", Expected: 2 * time.Second},
+		{Name: "duration_number", Target: time.Duration(0), Arg: 5.0, Expected: time.Duration(5)},
+		{Name: "mismatch", Target: 0, Arg: "abc", Error: "mismatching argument type of 1. argument"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			value, err := decoderOf(reflect.TypeOf(test.Target))(1, test.Arg)
+			if test.Error != "" {
+				if assert.NotNil(t, err) {
+					assert.Contains(t, err.Message, test.Error)
+				}
+				return
+			}
+
+			if assert.Nil(t, err) {
+				assert.Equal(t, test.Expected, value.Interface())
+			}
+		})
+	}
+}
+
+func TestDecoderOfCached(t *testing.T) {
+	a := decoderOf(reflect.TypeOf(0))
+	b := decoderOf(reflect.TypeOf(0))
+	assert.Equal(t, reflect.ValueOf(a).Pointer(), reflect.ValueOf(b).Pointer())
+}
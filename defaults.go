@@ -0,0 +1,135 @@
+package nra
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// defaultTag is the struct tag holding the value a field gets
+// if it is missing in the JSON object passed as argument:
+//
+//	type Search struct {
+//	  Text  string `json:"text"`
+//	  Limit int    `json:"limit" default:"50"`
+//	}
+const defaultTag = "default"
+
+// defaultOf returns the default value of the struct field in its
+// generic JSON form. Defaults of string fields are taken as they
+// are, all others have to be valid JSON.
+func defaultOf(f reflect.StructField) (interface{}, bool, error) {
+	tag, ok := f.Tag.Lookup(defaultTag)
+	if !ok {
+		return nil, false, nil
+	}
+
+	t := f.Type
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.String {
+		return tag, true, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(tag), &value); err != nil {
+		return nil, true, err
+	}
+	return value, true, nil
+}
+
+// checkDefaults checks that all default tags reachable from
+// t can be decoded into the field they belong to.
+func checkDefaults(t reflect.Type, seen map[reflect.Type]bool) error {
+	if seen[t] {
+		return nil
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return checkDefaults(t.Elem(), seen)
+	case reflect.Struct:
+		for _, f := range jsonFields(t) {
+			value, ok, err := defaultOf(f.field)
+			if ok && err == nil {
+				data, _ := json.Marshal(value)
+				err = json.Unmarshal(data, reflect.New(f.typ).Interface())
+			}
+			if err != nil {
+				return fmt.Errorf("invalid default of %s.%s: %v", t.String(), f.field.Name, err)
+			}
+
+			if err := checkDefaults(f.typ, seen); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defaultsHook is a mapstructure decode hook that adds the
+// defaults of all missing fields before a object is decoded
+// into a struct. As the hook runs for every nested object
+// the defaults also apply to structs in slices or maps.
+func defaultsHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to.Kind() != reflect.Struct || from.Kind() != reflect.Map {
+		return data, nil
+	}
+
+	object, ok := data.(map[string]interface{})
+	if !ok {
+		return data, nil
+	}
+
+	var filled map[string]interface{}
+	for _, f := range jsonFields(to) {
+		if hasKey(object, f.name) {
+			continue
+		}
+
+		value, ok, err := defaultOf(f.field)
+		if err != nil {
+			continue
+		}
+
+		// a missing nested struct is decoded from a empty
+		// object so the defaults of its fields apply.
+		if !ok && f.typ.Kind() == reflect.Struct && f.typ != timeType {
+			value, ok = map[string]interface{}{}, true
+		}
+		if !ok {
+			continue
+		}
+
+		// copy the object so the decoded arguments stay untouched.
+		if filled == nil {
+			filled = make(map[string]interface{}, len(object)+1)
+			for k, v := range object {
+				filled[k] = v
+			}
+		}
+		filled[f.name] = value
+	}
+
+	if filled == nil {
+		return data, nil
+	}
+	return filled, nil
+}
+
+// hasKey checks if the object has the key the same way
+// mapstructure matches keys to fields.
+func hasKey(object map[string]interface{}, key string) bool {
+	if _, ok := object[key]; ok {
+		return true
+	}
+	for k := range object {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type defaultsPage struct {
+	Size  int    `json:"size" default:"20"`
+	Order string `json:"order" default:"asc"`
+}
+
+type defaultsSearch struct {
+	Text   string         `json:"text"`
+	Exact  bool           `json:"exact" default:"true"`
+	Fields []string       `json:"fields" default:"[\"title\"]"`
+	Page   defaultsPage   `json:"page"`
+	Pages  []defaultsPage `json:"pages"`
+}
+
+func TestBindDefaults(t *testing.T) {
+	h := MustBind(func(s defaultsSearch) (defaultsSearch, error) { return s, nil })
+
+	for _, c := range []struct {
+		input    string
+		expected string
+	}{
+		{`[{"text": "a"}]`, `{"text": "a", "exact": true, "fields": ["title"], "page": {"size": 20, "order": "asc"}, "pages": null}`},
+		{`[{"text": "a", "exact": false, "page": {"size": 5}}]`, `{"text": "a", "exact": false, "fields": ["title"], "page": {"size": 5, "order": "asc"}, "pages": null}`},
+		{`[{"pages": [{"order": "desc"}]}]`, `{"text": "", "exact": true, "fields": ["title"], "page": {"size": 20, "order": "asc"}, "pages": [{"size": 20, "order": "desc"}]}`},
+	} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(c.input)))
+		if assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+			assert.JSONEq(t, c.expected, rr.Body.String(), c.input)
+		}
+	}
+}
+
+func TestBindInvalidDefault(t *testing.T) {
+	_, err := Bind(func(s struct {
+		Limit int `default:"many"`
+	}) error {
+		return nil
+	})
+	assert.EqualError(t, err, "invalid default of struct { Limit int \"default:\\\"many\\\"\" }.Limit: invalid character 'm' looking for beginning of value")
+
+	_, err = Bind(func(s []struct {
+		Limit int `default:"\"5\""`
+	}) error {
+		return nil
+	})
+	assert.Error(t, err)
+}
+
+func TestDefaultsInClients(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("search", func(s defaultsSearch) error { return nil })
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, GenerateTypeScript(&buf, r)) {
+		return
+	}
+	assert.Contains(t, buf.String(), `export interface defaultsPage {
+  /** @default 20 */
+  size: number;
+  /** @default "asc" */
+  order: string;
+}
+`)
+
+	g := newSchemaGenerator("#/")
+	g.schemaOf(reflect.TypeOf(defaultsSearch{}))
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"size": {"type": "integer", "format": "int32", "default": 20},
+			"order": {"type": "string", "default": "asc"}
+		},
+		"required": ["size", "order"]
+	}`, mustJSON(g.defs["defaultsPage"]))
+}
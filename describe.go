@@ -0,0 +1,229 @@
+package nra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// DescribeFormat is the output format of Registry.Describe.
+type DescribeFormat int
+
+const (
+	// DescribeTable is a table meant to be read by humans,
+	// e.g. printed once when the server starts.
+	DescribeTable DescribeFormat = iota
+
+	// DescribeJSON is the Description encoded as JSON,
+	// e.g. to compare the registry against a snapshot.
+	DescribeJSON
+)
+
+// Description is everything a registry serves, see Registry.Description.
+type Description struct {
+	// Prefix is the path prefix clients call the functions under.
+	Prefix string `json:"prefix"`
+
+	// Endpoints are the paths of the enabled endpoints besides
+	// the functions, e.g. the Javascript client.
+	Endpoints []string `json:"endpoints"`
+
+	// Codecs are the MIME types calls can be encoded with.
+	Codecs []string `json:"codecs"`
+
+	// Middleware are the features that apply to all calls,
+	// e.g. "cors" or "rate limit".
+	Middleware []string `json:"middleware"`
+
+	// Functions describes the registered functions sorted by name.
+	Functions []FunctionDescription `json:"functions"`
+}
+
+// FunctionDescription describes a registered function
+// and how it is called.
+type FunctionDescription struct {
+	Method
+
+	// HTTPMethods are the http methods the function
+	// can be called with.
+	HTTPMethods []string `json:"http_methods"`
+
+	// Transport is how the function is executed, one of "direct",
+	// "static", "locked thread", "thread pool" or "subprocess".
+	Transport string `json:"transport"`
+
+	// Options are the options the function was bound
+	// with, e.g. "timeout 2s" or "native".
+	Options []string `json:"options"`
+}
+
+// Description returns what the registry serves.
+func (r *Registry) Description() Description {
+	prefix := r.PublicPrefix()
+
+	d := Description{Prefix: prefix, Endpoints: []string{}, Middleware: []string{}, Functions: []FunctionDescription{}}
+	if r.clientJS {
+		d.Endpoints = append(d.Endpoints, prefix+ClientJSName)
+	}
+	if r.openAPI != nil {
+		d.Endpoints = append(d.Endpoints, prefix+OpenAPIName)
+	}
+	if r.introspection {
+		d.Endpoints = append(d.Endpoints, prefix+MethodsName)
+	}
+	if r.jsonSchemas {
+		d.Endpoints = append(d.Endpoints, prefix+JSONSchemaName)
+	}
+	if len(r.encryptionKeys) > 0 {
+		d.Endpoints = append(d.Endpoints, prefix+EncryptionKeyName)
+	}
+	if r.healthEnabled() {
+		d.Endpoints = append(d.Endpoints, prefix+HealthName, prefix+ReadyName)
+	}
+
+	codecsMtx.RLock()
+	for mimeType := range codecs {
+		d.Codecs = append(d.Codecs, mimeType)
+	}
+	codecsMtx.RUnlock()
+	sort.Strings(d.Codecs)
+
+	middleware := []struct {
+		name    string
+		enabled bool
+	}{
+		{"access log", r.accessLog != nil},
+		{"audit", r.audit != nil},
+		{"authentication", r.authenticator != nil},
+		{"cors", r.cors != nil},
+		{"csrf", r.csrf != nil},
+		{"load shedding", r.shedder != nil},
+		{"logging", r.logger != nil},
+		{"observers", len(r.observers) > 0},
+		{"rate limit", r.rateLimit != nil},
+		{"request ids", r.requestIDs},
+		{"sessions", r.sessions != nil},
+		{"body transforms", len(r.transforms) > 0},
+		{"max body " + strconv.FormatInt(r.maxBodyBytes, 10) + " bytes", r.maxBodyBytes > 0},
+		{"forwarded prefix", r.trustForwardedPrefix},
+		{"compression", r.compression > 0},
+		{"encryption", len(r.encryptionKeys) > 0},
+		{"recording", r.recorder != nil},
+		{"replay", r.replayer != nil},
+	}
+	for _, m := range middleware {
+		if m.enabled {
+			d.Middleware = append(d.Middleware, m.name)
+		}
+	}
+
+	names, functions := r.snapshot()
+	for _, m := range r.methodsFrom(prefix, names, functions) {
+		d.Functions = append(d.Functions, describeFunction(m, functions[m.Name].options))
+	}
+	return d
+}
+
+// describeFunction adds how the function is called to its method.
+func describeFunction(m Method, o *options) FunctionDescription {
+	f := FunctionDescription{Method: m, HTTPMethods: []string{"POST"}, Transport: "direct", Options: []string{}}
+	if o.safe {
+		f.HTTPMethods = []string{"GET", "POST"}
+	}
+
+	switch {
+	case o.subprocess:
+		f.Transport = "subprocess"
+	case o.threadPool > 0:
+		f.Transport = "thread pool"
+	case o.lockOSThread:
+		f.Transport = "locked thread"
+	case o.static != nil:
+		f.Transport = "static"
+	}
+
+	add := func(enabled bool, format string, args ...interface{}) {
+		if enabled {
+			f.Options = append(f.Options, fmt.Sprintf(format, args...))
+		}
+	}
+	add(o.idempotent, "idempotent")
+	add(o.safe, "safe")
+	add(o.native, "native")
+	add(o.sandbox != nil, "sandbox")
+	add(o.threadPool > 0, "threads %d", o.threadPool)
+	add(o.timeout > 0, "timeout %s", o.timeout)
+	add(o.maxConcurrent > 0, "max concurrent %d", o.maxConcurrent)
+	add(o.memoryBudget > 0, "memory budget %d bytes", o.memoryBudget)
+	add(o.maxBodyBytes > 0, "max body %d bytes", o.maxBodyBytes)
+	add(o.compression > 0, "compression")
+	add(o.cacheTTL > 0, "cache %s", o.cacheTTL)
+	add(len(o.dependsOn) > 0, "depends on %s", strings.Join(o.dependsOn, ", "))
+	add(o.rateLimit != nil, "rate limit")
+	add(o.priority < PriorityNormal, "priority low")
+	add(o.priority > PriorityNormal, "priority high")
+	add(o.cors != nil, "cors")
+	add(o.authenticator == Anonymous, "anonymous")
+	add(o.authenticator != nil && o.authenticator != Anonymous, "authentication")
+	add(len(o.middleware) > 0, "middleware %d", len(o.middleware))
+	add(len(m.Versions) > 0, "versions %s", strings.Trim(fmt.Sprint(m.Versions), "[]"))
+	add(o.deprecated, "deprecated")
+	add(o.idempotencyKey, "idempotency key")
+	add(o.schemaValidation, "schema validation")
+	return f
+}
+
+// Describe writes what the registry serves to w, so misregistrations
+// are noticed as soon as the server starts:
+//
+//	r.Describe(os.Stdout, nra.DescribeTable)
+//
+// DescribeTable prints a table with a line per function, DescribeJSON
+// writes the result of Description.
+func (r *Registry) Describe(w io.Writer, format DescribeFormat) error {
+	d := r.Description()
+
+	switch format {
+	case DescribeJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(d)
+	case DescribeTable:
+	default:
+		return errors.New("unknown describe format")
+	}
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "nra registry at %s with %d functions\n", d.Prefix, len(d.Functions))
+	if len(d.Endpoints) > 0 {
+		fmt.Fprintf(&buf, "endpoints:  %s\n", strings.Join(d.Endpoints, ", "))
+	}
+	fmt.Fprintf(&buf, "codecs:     %s\n", strings.Join(d.Codecs, ", "))
+	if len(d.Middleware) > 0 {
+		fmt.Fprintf(&buf, "middleware: %s\n", strings.Join(d.Middleware, ", "))
+	}
+	buf.WriteString("\n")
+	if _, err := io.WriteString(w, buf.String()); err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tPATH\tPARAMS\tRESULT\tTRANSPORT\tOPTIONS")
+	for _, f := range d.Functions {
+		result := f.Result
+		if result == "" {
+			result = "-"
+		}
+		options := strings.Join(f.Options, ", ")
+		if options == "" {
+			options = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\t%s\t%s\t%s\n", strings.Join(f.HTTPMethods, ","), f.Path, strings.Join(f.Params, ", "), result, f.Transport, options)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,52 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDescribeRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil }, WithSafe(), WithTimeout(2*time.Second))
+	r.MustRegister("users.delete", func(id string) error { return nil }, WithPriority(PriorityLow), WithMaxConcurrent(4, 0))
+	r.EnableClientJS()
+	r.EnableCORS(CORS{AllowedOrigins: []string{"*"}})
+	return r
+}
+
+func TestRegistryDescribeTable(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testDescribeRegistry().Describe(&buf, DescribeTable))
+
+	lines := strings.Split(buf.String(), "\n")
+	assert.Equal(t, "nra registry at /rpc/ with 2 functions", lines[0])
+	assert.Equal(t, "endpoints:  /rpc/client.js", lines[1])
+	assert.Contains(t, buf.String(), "middleware: cors\n")
+	assert.Contains(t, buf.String(), "METHOD    PATH               PARAMS      RESULT  TRANSPORT  OPTIONS\n")
+	assert.Contains(t, buf.String(), "GET,POST  /rpc/add           (int, int)  int     direct     idempotent, safe, timeout 2s\n")
+	assert.Contains(t, buf.String(), "POST      /rpc/users.delete  (string)    -       direct     max concurrent 4, priority low\n")
+}
+
+func TestRegistryDescribeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, testDescribeRegistry().Describe(&buf, DescribeJSON))
+
+	var d Description
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &d))
+	assert.Equal(t, "/rpc/", d.Prefix)
+	assert.Contains(t, d.Codecs, "application/json")
+	assert.Equal(t, []string{"cors"}, d.Middleware)
+	if assert.Len(t, d.Functions, 2) {
+		assert.Equal(t, "add", d.Functions[0].Name)
+		assert.Equal(t, []string{"GET", "POST"}, d.Functions[0].HTTPMethods)
+		assert.Equal(t, "direct", d.Functions[0].Transport)
+		assert.Equal(t, []string{"max concurrent 4", "priority low"}, d.Functions[1].Options)
+	}
+
+	assert.Error(t, NewRegistry().Describe(&buf, DescribeFormat(42)))
+}
@@ -0,0 +1,290 @@
+package nra
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// EncryptionKeyName is the name under which the public key for
+// encrypted fields is served, see Registry.SetEncryptionKeys.
+const EncryptionKeyName = "_key"
+
+// EncryptionAlgorithm is how the clients encrypt fields: the value is
+// encrypted with a new AES-256-GCM key, which is encrypted with the
+// RSA-OAEP SHA-256 public key of the registry.
+const EncryptionAlgorithm = "RSA-OAEP-256+A256GCM"
+
+// encryptTag marks struct fields that the clients encrypt:
+//
+//	type Login struct {
+//	  User     string `json:"user"`
+//	  Password string `json:"password" encrypt:"true"`
+//	}
+const encryptTag = "encrypt"
+
+// encryptedPrefix starts all encrypted values, followed by the
+// encrypted AES key, the nonce and the ciphertext in base64.
+const encryptedPrefix = "enc1:"
+
+// encryptedPath is the way to a encrypted field inside of a argument.
+// The fields are the json names on the way, "*" stands for all
+// elements of a slice or map.
+type encryptedPath struct {
+	arg    int
+	fields []string
+}
+
+// isEncrypted reports if the struct field is marked as encrypted.
+func isEncrypted(f reflect.StructField) bool {
+	return f.Tag.Get(encryptTag) == "true"
+}
+
+// encryptedPaths returns the paths of all encrypted fields
+// reachable from t. Recursive types are only followed once.
+func encryptedPaths(t reflect.Type, path []string, visiting map[reflect.Type]bool) [][]string {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return encryptedPaths(t.Elem(), path, visiting)
+	case reflect.Slice, reflect.Array, reflect.Map:
+		if isBytes(t) {
+			return nil
+		}
+		return encryptedPaths(t.Elem(), append(path[:len(path):len(path)], "*"), visiting)
+	case reflect.Struct:
+		if visiting[t] {
+			return nil
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		var paths [][]string
+		for _, f := range jsonFields(t) {
+			p := append(path[:len(path):len(path)], f.name)
+			if isEncrypted(f.field) {
+				paths = append(paths, p)
+				continue
+			}
+			paths = append(paths, encryptedPaths(f.typ, p, visiting)...)
+		}
+		return paths
+	}
+	return nil
+}
+
+// encryptedFieldsOf returns the paths of the encrypted fields in
+// the parameters of the binding.
+func encryptedFieldsOf(b *binding) ([]encryptedPath, error) {
+	var paths []encryptedPath
+	for i, p := range b.params {
+		fields := encryptedPaths(p, nil, map[reflect.Type]bool{})
+		if len(fields) > 0 && b.variadic && i == len(b.params)-1 {
+			return nil, errors.New("encrypted fields can't be used in variadic parameters")
+		}
+		for _, f := range fields {
+			paths = append(paths, encryptedPath{arg: i, fields: f})
+		}
+	}
+	return paths, nil
+}
+
+// clientPaths returns the paths in the form the generated
+// clients use, with the index of the argument first.
+func clientPaths(paths []encryptedPath) [][]interface{} {
+	out := make([][]interface{}, 0, len(paths))
+	for _, p := range paths {
+		path := []interface{}{p.arg}
+		for _, f := range p.fields {
+			path = append(path, f)
+		}
+		out = append(out, path)
+	}
+	return out
+}
+
+// SetEncryptionKeys makes the generated clients encrypt the struct
+// fields tagged with encrypt:"true" with the public key of the first
+// key, so secrets like passwords can't be read by proxies or logging
+// in between. Fields are decrypted right before the function is
+// called, so observers and the audit trail only see the encrypted
+// values. Fields that aren't encrypted are rejected.
+//
+// The public key is served under EncryptionKeyName, all keys are
+// tried for decryption, so a new key can be put first while clients
+// with the old one are still around. Functions with encrypted fields
+// can't be called if no key is set.
+func (r *Registry) SetEncryptionKeys(keys ...*rsa.PrivateKey) {
+	r.encryptionKeys = keys
+}
+
+// encryptionKeysKey is the context key of the encryption keys.
+type encryptionKeysKey struct{}
+
+// withEncryptionKeys makes the keys of the registry available to the call.
+func (r *Registry) withEncryptionKeys(request *http.Request) *http.Request {
+	return request.WithContext(context.WithValue(request.Context(), encryptionKeysKey{}, r.encryptionKeys))
+}
+
+// publicKey returns the public key the clients encrypt
+// with in base64 encoded DER form.
+func (r *Registry) publicKey() (string, error) {
+	if len(r.encryptionKeys) == 0 {
+		return r.importedKey, nil
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&r.encryptionKeys[0].PublicKey)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(der), nil
+}
+
+// serveEncryptionKey writes the public key as response.
+func (r *Registry) serveEncryptionKey(writer http.ResponseWriter, request *http.Request) {
+	key, err := r.publicKey()
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: err.Error(), Type: ErrorTypeInternal})
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(map[string]string{"algorithm": EncryptionAlgorithm, "key": key})
+}
+
+// decryptArgs returns the arguments with all encrypted fields
+// decrypted. The decoded arguments stay untouched, so everything
+// that saw them before still only has the encrypted values.
+func decryptArgs(request *http.Request, args []interface{}, paths []encryptedPath) ([]interface{}, *Error) {
+	keys, _ := request.Context().Value(encryptionKeysKey{}).([]*rsa.PrivateKey)
+	if len(keys) == 0 {
+		return nil, &Error{Message: "no key to decrypt the arguments", Type: ErrorTypeInternal}
+	}
+
+	args = append([]interface{}(nil), args...)
+	for _, p := range paths {
+		if p.arg >= len(args) {
+			continue
+		}
+
+		value, err := decryptAt(keys, args[p.arg], p.fields)
+		if err != nil {
+			return nil, &Error{Message: fmt.Sprintf("%d. argument: field '%s' %s", p.arg+1, strings.Join(p.fields, "."), err.Error()), Type: ErrorTypeValidation, Argument: p.arg + 1}
+		}
+		args[p.arg] = value
+	}
+	return args, nil
+}
+
+// decryptAt decrypts the value at path inside of value. Objects and
+// arrays on the way are copied. Keys are matched the same way as
+// they are when decoded into structs.
+func decryptAt(keys []*rsa.PrivateKey, value interface{}, path []string) (interface{}, error) {
+	if value == nil {
+		return nil, nil
+	}
+	if len(path) == 0 {
+		return decryptValue(keys, value)
+	}
+
+	switch v := value.(type) {
+	case []interface{}:
+		if path[0] != "*" {
+			return value, nil
+		}
+
+		copied := make([]interface{}, len(v))
+		for i := range v {
+			decrypted, err := decryptAt(keys, v[i], path[1:])
+			if err != nil {
+				return nil, err
+			}
+			copied[i] = decrypted
+		}
+		return copied, nil
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, field := range v {
+			if path[0] == "*" || strings.EqualFold(k, path[0]) {
+				decrypted, err := decryptAt(keys, field, path[1:])
+				if err != nil {
+					return nil, err
+				}
+				field = decrypted
+			}
+			copied[k] = field
+		}
+		return copied, nil
+	}
+	return value, nil
+}
+
+// decryptValue decrypts a single encrypted value and
+// decodes the JSON it contains.
+func decryptValue(keys []*rsa.PrivateKey, value interface{}) (interface{}, error) {
+	s, ok := value.(string)
+	if !ok || !strings.HasPrefix(s, encryptedPrefix) {
+		return nil, errors.New("has to be encrypted")
+	}
+
+	parts := strings.Split(strings.TrimPrefix(s, encryptedPrefix), ":")
+	if len(parts) != 3 {
+		return nil, errors.New("isn't encrypted correctly")
+	}
+
+	var decoded [3][]byte
+	for i, p := range parts {
+		var err error
+		if decoded[i], err = base64.StdEncoding.DecodeString(p); err != nil {
+			return nil, errors.New("isn't encrypted correctly")
+		}
+	}
+
+	var plain []byte
+	var err error
+	for _, key := range keys {
+		if plain, err = decryptWith(key, decoded[0], decoded[1], decoded[2]); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, errors.New("can't be decrypted")
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(plain, &result); err != nil {
+		return nil, errors.New("doesn't contain JSON")
+	}
+	return result, nil
+}
+
+// decryptWith decrypts the AES key with the RSA key
+// and the ciphertext with the AES key.
+func decryptWith(key *rsa.PrivateKey, encryptedKey, nonce, ciphertext []byte) ([]byte, error) {
+	aesKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, encryptedKey, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(aesKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("invalid nonce")
+	}
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
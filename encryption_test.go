@@ -0,0 +1,135 @@
+package nra
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type secretLogin struct {
+	User     string        `json:"user"`
+	Password string        `json:"password" encrypt:"true"`
+	Backup   *secretLogin  `json:"backup,omitempty"`
+	Keys     []secretValue `json:"keys"`
+}
+
+type secretValue struct {
+	Value map[string]int `json:"value" encrypt:"true"`
+}
+
+// encryptForTest encrypts value the same way the clients do.
+func encryptForTest(t *testing.T, key *rsa.PublicKey, value interface{}) string {
+	data, err := json.Marshal(value)
+	assert.NoError(t, err)
+
+	aesKey := make([]byte, 32)
+	nonce := make([]byte, 12)
+	_, _ = rand.Read(aesKey)
+	_, _ = rand.Read(nonce)
+
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, key, aesKey, nil)
+	assert.NoError(t, err)
+
+	block, _ := aes.NewCipher(aesKey)
+	gcm, _ := cipher.NewGCM(block)
+	ciphertext := gcm.Seal(nil, nonce, data, nil)
+
+	enc := base64.StdEncoding.EncodeToString
+	return encryptedPrefix + enc(encryptedKey) + ":" + enc(nonce) + ":" + enc(ciphertext)
+}
+
+func TestEncryptedPaths(t *testing.T) {
+	paths := encryptedPaths(reflect.TypeOf(secretLogin{}), nil, map[reflect.Type]bool{})
+	assert.Equal(t, [][]string{{"password"}, {"keys", "*", "value"}}, paths)
+
+	_, err := Bind(func(l secretLogin) error { return nil })
+	assert.EqualError(t, err, "encrypted fields can only be used with a Registry")
+
+	err = NewRegistry().Register("login", func(l ...secretLogin) error { return nil })
+	assert.EqualError(t, err, "encrypted fields can't be used in variadic parameters")
+}
+
+func TestRegistryEncryption(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	var audit bytes.Buffer
+	var got secretLogin
+	r := NewRegistry()
+	r.EnableAudit(Audit{Sink: NewAuditWriter(&audit), IncludeArgs: true})
+	r.MustRegister("login", func(l secretLogin) error {
+		got = l
+		return nil
+	})
+
+	call := func(args ...interface{}) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(args)
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/login", bytes.NewReader(body)))
+		return rr
+	}
+
+	// without a key nothing can be decrypted.
+	rr := call(map[string]interface{}{"user": "alice"})
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+
+	r.SetEncryptionKeys(key, oldKey)
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/"+EncryptionKeyName, nil))
+	var published struct {
+		Algorithm string `json:"algorithm"`
+		Key       string `json:"key"`
+	}
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &published))
+	assert.Equal(t, EncryptionAlgorithm, published.Algorithm)
+	der, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(der), published.Key)
+
+	password := encryptForTest(t, &key.PublicKey, "hunter2")
+	rr = call(map[string]interface{}{
+		"user":     "alice",
+		"password": password,
+		"keys": []interface{}{
+			map[string]interface{}{"value": encryptForTest(t, &oldKey.PublicKey, map[string]int{"a": 1})},
+			map[string]interface{}{"value": nil},
+		},
+	})
+	assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String())
+	assert.Equal(t, secretLogin{User: "alice", Password: "hunter2", Keys: []secretValue{{Value: map[string]int{"a": 1}}, {}}}, got)
+
+	// the audit trail only saw the encrypted value.
+	assert.Contains(t, audit.String(), password)
+	assert.NotContains(t, audit.String(), "hunter2")
+
+	// plain values are rejected.
+	rr = call(map[string]interface{}{"user": "alice", "password": "hunter2"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "1. argument: field 'password' has to be encrypted")
+
+	rr = call(map[string]interface{}{"user": "alice", "Password": strings.TrimSuffix(password, "=") + "x"})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "isn't encrypted correctly")
+
+	unknown, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rr = call(map[string]interface{}{"password": encryptForTest(t, &unknown.PublicKey, "x")})
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "can't be decrypted")
+
+	assert.Contains(t, r.Description().Endpoints, "/rpc/"+EncryptionKeyName)
+}
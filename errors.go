@@ -0,0 +1,265 @@
+package nra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"unicode/utf8"
+)
+
+// ErrorType classifies an error so that the Javascript side
+// can tell apart what went wrong without parsing the message.
+type ErrorType string
+
+const (
+	// ErrorTypeRequest means the request itself was malformed,
+	// e.g. a wrong http method or a body that isn't valid JSON.
+	ErrorTypeRequest ErrorType = "request"
+
+	// ErrorTypeValidation means the arguments didn't fit the
+	// function, e.g. a wrong number of arguments or a null value
+	// for an argument that can't be nil.
+	ErrorTypeValidation ErrorType = "validation"
+
+	// ErrorTypeConversion means an argument couldn't be converted
+	// to the type the function expects.
+	ErrorTypeConversion ErrorType = "conversion"
+
+	// ErrorTypeApplication means the bound function itself
+	// returned an error.
+	ErrorTypeApplication ErrorType = "application"
+
+	// ErrorTypeNotFound means no function with the called
+	// name is registered.
+	ErrorTypeNotFound ErrorType = "not_found"
+
+	// ErrorTypeInternal means the function crashed, e.g.
+	// because it panicked.
+	ErrorTypeInternal ErrorType = "internal"
+
+	// ErrorTypeLimit means the call exceeded a configured
+	// limit, e.g. its memory budget.
+	ErrorTypeLimit ErrorType = "limit"
+
+	// ErrorTypeUnavailable means the registry can't serve calls
+	// right now, e.g. because its start hooks didn't run or it is
+	// overloaded.
+	ErrorTypeUnavailable ErrorType = "unavailable"
+
+	// ErrorTypeConflict means the function was called with a
+	// version of an entity that isn't the current one anymore,
+	// see CheckVersion.
+	ErrorTypeConflict ErrorType = "conflict"
+
+	// ErrorTypeTimeout means the call didn't finish within
+	// the timeout of the function, see WithTimeout.
+	ErrorTypeTimeout ErrorType = "timeout"
+
+	// ErrorTypeUnauthorized means the call didn't have valid
+	// credentials, see Authenticator.
+	ErrorTypeUnauthorized ErrorType = "unauthorized"
+
+	// ErrorTypeForbidden means the call was rejected before the
+	// function was called, e.g. because it failed the CSRF check
+	// or the caller isn't allowed to call it.
+	ErrorTypeForbidden ErrorType = "forbidden"
+)
+
+// Error is the error that is send to the client. It will
+// always be wrapped in an envelope, so the response body
+// looks like:
+//
+//	{"error": {"message": "...", "type": "conversion", "argument": 2}}
+//
+// Bound functions can return a *Error themselves if they want
+// to control the type that is reported to the client. Any other
+// error will be reported as ErrorTypeApplication.
+type Error struct {
+	Message string    `json:"message"`
+	Type    ErrorType `json:"type"`
+
+	// Argument is the 1-based index of the argument that caused
+	// the error. It is 0 if the error isn't related to a argument.
+	Argument int `json:"argument,omitempty"`
+
+	// Received is the (truncated) JSON value of the argument
+	// that couldn't be converted.
+	Received string `json:"received,omitempty"`
+
+	// Expected is the Go type the function expected.
+	Expected string `json:"expected,omitempty"`
+
+	// Hint describes what values could be passed instead.
+	Hint string `json:"hint,omitempty"`
+
+	// Fields maps the path of every field of the argument that
+	// failed its validate rules to the reason, e.g. "address.zip".
+	Fields map[string]string `json:"fields,omitempty"`
+
+	// Suggestions contains the names of registered functions
+	// that are close to the name of a unknown function.
+	Suggestions []string `json:"suggestions,omitempty"`
+
+	// Version is the current version of the entity
+	// in a ErrorTypeConflict error.
+	Version string `json:"version,omitempty"`
+
+	// RequestID is the id of the request that failed,
+	// see Registry.EnableRequestIDs.
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// errorEnvelope is the body of every error response.
+type errorEnvelope struct {
+	Error *Error `json:"error"`
+}
+
+// asError converts any error returned by a bound function to a *Error.
+func asError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return &Error{Message: err.Error(), Type: ErrorTypeApplication}
+}
+
+// statusOf returns the status code a error returned
+// by a bound function is reported with.
+func statusOf(e *Error) int {
+	switch e.Type {
+	case ErrorTypeConflict:
+		return http.StatusConflict
+	case ErrorTypeUnauthorized:
+		return http.StatusUnauthorized
+	case ErrorTypeForbidden:
+		return http.StatusForbidden
+	}
+	return http.StatusBadRequest
+}
+
+// writeError writes the error wrapped in its envelope as response.
+func writeError(writer http.ResponseWriter, code int, err *Error) {
+	// the error is copied, as functions may return a shared one.
+	if id := writer.Header().Get(RequestIDHeader); id != "" && err.RequestID == "" {
+		withID := *err
+		withID.RequestID = id
+		err = &withID
+	}
+
+	if w, ok := writer.(errorRecorder); ok {
+		w.recordError(err)
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.WriteHeader(code)
+	_ = json.NewEncoder(writer).Encode(errorEnvelope{Error: err})
+}
+
+// maxReceivedLength is the maximum length of the received
+// value that is included in a conversion error.
+const maxReceivedLength = 64
+
+// conversionError creates a error for a argument that couldn't be
+// converted to the expected type. It includes the received value, the
+// expected Go type and a hint on which JSON values would have worked.
+func conversionError(index int, value interface{}, expected reflect.Type) *Error {
+	return &Error{
+		Message:  fmt.Sprintf("mismatching argument type of %d. argument. got=%s expected=%s", index, jsonTypeName(value), expected.String()),
+		Type:     ErrorTypeConversion,
+		Argument: index,
+		Received: truncatedJSON(value),
+		Expected: expected.String(),
+		Hint:     coercionHint(expected),
+	}
+}
+
+// jsonTypeName returns the name of the JSON type of a
+// generically decoded value.
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+
+	// other wire formats than JSON keep integers as integers.
+	if isNumber(reflect.TypeOf(value).Kind()) {
+		return "number"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// truncatedJSON encodes the value to JSON and cuts it down to
+// maxReceivedLength bytes without splitting a character.
+func truncatedJSON(value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+
+	if len(data) > maxReceivedLength {
+		end := maxReceivedLength
+		for end > 0 && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		return string(data[:end]) + "..."
+	}
+	return string(data)
+}
+
+// coercionHint describes which JSON values can be
+// converted to the given type.
+func coercionHint(t reflect.Type) string {
+	switch t {
+	case timeType:
+		return "pass a RFC3339 string or the milliseconds since the epoch"
+	case durationType:
+		return "pass a duration string like \"1m30s\" or the nanoseconds"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "pass true or false, strings and numbers are not converted to booleans"
+	case reflect.String:
+		return "pass a JSON string, numbers and booleans are not converted to strings"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return fmt.Sprintf("pass a JSON number, it will be converted to %s", t.String())
+	case reflect.Struct:
+		return fmt.Sprintf("pass a JSON object with the fields of %s", t.String())
+	case reflect.Slice, reflect.Array:
+		if isBytes(t) {
+			return "pass a base64 encoded JSON string"
+		}
+		return fmt.Sprintf("pass a JSON array of %s", t.Elem().String())
+	case reflect.Map:
+		return fmt.Sprintf("pass a JSON object of %s", t.Elem().String())
+	case reflect.Ptr:
+		return coercionHint(t.Elem())
+	}
+	return ""
+}
+
+// errorRecorder is implemented by writers that
+// keep the error written to them for observers.
+type errorRecorder interface {
+	recordError(err *Error)
+}
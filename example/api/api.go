@@ -0,0 +1,31 @@
+// Package api contains the functions of the example that
+// are callable from Javascript.
+package api
+
+import (
+	"strings"
+
+	"github.com/BigJk/nra"
+)
+
+// some function that you would like to call from javascript
+func addFunction(a int, b float64, c uint8) (float64, error) {
+	return float64(a) + b + float64(c), nil
+}
+
+// NewRegistry creates the registry with all functions of the example.
+func NewRegistry() *nra.Registry {
+	r := nra.NewRegistry()
+
+	// registering the function with error checking
+	if err := r.Register("add", addFunction); err != nil {
+		panic(err)
+	}
+
+	// registering the function inline
+	r.MustRegister("echo", func(s string) (string, error) {
+		return strings.Repeat(s, 2), nil
+	})
+
+	return r
+}
@@ -2,9 +2,8 @@ package main
 
 import (
 	"net/http"
-	"strings"
 
-	"github.com/BigJk/nra"
+	"github.com/BigJk/nra/example/api"
 )
 
 // example index page that calls the rpc functions on button press.
@@ -14,41 +13,21 @@ var index = `
   <title></title>
   <meta name="viewport" content="width=device-width, initial-scale=1">
   <body>
+	<script src="/rpc/client.js"></script>
 	<script>
-		function call(func, ...args) {
-			return new Promise((resolve, reject) => {
-				var request = new XMLHttpRequest();
-				request.open('POST', '/rpc/' + func, true);
-		
-				request.onload = function() {
-					if (request.status === 200) {
-						resolve(JSON.parse(request.responseText));
-					} else {
-						reject(request.responseText);
-					}
-				};
-		
-				request.onerror = function() {
-					reject(request.responseText);
-				};
-		
-				request.send(JSON.stringify(args));
-			});
-		}
-
 		function add_example() {
-			call('add', 1, 5, 10).then(function(result) {
+			rpc.add(1, 5, 10).then(function(result) {
 				alert("Add Result: " + result);
 			}, function(err) {
-				alert("Error: " + err);
+				alert("Error: " + err.message);
 			});
 		}
 
 		function echo_example() {
-			call('echo', 'double me').then(function(result) {
+			rpc.echo('double me').then(function(result) {
 				alert("Add Result: " + result);
 			}, function(err) {
-				alert("Error: " + err);
+				alert("Error: " + err.message);
 			});
 		}
 	</script>
@@ -59,24 +38,14 @@ var index = `
 </html>
 `
 
-// some function that you would like to call from javascript
-func addFunction(a int, b float64, c uint8) (float64, error) {
-	return float64(a) + b + float64(c), nil
-}
+//go:generate go run ./tsgen -o client.ts
 
 func main() {
-	// binding the function with error checking
-	add, err := nra.Bind(addFunction)
-	if err != nil {
-		panic(err)
-	}
-
-	http.HandleFunc("/rpc/add", add)
-
-	// binding the function inline
-	http.HandleFunc("/rpc/echo", nra.MustBind(func(s string) (string, error) {
-		return strings.Repeat(s, 2), nil
-	}))
+	// all rpc functions are served by the registry
+	// which also provides the client under /rpc/client.js
+	r := api.NewRegistry()
+	r.EnableClientJS()
+	http.Handle("/rpc/", r)
 
 	http.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
 		_, _ = writer.Write([]byte(index))
@@ -0,0 +1,27 @@
+// Command tsgen writes the TypeScript client for the example registry.
+//
+//	go run ./tsgen -o client.ts
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/BigJk/nra"
+	"github.com/BigJk/nra/example/api"
+)
+
+func main() {
+	out := flag.String("o", "client.ts", "file to write the client to")
+	flag.Parse()
+
+	f, err := os.Create(*out)
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+
+	if err := nra.GenerateTypeScript(f, api.NewRegistry()); err != nil {
+		panic(err)
+	}
+}
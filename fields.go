@@ -0,0 +1,107 @@
+package nra
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// FieldsName is the query parameter that selects the fields of the
+// result of a call, e.g. ?_fields=id,name,address.city, so list views
+// only receive what they show. Nested fields are separated by dots,
+// the mask applies to every element of arrays.
+const FieldsName = "_fields"
+
+// fieldMask is a parsed set of field paths. A field
+// with a nil mask is selected with all of its fields.
+type fieldMask map[string]fieldMask
+
+// parseFieldMask parses a comma separated list of field paths.
+func parseFieldMask(s string) fieldMask {
+	mask := fieldMask{}
+	for _, path := range strings.Split(s, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+
+		m := mask
+		parts := strings.Split(path, ".")
+		for i, part := range parts {
+			sub, ok := m[part]
+			if ok && sub == nil {
+				// the whole field is already selected.
+				break
+			}
+			if i == len(parts)-1 {
+				m[part] = nil
+				break
+			}
+			if !ok {
+				sub = fieldMask{}
+				m[part] = sub
+			}
+			m = sub
+		}
+	}
+	return mask
+}
+
+// apply removes all fields of the generic JSON value v that
+// aren't selected. Values other than objects and arrays are
+// returned as they are.
+func (m fieldMask) apply(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		selected := make(map[string]interface{}, len(m))
+		for key, sub := range m {
+			value, ok := v[key]
+			if !ok {
+				continue
+			}
+			if sub != nil {
+				value = sub.apply(value)
+			}
+			selected[key] = value
+		}
+		return selected
+	case []interface{}:
+		for i := range v {
+			v[i] = m.apply(v[i])
+		}
+		return v
+	}
+	return v
+}
+
+// selectFields returns the generic JSON form of the
+// result with only the fields selected by mask.
+func selectFields(result interface{}, mask fieldMask) (interface{}, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return nil, err
+	}
+	return mask.apply(generic), nil
+}
+
+// hasFields reports if values of type t, or their elements,
+// are encoded as objects, so a field mask can apply to them.
+func hasFields(t reflect.Type) bool {
+	for {
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			t = t.Elem()
+		case reflect.Struct:
+			return t != timeType
+		case reflect.Map, reflect.Interface:
+			return true
+		default:
+			return false
+		}
+	}
+}
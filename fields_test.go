@@ -0,0 +1,77 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fieldsAddress struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+type fieldsUser struct {
+	ID      int            `json:"id"`
+	Name    string         `json:"name"`
+	Email   string         `json:"email"`
+	Address *fieldsAddress `json:"address"`
+}
+
+func TestParseFieldMask(t *testing.T) {
+	assert.Equal(t, fieldMask{"id": nil, "address": fieldMask{"city": nil}}, parseFieldMask("id, address.city,,"))
+	assert.Equal(t, fieldMask{"address": nil}, parseFieldMask("address,address.city"))
+	assert.Equal(t, fieldMask{"address": nil}, parseFieldMask("address.city,address"))
+}
+
+func TestFieldSelection(t *testing.T) {
+	users := []fieldsUser{
+		{ID: 1, Name: "alice", Email: "alice@example.com", Address: &fieldsAddress{Street: "Main St", City: "Berlin"}},
+		{ID: 2, Name: "bob", Email: "bob@example.com"},
+	}
+
+	r := NewRegistry()
+	r.MustRegister("users", func() ([]fieldsUser, error) { return users, nil }, WithSafe())
+	r.MustRegister("user", func(u fieldsUser) (fieldsUser, error) { return u, nil }, WithSafe())
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		result string
+	}{
+		{name: "list", method: "POST", path: "/rpc/users?_fields=id,address.city", result: `[{"address":{"city":"Berlin"},"id":1},{"address":null,"id":2}]`},
+		{name: "unknown field", method: "POST", path: "/rpc/users?_fields=id,phone", result: `[{"id":1},{"id":2}]`},
+		{name: "without mask", method: "POST", path: "/rpc/user", body: `[{"id":3}]`, result: `{"id":3,"name":"","email":"","address":null}`},
+		{name: "get", method: "GET", path: "/rpc/users?_fields=name", result: `[{"name":"alice"},{"name":"bob"}]`},
+		{name: "named query", method: "GET", path: "/rpc/user?id=4&name=eve&_fields=name", result: `{"name":"eve"}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(test.method, test.path, strings.NewReader(test.body)))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, test.result+"\n", rec.Body.String())
+		})
+	}
+}
+
+func TestFieldSelectionClients(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("users", func() ([]fieldsUser, error) { return nil, nil })
+
+	var js bytes.Buffer
+	assert.NoError(t, GenerateJavaScript(&js, r))
+	assert.Contains(t, js.String(), "withFields: function(selected, fn) {")
+
+	var ts bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&ts, r))
+	assert.Contains(t, ts.String(), "export function withFields<T>(selected: string[], fn: () => Promise<T>): Promise<T> {")
+}
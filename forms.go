@@ -0,0 +1,256 @@
+package nra
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// labelTag is the struct tag with the label of a field in forms:
+//
+//	type User struct {
+//	  Name  string `json:"name" label:"Full name"`
+//	  Role  string `json:"role" form:"options=admin|editor|viewer"`
+//	  Age   int    `json:"age" form:"min=18,max=130"`
+//	  Login string `json:"login" form:"pattern=^[a-z]+$"`
+//	}
+//
+// The form tag holds the rules of the field as comma separated list.
+// min and max limit numbers, pattern is a regular expression strings
+// have to match and can't contain commas, options are the values a
+// string can have and hidden leaves the field out of the form.
+const (
+	labelTag = "label"
+	formTag  = "form"
+)
+
+// form describes the form to call a function with,
+// see WithTypeScriptForms.
+type form struct {
+	Function string      `json:"function"`
+	Fields   []formField `json:"fields"`
+}
+
+// formField describes a single input of a form. Groups have the
+// fields of a struct, lists and maps have the input of their items.
+type formField struct {
+	Name     string      `json:"name"`
+	Label    string      `json:"label"`
+	Type     string      `json:"type"`
+	Required bool        `json:"required"`
+	Default  interface{} `json:"default,omitempty"`
+	Min      *float64    `json:"min,omitempty"`
+	Max      *float64    `json:"max,omitempty"`
+	Pattern  string      `json:"pattern,omitempty"`
+	Options  []string    `json:"options,omitempty"`
+	Fields   []formField `json:"fields,omitempty"`
+	Item     *formField  `json:"item,omitempty"`
+}
+
+// formOf returns the form of the function, or false if it can't be
+// called with a form because it takes a *Upload.
+func formOf(name string, b *binding) (form, bool) {
+	if b.upload {
+		return form{}, false
+	}
+
+	f := form{Function: name, Fields: []formField{}}
+	for i, p := range b.params {
+		field := formFieldOf(p, map[reflect.Type]bool{})
+		field.Name = "arg" + strconv.Itoa(i+1)
+		field.Label = "Argument " + strconv.Itoa(i+1)
+		field.Required = !b.optional(i) && p.Kind() != reflect.Ptr
+
+		// the variadic arguments are entered as list.
+		if b.variadic && i == len(b.params)-1 {
+			item := formFieldOf(p.Elem(), map[reflect.Type]bool{})
+			field = formField{Name: field.Name, Label: field.Label, Type: "list", Item: &item}
+		}
+		f.Fields = append(f.Fields, field)
+	}
+	return f, true
+}
+
+// formFieldOf returns the input for values of type t. Recursive
+// structs are entered as JSON below their first level.
+func formFieldOf(t reflect.Type, visiting map[reflect.Type]bool) formField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return formField{Type: "datetime"}
+	case t == durationType:
+		return formField{Type: "duration"}
+	case isBytes(t):
+		return formField{Type: "file"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return formField{Type: "checkbox"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f := formField{Type: "integer"}
+		f.Min, f.Max = integerRange(t.Kind())
+		return f
+	case reflect.Float32, reflect.Float64:
+		return formField{Type: "number"}
+	case reflect.String:
+		return formField{Type: "text"}
+	case reflect.Slice, reflect.Array:
+		item := formFieldOf(t.Elem(), visiting)
+		return formField{Type: "list", Item: &item}
+	case reflect.Map:
+		item := formFieldOf(t.Elem(), visiting)
+		return formField{Type: "map", Item: &item}
+	case reflect.Struct:
+		if visiting[t] {
+			return formField{Type: "json"}
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		fields := []formField{}
+		for _, jf := range jsonFields(t) {
+			rules := parseFormRules(jf.field.Tag.Get(formTag))
+			if rules.hidden {
+				continue
+			}
+
+			field := formFieldOf(jf.typ, visiting)
+			field.Name = jf.name
+			field.Label = jf.field.Tag.Get(labelTag)
+			if field.Label == "" {
+				field.Label = humanize(jf.name)
+			}
+			if isEncrypted(jf.field) {
+				field.Type = "password"
+			}
+
+			value, hasDefault, err := defaultOf(jf.field)
+			if hasDefault && err == nil {
+				field.Default = value
+			}
+			field.Required = !jf.omitEmpty && !hasDefault && jf.typ.Kind() != reflect.Ptr
+			rules.apply(&field)
+			fields = append(fields, field)
+		}
+		return formField{Type: "group", Fields: fields}
+	}
+	return formField{Type: "json"}
+}
+
+// integerRange returns the range of the integer kind, if all
+// of it can be represented exactly in Javascript.
+func integerRange(kind reflect.Kind) (*float64, *float64) {
+	bound := func(v float64) *float64 { return &v }
+	switch kind {
+	case reflect.Int8:
+		return bound(math.MinInt8), bound(math.MaxInt8)
+	case reflect.Int16:
+		return bound(math.MinInt16), bound(math.MaxInt16)
+	case reflect.Int32:
+		return bound(math.MinInt32), bound(math.MaxInt32)
+	case reflect.Uint8:
+		return bound(0), bound(math.MaxUint8)
+	case reflect.Uint16:
+		return bound(0), bound(math.MaxUint16)
+	case reflect.Uint32:
+		return bound(0), bound(math.MaxUint32)
+	case reflect.Uint, reflect.Uint64:
+		return bound(0), nil
+	}
+	return nil, nil
+}
+
+// formRules are the rules of a field from its form tag.
+type formRules struct {
+	min, max *float64
+	pattern  string
+	options  []string
+	hidden   bool
+}
+
+// parseFormRules parses the form tag of a field.
+// Rules that can't be parsed are ignored.
+func parseFormRules(tag string) formRules {
+	var rules formRules
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch key {
+		case "min", "max":
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			if key == "min" {
+				rules.min = &v
+			} else {
+				rules.max = &v
+			}
+		case "pattern":
+			rules.pattern = value
+		case "options":
+			rules.options = strings.Split(value, "|")
+		case "hidden":
+			rules.hidden = true
+		}
+	}
+	return rules
+}
+
+// apply sets the rules on the field. Options
+// turn a text field into a select.
+func (r formRules) apply(f *formField) {
+	if r.min != nil {
+		f.Min = r.min
+	}
+	if r.max != nil {
+		f.Max = r.max
+	}
+	f.Pattern = r.pattern
+	if len(r.options) > 0 {
+		f.Options = r.options
+		if f.Type == "text" {
+			f.Type = "select"
+		}
+	}
+}
+
+// humanize turns a field name like "first_name"
+// or "firstName" into a label like "First name".
+func humanize(name string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, strings.ToLower(string(word)))
+			word = nil
+		}
+	}
+
+	runes := []rune(name)
+	for i, c := range runes {
+		switch {
+		case c == '_' || c == '-' || c == ' ' || c == '.':
+			flush()
+			continue
+		case unicode.IsUpper(c) && i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+		}
+		word = append(word, c)
+	}
+	flush()
+
+	if len(words) == 0 {
+		return name
+	}
+	label := strings.Join(words, " ")
+	r := []rune(label)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
@@ -0,0 +1,105 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type formUser struct {
+	FullName string            `json:"fullName" label:"Name"`
+	Role     string            `json:"role" form:"options=admin|editor"`
+	Age      uint8             `json:"age" form:"min=18"`
+	Login    string            `json:"login,omitempty" form:"pattern=^[a-z]+$"`
+	Password string            `json:"password" encrypt:"true"`
+	Limit    int               `json:"limit" default:"10"`
+	Born     *time.Time        `json:"born"`
+	Tags     []string          `json:"tags"`
+	Internal string            `json:"internal" form:"hidden"`
+	Manager  *formUser         `json:"manager"`
+	Extra    map[string]string `json:"extra"`
+}
+
+func TestFormOf(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("users.create", func(u formUser, notify *bool, tags ...string) error { return nil })
+	r.MustRegister("users.list", func(limit int) ([]formUser, error) { return nil, nil }, WithSafe())
+	r.MustRegister("ingest", func(u *Upload[formUser]) error { return nil })
+
+	names, functions := r.snapshot()
+	assert.Equal(t, []string{"ingest", "users.create", "users.list"}, names)
+
+	_, ok := formOf("ingest", functions["ingest"])
+	assert.False(t, ok)
+
+	f, ok := formOf("users.create", functions["users.create"])
+	assert.True(t, ok)
+	assert.Equal(t, "users.create", f.Function)
+	assert.Len(t, f.Fields, 3)
+
+	user := f.Fields[0]
+	assert.Equal(t, "arg1", user.Name)
+	assert.Equal(t, "group", user.Type)
+	assert.True(t, user.Required)
+
+	byName := map[string]formField{}
+	for _, field := range user.Fields {
+		byName[field.Name] = field
+	}
+	assert.NotContains(t, byName, "internal")
+	assert.Equal(t, formField{Name: "fullName", Label: "Name", Type: "text", Required: true}, byName["fullName"])
+	assert.Equal(t, formField{Name: "role", Label: "Role", Type: "select", Required: true, Options: []string{"admin", "editor"}}, byName["role"])
+	assert.Equal(t, 18.0, *byName["age"].Min)
+	assert.Equal(t, 255.0, *byName["age"].Max)
+	assert.Equal(t, "^[a-z]+$", byName["login"].Pattern)
+	assert.False(t, byName["login"].Required)
+	assert.Equal(t, "password", byName["password"].Type)
+	assert.Equal(t, 10.0, byName["limit"].Default)
+	assert.False(t, byName["limit"].Required)
+	assert.Equal(t, "datetime", byName["born"].Type)
+	assert.False(t, byName["born"].Required)
+	assert.Equal(t, "list", byName["tags"].Type)
+	assert.Equal(t, "text", byName["tags"].Item.Type)
+	assert.Equal(t, "map", byName["extra"].Type)
+
+	// recursive structs end in a JSON input.
+	assert.Equal(t, "json", byName["manager"].Type)
+
+	assert.Equal(t, formField{Name: "arg2", Label: "Argument 2", Type: "checkbox"}, f.Fields[1])
+	assert.Equal(t, "list", f.Fields[2].Type)
+	assert.Equal(t, "text", f.Fields[2].Item.Type)
+
+	var buf bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&buf, r))
+	assert.NotContains(t, buf.String(), "export const forms")
+
+	buf.Reset()
+	assert.NoError(t, GenerateTypeScript(&buf, r, WithTypeScriptForms()))
+	ts := buf.String()
+	assert.Contains(t, ts, "export interface FormField {")
+
+	i := strings.Index(ts, "export const forms: Record<string, Form> = ")
+	assert.NotEqual(t, -1, i)
+	var forms map[string]form
+	assert.NoError(t, json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimSpace(ts[i+len("export const forms: Record<string, Form> = "):]), ";")), &forms))
+	assert.Contains(t, forms, "users.create")
+	assert.NotContains(t, forms, "users.list")
+	assert.NotContains(t, forms, "ingest")
+}
+
+func TestHumanize(t *testing.T) {
+	for name, label := range map[string]string{
+		"name":       "Name",
+		"first_name": "First name",
+		"firstName":  "First name",
+		"userID":     "User id",
+		"HTTPServer": "Http server",
+		"":           "",
+	} {
+		assert.Equal(t, label, humanize(name), name)
+	}
+}
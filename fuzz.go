@@ -0,0 +1,147 @@
+package nra
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// fuzzDepth is how deep the deeply nested arguments of
+// FuzzCorpus are, deeper than any sane argument would be.
+const fuzzDepth = 1000
+
+// FuzzCorpus returns JSON bodies for calls of fn, as seed corpus for
+// fuzzing the decoding of its arguments with Go's native fuzzing, see
+// also nratest.Fuzz. Besides valid calls there are calls with nulls,
+// wrong types, huge numbers, deep nesting and the wrong number of
+// arguments in every position:
+//
+//	func FuzzCreateUser(f *testing.F) {
+//	  h := nra.MustBind(createUser)
+//	  corpus, _ := nra.FuzzCorpus(createUser)
+//	  for _, body := range corpus {
+//	    f.Add(body)
+//	  }
+//	  f.Fuzz(func(t *testing.T, body []byte) {
+//	    // call h with body and check the response.
+//	  })
+//	}
+//
+// All of these calls have to be answered with a error or a result,
+// never with a panic.
+func FuzzCorpus(fn interface{}) ([][]byte, error) {
+	b, err := newBinding(fn)
+	if err != nil {
+		return nil, err
+	}
+	if b.upload {
+		return nil, errors.New("functions with a *Upload argument can't be called with JSON arguments")
+	}
+
+	valid := make([]string, len(b.params))
+	for i := range b.params {
+		valid[i] = sampleJSON(b.paramType(i), 0)
+	}
+
+	var corpus [][]byte
+	add := func(args []string) {
+		corpus = append(corpus, []byte("["+strings.Join(args, ",")+"]"))
+	}
+
+	add(valid)
+	if b.minArgs < len(valid) {
+		add(valid[:b.minArgs])
+	}
+
+	// every argument is replaced by each invalid
+	// value, while the others stay valid.
+	for i := range valid {
+		for _, invalid := range invalidJSON(b.paramType(i)) {
+			args := append([]string(nil), valid...)
+			args[i] = invalid
+			add(args)
+		}
+	}
+
+	// the wrong number of arguments, and bodies that aren't a list.
+	if b.minArgs > 0 {
+		add(valid[:b.minArgs-1])
+	}
+	add(append(append([]string(nil), valid...), "1"))
+	for _, body := range []string{"", "null", "{}", `"a"`, "[", "[1,", strings.Repeat("[", fuzzDepth)} {
+		corpus = append(corpus, []byte(body))
+	}
+	return corpus, nil
+}
+
+// sampleJSON returns a valid JSON value for t. Nesting
+// stops with null, so recursive types end.
+func sampleJSON(t reflect.Type, depth int) string {
+	if depth > 3 {
+		return "null"
+	}
+
+	switch {
+	case t == timeType:
+		return `"2006-01-02T15:04:05Z"`
+	case t == durationType:
+		return `"1s"`
+	case isBytes(t):
+		return `"YQ=="`
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "1"
+	case reflect.Float32, reflect.Float64:
+		return "1.5"
+	case reflect.String, reflect.Interface:
+		return `"a"`
+	case reflect.Ptr:
+		return sampleJSON(t.Elem(), depth)
+	case reflect.Slice, reflect.Array:
+		return "[" + sampleJSON(t.Elem(), depth+1) + "]"
+	case reflect.Map:
+		return `{"1":` + sampleJSON(t.Elem(), depth+1) + "}"
+	case reflect.Struct:
+		fields := jsonFields(t)
+		parts := make([]string, len(fields))
+		for i, f := range fields {
+			name, _ := json.Marshal(f.name)
+			parts[i] = string(name) + ":" + sampleJSON(f.typ, depth+1)
+		}
+		return "{" + strings.Join(parts, ",") + "}"
+	}
+	return "null"
+}
+
+// invalidJSON returns values that don't fit t, or are at the edge
+// of what it can hold: the wrong type, null, huge and negative
+// numbers, fractions for integers and deeply nested values.
+func invalidJSON(t reflect.Type) []string {
+	values := []string{
+		"null",
+		strings.Repeat("[", fuzzDepth) + strings.Repeat("]", fuzzDepth),
+		strings.Repeat(`{"a":`, fuzzDepth) + "1" + strings.Repeat("}", fuzzDepth),
+	}
+
+	kind := t.Kind()
+	if kind == reflect.Ptr {
+		kind = t.Elem().Kind()
+	}
+	switch {
+	case isNumber(kind):
+		values = append(values, `"1"`, "true", "1.5", "-1", "1e308", "-1e308", "18446744073709551616", "-9223372036854775809")
+	case kind == reflect.String:
+		values = append(values, "1", "true", "[]", "{}", `"`+strings.Repeat("a", 1<<16)+`"`)
+	case kind == reflect.Bool:
+		values = append(values, "1", `"true"`, "[]")
+	default:
+		values = append(values, "1", `"a"`, "true", "[]", "{}", `[null]`, `{"":null}`)
+	}
+	return values
+}
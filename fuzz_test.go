@@ -0,0 +1,64 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fuzzNode struct {
+	Name     string            `json:"name"`
+	Weight   float32           `json:"weight"`
+	Children []*fuzzNode       `json:"children"`
+	Labels   map[string]string `json:"labels"`
+	Data     []byte            `json:"data"`
+	At       time.Time         `json:"at"`
+	Every    time.Duration     `json:"every"`
+}
+
+func TestFuzzCorpus(t *testing.T) {
+	for name, fn := range map[string]interface{}{
+		"numbers": func(a int8, b uint16, c int64, d uint, e float64) (float64, error) { return float64(a) + e, nil },
+		"strings": func(ctx context.Context, s string, ok bool, p *string) (string, error) { return s, nil },
+		"structs": func(n fuzzNode, nodes []fuzzNode, byID map[string]*fuzzNode) (*fuzzNode, error) { return &n, nil },
+		"generic": func(m map[string]interface{}, l []interface{}, counts map[string]int) (interface{}, error) {
+			return m, nil
+		},
+		"times":    func(at time.Time, d time.Duration, data []byte) (time.Time, error) { return at, nil },
+		"variadic": func(prefix string, ids ...int) (int, error) { return len(ids), nil },
+		"none":     func() error { return nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			corpus, err := FuzzCorpus(fn)
+			assert.NoError(t, err)
+			assert.NotEmpty(t, corpus)
+
+			h := MustBind(fn)
+			ok := 0
+			for _, body := range corpus {
+				req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+				rr := httptest.NewRecorder()
+				h(rr, req)
+				assert.NotEqual(t, http.StatusInternalServerError, rr.Code, "%s: %s", body, rr.Body.String())
+				if rr.Code == http.StatusOK {
+					ok++
+				}
+			}
+
+			// at least the valid calls succeed.
+			assert.NotZero(t, ok)
+			req := httptest.NewRequest("POST", "/", bytes.NewReader(corpus[0]))
+			rr := httptest.NewRecorder()
+			h(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code, "%s: %s", corpus[0], rr.Body.String())
+		})
+	}
+
+	_, err := FuzzCorpus(func(u *Upload[fuzzNode]) error { return nil })
+	assert.Error(t, err)
+}
@@ -0,0 +1,107 @@
+package nra
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+var contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+
+// callGroupKey is the context key of the callGroup.
+type callGroupKey struct{}
+
+// callGroup tracks the goroutines started during a call,
+// similar to golang.org/x/sync/errgroup.
+type callGroup struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	once   sync.Once
+	err    error
+}
+
+// newCallContext creates the context of a call. It is
+// cancelled when parent is, or when the call is done.
+func newCallContext(parent context.Context) (context.Context, *callGroup) {
+	ctx, cancel := context.WithCancel(parent)
+
+	g := &callGroup{cancel: cancel}
+	g.ctx = context.WithValue(ctx, callGroupKey{}, g)
+
+	return g.ctx, g
+}
+
+// wait waits for all goroutines of the group
+// and returns the first error one of them returned.
+func (g *callGroup) wait() error {
+	g.wg.Wait()
+	return g.err
+}
+
+// Go runs fn in a new goroutine that belongs to the call ctx is the
+// context of. The call doesn't finish before all of its goroutines are
+// done, so they are never left running in the background. The context
+// passed to fn is cancelled when the client disconnects, the call times
+// out, exceeds its memory budget or faults in native code, or another
+// goroutine of the call returned a error. The first
+// error is returned by Wait and, if the function itself didn't fail,
+// sent to the client.
+//
+//	func Dashboard(ctx context.Context) (*Data, error) {
+//		var data Data
+//		nra.Go(ctx, func(ctx context.Context) (err error) {
+//			data.Users, err = loadUsers(ctx)
+//			return
+//		})
+//		nra.Go(ctx, func(ctx context.Context) (err error) {
+//			data.Orders, err = loadOrders(ctx)
+//			return
+//		})
+//		return &data, nra.Wait(ctx)
+//	}
+//
+// If ctx doesn't belong to a call fn is simply started in a new goroutine.
+func Go(ctx context.Context, fn func(ctx context.Context) error) {
+	g, ok := ctx.Value(callGroupKey{}).(*callGroup)
+	if !ok {
+		go func() {
+			_ = fn(ctx)
+		}()
+		return
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+
+		if err := runGroupFunc(g.ctx, fn); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for all goroutines started with Go for the call ctx
+// is the context of and returns the first error of them.
+func Wait(ctx context.Context) error {
+	g, ok := ctx.Value(callGroupKey{}).(*callGroup)
+	if !ok {
+		return nil
+	}
+	return g.wait()
+}
+
+// runGroupFunc runs fn and turns a panic into a error, as a panic
+// in a goroutine would otherwise take down the whole server.
+func runGroupFunc(ctx context.Context, fn func(ctx context.Context) error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &Error{Message: fmt.Sprintf("goroutine panicked: %v", p), Type: ErrorTypeInternal}
+		}
+	}()
+	return fn(ctx)
+}
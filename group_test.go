@@ -0,0 +1,90 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGo(t *testing.T) {
+	h := MustBind(func(ctx context.Context, n int) (int64, error) {
+		var sum int64
+		for i := 1; i <= n; i++ {
+			i := i
+			Go(ctx, func(ctx context.Context) error {
+				atomic.AddInt64(&sum, int64(i))
+				return nil
+			})
+		}
+		return sum, Wait(ctx)
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("[10]")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "55\n", rr.Body.String())
+}
+
+func TestGoError(t *testing.T) {
+	cancelled := int32(0)
+
+	h := MustBind(func(ctx context.Context) error {
+		Go(ctx, func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				atomic.StoreInt32(&cancelled, 1)
+			case <-time.After(5 * time.Second):
+			}
+			return nil
+		})
+		Go(ctx, func(ctx context.Context) error {
+			return errors.New("failed")
+		})
+
+		// the call waits for the goroutines even
+		// without calling Wait explicitly.
+		return nil
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"failed\",\"type\":\"application\"}}\n", rr.Body.String())
+	assert.Equal(t, int32(1), atomic.LoadInt32(&cancelled), "other goroutines have to be cancelled")
+}
+
+func TestGoPanic(t *testing.T) {
+	h := MustBind(func(ctx context.Context) error {
+		Go(ctx, func(ctx context.Context) error {
+			panic("boom")
+		})
+		return nil
+	})
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"goroutine panicked: boom\",\"type\":\"internal\"}}\n", rr.Body.String())
+}
+
+func TestGoWithoutCall(t *testing.T) {
+	done := make(chan struct{})
+	Go(context.Background(), func(ctx context.Context) error {
+		close(done)
+		return nil
+	})
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("goroutine wasn't started")
+	}
+	assert.NoError(t, Wait(context.Background()))
+}
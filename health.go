@@ -0,0 +1,267 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Names under which the health and readiness status are
+// served if they are enabled, see Registry.EnableHealth.
+const (
+	HealthName = "_health"
+	ReadyName  = "_ready"
+)
+
+// DefaultHealthTimeout is how long the checks of a
+// health or readiness request can take at most.
+const DefaultHealthTimeout = 5 * time.Second
+
+// HealthCheck checks a dependency, e.g. by pinging the database,
+// and returns a error if it isn't usable.
+type HealthCheck func(ctx context.Context) error
+
+// HealthStatus is the result of the checks, Status is "ok"
+// if all checks passed and "fail" otherwise. Degraded has the
+// failed checks of each function that depends on them, see
+// DependsOn.
+type HealthStatus struct {
+	Status   string                 `json:"status"`
+	Checks   map[string]CheckStatus `json:"checks"`
+	Degraded map[string][]string    `json:"degraded,omitempty"`
+}
+
+// CheckStatus is the result of a single check.
+type CheckStatus struct {
+	Status   string  `json:"status"`
+	Error    string  `json:"error,omitempty"`
+	Duration float64 `json:"duration_ms"`
+}
+
+// health keeps the checks of a registry.
+type health struct {
+	mtx     sync.RWMutex
+	enabled bool
+	live    map[string]HealthCheck
+	ready   map[string]HealthCheck
+}
+
+// EnableHealth makes the registry serve its health under the prefix,
+// e.g. /rpc/_health, and its readiness under /rpc/_ready. Both answer
+// with status 200 if all checks passed and 503 otherwise, so they can
+// be used by load balancers and orchestrators:
+//
+//	r.EnableHealth()
+//	r.AddReadinessCheck("db", db.PingContext)
+//
+// The endpoints are served even while the registry isn't ready.
+func (r *Registry) EnableHealth() {
+	r.health.mtx.Lock()
+	defer r.health.mtx.Unlock()
+
+	r.health.enabled = true
+}
+
+// healthEnabled reports if the health endpoints are served.
+func (r *Registry) healthEnabled() bool {
+	r.health.mtx.RLock()
+	defer r.health.mtx.RUnlock()
+
+	return r.health.enabled
+}
+
+// AddHealthCheck adds a check to the health status, which tells if the
+// server works at all and has to be restarted otherwise. Only add checks
+// that a restart can fix, a unavailable dependency belongs in
+// AddReadinessCheck. A check with the same name is replaced.
+func (r *Registry) AddHealthCheck(name string, check HealthCheck) {
+	r.health.mtx.Lock()
+	defer r.health.mtx.Unlock()
+
+	if r.health.live == nil {
+		r.health.live = map[string]HealthCheck{}
+	}
+	r.health.live[name] = check
+}
+
+// AddReadinessCheck adds a check to the readiness status, which tells
+// if the server can serve calls right now. Besides its checks the
+// registry is only ready if Start succeeded and it isn't shut down.
+// A check with the same name is replaced.
+func (r *Registry) AddReadinessCheck(name string, check HealthCheck) {
+	r.health.mtx.Lock()
+	defer r.health.mtx.Unlock()
+
+	if r.health.ready == nil {
+		r.health.ready = map[string]HealthCheck{}
+	}
+	r.health.ready[name] = check
+}
+
+// Health runs the health checks.
+func (r *Registry) Health(ctx context.Context) HealthStatus {
+	r.health.mtx.RLock()
+	checks := make(map[string]HealthCheck, len(r.health.live))
+	for name, check := range r.health.live {
+		checks[name] = check
+	}
+	r.health.mtx.RUnlock()
+
+	return runChecks(ctx, checks)
+}
+
+// Readiness runs the readiness checks. The "registry" check
+// fails if the registry didn't start or is shut down.
+func (r *Registry) Readiness(ctx context.Context) HealthStatus {
+	r.health.mtx.RLock()
+	checks := make(map[string]HealthCheck, len(r.health.ready)+1)
+	for name, check := range r.health.ready {
+		checks[name] = check
+	}
+	r.health.mtx.RUnlock()
+
+	checks["registry"] = func(ctx context.Context) error {
+		if !r.Ready() {
+			return fmt.Errorf("registry is not ready")
+		}
+		return nil
+	}
+
+	status := runChecks(ctx, checks)
+	names, functions := r.snapshot()
+	status.Degraded = degradedFunctions(names, functions, status.Checks)
+	return status
+}
+
+// DependsOn declares the readiness checks the function relies on, see
+// Registry.AddReadinessCheck. While one of them fails the function is
+// listed as degraded by the readiness endpoint and the introspection,
+// so frontends can disable the actions using it:
+//
+//	r.AddReadinessCheck("s3", bucket.Ping)
+//	r.MustRegister("upload", upload, nra.DependsOn("postgres", "s3"))
+//
+// The function is still called as usual.
+func DependsOn(checks ...string) Option {
+	return func(o *options) {
+		o.dependsOn = append(o.dependsOn, checks...)
+	}
+}
+
+// degradedFunctions returns the failed checks of each function
+// that depends on them. Checks that don't exist are ignored.
+func degradedFunctions(names []string, functions map[string]*binding, checks map[string]CheckStatus) map[string][]string {
+	var degraded map[string][]string
+	for _, name := range names {
+		for _, dep := range functions[name].options.dependsOn {
+			if c, ok := checks[dep]; !ok || c.Status == "ok" {
+				continue
+			}
+			if degraded == nil {
+				degraded = map[string][]string{}
+			}
+			degraded[name] = append(degraded[name], dep)
+		}
+	}
+	return degraded
+}
+
+// dependencyChecks runs the readiness checks the functions depend on.
+func (r *Registry) dependencyChecks(ctx context.Context, names []string, functions map[string]*binding) map[string]CheckStatus {
+	r.health.mtx.RLock()
+	checks := map[string]HealthCheck{}
+	for _, name := range names {
+		for _, dep := range functions[name].options.dependsOn {
+			if check, ok := r.health.ready[dep]; ok {
+				checks[dep] = check
+			}
+		}
+	}
+	r.health.mtx.RUnlock()
+
+	if len(checks) == 0 {
+		return nil
+	}
+	return runChecks(ctx, checks).Checks
+}
+
+// runChecks runs all checks at once. A check that
+// panics or doesn't return in time fails.
+func runChecks(ctx context.Context, checks map[string]HealthCheck) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, DefaultHealthTimeout)
+	defer cancel()
+
+	var mtx sync.Mutex
+	var wg sync.WaitGroup
+	status := HealthStatus{Status: "ok", Checks: make(map[string]CheckStatus, len(checks))}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheck) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := runCheck(ctx, check)
+
+			result := CheckStatus{Status: "ok", Duration: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			status.Checks[name] = result
+			if err != nil {
+				status.Status = "fail"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return status
+}
+
+// runCheck runs the check until it returns or ctx is done.
+func runCheck(ctx context.Context, check HealthCheck) error {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- fmt.Errorf("check panicked: %v", p)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// serveHealth answers the health and readiness endpoints
+// and reports if the request was one of them.
+func (r *Registry) serveHealth(writer http.ResponseWriter, request *http.Request, name string) bool {
+	if !r.healthEnabled() || (name != HealthName && name != ReadyName) {
+		return false
+	}
+
+	var status HealthStatus
+	if name == HealthName {
+		status = r.Health(request.Context())
+	} else {
+		status = r.Readiness(request.Context())
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.Header().Set("Cache-Control", "no-store")
+	if status.Status != "ok" {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(writer).Encode(status)
+	return true
+}
@@ -0,0 +1,127 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryHealth(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("ping", func() error { return nil })
+
+	get := func(name string) (int, HealthStatus) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/"+name, nil))
+
+		var status HealthStatus
+		_ = json.Unmarshal(rr.Body.Bytes(), &status)
+		return rr.Code, status
+	}
+
+	// without EnableHealth the names are looked up as functions.
+	code, _ := get(HealthName)
+	assert.Equal(t, http.StatusNotFound, code)
+
+	r.EnableHealth()
+	code, status := get(HealthName)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", status.Status)
+	assert.Empty(t, status.Checks)
+
+	var dbErr error
+	r.AddHealthCheck("goroutines", func(ctx context.Context) error { return nil })
+	r.AddReadinessCheck("db", func(ctx context.Context) error { return dbErr })
+	r.AddReadinessCheck("cache", func(ctx context.Context) error { panic("boom") })
+
+	code, status = get(HealthName)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"goroutines"}, keysOf(status.Checks))
+
+	code, status = get(ReadyName)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "fail", status.Status)
+	assert.Equal(t, "ok", status.Checks["db"].Status)
+	assert.Equal(t, "ok", status.Checks["registry"].Status)
+	assert.Equal(t, CheckStatus{Status: "fail", Error: "check panicked: boom"}, CheckStatus{Status: status.Checks["cache"].Status, Error: status.Checks["cache"].Error})
+
+	r.AddReadinessCheck("cache", func(ctx context.Context) error { return nil })
+	dbErr = errors.New("connection refused")
+	code, status = get(ReadyName)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "connection refused", status.Checks["db"].Error)
+
+	dbErr = nil
+	code, _ = get(ReadyName)
+	assert.Equal(t, http.StatusOK, code)
+
+	// the probes are answered while the registry doesn't take calls.
+	assert.NoError(t, r.Shutdown(context.Background()))
+	code, status = get(ReadyName)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "registry is not ready", status.Checks["registry"].Error)
+
+	code, _ = get(HealthName)
+	assert.Equal(t, http.StatusOK, code)
+
+	assert.Contains(t, r.Description().Endpoints, "/rpc/_ready")
+}
+
+func TestRunChecksTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+
+	status := runChecks(ctx, map[string]HealthCheck{
+		"stuck": func(ctx context.Context) error { <-release; return nil },
+	})
+	assert.Equal(t, "fail", status.Status)
+	assert.Equal(t, context.Canceled.Error(), status.Checks["stuck"].Error)
+}
+
+func keysOf(checks map[string]CheckStatus) []string {
+	var keys []string
+	for k := range checks {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+func TestDependsOn(t *testing.T) {
+	s3 := errors.New("bucket unreachable")
+	r := NewRegistry()
+	r.EnableHealth()
+	r.EnableIntrospection()
+	r.AddReadinessCheck("postgres", func(ctx context.Context) error { return nil })
+	r.AddReadinessCheck("s3", func(ctx context.Context) error { return s3 })
+	r.MustRegister("upload", func() error { return nil }, DependsOn("postgres", "s3"))
+	r.MustRegister("list", func() error { return nil }, DependsOn("postgres", "unknown"))
+	r.MustRegister("ping", func() error { return nil })
+
+	status := r.Readiness(context.Background())
+	assert.Equal(t, map[string][]string{"upload": {"s3"}}, status.Degraded)
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/"+MethodsName, nil))
+	var methods []Method
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &methods))
+	assert.Equal(t, []string{"postgres", "unknown"}, methods[0].DependsOn)
+	assert.Empty(t, methods[0].Degraded)
+	assert.Empty(t, methods[1].DependsOn)
+	assert.Equal(t, []string{"s3"}, methods[2].Degraded)
+
+	// degraded functions are still called.
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/upload", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	s3 = nil
+	assert.Empty(t, r.Readiness(context.Background()).Degraded)
+}
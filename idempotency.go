@@ -0,0 +1,324 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IdempotencyKeyHeader carries the key that identifies a call of
+// a function bound with WithIdempotencyKey across retries.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set to "true" on responses
+// that were stored by an earlier call with the same key.
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// DefaultIdempotencyTTL is how long responses are kept
+// if Idempotency.TTL isn't set.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+var (
+	// ErrIdempotencyInProgress is returned by IdempotencyStore.Begin
+	// if a call with the key is still running.
+	ErrIdempotencyInProgress = errors.New("a call with the same idempotency key is still in progress")
+
+	// ErrIdempotencyMismatch is returned by IdempotencyStore.Begin if
+	// the key was used for a call with other arguments.
+	ErrIdempotencyMismatch = errors.New("the idempotency key was already used with other arguments")
+)
+
+// StoredResponse is a response kept by a IdempotencyStore.
+type StoredResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// IdempotencyStore keeps the responses of calls by their idempotency
+// key. Begin reserves the key for a call whose body has the given
+// hash and returns nil, or returns the stored response if the call
+// already finished. It returns ErrIdempotencyInProgress if the key is
+// reserved by a running call and ErrIdempotencyMismatch if the hash
+// differs. Finish stores the response, Abort releases the key so the
+// call can be tried again. Stores shared by several servers have to
+// reserve keys atomically.
+type IdempotencyStore interface {
+	Begin(ctx context.Context, key string, hash string, ttl time.Duration) (*StoredResponse, error)
+	Finish(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error
+	Abort(ctx context.Context, key string) error
+}
+
+// Idempotency configures how calls with a idempotency
+// key are deduplicated, see Registry.EnableIdempotency.
+type Idempotency struct {
+	// Store keeps the responses. Defaults to a
+	// MemoryIdempotencyStore, which is lost on restart.
+	Store IdempotencyStore
+
+	// TTL is how long responses are kept, and so how long
+	// retries are deduplicated. Defaults to DefaultIdempotencyTTL.
+	TTL time.Duration
+}
+
+// EnableIdempotency sets where the responses of functions bound with
+// WithIdempotencyKey are kept. Without it the registry keeps them in
+// memory, which only works with a single server.
+func (r *Registry) EnableIdempotency(config Idempotency) {
+	if config.Store == nil {
+		config.Store = NewMemoryIdempotencyStore()
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultIdempotencyTTL
+	}
+	r.idempotency = &config
+}
+
+// WithIdempotencyKey makes a function that changes something safe to
+// retry. The generated clients send a random key in the
+// IdempotencyKeyHeader with every call and retry it with the same key
+// if it failed because of the network or the server being unavailable.
+// The registry runs the function once per key and answers all other
+// calls with the same key with the stored response, so a retry, or a
+// double click that reuses the key, never runs it twice. Responses
+// with status 5xx aren't stored, so the call can be retried.
+//
+// Calls without the header run as usual. Functions with this option
+// can only be registered at a Registry, see EnableIdempotency.
+func WithIdempotencyKey() Option {
+	return func(o *options) {
+		o.idempotencyKey = true
+	}
+}
+
+// validIdempotencyKey matches the keys that are accepted.
+var validIdempotencyKey = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,255}$`)
+
+// idempotencyConfig returns the configuration of the registry,
+// creating the in memory default on first use.
+func (r *Registry) idempotencyConfig() *Idempotency {
+	r.idempotencyOnce.Do(func() {
+		if r.idempotency == nil {
+			r.EnableIdempotency(Idempotency{})
+		}
+	})
+	return r.idempotency
+}
+
+// serveIdempotent calls handler once for the idempotency key of the
+// request, or replays the stored response. Keys are per function and
+// caller, so a stored response is only replayed to the identity that
+// made the call. The authenticator of the function, if any, runs before
+// the stored response is looked up.
+func (r *Registry) serveIdempotent(writer http.ResponseWriter, request *http.Request, name string, auth Authenticator, handler http.HandlerFunc) {
+	key := request.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		handler(writer, request)
+		return
+	}
+	if !validIdempotencyKey.MatchString(key) {
+		writeError(writer, http.StatusBadRequest, &Error{Message: "invalid idempotency key", Type: ErrorTypeRequest})
+		return
+	}
+
+	if auth != nil && !signed(request.Context()) {
+		if request = authenticate(auth, writer, request); request == nil {
+			return
+		}
+		request = request.WithContext(context.WithValue(request.Context(), authenticatedKey{}, true))
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+			return
+		}
+		writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+		return
+	}
+	_ = request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	config := r.idempotencyConfig()
+	ctx := request.Context()
+	key = idempotencyStoreKey(name, IdentityOf(ctx), key)
+
+	hash := sha256.Sum256(append([]byte(request.Method+" "+request.URL.RawQuery+"\n"), body...))
+	stored, err := config.Store.Begin(ctx, key, hex.EncodeToString(hash[:]), config.TTL)
+	switch {
+	case errors.Is(err, ErrIdempotencyInProgress):
+		writer.Header().Set("Retry-After", "1")
+		writeError(writer, http.StatusConflict, &Error{Message: err.Error(), Type: ErrorTypeConflict})
+		return
+	case errors.Is(err, ErrIdempotencyMismatch):
+		writeError(writer, http.StatusUnprocessableEntity, &Error{Message: err.Error(), Type: ErrorTypeValidation})
+		return
+	case err != nil:
+		writeError(writer, http.StatusServiceUnavailable, &Error{Message: "can't check idempotency key: " + err.Error(), Type: ErrorTypeUnavailable})
+		return
+	case stored != nil:
+		for k, v := range stored.Header {
+			writer.Header()[k] = v
+		}
+		writer.Header().Set("Content-Length", strconv.Itoa(len(stored.Body)))
+		writer.Header().Set(IdempotentReplayedHeader, "true")
+		writer.WriteHeader(stored.Status)
+		_, _ = writer.Write(stored.Body)
+		return
+	}
+
+	w := &captureWriter{ResponseWriter: writer}
+	finished := false
+	defer func() {
+		// a panicking call or a server error releases the
+		// key, so the call can be tried again.
+		if !finished || w.statusCode() >= http.StatusInternalServerError {
+			_ = config.Store.Abort(context.WithoutCancel(ctx), key)
+		}
+	}()
+
+	handler(w, request)
+	finished = true
+
+	if w.statusCode() < http.StatusInternalServerError {
+		response := &StoredResponse{Status: w.statusCode(), Header: w.Header().Clone(), Body: w.body.Bytes()}
+		// the body is captured before it is compressed, the
+		// replay compresses it again if the client accepts it.
+		response.Header.Del(RequestIDHeader)
+		response.Header.Del("Content-Encoding")
+		response.Header.Del("Content-Length")
+		_ = config.Store.Finish(context.WithoutCancel(ctx), key, response, config.TTL)
+	}
+}
+
+// idempotencyStoreKey returns the key a response is stored under. The
+// identity is hashed, so it can't be confused with the key the client
+// sent and isn't stored in plain text. Anonymous calls share a scope.
+func idempotencyStoreKey(name string, identity interface{}, key string) string {
+	scope := ""
+	if identity != nil {
+		hash := sha256.Sum256([]byte(AuditIdentity(identity)))
+		scope = hex.EncodeToString(hash[:16])
+	}
+	return name + ":" + scope + ":" + key
+}
+
+// captureWriter keeps a copy of the response written through it.
+type captureWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *captureWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *captureWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// recordError passes the error on to the writer below,
+// so observers still see it.
+func (w *captureWriter) recordError(err *Error) {
+	if r, ok := w.ResponseWriter.(errorRecorder); ok {
+		r.recordError(err)
+	}
+}
+
+// statusCode returns the status code of the response.
+func (w *captureWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// MemoryIdempotencyStore is a IdempotencyStore that
+// keeps the responses in memory.
+type MemoryIdempotencyStore struct {
+	mtx     sync.Mutex
+	entries map[string]memoryIdempotencyEntry
+	begins  int
+	now     func() time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	hash     string
+	response *StoredResponse
+	expires  time.Time
+}
+
+// NewMemoryIdempotencyStore creates a empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: map[string]memoryIdempotencyEntry{}, now: time.Now}
+}
+
+// Begin implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Begin(ctx context.Context, key string, hash string, ttl time.Duration) (*StoredResponse, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+	if e, ok := m.entries[key]; ok && now.Before(e.expires) {
+		switch {
+		case e.hash != hash:
+			return nil, ErrIdempotencyMismatch
+		case e.response == nil:
+			return nil, ErrIdempotencyInProgress
+		}
+		return e.response, nil
+	}
+	m.entries[key] = memoryIdempotencyEntry{hash: hash, expires: now.Add(ttl)}
+
+	// expired entries are removed every now and then.
+	if m.begins++; m.begins%1024 == 0 {
+		for key, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, key)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Finish implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Finish(ctx context.Context, key string, response *StoredResponse, ttl time.Duration) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.response = response
+		e.expires = m.now().Add(ttl)
+		m.entries[key] = e
+	}
+	return nil
+}
+
+// Abort implements IdempotencyStore.
+func (m *MemoryIdempotencyStore) Abort(ctx context.Context, key string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.entries, key)
+	return nil
+}
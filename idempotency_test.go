@@ -0,0 +1,253 @@
+package nra
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKey(t *testing.T) {
+	calls := 0
+	fail := true
+
+	r := NewRegistry()
+	r.MustRegister("pay", func(amount int) (int, error) {
+		calls++
+		return calls, nil
+	}, WithIdempotencyKey())
+	r.MustRegister("flaky", func() error {
+		calls++
+		if fail {
+			panic("down")
+		}
+		return nil
+	}, WithIdempotencyKey(), WithNative())
+
+	call := func(name, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("pay", "a", "[10]")
+	assert.Equal(t, "1\n", rec.Body.String())
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = call("pay", "a", "[10]")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "1\n", rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 1, calls)
+
+	rec = call("pay", "a", "[20]")
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), ErrIdempotencyMismatch.Error())
+
+	assert.Equal(t, "2\n", call("pay", "b", "[10]").Body.String())
+	assert.Equal(t, "3\n", call("pay", "", "[10]").Body.String())
+	assert.Equal(t, "4\n", call("pay", "", "[10]").Body.String())
+	assert.Equal(t, http.StatusBadRequest, call("pay", "not a key", "[10]").Code)
+
+	// server errors aren't stored, so the call can be retried.
+	calls = 0
+	assert.Equal(t, http.StatusInternalServerError, call("flaky", "c", "").Code)
+	fail = false
+	assert.Equal(t, http.StatusOK, call("flaky", "c", "").Code)
+	assert.Equal(t, http.StatusOK, call("flaky", "c", "").Code)
+	assert.Equal(t, 2, calls)
+
+	_, err := Bind(func() error { return nil }, WithIdempotencyKey())
+	assert.Error(t, err)
+}
+
+func TestIdempotencyKeyPerCaller(t *testing.T) {
+	calls := 0
+	auths := 0
+
+	r := NewRegistry()
+	r.MustRegister("pay", func(ctx context.Context, amount int) (string, error) {
+		calls++
+		return IdentityOf(ctx).(string), nil
+	}, WithIdempotencyKey(), WithAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		auths++
+		if user := request.Header.Get("X-User"); user != "" {
+			return user, nil
+		}
+		return nil, errors.New("please log in")
+	})))
+
+	call := func(user string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/pay", strings.NewReader("[10]"))
+		req.Header.Set(IdempotencyKeyHeader, "a")
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, "\"alice\"\n", call("alice").Body.String())
+	assert.Equal(t, 1, auths, "the authenticator runs once per call")
+
+	// the stored response is neither replayed without
+	// authentication nor to other callers.
+	rec := call("")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = call("bob")
+	assert.Equal(t, "\"bob\"\n", rec.Body.String())
+	assert.Empty(t, rec.Header().Get(IdempotentReplayedHeader))
+
+	rec = call("alice")
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyKeyCompression(t *testing.T) {
+	calls := 0
+	text := strings.Repeat("a", 2048)
+
+	r := NewRegistry()
+	r.SetCompression(0)
+	r.MustRegister("text", func() (string, error) {
+		calls++
+		return text, nil
+	}, WithIdempotencyKey())
+
+	call := func(key string, gzipped bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/text", nil)
+		req.Header.Set(IdempotencyKeyHeader, key)
+		if gzipped {
+			req.Header.Set("Accept-Encoding", "gzip")
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	decode := func(rec *httptest.ResponseRecorder) string {
+		if rec.Header().Get("Content-Encoding") != "gzip" {
+			return rec.Body.String()
+		}
+		reader, err := gzip.NewReader(rec.Body)
+		if !assert.NoError(t, err) {
+			return ""
+		}
+		data, err := io.ReadAll(reader)
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	// the stored response is replayed to clients with and
+	// without compression, whichever made the first call.
+	for _, first := range []bool{true, false} {
+		key := fmt.Sprint("key-", first)
+		for _, gzipped := range []bool{first, !first, first} {
+			rec := call(key, gzipped)
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, gzipped, rec.Header().Get("Content-Encoding") == "gzip")
+			assert.Equal(t, "\""+text+"\"\n", decode(rec))
+		}
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotencyKeyInProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := NewRegistry()
+	r.EnableIdempotency(Idempotency{Store: NewMemoryIdempotencyStore(), TTL: time.Minute})
+	r.MustRegister("slow", func() error {
+		close(started)
+		<-release
+		return nil
+	}, WithIdempotencyKey())
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		req := httptest.NewRequest("POST", "/rpc/slow", nil)
+		req.Header.Set(IdempotencyKeyHeader, "k")
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	req := httptest.NewRequest("POST", "/rpc/slow", nil)
+	req.Header.Set(IdempotencyKeyHeader, "k")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	close(release)
+	wg.Wait()
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Unix(0, 0)
+	m := NewMemoryIdempotencyStore()
+	m.now = func() time.Time { return now }
+
+	stored, err := m.Begin(ctx, "k", "h", time.Minute)
+	assert.Nil(t, stored)
+	assert.NoError(t, err)
+
+	_, err = m.Begin(ctx, "k", "h", time.Minute)
+	assert.True(t, errors.Is(err, ErrIdempotencyInProgress))
+
+	response := &StoredResponse{Status: http.StatusOK, Body: []byte("1")}
+	assert.NoError(t, m.Finish(ctx, "k", response, time.Minute))
+
+	stored, err = m.Begin(ctx, "k", "h", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, response, stored)
+
+	_, err = m.Begin(ctx, "k", "other", time.Minute)
+	assert.True(t, errors.Is(err, ErrIdempotencyMismatch))
+
+	now = now.Add(2 * time.Minute)
+	stored, err = m.Begin(ctx, "k", "other", time.Minute)
+	assert.Nil(t, stored)
+	assert.NoError(t, err)
+
+	assert.NoError(t, m.Abort(ctx, "k"))
+	stored, err = m.Begin(ctx, "k", "h", time.Minute)
+	assert.Nil(t, stored)
+	assert.NoError(t, err)
+}
+
+func TestIdempotencyClients(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("pay", func(amount int) error { return nil }, WithIdempotencyKey())
+
+	var buf bytes.Buffer
+	assert.NoError(t, GenerateJavaScript(&buf, r))
+	assert.Contains(t, buf.String(), `rpc["pay"] = bind("pay", false, true);`)
+	assert.Contains(t, buf.String(), "withIdempotencyKey: function(key, fn)")
+
+	buf.Reset()
+	assert.NoError(t, GenerateTypeScript(&buf, r))
+	assert.Contains(t, buf.String(), `return call<void>("pay", [arg1], false, true);`)
+	assert.Contains(t, buf.String(), "export function withIdempotencyKey<T>(")
+}
@@ -0,0 +1,181 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// InProcessClient calls the functions of a registry in the same
+// process, e.g. from another module of a modular monolith. Calls run
+// through the whole registry, including authentication, middleware,
+// limits and observers, but not through the network. If the modules
+// are split into services later, the client can be replaced by one
+// that calls the registry over HTTP without changing the callers.
+type InProcessClient struct {
+	// Header is sent with every call, e.g. the credentials
+	// the authenticator of the registry expects.
+	Header http.Header
+
+	// Serialize encodes the arguments and the result as JSON the
+	// same way a remote call does. Without it the arguments are
+	// passed as they are if they have the type of the parameter,
+	// and the result is assigned directly, which is faster but
+	// may share memory between caller and function. Results of
+	// functions bound with WithCache are only cached if it is set.
+	Serialize bool
+
+	registry *Registry
+}
+
+// InProcess creates a client that calls the functions of r in process:
+//
+//	users := nra.InProcess(usersRegistry)
+//
+//	var user User
+//	err := users.Call(ctx, "users.get", &user, 42)
+//
+// Calls from the client skip the CSRF check, as they can't come
+// from a browser.
+func InProcess(r *Registry) *InProcessClient {
+	return &InProcessClient{Header: http.Header{}, registry: r}
+}
+
+// inProcessKey is the context key of the inProcessCall of a call.
+type inProcessKey struct{}
+
+// inProcessCall passes the arguments and the result of a call
+// from a InProcessClient without serialization if direct is set.
+type inProcessCall struct {
+	direct bool
+	args   []interface{}
+	result interface{}
+}
+
+// inProcessOf returns the inProcessCall of a call, or
+// nil if the call doesn't come from a InProcessClient.
+func inProcessOf(ctx context.Context) *inProcessCall {
+	call, _ := ctx.Value(inProcessKey{}).(*inProcessCall)
+	return call
+}
+
+// isDirect reports if the call passes its arguments
+// and result without serialization.
+func (c *inProcessCall) isDirect() bool {
+	return c != nil && c.direct
+}
+
+// Call calls the function with the arguments and stores its result
+// in the value pointed to by result, which can be nil if the result
+// isn't needed. A failed call returns a *Error, the same as a remote
+// call would.
+func (c *InProcessClient) Call(ctx context.Context, name string, result interface{}, args ...interface{}) error {
+	if result != nil {
+		if rv := reflect.ValueOf(result); rv.Kind() != reflect.Ptr || rv.IsNil() {
+			return errors.New("result has to be a non-nil pointer")
+		}
+	}
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	call := &inProcessCall{direct: !c.Serialize, args: args}
+	var body io.Reader = http.NoBody
+	if c.Serialize {
+		data, err := json.Marshal(args)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(context.WithValue(ctx, inProcessKey{}, call), "POST", c.registry.prefix+name, body)
+	if err != nil {
+		return err
+	}
+	for k, v := range c.Header {
+		request.Header[k] = v
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	w := &inProcessWriter{header: http.Header{}}
+	c.registry.ServeHTTP(w, request)
+
+	if w.statusCode() < 200 || w.statusCode() >= 300 {
+		var response struct {
+			Error *Error `json:"error"`
+		}
+		if err := json.Unmarshal(w.body.Bytes(), &response); err != nil || response.Error == nil {
+			return &Error{Message: w.body.String(), Type: ErrorTypeRequest}
+		}
+		return response.Error
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	target := reflect.ValueOf(result).Elem()
+	if !c.Serialize {
+		if call.result == nil {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if value := reflect.ValueOf(call.result); value.Type().AssignableTo(target.Type()) {
+			target.Set(value)
+			return nil
+		}
+
+		// results of another type are converted
+		// the same way a remote call does.
+		data, err := json.Marshal(call.result)
+		if err != nil {
+			return err
+		}
+		return json.Unmarshal(data, result)
+	}
+
+	if w.body.Len() == 0 {
+		return nil
+	}
+	return json.Unmarshal(w.body.Bytes(), result)
+}
+
+// inProcessWriter is the http.ResponseWriter of in process calls.
+type inProcessWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (w *inProcessWriter) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *inProcessWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (w *inProcessWriter) Write(data []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(data)
+}
+
+// statusCode returns the status code of the response.
+func (w *inProcessWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
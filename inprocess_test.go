@@ -0,0 +1,83 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type inProcessUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestInProcess(t *testing.T) {
+	var seen []string
+	r := NewRegistry()
+	r.EnableCSRF(CSRF{})
+	r.SetAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		if request.Header.Get("X-User") == "" {
+			return nil, ErrUnauthenticated
+		}
+		return request.Header.Get("X-User"), nil
+	}))
+	r.MustRegister("users.get", func(ctx context.Context, id int) (*inProcessUser, error) {
+		if id == 0 {
+			return nil, errors.New("no such user")
+		}
+		return &inProcessUser{ID: id, Name: IdentityOf(ctx).(string)}, nil
+	}, WithMiddleware(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			seen = append(seen, request.URL.Path)
+			next.ServeHTTP(writer, request)
+		})
+	}))
+	r.MustRegister("users.rename", func(u inProcessUser, name string) (inProcessUser, error) {
+		u.Name = name
+		return u, nil
+	})
+
+	for _, serialize := range []bool{false, true} {
+		seen = nil
+		c := InProcess(r)
+		c.Serialize = serialize
+
+		// the authenticator still runs.
+		err := c.Call(context.Background(), "users.get", nil, 1)
+		var e *Error
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrorTypeUnauthorized, e.Type)
+
+		c.Header.Set("X-User", "alice")
+		var user *inProcessUser
+		assert.NoError(t, c.Call(context.Background(), "users.get", &user, 42))
+		assert.Equal(t, &inProcessUser{ID: 42, Name: "alice"}, user)
+		assert.Equal(t, []string{"/rpc/users.get"}, seen)
+
+		// a result of another type is converted.
+		var other map[string]interface{}
+		assert.NoError(t, c.Call(context.Background(), "users.get", &other, 7))
+		assert.Equal(t, map[string]interface{}{"id": float64(7), "name": "alice"}, other)
+
+		err = c.Call(context.Background(), "users.get", &user, 0)
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, "no such user", e.Message)
+
+		var renamed inProcessUser
+		assert.NoError(t, c.Call(context.Background(), "users.rename", &renamed, inProcessUser{ID: 1, Name: "a"}, "b"))
+		assert.Equal(t, inProcessUser{ID: 1, Name: "b"}, renamed)
+
+		// generic arguments are converted the same as in remote calls.
+		assert.NoError(t, c.Call(context.Background(), "users.rename", &renamed, map[string]interface{}{"id": 2}, "c"))
+		assert.Equal(t, inProcessUser{ID: 2, Name: "c"}, renamed)
+
+		err = c.Call(context.Background(), "users.missing", nil)
+		assert.True(t, errors.As(err, &e))
+		assert.Equal(t, ErrorTypeNotFound, e.Type)
+	}
+
+	assert.EqualError(t, InProcess(r).Call(context.Background(), "users.get", inProcessUser{}, 1), "result has to be a non-nil pointer")
+}
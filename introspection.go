@@ -0,0 +1,119 @@
+package nra
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// MethodsName is the name under which the list of
+// methods is served if introspection is enabled.
+const MethodsName = "_methods"
+
+// Method describes a registered function.
+type Method struct {
+	// Name is the name the function is registered under.
+	Name string `json:"name"`
+
+	// Path is the path the function is called under,
+	// including the base path of the registry.
+	Path string `json:"path"`
+
+	// Params are the Go types of the arguments that
+	// have to be passed when calling the function.
+	Params []string `json:"params"`
+
+	// Result is the Go type of the returned value. It is
+	// empty if the function only returns a error.
+	Result string `json:"result,omitempty"`
+
+	// Idempotent is true if the function was bound with
+	// WithIdempotent, so clients can safely retry calls.
+	Idempotent bool `json:"idempotent,omitempty"`
+
+	// Safe is true if the function was bound with WithSafe,
+	// so it can also be called via GET.
+	Safe bool `json:"safe,omitempty"`
+
+	// Bulk is true if the function was created with Bulk, so
+	// its result reports the outcome of every item.
+	Bulk bool `json:"bulk,omitempty"`
+
+	// Deprecated is true if the default version of the
+	// function was bound with WithDeprecated.
+	Deprecated bool `json:"deprecated,omitempty"`
+
+	// Versions are all registered versions of the function,
+	// if there is more than one, see WithVersion.
+	Versions []int `json:"versions,omitempty"`
+
+	// DependsOn are the readiness checks the function relies on,
+	// see DependsOn. Degraded are the ones that currently fail, it
+	// is only filled by the introspection endpoint.
+	DependsOn []string `json:"depends_on,omitempty"`
+	Degraded  []string `json:"degraded,omitempty"`
+}
+
+// Methods describes all registered functions sorted by name.
+func (r *Registry) Methods() []Method {
+	return r.methodsOf(r.PublicPrefix())
+}
+
+// methodsOf describes all functions with their paths under the prefix.
+func (r *Registry) methodsOf(prefix string) []Method {
+	names, functions := r.snapshot()
+	return r.methodsFrom(prefix, names, functions)
+}
+
+// methodsFrom describes the functions of a snapshot.
+func (r *Registry) methodsFrom(prefix string, names []string, functions map[string]*binding) []Method {
+	methods := make([]Method, 0, len(names))
+	for _, name := range names {
+		b := functions[name]
+
+		m := Method{Name: name, Path: prefix + name, Params: make([]string, len(b.params))}
+		for i, p := range b.params {
+			m.Params[i] = p.String()
+		}
+		if b.variadic {
+			m.Params[len(m.Params)-1] = "..." + b.params[len(b.params)-1].Elem().String()
+		}
+		if b.result != nil {
+			m.Result = b.result.String()
+		}
+		m.Idempotent = b.options.idempotent
+		m.Safe = b.options.safe
+		m.Bulk = isBulk(b.result)
+		m.Deprecated = b.options.deprecated
+		if versions := r.versionsOf(name); len(versions) > 1 {
+			m.Versions = versions
+		}
+		m.DependsOn = b.options.dependsOn
+
+		methods = append(methods, m)
+	}
+	return methods
+}
+
+// EnableIntrospection makes the registry serve the result of
+// Methods as JSON under the prefix, e.g. /rpc/_methods, so
+// dynamic clients and tools can discover the functions.
+func (r *Registry) EnableIntrospection() {
+	r.introspection = true
+}
+
+// serveMethods writes the list of methods as response. The
+// checks the functions depend on are run to report which
+// functions are degraded right now.
+func (r *Registry) serveMethods(writer http.ResponseWriter, request *http.Request) {
+	names, functions := r.snapshot()
+	methods := r.methodsFrom(r.publicPrefixOf(request), names, functions)
+	if checks := r.dependencyChecks(request.Context(), names, functions); checks != nil {
+		degraded := degradedFunctions(names, functions, checks)
+		for i := range methods {
+			methods[i].Degraded = degraded[methods[i].Name]
+		}
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(writer).Encode(methods)
+}
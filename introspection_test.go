@@ -0,0 +1,40 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryMethods(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a int, b float64) (float64, error) { return 0, nil }, WithSafe())
+	r.MustRegister("user", func(req *http.Request, id int) (*tsUser, error) { return nil, nil })
+	r.MustRegister("ping", func() error { return nil }, WithIdempotent())
+	r.MustRegister("join", func(sep string, items ...string) (string, error) { return "", nil })
+
+	assert.Equal(t, []Method{
+		{Name: "add", Path: "/rpc/add", Params: []string{"int", "float64"}, Result: "float64", Idempotent: true, Safe: true},
+		{Name: "join", Path: "/rpc/join", Params: []string{"string", "...string"}, Result: "string"},
+		{Name: "ping", Path: "/rpc/ping", Params: []string{}, Idempotent: true},
+		{Name: "user", Path: "/rpc/user", Params: []string{"int"}, Result: "*nra.tsUser"},
+	}, r.Methods())
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/_methods", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "introspection is disabled by default")
+
+	r.EnableIntrospection()
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/_methods", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `[
+		{"name": "add", "path": "/rpc/add", "params": ["int", "float64"], "result": "float64", "idempotent": true, "safe": true},
+		{"name": "join", "path": "/rpc/join", "params": ["string", "...string"], "result": "string"},
+		{"name": "ping", "path": "/rpc/ping", "params": [], "idempotent": true},
+		{"name": "user", "path": "/rpc/user", "params": ["int"], "result": "*nra.tsUser"}
+	]`, rr.Body.String())
+}
@@ -0,0 +1,506 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// jsRuntime is the part of the generated Javascript client
+// that doesn't depend on the registered functions.
+const jsRuntime = `// Code generated by nra. DO NOT EDIT.
+(function(global) {
+  var prefix = %s;
+  var csrf = %s;
+
+  // debug keeps the recent calls for the debug overlay.
+  var debug = { enabled: %t, max: 50, calls: [], overlay: null };
+
+  // encryption has the public key and the paths of the encrypted
+  // fields of each function, see Registry.SetEncryptionKeys.
+  var encryption = %s;
+  var publicKey = null;
+
+  // csrfToken returns the double-submit token from the cookie,
+  // or any value if only the header is required.
+  function csrfToken() {
+    var cookies = csrf.cookie ? document.cookie.split('; ') : [];
+    for (var i = 0; i < cookies.length; i++) {
+      if (cookies[i].indexOf(csrf.cookie + '=') === 0) {
+        return decodeURIComponent(cookies[i].slice(csrf.cookie.length + 1));
+      }
+    }
+    return '1';
+  }
+
+  // fields is the field mask of the calls started
+  // inside of the function passed to rpc.withFields.
+  var fields = null;
+
+  // curlOf returns a curl command that repeats the call.
+  function curlOf(entry) {
+    function quote(s) {
+      return "'" + String(s).replace(/'/g, "'\\''") + "'";
+    }
+
+    var url = typeof location !== 'undefined' ? new URL(entry.url, location.href).href : entry.url;
+    var command = 'curl -X POST ' + quote(url);
+    for (var header in entry.headers) {
+      command += ' -H ' + quote(header + ': ' + entry.headers[header]);
+    }
+    return command + ' --data-raw ' + quote(JSON.stringify(entry.args));
+  }
+
+  function element(tag, text, style) {
+    var e = document.createElement(tag);
+    if (text) {
+      e.textContent = text;
+    }
+    if (style) {
+      e.style.cssText = style;
+    }
+    return e;
+  }
+
+  function button(text, onclick) {
+    var b = element('button', text, 'margin-left:6px;font:inherit;cursor:pointer;');
+    b.onclick = onclick;
+    return b;
+  }
+
+  // renderOverlay shows the recent calls in a fixed box on the
+  // page. It is only built from text nodes, so arguments and
+  // results can't inject anything into the page.
+  function renderOverlay() {
+    if (!debug.enabled || typeof document === 'undefined' || !document.body) {
+      return;
+    }
+
+    if (!debug.overlay) {
+      debug.overlay = element('div', '', 'position:fixed;right:8px;bottom:8px;width:480px;max-height:40vh;overflow:auto;z-index:2147483647;background:#1e1e1e;color:#ddd;font:12px monospace;padding:6px;border-radius:4px;opacity:0.95;');
+      debug.overlay.setAttribute('data-nra-debug', '');
+      document.body.appendChild(debug.overlay);
+    }
+
+    var overlay = debug.overlay;
+    overlay.textContent = '';
+
+    var header = element('div', 'nra calls', 'font-weight:bold;margin-bottom:4px;');
+    header.appendChild(button('clear', function() {
+      debug.calls = [];
+      renderOverlay();
+    }));
+    header.appendChild(button('hide', function() { rpc.debug.disable(); }));
+    overlay.appendChild(header);
+
+    debug.calls.forEach(function(entry) {
+      var failed = entry.error !== undefined;
+      var summary = entry.name + '  ' + (entry.status === null ? 'pending' : entry.status) +
+        (entry.duration === null ? '' : '  ' + entry.duration + 'ms') +
+        (entry.requestId ? '  ' + entry.requestId : '');
+
+      var details = element('details', '', 'border-top:1px solid #333;padding:2px 0;');
+      var line = element('summary', summary, 'cursor:pointer;color:' + (failed ? '#f88' : '#ddd') + ';');
+      line.appendChild(button('copy as curl', function(event) {
+        event.preventDefault();
+        if (typeof navigator !== 'undefined' && navigator.clipboard) {
+          navigator.clipboard.writeText(curlOf(entry));
+        }
+      }));
+      details.appendChild(line);
+      details.appendChild(element('pre', 'args: ' + JSON.stringify(entry.args, null, 2), 'margin:2px 0;white-space:pre-wrap;'));
+      details.appendChild(element('pre', (failed ? 'error: ' + JSON.stringify(entry.error, null, 2) : 'result: ' + JSON.stringify(entry.result, null, 2)), 'margin:2px 0;white-space:pre-wrap;'));
+      overlay.appendChild(details);
+    });
+  }
+
+  // record adds a call to the recent calls if debugging is enabled.
+  function record(name, url, args, headers) {
+    if (!debug.enabled) {
+      return null;
+    }
+
+    var entry = { name: name, url: url, args: args, headers: headers, started: Date.now(), duration: null, status: null, result: undefined, error: undefined, requestId: null };
+    debug.calls.unshift(entry);
+    if (debug.calls.length > debug.max) {
+      debug.calls.pop();
+    }
+    renderOverlay();
+    return entry;
+  }
+
+  function finish(entry, request, result, error) {
+    if (!entry) {
+      return;
+    }
+
+    entry.duration = Date.now() - entry.started;
+    entry.status = request ? request.status : 0;
+    entry.requestId = request ? request.getResponseHeader('X-Request-ID') : null;
+    entry.result = result;
+    entry.error = error;
+    renderOverlay();
+  }
+
+  // idempotencyKey is the key of the calls started inside
+  // of the function passed to rpc.withIdempotencyKey.
+  var idempotencyKey = null;
+
+  function newIdempotencyKey() {
+    if (typeof crypto !== 'undefined' && crypto.randomUUID) {
+      return crypto.randomUUID();
+    }
+    return Date.now().toString(36) + '-' + Math.random().toString(36).slice(2) + Math.random().toString(36).slice(2);
+  }
+
+  function base64(buffer) {
+    var bytes = new Uint8Array(buffer);
+    var s = '';
+    for (var i = 0; i < bytes.length; i++) {
+      s += String.fromCharCode(bytes[i]);
+    }
+    return btoa(s);
+  }
+
+  // encryptValue encrypts the JSON of value with a new AES-GCM
+  // key, which is encrypted with the public key of the server.
+  function encryptValue(value) {
+    if (!publicKey) {
+      var der = Uint8Array.from(atob(encryption.key), function(c) { return c.charCodeAt(0); });
+      publicKey = crypto.subtle.importKey('spki', der, { name: 'RSA-OAEP', hash: 'SHA-256' }, false, ['encrypt']);
+    }
+
+    var iv = crypto.getRandomValues(new Uint8Array(12));
+    var data = new TextEncoder().encode(JSON.stringify(value));
+    return Promise.all([publicKey, crypto.subtle.generateKey({ name: 'AES-GCM', length: 256 }, true, ['encrypt'])]).then(function(keys) {
+      return Promise.all([
+        crypto.subtle.exportKey('raw', keys[1]).then(function(raw) { return crypto.subtle.encrypt({ name: 'RSA-OAEP' }, keys[0], raw); }),
+        crypto.subtle.encrypt({ name: 'AES-GCM', iv: iv }, keys[1], data)
+      ]);
+    }).then(function(parts) {
+      return 'enc1:' + base64(parts[0]) + ':' + base64(iv) + ':' + base64(parts[1]);
+    });
+  }
+
+  // encryptAt encrypts the value at path[i:] inside of value.
+  // Objects and arrays on the way are copied, so the arguments
+  // of the caller stay untouched.
+  function encryptAt(value, path, i) {
+    if (value === null || value === undefined) {
+      return Promise.resolve(value);
+    }
+    if (i === path.length) {
+      return encryptValue(value);
+    }
+    if (typeof value !== 'object') {
+      return Promise.resolve(value);
+    }
+
+    var copy = Array.isArray(value) ? value.slice() : Object.assign({}, value);
+    var keys = path[i] === '*' ? Object.keys(copy) : (path[i] in copy ? [path[i]] : []);
+    return Promise.all(keys.map(function(k) {
+      return encryptAt(copy[k], path, i + 1).then(function(v) { copy[k] = v; });
+    })).then(function() { return copy; });
+  }
+
+  function encryptArgs(paths, args) {
+    return paths.reduce(function(done, path) {
+      return done.then(function(args) { return encryptAt(args, path, 0); });
+    }, Promise.resolve(args));
+  }
+
+  function call(name, args, idempotent, keyed) {
+    var url = prefix + name;
+    if (fields) {
+      url += '?_fields=' + encodeURIComponent(fields.join(','));
+    }
+
+    // calls with a idempotency key run at most once on the
+    // server, so they are retried with the same key.
+    var key = keyed ? idempotencyKey || newIdempotencyKey() : null;
+    idempotent = idempotent || keyed;
+
+    // the fields are encrypted once, so retries send the same body.
+    if (encryption && encryption.fields[name]) {
+      return encryptArgs(encryption.fields[name], args).then(start);
+    }
+    return start(args);
+
+    function start(args) {
+      return new Promise(function(resolve, reject) {
+        var attempt = 0;
+
+        // only idempotent calls are retried, as all others
+        // might already have had an effect on the server.
+        function retry() {
+          if (!idempotent || attempt >= rpc.retries) {
+            return false;
+          }
+          setTimeout(send, rpc.retryDelay * Math.pow(2, attempt++));
+          return true;
+        }
+
+        function send() {
+          var headers = { 'Content-Type': 'application/json' };
+          if (csrf) {
+            headers[csrf.header] = csrfToken();
+          }
+          if (key) {
+            headers['Idempotency-Key'] = key;
+          }
+          for (var header in rpc.headers) {
+            headers[header] = rpc.headers[header];
+          }
+
+          var request = new XMLHttpRequest();
+          request.open('POST', url, true);
+          for (header in headers) {
+            request.setRequestHeader(header, headers[header]);
+          }
+          var entry = record(name, url, args, headers);
+
+          request.onload = function() {
+            var busy = key && request.status === 409 && request.getResponseHeader('Retry-After');
+            if ((request.status === 503 || busy) && retry()) {
+              finish(entry, request, undefined, { message: 'retrying', type: 'unavailable' });
+              return;
+            }
+
+            // bodies that aren't JSON, e.g. the error page of a
+            // proxy, reject the call instead of throwing here.
+            var ok = request.status >= 200 && request.status < 300;
+            var body;
+            try {
+              body = request.responseText.length > 0 ? JSON.parse(request.responseText) : undefined;
+            } catch (e) {
+              var invalid = { message: ok ? 'invalid response: ' + e.message : request.responseText, type: 'request' };
+              finish(entry, request, undefined, invalid);
+              reject(invalid);
+              return;
+            }
+            if (ok) {
+              finish(entry, request, body);
+              resolve(body);
+            } else {
+              var err = body && body.error ? body.error : { message: request.responseText, type: 'request' };
+              finish(entry, request, undefined, err);
+              reject(err);
+            }
+          };
+
+          request.onerror = function() {
+            finish(entry, null, undefined, { message: 'network error', type: 'request' });
+            if (!retry()) {
+              reject({ message: 'network error', type: 'request' });
+            }
+          };
+
+          request.send(JSON.stringify(args));
+        }
+
+        send();
+      });
+    }
+  }
+
+  function bind(name, idempotent, keyed) {
+    return function() {
+      return call(name, Array.prototype.slice.call(arguments), idempotent, keyed);
+    };
+  }
+
+  var rpc = {
+    retries: 3,
+    retryDelay: 100,
+    headers: {},
+    call: function(name) { return call(name, Array.prototype.slice.call(arguments, 1), false); },
+    isConflict: function(err) { return !!err && err.type === 'conflict'; },
+    debug: {
+      enable: function() {
+        debug.enabled = true;
+        renderOverlay();
+      },
+      disable: function() {
+        debug.enabled = false;
+        if (debug.overlay) {
+          debug.overlay.parentNode.removeChild(debug.overlay);
+          debug.overlay = null;
+        }
+      },
+      calls: function() { return debug.calls.slice(); },
+      curl: curlOf
+    },
+    withFields: function(selected, fn) {
+      fields = selected;
+      try {
+        return fn();
+      } finally {
+        fields = null;
+      }
+    },
+    withIdempotencyKey: function(key, fn) {
+      idempotencyKey = key;
+      try {
+        return fn();
+      } finally {
+        idempotencyKey = null;
+      }
+    }
+  };
+`
+
+// GenerateJavaScript writes a Javascript client for all functions
+// of the registry to w. The client defines a global rpc object
+// with one function per registered function that returns a
+// Promise, so after including the script a function can be
+// called like:
+//
+//	rpc.add(1, 2).then(function(result) { ... });
+//
+// Names containing dots are turned into nested objects, so
+// "users.create" can be called with rpc.users.create(...).
+//
+// Calls of functions bound with WithIdempotent are retried up to
+// rpc.retries times with a exponential backoff starting at
+// rpc.retryDelay milliseconds. rpc.isConflict(err) tells if a call
+// failed because of a version conflict, see CheckVersion. With
+// Registry.EnableCSRF the client sends the CSRF header on its own,
+// other headers like credentials can be set in rpc.headers. Calls
+// started inside of rpc.withFields(['id', 'name'], function() { ... })
+// only return the selected fields of their result, see FieldsName.
+//
+// Calls of functions bound with WithIdempotencyKey send a new
+// idempotency key and are retried with it. Calls started inside of
+// rpc.withIdempotencyKey(key, function() { ... }) use the given key
+// instead, e.g. one created when a form is shown, so submitting the
+// form twice only runs the function once.
+//
+// Struct fields tagged with encrypt:"true" are encrypted with the
+// public key of the registry before they are sent, see
+// Registry.SetEncryptionKeys.
+//
+// rpc.debug.enable() shows a overlay listing the recent calls with
+// their arguments, results, timing and request id, see
+// Registry.EnableDebugOverlay.
+//
+// It fails if a function would replace a property of the rpc object
+// itself, e.g. a function named call or headers.
+func GenerateJavaScript(w io.Writer, r *Registry) error {
+	return generateJavaScript(w, r, r.PublicPrefix())
+}
+
+// generateJavaScript writes the Javascript client that
+// calls the functions under the given prefix.
+func generateJavaScript(w io.Writer, r *Registry, publicPrefix string) error {
+	prefix, err := json.Marshal(publicPrefix)
+	if err != nil {
+		return err
+	}
+
+	csrf := []byte("null")
+	if r.csrf != nil {
+		if csrf, err = json.Marshal(map[string]string{"header": r.csrf.Header, "cookie": r.csrf.Cookie}); err != nil {
+			return err
+		}
+	}
+
+	names, functions := r.snapshot()
+	encryption, err := r.encryptionOf(names, functions)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, jsRuntime, prefix, csrf, r.debugOverlay, encryption); err != nil {
+		return err
+	}
+
+	created := map[string]bool{}
+	for _, name := range names {
+		parts := strings.Split(name, ".")
+		if jsRuntimeNames[parts[0]] {
+			return fmt.Errorf("function '%s' can't be generated, rpc.%s is used by the runtime", name, parts[0])
+		}
+
+		// create the parent objects of nested names.
+		for i := 1; i < len(parts); i++ {
+			parent := strings.Join(parts[:i], ".")
+			if created[parent] {
+				continue
+			}
+			created[parent] = true
+
+			if _, err := fmt.Fprintf(w, "  %s = %s || {};\n", jsPath(parts[:i]), jsPath(parts[:i])); err != nil {
+				return err
+			}
+		}
+
+		quoted, err := json.Marshal(name)
+		if err != nil {
+			return err
+		}
+
+		o := functions[name].options
+		keyed := ""
+		if o.idempotencyKey {
+			keyed = ", true"
+		}
+		if _, err := fmt.Fprintf(w, "  %s = bind(%s, %t%s);\n", jsPath(parts), quoted, o.idempotent, keyed); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.WriteString(w, "\n  global.rpc = rpc;\n})(typeof window !== 'undefined' ? window : this);\n")
+	return err
+}
+
+// encryptionOf returns the public key and the encrypted fields of
+// the functions for the clients as JSON, or null without a key.
+func (r *Registry) encryptionOf(names []string, functions map[string]*binding) ([]byte, error) {
+	key, err := r.publicKey()
+	if err != nil || key == "" {
+		return []byte("null"), err
+	}
+
+	fields := map[string][][]interface{}{}
+	for _, name := range names {
+		if paths := functions[name].encrypted; len(paths) > 0 {
+			fields[name] = clientPaths(paths)
+		}
+	}
+	return json.Marshal(map[string]interface{}{"key": key, "fields": fields})
+}
+
+// jsRuntimeNames are the properties of the rpc object of jsRuntime,
+// which functions can't replace.
+var jsRuntimeNames = map[string]bool{
+	"retries": true, "retryDelay": true, "headers": true, "call": true, "isConflict": true,
+	"debug": true, "withFields": true, "withIdempotencyKey": true, "__proto__": true,
+}
+
+// jsPath returns the property access of the nested name
+// parts on the rpc object, e.g. rpc["users"]["create"].
+func jsPath(parts []string) string {
+	var buf strings.Builder
+	buf.WriteString("rpc")
+	for _, p := range parts {
+		quoted, _ := json.Marshal(p)
+		buf.WriteString("[" + string(quoted) + "]")
+	}
+	return buf.String()
+}
+
+// serveClientJS writes the Javascript client of the registry as response.
+func (r *Registry) serveClientJS(writer http.ResponseWriter, request *http.Request) {
+	// the client is generated completely first,
+	// so a error isn't appended to half of it.
+	var buf bytes.Buffer
+	if err := generateJavaScript(&buf, r, r.publicPrefixOf(request)); err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: err.Error(), Type: ErrorTypeInternal})
+		return
+	}
+	writer.Header().Set("Content-Type", "application/javascript")
+	_, _ = buf.WriteTo(writer)
+}
@@ -0,0 +1,90 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateJavaScript(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("users.create", func(name string) error { return nil })
+	r.MustRegister("users.get", func(id int) (string, error) { return "", nil }, WithIdempotent())
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, GenerateJavaScript(&buf, r)) {
+		return
+	}
+
+	js := buf.String()
+	assert.Contains(t, js, "var prefix = \"/rpc/\";")
+	assert.Contains(t, js, "  rpc[\"add\"] = bind(\"add\", false);\n")
+	assert.Contains(t, js, "  rpc[\"users\"] = rpc[\"users\"] || {};\n  rpc[\"users\"][\"create\"] = bind(\"users.create\", false);\n")
+	assert.Contains(t, js, "  rpc[\"users\"][\"get\"] = bind(\"users.get\", true);\n")
+	assert.Contains(t, js, "isConflict: function(err)")
+	assert.Contains(t, js, "} catch (e) {\n              var invalid = { message: ok ? 'invalid response: ' + e.message : request.responseText, type: 'request' };\n              finish(entry, request, undefined, invalid);\n              reject(invalid);")
+}
+
+func TestGenerateJavaScriptRuntimeNames(t *testing.T) {
+	for _, name := range []string{"call", "headers", "debug", "withFields", "debug.enable"} {
+		r := NewRegistry()
+		r.MustRegister(name, func() error { return nil })
+
+		err := GenerateJavaScript(&bytes.Buffer{}, r)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "is used by the runtime")
+		}
+	}
+
+	r := NewRegistry()
+	r.MustRegister("users.call", func() error { return nil })
+	assert.NoError(t, GenerateJavaScript(&bytes.Buffer{}, r), "nested names don't replace the runtime")
+}
+
+func TestRegistryClientJS(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/client.js", nil))
+	assert.Equal(t, http.StatusNotFound, rr.Code, "client.js is disabled by default")
+
+	r.EnableClientJS()
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/client.js", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/javascript", rr.Header().Get("Content-Type"))
+	assert.Contains(t, rr.Body.String(), "global.rpc = rpc;")
+
+	// a failed generation only sends the error.
+	r.MustRegister("call", func() error { return nil })
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/client.js", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.NotEqual(t, "application/javascript", rr.Header().Get("Content-Type"))
+	assert.True(t, strings.HasPrefix(rr.Body.String(), "{\"error\":"), rr.Body.String())
+	assert.Contains(t, rr.Body.String(), "\"type\":\"internal\"")
+}
+
+func TestDebugOverlay(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+
+	var buf bytes.Buffer
+	assert.NoError(t, GenerateJavaScript(&buf, r))
+	assert.Contains(t, buf.String(), "var debug = { enabled: false,")
+	assert.Contains(t, buf.String(), "curl: curlOf")
+
+	r.EnableDebugOverlay()
+
+	buf.Reset()
+	assert.NoError(t, GenerateJavaScript(&buf, r))
+	assert.Contains(t, buf.String(), "var debug = { enabled: true,")
+}
@@ -0,0 +1,283 @@
+package nra
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONSchemaName is the name under which the JSON Schemas of the
+// arguments are served if they are enabled. The schema of a single
+// function is served below it, e.g. /rpc/_schemas/users.create.
+const JSONSchemaName = "_schemas"
+
+// jsonSchemaDialect is the dialect of the generated schemas.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// argumentsDocument is the JSON Schema of the array of arguments of
+// a function, with the named structs it uses as definitions.
+type argumentsDocument struct {
+	Dialect string `json:"$schema"`
+	Title   string `json:"title"`
+	schema
+	Defs map[string]*schema `json:"$defs,omitempty"`
+}
+
+// argumentsDocumentOf creates the JSON Schema of the arguments of the
+// function. Fields with a default aren't required, as they can be
+// missing.
+func argumentsDocumentOf(name string, b *binding) *argumentsDocument {
+	g := newSchemaGenerator("#/$defs/")
+	g.optionalDefaults = true
+
+	doc := &argumentsDocument{Dialect: jsonSchemaDialect, Title: name, schema: *argumentsSchemaOf(g, b)}
+	if len(g.defs) > 0 {
+		doc.Defs = g.defs
+	}
+	return doc
+}
+
+// JSONSchema returns the JSON Schema of the array of arguments the
+// function name has to be called with, so clients can check arguments
+// before they call, or false if there is no such function. Every struct
+// field without omitempty or a default is required.
+func (r *Registry) JSONSchema(name string) ([]byte, bool) {
+	b, ok := r.function(name)
+	if !ok {
+		return nil, false
+	}
+
+	data, err := json.MarshalIndent(argumentsDocumentOf(name, b), "", "  ")
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+// EnableJSONSchemas makes the registry serve the JSON Schemas of the
+// arguments of all functions, see JSONSchema, as one object keyed by
+// the function names under the prefix, e.g. /rpc/_schemas, and the
+// schema of each function below it, e.g. /rpc/_schemas/users.create.
+func (r *Registry) EnableJSONSchemas() {
+	r.jsonSchemas = true
+}
+
+// serveJSONSchemas writes the schema of the function name,
+// or of all functions if name is empty, as response.
+func (r *Registry) serveJSONSchemas(writer http.ResponseWriter, request *http.Request, name string) {
+	var doc interface{}
+	if name == "" {
+		names, functions := r.snapshot()
+		all := make(map[string]*argumentsDocument, len(names))
+		for _, name := range names {
+			all[name] = argumentsDocumentOf(name, functions[name])
+		}
+		doc = all
+	} else {
+		b, ok := r.function(name)
+		if !ok {
+			writeError(writer, http.StatusNotFound, r.notFoundError(name))
+			return
+		}
+		doc = argumentsDocumentOf(name, b)
+	}
+
+	writer.Header().Set("Content-Type", "application/schema+json")
+	enc := json.NewEncoder(writer)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: err.Error(), Type: ErrorTypeInternal})
+	}
+}
+
+// WithSchemaValidation checks the arguments of every call against the
+// JSON Schema of the function, see Registry.JSONSchema, before they are
+// converted. Calls that don't match are rejected with a error naming
+// the first mismatching value, so arguments the conversion would accept
+// with a different meaning, like a missing field becoming its zero
+// value or a string holding a number, can't reach the function.
+//
+// Calls with protobuf messages are not checked, they are already
+// typed by the message.
+func WithSchemaValidation() Option {
+	return func(o *options) {
+		o.schemaValidation = true
+	}
+}
+
+// argumentsValidator checks generically decoded arguments
+// against the schema of the arguments of a function.
+type argumentsValidator struct {
+	args *schema
+	defs map[string]*schema
+}
+
+// newArgumentsValidator creates the validator of the binding.
+func newArgumentsValidator(b *binding) *argumentsValidator {
+	g := newSchemaGenerator("#/$defs/")
+	g.optionalDefaults = true
+	return &argumentsValidator{args: argumentsSchemaOf(g, b), defs: g.defs}
+}
+
+// validate checks the arguments and returns a error
+// describing the first value that doesn't match.
+func (v *argumentsValidator) validate(args []interface{}) *Error {
+	for i, arg := range args {
+		s := v.args.Items
+		if i < len(v.args.PrefixItems) {
+			s = v.args.PrefixItems[i]
+		}
+		if s == nil {
+			continue
+		}
+
+		if path, message := v.check(s, arg, ""); message != "" {
+			e := &Error{Message: fmt.Sprintf("%d. argument %s", i+1, message), Type: ErrorTypeValidation, Argument: i + 1}
+			if path != "" {
+				e.Message = fmt.Sprintf("%d. argument: '%s' %s", i+1, path, message)
+				e.Fields = map[string]string{path: message}
+			}
+			return e
+		}
+	}
+	return nil
+}
+
+// validateRaw is validate for raw JSON arguments.
+func (v *argumentsValidator) validateRaw(raw []json.RawMessage) *Error {
+	args := make([]interface{}, len(raw))
+	for i := range raw {
+		if err := json.Unmarshal(raw[i], &args[i]); err != nil {
+			return &Error{Message: fmt.Sprintf("can't decode %d. argument: %v", i+1, err), Type: ErrorTypeRequest, Argument: i + 1}
+		}
+	}
+	return v.validate(args)
+}
+
+// check checks the value against the schema and returns the path of
+// the first value that doesn't match and why, or a empty message.
+func (v *argumentsValidator) check(s *schema, value interface{}, path string) (string, string) {
+	if s.Ref != "" {
+		def, ok := v.defs[strings.TrimPrefix(s.Ref, "#/$defs/")]
+		if !ok {
+			return path, "references the unknown schema " + s.Ref
+		}
+		return v.check(def, value, path)
+	}
+
+	if len(s.AnyOf) > 0 {
+		var firstPath, firstMessage string
+		for i, option := range s.AnyOf {
+			p, message := v.check(option, value, path)
+			if message == "" {
+				return "", ""
+			}
+			if i == 0 {
+				firstPath, firstMessage = p, message
+			}
+		}
+		return firstPath, firstMessage
+	}
+
+	if len(s.Type) > 0 && !matchesType(s.Type, value) {
+		return path, "must be " + strings.Join(s.Type, " or ") + ", got " + schemaTypeOf(value)
+	}
+
+	switch value := value.(type) {
+	case string:
+		switch s.Format {
+		case "date-time":
+			if _, err := time.Parse(time.RFC3339, value); err != nil {
+				return path, "must be a RFC3339 date-time"
+			}
+		case "byte":
+			if _, err := base64.StdEncoding.DecodeString(value); err != nil {
+				return path, "must be base64 encoded"
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			return path, "must have at least " + strconv.Itoa(*s.MinItems) + " items"
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			return path, "must have at most " + strconv.Itoa(*s.MaxItems) + " items"
+		}
+		for i, item := range value {
+			items := s.Items
+			if i < len(s.PrefixItems) {
+				items = s.PrefixItems[i]
+			}
+			if items == nil {
+				continue
+			}
+			if p, message := v.check(items, item, joinPath(path, strconv.Itoa(i))); message != "" {
+				return p, message
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				return joinPath(path, name), "is required"
+			}
+		}
+
+		// the fields are checked in order, so the
+		// same call always reports the same field.
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			property, ok := s.Properties[k]
+			if !ok {
+				property = s.AdditionalProperties
+			}
+			if property == nil {
+				continue
+			}
+			if p, message := v.check(property, value[k], joinPath(path, k)); message != "" {
+				return p, message
+			}
+		}
+	}
+	return "", ""
+}
+
+// matchesType reports if the generically decoded value
+// has one of the types of a schema.
+func matchesType(types schemaType, value interface{}) bool {
+	actual := schemaTypeOf(value)
+	for _, t := range types {
+		if t == actual || t == "number" && actual == "integer" {
+			return true
+		}
+	}
+	return false
+}
+
+// schemaTypeOf returns the JSON Schema type of the generically
+// decoded value. Numbers without a fraction are integers.
+func schemaTypeOf(value interface{}) string {
+	switch value := value.(type) {
+	case float64:
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			return "integer"
+		}
+		return "number"
+	case float32:
+		return schemaTypeOf(float64(value))
+	}
+
+	if value != nil && isNumber(reflect.TypeOf(value).Kind()) {
+		return "integer"
+	}
+	return jsonTypeName(value)
+}
@@ -0,0 +1,150 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type schemaTask struct {
+	Title    string        `json:"title"`
+	Due      time.Time     `json:"due"`
+	Priority int           `json:"priority" default:"1"`
+	Tags     []string      `json:"tags,omitempty"`
+	Parent   *schemaTask   `json:"parent"`
+	Subtasks []*schemaTask `json:"subtasks,omitempty"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("tasks.create", func(task schemaTask, notify *bool) error { return nil })
+	r.MustRegister("tasks.tag", func(id int, tags ...string) error { return nil })
+
+	data, ok := r.JSONSchema("tasks.create")
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "tasks.create",
+		"type": "array",
+		"minItems": 1,
+		"maxItems": 2,
+		"prefixItems": [
+			{"$ref": "#/$defs/schemaTask"},
+			{"type": ["boolean", "null"]}
+		],
+		"$defs": {
+			"schemaTask": {
+				"type": "object",
+				"properties": {
+					"title": {"type": "string"},
+					"due": {"type": "string", "format": "date-time"},
+					"priority": {"type": "integer", "format": "int32", "default": 1},
+					"tags": {"type": ["array", "null"], "items": {"type": "string"}},
+					"parent": {"anyOf": [{"$ref": "#/$defs/schemaTask"}, {"type": "null"}]},
+					"subtasks": {"type": ["array", "null"], "items": {"anyOf": [{"$ref": "#/$defs/schemaTask"}, {"type": "null"}]}}
+				},
+				"required": ["title", "due", "parent"]
+			}
+		}
+	}`, string(data))
+
+	_, ok = r.JSONSchema("missing")
+	assert.False(t, ok)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		return rr
+	}
+	assert.Equal(t, http.StatusNotFound, get("/rpc/_schemas").Code, "schemas are disabled by default")
+
+	r.EnableJSONSchemas()
+	rr := get("/rpc/_schemas")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/schema+json", rr.Header().Get("Content-Type"))
+
+	var all map[string]json.RawMessage
+	assert.NoError(t, json.Unmarshal(rr.Body.Bytes(), &all))
+	assert.Len(t, all, 2)
+	assert.JSONEq(t, string(data), string(all["tasks.create"]))
+
+	rr = get("/rpc/_schemas/tasks.tag")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.JSONEq(t, `{
+		"$schema": "https://json-schema.org/draft/2020-12/schema",
+		"title": "tasks.tag",
+		"type": "array",
+		"minItems": 1,
+		"prefixItems": [{"type": "integer", "format": "int32"}],
+		"items": {"type": "string"}
+	}`, rr.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("/rpc/_schemas/tasks.missing").Code)
+}
+
+func TestSchemaValidation(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("tasks.create", func(task schemaTask, tags ...string) (string, error) { return task.Title, nil }, WithSchemaValidation())
+	r.MustRegister("tasks.loose", func(task schemaTask) (string, error) { return task.Title, nil })
+
+	call := func(name string, body string) (*httptest.ResponseRecorder, *Error) {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body)))
+
+		var response errorEnvelope
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr, response.Error
+	}
+
+	rr, _ := call("tasks.create", `[{"title":"a","due":"2024-01-02T15:04:05Z","parent":null,"subtasks":[{"title":"b","due":"2024-01-02T15:04:05Z","parent":null}]},"x"]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "\"a\"\n", rr.Body.String())
+
+	for body, expected := range map[string]string{
+		`[{"due":"2024-01-02T15:04:05Z","parent":null}]`:                                                               "1. argument: 'title' is required",
+		`[{"title":"a","due":1700000000000,"parent":null}]`:                                                            "1. argument: 'due' must be string, got integer",
+		`[{"title":"a","due":"yesterday","parent":null}]`:                                                              "1. argument: 'due' must be a RFC3339 date-time",
+		`[{"title":"a","due":"2024-01-02T15:04:05Z","parent":null,"priority":1.5}]`:                                    "1. argument: 'priority' must be integer, got number",
+		`[{"title":"a","due":"2024-01-02T15:04:05Z","parent":null,"subtasks":[{"title":"b","parent":null}]}]`:          "1. argument: 'subtasks.0.due' is required",
+		`[{"title":"a","due":"2024-01-02T15:04:05Z","parent":{"title":1,"due":"2024-01-02T15:04:05Z","parent":null}}]`: "1. argument: 'parent.title' must be string, got integer",
+		`[{"title":"a","due":"2024-01-02T15:04:05Z","parent":null},"x",2]`:                                             "3. argument must be string, got integer",
+		`[null]`: "1. argument must be object, got null",
+	} {
+		rr, e := call("tasks.create", body)
+		assert.Equal(t, http.StatusBadRequest, rr.Code, body)
+		if assert.NotNil(t, e, body) {
+			assert.Equal(t, ErrorTypeValidation, e.Type, body)
+			assert.Equal(t, expected, e.Message, body)
+		}
+	}
+
+	_, e := call("tasks.create", `[{"title":"a","due":"2024-01-02T15:04:05Z"}]`)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, map[string]string{"parent": "is required"}, e.Fields)
+	}
+
+	// without the option missing fields are the zero value.
+	rr, _ = call("tasks.loose", `[{"due":1700000000000}]`)
+	assert.Equal(t, http.StatusOK, rr.Code)
+
+	// generated handlers get the raw arguments checked.
+	static := func(ctx context.Context, args []json.RawMessage) (interface{}, error) { return "static", nil }
+	r.MustRegister("tasks.static", func(task schemaTask) (string, error) { return "", nil }, WithStatic(static), WithSchemaValidation())
+	rr, e = call("tasks.static", `[{"title":"a"}]`)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, "1. argument: 'due' is required", e.Message)
+	}
+	rr, _ = call("tasks.static", `[{"title":"a","due":"2024-01-02T15:04:05Z","parent":null}]`)
+	assert.Equal(t, "\"static\"\n", rr.Body.String())
+
+	_, err := Bind(func(u *Upload[schemaTask]) error { return nil }, WithSchemaValidation())
+	assert.Error(t, err)
+}
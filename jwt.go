@@ -0,0 +1,327 @@
+package nra
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers the hashes of HS256 and RS256
+	_ "crypto/sha512" // registers the hashes of HS384, HS512, RS384 and RS512
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Claims are the claims of a verified JSON Web Token. JWTAuth attaches
+// them as identity to the call. A function can also take them as
+// parameter after the context or request, or as first parameter:
+//
+//	func DeletePost(ctx context.Context, claims nra.Claims, id int) error
+//
+// Instead of Claims the parameter can be a struct that embeds
+// RegisteredClaims, or a pointer to one. It is filled from the claims
+// the same way encoding/json would decode the payload of the token.
+type Claims map[string]interface{}
+
+// Subject returns the "sub" claim.
+func (c Claims) Subject() string {
+	s, _ := c["sub"].(string)
+	return s
+}
+
+// RegisteredClaims are the claims defined by RFC 7519. Embed it into a
+// struct with the claims of your tokens to use it as claims parameter.
+type RegisteredClaims struct {
+	Issuer    string   `json:"iss,omitempty"`
+	Subject   string   `json:"sub,omitempty"`
+	Audience  Audience `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+}
+
+// Audience is the "aud" claim, which can either be
+// a single string or a array of strings.
+type Audience []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(a))
+}
+
+// JWT configures the verification of JSON Web Tokens, see JWTAuth.
+type JWT struct {
+	// Key verifies the signatures. A []byte is the secret of HS256,
+	// HS384 and HS512 tokens, a *rsa.PublicKey the key of RS256, RS384
+	// and RS512 tokens. Tokens signed with other algorithms are rejected.
+	Key interface{}
+
+	// KeyFunc returns the key for the "kid" header of a token,
+	// e.g. to rotate keys. It is used instead of Key if set.
+	KeyFunc func(kid string) (interface{}, error)
+
+	// Issuer is the required "iss" claim, if set.
+	Issuer string
+
+	// Audience is a value the "aud" claim has to contain, if set.
+	Audience string
+
+	// Leeway is the clock skew allowed when checking
+	// the "exp" and "nbf" claims.
+	Leeway time.Duration
+
+	// Required are the claims every token has to have, e.g. "exp".
+	Required []string
+
+	// Validate checks the claims after the standard checks passed.
+	// Returning ErrForbidden rejects the call with status 403.
+	Validate func(claims Claims) error
+
+	now func() time.Time
+}
+
+// JWTAuth authenticates calls by a JSON Web Token sent as
+// "Authorization: Bearer <token>". Calls with a valid token
+// get its Claims as identity:
+//
+//	r.SetAuthenticator(nra.JWTAuth(nra.JWT{
+//		Key:      []byte(os.Getenv("JWT_SECRET")),
+//		Issuer:   "https://auth.example.com",
+//		Required: []string{"exp", "sub"},
+//	}))
+func JWTAuth(config JWT) Authenticator {
+	if config.now == nil {
+		config.now = time.Now
+	}
+	return &jwtAuthenticator{config: config}
+}
+
+// jwtAuthenticator verifies bearer tokens as JWT.
+type jwtAuthenticator struct {
+	config JWT
+}
+
+// jwtAlgorithms maps the supported algorithms to their hash.
+var jwtAlgorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256,
+	"HS384": crypto.SHA384,
+	"HS512": crypto.SHA512,
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+}
+
+// Authenticate implements Authenticator.
+func (a *jwtAuthenticator) Authenticate(request *http.Request) (interface{}, error) {
+	token := bearerToken(request)
+	if token == "" {
+		return nil, ErrUnauthenticated
+	}
+
+	claims, err := a.verify(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if a.config.Validate != nil {
+		if err := a.config.Validate(claims); err != nil {
+			return nil, err
+		}
+	}
+	return claims, nil
+}
+
+// challenge returns the WWW-Authenticate header of rejected calls.
+func (a *jwtAuthenticator) challenge() string {
+	return "Bearer"
+}
+
+// invalidToken creates the error of a token that can't be used.
+func invalidToken(reason string) error {
+	return errors.New("invalid token: " + reason)
+}
+
+// verify checks the signature and the registered
+// claims of the token and returns its claims.
+func (a *jwtAuthenticator) verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, invalidToken("malformed")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, invalidToken("malformed header")
+	}
+
+	hash, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, invalidToken("unsupported algorithm '" + header.Alg + "'")
+	}
+
+	key := a.config.Key
+	if a.config.KeyFunc != nil {
+		var err error
+		if key, err = a.config.KeyFunc(header.Kid); err != nil {
+			return nil, invalidToken(err.Error())
+		}
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, invalidToken("malformed signature")
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	// the type of the key has to fit the algorithm, so a
+	// public RSA key can't be used as HMAC secret.
+	valid := false
+	switch key := key.(type) {
+	case []byte:
+		if strings.HasPrefix(header.Alg, "HS") {
+			mac := hmac.New(hash.New, key)
+			mac.Write(signed)
+			valid = hmac.Equal(mac.Sum(nil), signature)
+		}
+	case *rsa.PublicKey:
+		if strings.HasPrefix(header.Alg, "RS") {
+			h := hash.New()
+			h.Write(signed)
+			valid = rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), signature) == nil
+		}
+	}
+	if !valid {
+		return nil, invalidToken("signature doesn't match")
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil || claims == nil {
+		return nil, invalidToken("malformed claims")
+	}
+
+	if err := a.checkClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// checkClaims checks the registered claims against the config.
+func (a *jwtAuthenticator) checkClaims(claims Claims) error {
+	for _, name := range a.config.Required {
+		if _, ok := claims[name]; !ok {
+			return invalidToken("missing claim '" + name + "'")
+		}
+	}
+
+	now := a.config.now()
+	if exp, ok := claims["exp"].(float64); ok && now.Add(-a.config.Leeway).After(time.Unix(int64(exp), 0)) {
+		return invalidToken("expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(a.config.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return invalidToken("not valid yet")
+	}
+
+	if a.config.Issuer != "" && claims["iss"] != a.config.Issuer {
+		return invalidToken("wrong issuer")
+	}
+
+	if a.config.Audience != "" {
+		found := false
+		switch aud := claims["aud"].(type) {
+		case string:
+			found = aud == a.config.Audience
+		case []interface{}:
+			for _, v := range aud {
+				found = found || v == a.config.Audience
+			}
+		}
+		if !found {
+			return invalidToken("wrong audience")
+		}
+	}
+	return nil
+}
+
+// decodeSegment decodes a base64url encoded JSON segment of a token.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+var (
+	claimsType           = reflect.TypeOf(Claims(nil))
+	registeredClaimsType = reflect.TypeOf(RegisteredClaims{})
+)
+
+// isClaims checks if a parameter of type t gets the claims of the call.
+func isClaims(t reflect.Type) bool {
+	if t == claimsType {
+		return true
+	}
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		if f := t.Field(i); f.Anonymous && f.Type == registeredClaimsType {
+			return true
+		}
+	}
+	return false
+}
+
+// claimsValue converts the identity of a call to the claims parameter
+// of type t. Identities that aren't Claims are only used if they
+// already have the right type.
+func claimsValue(t reflect.Type, identity interface{}) (reflect.Value, *Error) {
+	if identity == nil {
+		return reflect.Value{}, &Error{Message: "call isn't authenticated", Type: ErrorTypeUnauthorized}
+	}
+
+	if reflect.TypeOf(identity).AssignableTo(t) {
+		return reflect.ValueOf(identity), nil
+	}
+
+	claims, ok := identity.(Claims)
+	if !ok {
+		return reflect.Value{}, &Error{Message: "identity of the call isn't claims", Type: ErrorTypeInternal}
+	}
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return reflect.Value{}, &Error{Message: "can't convert claims: " + err.Error(), Type: ErrorTypeInternal}
+	}
+
+	elem := t
+	if t.Kind() == reflect.Ptr {
+		elem = t.Elem()
+	}
+	value := reflect.New(elem)
+	if err := json.Unmarshal(data, value.Interface()); err != nil {
+		return reflect.Value{}, &Error{Message: "invalid token: " + err.Error(), Type: ErrorTypeUnauthorized}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return value, nil
+	}
+	return value.Elem(), nil
+}
@@ -0,0 +1,168 @@
+package nra
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// signJWT creates a token with the claims signed by key.
+func signJWT(t *testing.T, alg string, key interface{}, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	var signature []byte
+	switch key := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(signed))
+		signature = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		digest := sha256.Sum256([]byte(signed))
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuth(t *testing.T) {
+	secret := []byte("secret")
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	now := time.Unix(1700000000, 0)
+	valid := map[string]interface{}{"sub": "alice", "iss": "auth", "aud": []string{"api", "web"}, "exp": now.Add(time.Minute).Unix()}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	hmacConfig := JWT{Key: secret, Issuer: "auth", Audience: "api", Leeway: 10 * time.Second, Required: []string{"sub"}, now: func() time.Time { return now }}
+	rsaConfig := JWT{Key: &rsaKey.PublicKey, now: func() time.Time { return now }}
+
+	tests := []struct {
+		name   string
+		config JWT
+		token  string
+		err    string
+	}{
+		{name: "hmac", config: hmacConfig, token: signJWT(t, "HS256", secret, valid)},
+		{name: "rsa", config: rsaConfig, token: signJWT(t, "RS256", rsaKey, valid)},
+		{name: "wrong secret", config: hmacConfig, token: signJWT(t, "HS256", []byte("other"), valid), err: "invalid token: signature doesn't match"},
+		{name: "algorithm confusion", config: rsaConfig, token: signJWT(t, "HS256", []byte("secret"), valid), err: "invalid token: signature doesn't match"},
+		{name: "none", config: hmacConfig, token: base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + ".e30.", err: "invalid token: unsupported algorithm 'none'"},
+		{name: "malformed", config: hmacConfig, token: "abc", err: "invalid token: malformed"},
+		{name: "expired", config: hmacConfig, token: signJWT(t, "HS256", secret, with("exp", now.Add(-time.Minute).Unix())), err: "invalid token: expired"},
+		{name: "leeway", config: hmacConfig, token: signJWT(t, "HS256", secret, with("exp", now.Add(-5*time.Second).Unix()))},
+		{name: "not valid yet", config: hmacConfig, token: signJWT(t, "HS256", secret, with("nbf", now.Add(time.Minute).Unix())), err: "invalid token: not valid yet"},
+		{name: "wrong issuer", config: hmacConfig, token: signJWT(t, "HS256", secret, with("iss", "other")), err: "invalid token: wrong issuer"},
+		{name: "wrong audience", config: hmacConfig, token: signJWT(t, "HS256", secret, with("aud", "web")), err: "invalid token: wrong audience"},
+		{name: "missing claim", config: hmacConfig, token: signJWT(t, "HS256", secret, with("sub", nil)), err: "invalid token: missing claim 'sub'"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", nil)
+			req.Header.Set("Authorization", "Bearer "+test.token)
+
+			identity, err := JWTAuth(test.config).Authenticate(req)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+
+			if assert.NoError(t, err) {
+				assert.Equal(t, "alice", identity.(Claims).Subject())
+			}
+		})
+	}
+}
+
+type testClaims struct {
+	RegisteredClaims
+	Role string `json:"role"`
+}
+
+func TestClaimsParameter(t *testing.T) {
+	secret := []byte("secret")
+	auth := JWTAuth(JWT{Key: secret, Validate: func(claims Claims) error {
+		if claims["role"] == "banned" {
+			return ErrForbidden
+		}
+		return nil
+	}})
+
+	r := NewRegistry()
+	r.SetAuthenticator(auth)
+	r.MustRegister("claims", func(ctx context.Context, claims Claims, n int) (string, error) {
+		return claims.Subject() + strings.Repeat("!", n), nil
+	})
+	r.MustRegister("struct", func(claims *testClaims) (string, error) {
+		return claims.Subject + " " + claims.Role, nil
+	})
+	r.MustRegister("public", func(claims Claims) (string, error) {
+		return claims.Subject(), nil
+	}, WithAuthenticator(Anonymous))
+
+	call := func(name string, claims map[string]interface{}, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		if claims != nil {
+			req.Header.Set("Authorization", "Bearer "+signJWT(t, "HS256", secret, claims))
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("claims", map[string]interface{}{"sub": "alice"}, "[2]")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\"alice!!\"\n", rec.Body.String())
+
+	rec = call("struct", map[string]interface{}{"sub": "bob", "role": "admin"}, "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\"bob admin\"\n", rec.Body.String())
+
+	rec = call("struct", map[string]interface{}{"sub": "eve", "role": "banned"}, "")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = call("claims", nil, "[2]")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+
+	// without a authenticator there are no claims to pass.
+	rec = call("public", nil, "")
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, `{"error":{"message":"call isn't authenticated","type":"unauthorized"}}`+"\n", rec.Body.String())
+
+	// the claims aren't a argument the client passes.
+	assert.Equal(t, []string{"int"}, r.Methods()[0].Params)
+
+	_, err := Bind(func(claims Claims) error { return nil }, WithStatic(func(ctx context.Context, args []json.RawMessage) (interface{}, error) { return nil, nil }))
+	assert.Error(t, err)
+}
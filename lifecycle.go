@@ -0,0 +1,288 @@
+package nra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// states of the lifecycle.
+const (
+	stateReady int32 = iota
+	stateNotStarted
+	stateShutdown
+)
+
+// lifecycle keeps the start hooks of a registry and
+// whether they already ran successfully.
+type lifecycle struct {
+	mtx     sync.Mutex
+	hooks   []func(ctx context.Context) error
+	started bool
+
+	// reporters get the report of Shutdown.
+	reporters []func(ShutdownReport)
+
+	// state is read on every call, so it is kept separately
+	// from the mutex.
+	state int32
+
+	// callsMtx guards the count of running calls, which are also
+	// counted by function. drained is closed once they finished
+	// after Shutdown was called.
+	callsMtx sync.Mutex
+	calls    int
+	running  map[string]int
+	drained  chan struct{}
+
+	// abort is cancelled if Shutdown has to give up
+	// waiting, which cancels all running calls.
+	abortOnce sync.Once
+	abort     context.Context
+	cancel    context.CancelFunc
+}
+
+// ready reports if the registry can serve calls.
+func (l *lifecycle) ready() bool {
+	return atomic.LoadInt32(&l.state) == stateReady
+}
+
+// aborted returns the context that is cancelled
+// if Shutdown runs out of time.
+func (l *lifecycle) aborted() context.Context {
+	l.abortOnce.Do(func() {
+		l.abort, l.cancel = context.WithCancel(context.Background())
+	})
+	return l.abort
+}
+
+// begin counts a call of the function as running and returns its
+// context, which is cancelled if Shutdown runs out of time. done has
+// to be called once the call finished. It fails if the registry is
+// shutting down.
+func (l *lifecycle) begin(parent context.Context, name string) (context.Context, func(), bool) {
+	l.callsMtx.Lock()
+	defer l.callsMtx.Unlock()
+
+	if atomic.LoadInt32(&l.state) == stateShutdown {
+		return nil, nil, false
+	}
+	l.calls++
+	if l.running == nil {
+		l.running = map[string]int{}
+	}
+	l.running[name]++
+
+	ctx, cancel := context.WithCancel(parent)
+	stop := context.AfterFunc(l.aborted(), cancel)
+	return ctx, func() {
+		stop()
+		cancel()
+
+		l.callsMtx.Lock()
+		defer l.callsMtx.Unlock()
+
+		if l.running[name]--; l.running[name] == 0 {
+			delete(l.running, name)
+		}
+		if l.calls--; l.calls == 0 && l.drained != nil {
+			close(l.drained)
+			l.drained = nil
+		}
+	}, true
+}
+
+// runningCalls returns how many calls of each function are running.
+func (l *lifecycle) runningCalls() (int, map[string]int) {
+	l.callsMtx.Lock()
+	defer l.callsMtx.Unlock()
+
+	running := make(map[string]int, len(l.running))
+	for name, n := range l.running {
+		running[name] = n
+	}
+	return l.calls, running
+}
+
+// writeNotReady answers a call the registry can't serve right now.
+func (l *lifecycle) writeNotReady(writer http.ResponseWriter) {
+	if atomic.LoadInt32(&l.state) == stateShutdown {
+		writer.Header().Set("Retry-After", "1")
+		writeError(writer, http.StatusServiceUnavailable, &Error{Message: "registry is shutting down", Type: ErrorTypeUnavailable})
+		return
+	}
+	writeError(writer, http.StatusServiceUnavailable, &Error{Message: "registry is not ready", Type: ErrorTypeUnavailable})
+}
+
+// OnStart adds a hook that is run by Start, e.g. to migrate
+// a database or warm a cache. Once a hook was added the registry
+// doesn't serve any calls until Start finished successfully:
+//
+//	r.OnStart(func(ctx context.Context) error {
+//	  return db.Migrate(ctx)
+//	})
+//	if err := r.Start(ctx); err != nil {
+//	  log.Fatal(err)
+//	}
+//	http.ListenAndServe(":8080", r)
+//
+// Hooks added after Start succeeded are ignored.
+func (r *Registry) OnStart(hook func(ctx context.Context) error) {
+	r.lifecycle.mtx.Lock()
+	defer r.lifecycle.mtx.Unlock()
+
+	// the registry already started, so there is nothing to wait for.
+	if r.lifecycle.started {
+		return
+	}
+
+	r.lifecycle.hooks = append(r.lifecycle.hooks, hook)
+	atomic.CompareAndSwapInt32(&r.lifecycle.state, stateReady, stateNotStarted)
+}
+
+// Start runs the start hooks in the order they were added and
+// makes the registry ready. If a hook fails the remaining hooks
+// are skipped and the registry stays unavailable, so Start can
+// be called again to retry. Hooks are only run until they
+// succeeded once.
+func (r *Registry) Start(ctx context.Context) error {
+	r.lifecycle.mtx.Lock()
+	defer r.lifecycle.mtx.Unlock()
+
+	if r.lifecycle.started || atomic.LoadInt32(&r.lifecycle.state) == stateShutdown {
+		return nil
+	}
+
+	for len(r.lifecycle.hooks) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if err := r.lifecycle.hooks[0](ctx); err != nil {
+			return fmt.Errorf("start hook failed: %w", err)
+		}
+		r.lifecycle.hooks = r.lifecycle.hooks[1:]
+	}
+
+	r.lifecycle.started = true
+	atomic.CompareAndSwapInt32(&r.lifecycle.state, stateNotStarted, stateReady)
+	return nil
+}
+
+// ShutdownReport tells what happened to the calls that were still
+// running when Shutdown was called, so a deploy can be checked for
+// cut off work.
+type ShutdownReport struct {
+	// Started is when Shutdown was called and Duration
+	// how long it took.
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"duration"`
+
+	// Running is the number of calls that were running, Drained how
+	// many of them finished in time. Aborted has the number of calls
+	// by function that were cancelled because ctx was done first.
+	Running int            `json:"running"`
+	Drained int            `json:"drained"`
+	Aborted map[string]int `json:"aborted,omitempty"`
+}
+
+// OnShutdown adds a hook that gets the report of each call to Shutdown
+// once it returns, e.g. to store it for post-mortems:
+//
+//	r.OnShutdown(func(report nra.ShutdownReport) {
+//	  _ = json.NewEncoder(os.Stderr).Encode(report)
+//	})
+//
+// With a logger, see SetLogger, the report is also logged.
+func (r *Registry) OnShutdown(hook func(ShutdownReport)) {
+	r.lifecycle.mtx.Lock()
+	defer r.lifecycle.mtx.Unlock()
+
+	r.lifecycle.reporters = append(r.lifecycle.reporters, hook)
+}
+
+// Shutdown stops the registry from accepting calls and waits for the
+// running calls to finish. New calls are answered with status 503 and
+// a Retry-After header, so clients retry them on another server. If
+// ctx is done before all calls finished, their contexts are cancelled
+// and the error of ctx is returned. Shut the registry down before the
+// http.Server, so calls aren't cut off:
+//
+//	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+//	defer cancel()
+//	_ = r.Shutdown(ctx)
+//	_ = server.Shutdown(ctx)
+//
+// A registry that was shut down can't be started again.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	l := &r.lifecycle
+	report := ShutdownReport{Started: time.Now()}
+
+	l.callsMtx.Lock()
+	atomic.StoreInt32(&l.state, stateShutdown)
+	report.Running = l.calls
+	report.Drained = l.calls
+	if l.calls > 0 && l.drained == nil {
+		l.drained = make(chan struct{})
+	}
+	drained := l.drained
+	l.callsMtx.Unlock()
+
+	var err error
+	if report.Running > 0 {
+		select {
+		case <-drained:
+		case <-ctx.Done():
+			// the calls still running now are the ones
+			// that get cancelled.
+			var aborted int
+			aborted, report.Aborted = l.runningCalls()
+			report.Drained -= aborted
+
+			l.aborted()
+			l.cancel()
+			err = ctx.Err()
+		}
+	}
+	report.Duration = time.Since(report.Started)
+
+	r.reportShutdown(report)
+	return err
+}
+
+// reportShutdown passes the report to the hooks and the logger.
+func (r *Registry) reportShutdown(report ShutdownReport) {
+	r.lifecycle.mtx.Lock()
+	reporters := append([]func(ShutdownReport){}, r.lifecycle.reporters...)
+	r.lifecycle.mtx.Unlock()
+
+	for _, hook := range reporters {
+		hook(report)
+	}
+
+	if r.logger == nil {
+		return
+	}
+
+	level := slog.LevelInfo
+	attrs := []slog.Attr{
+		slog.Duration("duration", report.Duration),
+		slog.Int("running", report.Running),
+		slog.Int("drained", report.Drained),
+	}
+	if len(report.Aborted) > 0 {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.Any("aborted", report.Aborted))
+	}
+	r.logger.logger.LogAttrs(context.Background(), level, "shutdown", attrs...)
+}
+
+// Ready reports if the registry serves calls, which is the case if it
+// has no start hooks or Start succeeded, and it isn't shut down.
+func (r *Registry) Ready() bool {
+	return r.lifecycle.ready()
+}
@@ -0,0 +1,170 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistryStart(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("ping", func() error { return nil })
+
+	call := func() *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/ping", nil))
+		return rr
+	}
+
+	assert.True(t, r.Ready(), "registry without hooks is ready")
+
+	var ran []string
+	fail := errors.New("database down")
+	r.OnStart(func(ctx context.Context) error {
+		ran = append(ran, "migrate")
+		return nil
+	})
+	r.OnStart(func(ctx context.Context) error {
+		ran = append(ran, "warm")
+		return fail
+	})
+
+	assert.False(t, r.Ready())
+	rr := call()
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"registry is not ready\",\"type\":\"unavailable\"}}\n", rr.Body.String())
+
+	err := r.Start(context.Background())
+	assert.True(t, errors.Is(err, fail))
+	assert.False(t, r.Ready())
+	assert.Equal(t, http.StatusServiceUnavailable, call().Code)
+
+	// a retry only runs the hooks that didn't succeed yet.
+	fail = nil
+	assert.NoError(t, r.Start(context.Background()))
+	assert.Equal(t, []string{"migrate", "warm", "warm"}, ran)
+	assert.True(t, r.Ready())
+	assert.Equal(t, http.StatusOK, call().Code)
+
+	assert.NoError(t, r.Start(context.Background()))
+	assert.Equal(t, []string{"migrate", "warm", "warm"}, ran)
+}
+
+func TestRegistryStartCancelled(t *testing.T) {
+	r := NewRegistry()
+	r.OnStart(func(ctx context.Context) error { return nil })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.Equal(t, context.Canceled, r.Start(ctx))
+	assert.False(t, r.Ready())
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := NewRegistry()
+	r.MustRegister("ping", func() error { return nil })
+	r.MustRegister("slow", func(ctx context.Context) error {
+		close(started)
+		<-release
+		return nil
+	})
+
+	var reports []ShutdownReport
+	r.OnShutdown(func(report ShutdownReport) {
+		reports = append(reports, report)
+	})
+
+	// without running calls it returns right away.
+	assert.NoError(t, NewRegistry().Shutdown(context.Background()))
+
+	finished := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/slow", nil))
+		finished <- rr.Code
+	}()
+	<-started
+
+	shutdown := make(chan error)
+	go func() {
+		shutdown <- r.Shutdown(context.Background())
+	}()
+
+	assert.Eventually(t, func() bool { return !r.Ready() }, time.Second, time.Millisecond)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/ping", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Contains(t, rr.Body.String(), "registry is shutting down")
+
+	select {
+	case <-shutdown:
+		t.Fatal("shutdown didn't wait for the running call")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-finished)
+	assert.NoError(t, <-shutdown)
+	assert.NoError(t, r.Start(context.Background()))
+	assert.False(t, r.Ready())
+
+	assert.Len(t, reports, 1)
+	assert.Equal(t, 1, reports[0].Running)
+	assert.Equal(t, 1, reports[0].Drained)
+	assert.Empty(t, reports[0].Aborted)
+	assert.True(t, reports[0].Duration >= 10*time.Millisecond)
+}
+
+func TestRegistryShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+
+	r := NewRegistry()
+	r.MustRegister("stuck", func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	var logs bytes.Buffer
+	r.SetLogger(slog.New(slog.NewTextHandler(&logs, nil)))
+
+	var report ShutdownReport
+	r.OnShutdown(func(s ShutdownReport) {
+		report = s
+	})
+
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/stuck", nil))
+	}()
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.True(t, errors.Is(r.Shutdown(ctx), context.DeadlineExceeded))
+
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatal("the context of the running call wasn't cancelled")
+	}
+
+	assert.Equal(t, 1, report.Running)
+	assert.Equal(t, 0, report.Drained)
+	assert.Equal(t, map[string]int{"stuck": 1}, report.Aborted)
+	assert.Contains(t, logs.String(), "level=WARN msg=shutdown")
+	assert.Contains(t, logs.String(), "aborted=map[stuck:1]")
+}
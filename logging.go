@@ -0,0 +1,114 @@
+package nra
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// DefaultSlowCall is the duration after which calls are
+// logged as slow if no other threshold was set.
+const DefaultSlowCall = time.Second
+
+// SetLogger makes the registry log the calls of its functions as
+// structured events to logger:
+//
+//   - "call started" (debug) before a function is called
+//   - "call finished" (info) after it succeeded
+//   - "decode failed" (warn) if the arguments couldn't be decoded
+//   - "call failed" (warn, or error for server errors) if it failed
+//   - "slow call" (warn) if it took longer than the threshold
+//   - "function panicked" (error) with the stack of the panic
+//   - "shutdown" (info, or warn if calls were aborted) with the
+//     ShutdownReport, see OnShutdown
+//   - "recording failed" (warn) if a call couldn't be recorded,
+//     see SetRecording
+//
+// All events have the function, and the request id if enabled, as
+// attributes. For calls that aren't sampled, see WithSampleRate, only
+// failures, slow calls and panics are logged. Calling it with nil
+// disables logging.
+func (r *Registry) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		r.logger = nil
+		return
+	}
+
+	slow := DefaultSlowCall
+	if r.logger != nil {
+		slow = r.logger.slow
+	}
+	r.logger = &callLogger{logger: logger, slow: slow}
+}
+
+// SetSlowCallThreshold sets the duration after which calls are logged
+// as slow, see SetLogger. It defaults to DefaultSlowCall and has to be
+// called after SetLogger.
+func (r *Registry) SetSlowCallThreshold(d time.Duration) {
+	if r.logger != nil {
+		r.logger.slow = d
+	}
+}
+
+// callLogger is the Observer that logs the calls of a registry.
+type callLogger struct {
+	logger *slog.Logger
+	slow   time.Duration
+}
+
+// StartCall implements Observer.
+func (l *callLogger) StartCall(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+	ctx := request.Context()
+	logger := l.logger.With(slog.String("function", name))
+	if id := RequestIDOf(ctx); id != "" {
+		logger = logger.With(slog.String("request_id", id))
+	}
+
+	if Sampled(ctx) {
+		logger.DebugContext(ctx, "call started")
+	}
+	return request, func(info CallInfo) {
+		l.finish(ctx, logger, info)
+	}
+}
+
+// finish logs the outcome of the call.
+func (l *callLogger) finish(ctx context.Context, logger *slog.Logger, info CallInfo) {
+	attrs := []slog.Attr{
+		slog.Int("status", info.Status),
+		slog.Duration("duration", info.Duration),
+		slog.Int("args", info.Args),
+	}
+
+	switch {
+	case info.Panic != nil:
+		attrs = append(attrs, slog.String("panic", fmt.Sprint(info.Panic)), slog.String("stack", string(debug.Stack())))
+		logger.LogAttrs(ctx, slog.LevelError, "function panicked", attrs...)
+	case info.Error != nil:
+		attrs = append(attrs, slog.String("error", info.Error.Message), slog.String("error_type", string(info.Error.Type)))
+
+		switch {
+		case info.Status >= http.StatusInternalServerError:
+			logger.LogAttrs(ctx, slog.LevelError, "call failed", attrs...)
+		case isDecodeError(info.Error.Type):
+			logger.LogAttrs(ctx, slog.LevelWarn, "decode failed", attrs...)
+		default:
+			logger.LogAttrs(ctx, slog.LevelWarn, "call failed", attrs...)
+		}
+	case info.Sampled:
+		logger.LogAttrs(ctx, slog.LevelInfo, "call finished", attrs...)
+	}
+
+	if l.slow > 0 && info.Duration >= l.slow {
+		logger.LogAttrs(ctx, slog.LevelWarn, "slow call", slog.Duration("duration", info.Duration), slog.Duration("threshold", l.slow))
+	}
+}
+
+// isDecodeError reports if errors of type t are caused
+// by arguments that couldn't be decoded.
+func isDecodeError(t ErrorType) bool {
+	return t == ErrorTypeRequest || t == ErrorTypeConversion || t == ErrorTypeValidation
+}
@@ -0,0 +1,87 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	var out bytes.Buffer
+	r := NewRegistry()
+	r.EnableRequestIDs()
+	r.SetLogger(slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	r.SetSlowCallThreshold(20 * time.Millisecond)
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("fail", func() error { return errors.New("failed") })
+	r.MustRegister("slow", func() error {
+		time.Sleep(30 * time.Millisecond)
+		return nil
+	})
+	r.MustRegister("panic", func() error { panic("boom") })
+
+	events := func(name string, body string) []map[string]interface{} {
+		out.Reset()
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		req.Header.Set(RequestIDHeader, "abc")
+		func() {
+			defer func() { recover() }()
+			r.ServeHTTP(httptest.NewRecorder(), req)
+		}()
+
+		var events []map[string]interface{}
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			var event map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(line), &event))
+			assert.Equal(t, name, event["function"])
+			assert.Equal(t, "abc", event["request_id"])
+			events = append(events, event)
+		}
+		return events
+	}
+
+	logged := events("add", "[1, 2]")
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "call started", logged[0]["msg"])
+		assert.Equal(t, "DEBUG", logged[0]["level"])
+		assert.Equal(t, "call finished", logged[1]["msg"])
+		assert.Equal(t, "INFO", logged[1]["level"])
+		assert.Equal(t, float64(200), logged[1]["status"])
+		assert.Equal(t, float64(2), logged[1]["args"])
+	}
+
+	logged = events("add", `[1, "a"]`)
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "decode failed", logged[1]["msg"])
+		assert.Equal(t, "WARN", logged[1]["level"])
+		assert.Equal(t, "conversion", logged[1]["error_type"])
+	}
+
+	logged = events("fail", "")
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "call failed", logged[1]["msg"])
+		assert.Equal(t, "failed", logged[1]["error"])
+	}
+
+	logged = events("slow", "")
+	if assert.Len(t, logged, 3) {
+		assert.Equal(t, "call finished", logged[1]["msg"])
+		assert.Equal(t, "slow call", logged[2]["msg"])
+	}
+
+	logged = events("panic", "")
+	if assert.Len(t, logged, 2) {
+		assert.Equal(t, "function panicked", logged[1]["msg"])
+		assert.Equal(t, "ERROR", logged[1]["level"])
+		assert.Equal(t, "boom", logged[1]["panic"])
+		assert.Equal(t, float64(500), logged[1]["status"])
+		assert.Contains(t, logged[1]["stack"], "panic")
+	}
+}
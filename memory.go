@@ -0,0 +1,99 @@
+package nra
+
+import (
+	"errors"
+	"io"
+	"runtime/metrics"
+	"time"
+)
+
+// memoryPollInterval is the interval in which the heap
+// allocations are checked while a function with a memory
+// budget is running.
+const memoryPollInterval = 10 * time.Millisecond
+
+// heapAllocsMetric is the cumulative number of bytes
+// allocated on the heap by the whole process.
+const heapAllocsMetric = "/gc/heap/allocs:bytes"
+
+// errMemoryBudget is returned by a budgetReader
+// once more than the budget has been read.
+var errMemoryBudget = errors.New("request body exceeds the memory budget")
+
+// WithMemoryBudget restricts the approximate amount of memory a single
+// call can allocate. The request body can't be larger than the budget and
+// while the function runs the heap allocations are tracked. If they exceed
+// the budget the call is aborted with a error of the type ErrorTypeLimit
+// and the context of the request is cancelled, which also cancels the
+// goroutines the function started with Go.
+//
+// The allocations are measured for the whole process, so allocations of
+// calls running at the same time are counted as well. The function isn't
+// stopped when it is aborted, it should check the context of the request
+// to stop early.
+func WithMemoryBudget(bytes uint64) Option {
+	return func(o *options) {
+		o.memoryBudget = bytes
+	}
+}
+
+// budgetReader fails with errMemoryBudget once
+// more than remaining bytes are read.
+type budgetReader struct {
+	io.ReadCloser
+	remaining uint64
+}
+
+// Read implements io.Reader.
+func (b *budgetReader) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if uint64(n) > b.remaining {
+		b.remaining = 0
+		return 0, errMemoryBudget
+	}
+	b.remaining -= uint64(n)
+	return n, err
+}
+
+// heapAllocs returns the cumulative heap allocations of the process.
+func heapAllocs() uint64 {
+	sample := []metrics.Sample{{Name: heapAllocsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// runWithMemoryBudget runs call in its own goroutine and watches the heap
+// allocations while it runs. It returns false as soon as more than budget
+// bytes have been allocated, leaving call running in the background. A
+// panic inside call is passed on to the calling goroutine.
+func runWithMemoryBudget(budget uint64, call func()) bool {
+	start := heapAllocs()
+
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		call()
+	}()
+
+	ticker := time.NewTicker(memoryPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case p := <-done:
+			if p != nil {
+				panic(p)
+			}
+			return true
+		case <-ticker.C:
+			if heapAllocs()-start > budget {
+				return false
+			}
+		}
+	}
+}
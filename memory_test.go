@@ -0,0 +1,85 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithMemoryBudget(t *testing.T) {
+	cancelled := make(chan struct{})
+	var sink [][]byte
+
+	h := MustBind(func(r *http.Request, mb int) (int, error) {
+		for i := 0; i < mb; i++ {
+			sink = append(sink, bytes.Repeat([]byte{1}, 1<<20))
+			time.Sleep(time.Millisecond)
+
+			select {
+			case <-r.Context().Done():
+				close(cancelled)
+				return 0, r.Context().Err()
+			default:
+			}
+		}
+		return mb, nil
+	}, WithMemoryBudget(16<<20))
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("[1]")))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	sink = nil
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("[512]")))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"call exceeded the memory budget\",\"type\":\"limit\"}}\n", rr.Body.String())
+
+	select {
+	case <-cancelled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("context of the aborted call wasn't cancelled")
+	}
+	sink = nil
+
+	rr = httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("["+strings.Repeat(" ", 32<<20)+"1]")))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"request body exceeds the memory budget\",\"type\":\"limit\"}}\n", rr.Body.String())
+}
+
+func TestWithMemoryBudgetCancelsGoroutines(t *testing.T) {
+	h := MustBind(func(ctx context.Context) (int, error) {
+		var sink [][]byte
+		Go(ctx, func(ctx context.Context) error {
+			<-ctx.Done()
+			return nil
+		})
+		for i := 0; i < 512 && ctx.Err() == nil; i++ {
+			sink = append(sink, bytes.Repeat([]byte{1}, 1<<20))
+			time.Sleep(time.Millisecond)
+		}
+		return len(sink), nil
+	}, WithMemoryBudget(16<<20))
+
+	done := make(chan struct{})
+	rr := httptest.NewRecorder()
+	go func() {
+		defer close(done)
+		h.ServeHTTP(rr, httptest.NewRequest("POST", "/", nil))
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the aborted call waits for its goroutines")
+	}
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"call exceeded the memory budget\",\"type\":\"limit\"}}\n", rr.Body.String())
+}
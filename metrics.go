@@ -0,0 +1,240 @@
+package nra
+
+import (
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultDurationBuckets are the upper bounds in seconds of the
+// buckets of the call durations collected by Metrics.
+var DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets are the upper bounds in bytes of the buckets
+// of the request and response sizes collected by Metrics.
+var DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216}
+
+// Metrics is a Observer that counts the calls, errors, durations and
+// payload sizes of every function. It serves them in the Prometheus
+// text format, so it can be scraped without any other dependency:
+//
+//	metrics := nra.NewMetrics()
+//	r.AddObserver(metrics)
+//	http.Handle("/metrics", metrics)
+//
+// It is safe to use from multiple goroutines.
+type Metrics struct {
+	mtx       sync.Mutex
+	functions map[string]*FunctionMetrics
+}
+
+// FunctionMetrics are the metrics of a single function.
+type FunctionMetrics struct {
+	// Name is the name of the function.
+	Name string
+
+	// Calls is the number of calls.
+	Calls uint64
+
+	// Errors is the number of failed calls by the type of their error.
+	Errors map[ErrorType]uint64
+
+	// Duration is the distribution of the call durations in seconds.
+	Duration Histogram
+
+	// RequestSize and ResponseSize are the distributions
+	// of the size of the request and response bodies.
+	RequestSize  Histogram
+	ResponseSize Histogram
+}
+
+// Histogram counts observed values in buckets.
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets.
+	Buckets []float64
+
+	// Counts are the number of values in each bucket. Values
+	// above the last bound are only in Count.
+	Counts []uint64
+
+	// Count and Sum are the number and the sum of all values.
+	Count uint64
+	Sum   float64
+}
+
+// newHistogram creates a empty histogram with the buckets.
+func newHistogram(buckets []float64) Histogram {
+	return Histogram{Buckets: buckets, Counts: make([]uint64, len(buckets))}
+}
+
+// observe adds the value to the histogram.
+func (h *Histogram) observe(value float64) {
+	h.Count++
+	h.Sum += value
+
+	if i := sort.SearchFloat64s(h.Buckets, value); i < len(h.Buckets) {
+		h.Counts[i]++
+	}
+}
+
+// clone returns a copy of the histogram.
+func (h Histogram) clone() Histogram {
+	h.Counts = append([]uint64(nil), h.Counts...)
+	return h
+}
+
+// NewMetrics creates a empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{functions: map[string]*FunctionMetrics{}}
+}
+
+// StartCall implements Observer.
+func (m *Metrics) StartCall(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+	return request, m.record
+}
+
+// record adds the finished call to the metrics.
+func (m *Metrics) record(info CallInfo) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	f, ok := m.functions[info.Name]
+	if !ok {
+		f = &FunctionMetrics{
+			Name:         info.Name,
+			Errors:       map[ErrorType]uint64{},
+			Duration:     newHistogram(DefaultDurationBuckets),
+			RequestSize:  newHistogram(DefaultSizeBuckets),
+			ResponseSize: newHistogram(DefaultSizeBuckets),
+		}
+		m.functions[info.Name] = f
+	}
+
+	f.Calls++
+	if info.Error != nil {
+		f.Errors[info.Error.Type]++
+	}
+	f.Duration.observe(info.Duration.Seconds())
+	f.RequestSize.observe(float64(info.BytesIn))
+	f.ResponseSize.observe(float64(info.BytesOut))
+}
+
+// Functions returns a copy of the metrics of all
+// functions that were called, sorted by their name.
+func (m *Metrics) Functions() []FunctionMetrics {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	functions := make([]FunctionMetrics, 0, len(m.functions))
+	for _, f := range m.functions {
+		c := *f
+		c.Errors = make(map[ErrorType]uint64, len(f.Errors))
+		for t, n := range f.Errors {
+			c.Errors[t] = n
+		}
+		c.Duration = f.Duration.clone()
+		c.RequestSize = f.RequestSize.clone()
+		c.ResponseSize = f.ResponseSize.clone()
+		functions = append(functions, c)
+	}
+
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].Name < functions[j].Name
+	})
+	return functions
+}
+
+// ServeHTTP serves the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = m.WritePrometheus(writer)
+}
+
+// WritePrometheus writes the metrics in the Prometheus text format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	functions := m.Functions()
+
+	var buf strings.Builder
+	buf.WriteString("# HELP nra_calls_total Number of calls by function.\n")
+	buf.WriteString("# TYPE nra_calls_total counter\n")
+	for _, f := range functions {
+		buf.WriteString("nra_calls_total" + promLabels("function", f.Name) + " " + strconv.FormatUint(f.Calls, 10) + "\n")
+	}
+
+	buf.WriteString("# HELP nra_errors_total Number of failed calls by function and error type.\n")
+	buf.WriteString("# TYPE nra_errors_total counter\n")
+	for _, f := range functions {
+		types := make([]string, 0, len(f.Errors))
+		for t := range f.Errors {
+			types = append(types, string(t))
+		}
+		sort.Strings(types)
+
+		for _, t := range types {
+			buf.WriteString("nra_errors_total" + promLabels("function", f.Name, "type", t) + " " + strconv.FormatUint(f.Errors[ErrorType(t)], 10) + "\n")
+		}
+	}
+
+	histograms := []struct {
+		name string
+		help string
+		of   func(f *FunctionMetrics) Histogram
+	}{
+		{"nra_call_duration_seconds", "Duration of calls by function.", func(f *FunctionMetrics) Histogram { return f.Duration }},
+		{"nra_request_size_bytes", "Size of the request bodies by function.", func(f *FunctionMetrics) Histogram { return f.RequestSize }},
+		{"nra_response_size_bytes", "Size of the response bodies by function.", func(f *FunctionMetrics) Histogram { return f.ResponseSize }},
+	}
+	for _, h := range histograms {
+		buf.WriteString("# HELP " + h.name + " " + h.help + "\n")
+		buf.WriteString("# TYPE " + h.name + " histogram\n")
+		for i := range functions {
+			writePromHistogram(&buf, h.name, functions[i].Name, h.of(&functions[i]))
+		}
+	}
+
+	_, err := io.WriteString(w, buf.String())
+	return err
+}
+
+// writePromHistogram writes the series of a histogram.
+func writePromHistogram(buf *strings.Builder, name string, function string, h Histogram) {
+	var cumulative uint64
+	for i, bound := range h.Buckets {
+		cumulative += h.Counts[i]
+		buf.WriteString(name + "_bucket" + promLabels("function", function, "le", promFloat(bound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+	}
+	buf.WriteString(name + "_bucket" + promLabels("function", function, "le", "+Inf") + " " + strconv.FormatUint(h.Count, 10) + "\n")
+	buf.WriteString(name + "_sum" + promLabels("function", function) + " " + promFloat(h.Sum) + "\n")
+	buf.WriteString(name + "_count" + promLabels("function", function) + " " + strconv.FormatUint(h.Count, 10) + "\n")
+}
+
+// promLabels formats the label pairs of a series.
+func promLabels(pairs ...string) string {
+	var buf strings.Builder
+	buf.WriteString("{")
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		buf.WriteString(pairs[i] + "=" + promQuote(pairs[i+1]))
+	}
+	buf.WriteString("}")
+	return buf.String()
+}
+
+// promQuote quotes a label value. Unlike Go strings
+// only backslashes, quotes and newlines are escaped.
+func promQuote(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
+
+// promFloat formats a float the way Prometheus does.
+func promFloat(value float64) string {
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
@@ -0,0 +1,61 @@
+package nra
+
+import (
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+
+	r := NewRegistry()
+	r.AddObserver(metrics)
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("fail", func() error { return errors.New("failed") })
+
+	for i := 0; i < 2; i++ {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]")))
+	}
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/add", strings.NewReader(`["a"]`)))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/fail", nil))
+
+	functions := metrics.Functions()
+	if !assert.Len(t, functions, 2) {
+		return
+	}
+
+	add := functions[0]
+	assert.Equal(t, "add", add.Name)
+	assert.Equal(t, uint64(3), add.Calls)
+	assert.Equal(t, map[ErrorType]uint64{ErrorTypeValidation: 1}, add.Errors)
+	assert.Equal(t, uint64(3), add.Duration.Count)
+	assert.Equal(t, float64(6+6+5), add.RequestSize.Sum)
+	assert.Equal(t, uint64(3), add.RequestSize.Counts[0])
+
+	rec := httptest.NewRecorder()
+	metrics.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+	assert.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+	for _, line := range []string{
+		"# TYPE nra_calls_total counter",
+		`nra_calls_total{function="add"} 3`,
+		`nra_calls_total{function="fail"} 1`,
+		`nra_errors_total{function="add",type="validation"} 1`,
+		`nra_errors_total{function="fail",type="application"} 1`,
+		"# TYPE nra_call_duration_seconds histogram",
+		`nra_call_duration_seconds_bucket{function="add",le="+Inf"} 3`,
+		`nra_call_duration_seconds_count{function="fail"} 1`,
+		`nra_request_size_bytes_bucket{function="add",le="64"} 3`,
+		`nra_request_size_bytes_sum{function="add"} 17`,
+	} {
+		assert.Contains(t, body, line+"\n")
+	}
+}
+
+func TestPromQuote(t *testing.T) {
+	assert.Equal(t, `"a\"b\\c\nd"`, promQuote("a\"b\\c\nd"))
+}
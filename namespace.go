@@ -0,0 +1,126 @@
+package nra
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps the handler of a bound function, e.g. to check
+// permissions or add headers. It runs after the registry routed the
+// call and before the arguments are decoded.
+type Middleware func(next http.Handler) http.Handler
+
+// WithMiddleware wraps the function in the middleware. The first one
+// is the outermost, so it runs first. Passing the option more than
+// once adds to the middleware set before.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(o *options) {
+		o.middleware = append(o.middleware, middleware...)
+	}
+}
+
+// withMiddleware wraps handler in the middleware.
+func withMiddleware(handler http.HandlerFunc, middleware []Middleware) http.HandlerFunc {
+	if len(middleware) == 0 {
+		return handler
+	}
+
+	var h http.Handler = handler
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h.ServeHTTP
+}
+
+// Group registers functions under a common namespace, e.g.
+// users.create and users.delete, and passes its options to all of
+// them, so authentication, middleware or limits that apply to the
+// whole group are only set once:
+//
+//	users := r.Group("users", nra.WithAuthenticator(auth))
+//	users.MustRegister("create", createUser)
+//	users.MustRegister("delete", deleteUser, nra.WithRateLimit(limit))
+//
+// The options of the group are applied before the options passed
+// to Register, so a function can override them.
+type Group struct {
+	registry *Registry
+	name     string
+	opts     []Option
+}
+
+// Group creates a group whose functions are
+// registered under "<name>.<function>".
+func (r *Registry) Group(name string, opts ...Option) *Group {
+	return &Group{registry: r, name: name, opts: opts}
+}
+
+// Name returns the namespace of the group, e.g. admin.users.
+func (g *Group) Name() string {
+	return g.name
+}
+
+// Group creates a nested group under "<group>.<name>", which has the
+// options of g followed by opts.
+func (g *Group) Group(name string, opts ...Option) *Group {
+	return &Group{registry: g.registry, name: g.name + "." + name, opts: g.options(opts)}
+}
+
+// Use adds options, e.g. WithMiddleware, to the group. They only
+// apply to the functions registered afterwards.
+func (g *Group) Use(opts ...Option) {
+	g.opts = append(g.opts, opts...)
+}
+
+// Register binds fn with the options of the group followed by opts and
+// makes it callable under "<group>.<name>". See Registry.Register.
+func (g *Group) Register(name string, fn interface{}, opts ...Option) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.registry.Register(g.name+"."+name, fn, g.options(opts)...)
+}
+
+// MustRegister is the same as Register but panics
+// if the function can't be registered.
+func (g *Group) MustRegister(name string, fn interface{}, opts ...Option) {
+	if err := g.Register(name, fn, opts...); err != nil {
+		panic("nra: register failed with: " + err.Error())
+	}
+}
+
+// RegisterService registers the methods of svc under
+// "<group>.<name>.<Method>". See Registry.RegisterService.
+func (g *Group) RegisterService(name string, svc interface{}, opts ...Option) error {
+	if err := g.check(); err != nil {
+		return err
+	}
+	return g.registry.RegisterService(g.name+"."+name, svc, g.options(opts)...)
+}
+
+// MustRegisterService is the same as RegisterService
+// but panics if the service can't be registered.
+func (g *Group) MustRegisterService(name string, svc interface{}, opts ...Option) {
+	if err := g.RegisterService(name, svc, opts...); err != nil {
+		panic("nra: register service failed with: " + err.Error())
+	}
+}
+
+// check reports a invalid group name, which would
+// otherwise only show up as a odd function name.
+func (g *Group) check() error {
+	for _, part := range strings.Split(g.name, ".") {
+		if len(part) == 0 || strings.Contains(part, "/") {
+			return errors.New("group name '" + g.name + "' can't have empty parts or contain a '/'")
+		}
+	}
+	return nil
+}
+
+// options returns the options of the group followed by opts. The
+// slice is new, so appending to it doesn't change the group.
+func (g *Group) options(opts []Option) []Option {
+	all := make([]Option, 0, len(g.opts)+len(opts))
+	return append(append(all, g.opts...), opts...)
+}
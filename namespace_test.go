@@ -0,0 +1,83 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup(t *testing.T) {
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls = append(calls, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeError(w, http.StatusForbidden, &Error{Message: "denied", Type: ErrorTypeForbidden})
+		})
+	}
+
+	r := NewRegistry()
+	users := r.Group("users", WithMiddleware(trace("users")), WithMaxBodyBytes(8))
+	users.MustRegister("create", func(name string) (string, error) { return name, nil })
+	users.MustRegister("big", func(name string) (string, error) { return name, nil }, WithMaxBodyBytes(1024))
+
+	admin := users.Group("admin", WithMiddleware(trace("admin")))
+	admin.MustRegister("promote", func() error { return nil })
+
+	users.Use(WithMiddleware(deny))
+	users.MustRegister("delete", func() error { return nil })
+
+	assert.Equal(t, []string{"users.admin.promote", "users.big", "users.create", "users.delete"}, r.Names())
+	assert.Equal(t, "users.admin", admin.Name())
+
+	call := func(name, body string) *httptest.ResponseRecorder {
+		calls = nil
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body)))
+		return rec
+	}
+
+	rec := call("users.create", `["a"]`)
+	assert.Equal(t, "\"a\"\n", rec.Body.String())
+	assert.Equal(t, []string{"users"}, calls)
+
+	// the options of the function override the ones of the group.
+	assert.Equal(t, http.StatusRequestEntityTooLarge, call("users.create", `["abcdefghij"]`).Code)
+	assert.Equal(t, http.StatusOK, call("users.big", `["abcdefghij"]`).Code)
+
+	assert.Equal(t, http.StatusOK, call("users.admin.promote", "").Code)
+	assert.Equal(t, []string{"users", "admin"}, calls)
+
+	// options added with Use only apply to later functions.
+	assert.Equal(t, http.StatusForbidden, call("users.delete", "").Code)
+	assert.Equal(t, http.StatusOK, call("users.create", `["a"]`).Code)
+
+	assert.Error(t, r.Group("").Register("x", func() error { return nil }))
+	assert.Error(t, r.Group("a..b").Register("x", func() error { return nil }))
+	assert.Error(t, users.Register("create", func() error { return nil }))
+}
+
+func TestWithMiddleware(t *testing.T) {
+	header := func(value string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Add("X-Order", value)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := MustBind(func() error { return nil }, WithMiddleware(header("1"), header("2")), WithMiddleware(header("3")))
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, []string{"1", "2", "3"}, rec.Header().Values("X-Order"))
+}
@@ -0,0 +1,259 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strconv"
+)
+
+// recoverCall runs call and converts a panic into a *Error.
+func recoverCall(call func()) (err *Error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &Error{Message: fmt.Sprintf("function panicked: %v", p), Type: ErrorTypeInternal}
+		}
+	}()
+
+	call()
+	return nil
+}
+
+// panicOnFault wraps call so that unexpected memory faults
+// during it result in a panic instead of crashing the program.
+// It has to be applied around the call and not the executor, as
+// the setting is per goroutine.
+func panicOnFault(call func()) func() {
+	return func() {
+		defer debug.SetPanicOnFault(debug.SetPanicOnFault(true))
+		call()
+	}
+}
+
+// subprocessEnv is the environment variable that contains the
+// name of the function a subprocess has been started for.
+const subprocessEnv = "NRA_SUBPROCESS"
+
+// subprocessResponseFD is the file descriptor the subprocess
+// writes its response to, so that output of the function on
+// stdout can't corrupt it.
+const subprocessResponseFD = 3
+
+// subprocessRequest is send from the parent to the
+// subprocess via stdin.
+type subprocessRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// subprocessResponse is send back from the subprocess
+// to the parent via subprocessResponseFD.
+type subprocessResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header"`
+	Body   []byte      `json:"body"`
+}
+
+// isSubprocess reports if this process has been
+// started to execute a single call.
+func isSubprocess() bool {
+	return os.Getenv(subprocessEnv) != ""
+}
+
+// subprocessHandler creates a handler that executes every call of the
+// function registered under name in a new instance of this executable.
+// If limits isn't nil the call is killed after limits.Timeout. The
+// body is limited to maxBodyBytes before it is passed to the child.
+func subprocessHandler(name string, limits *SandboxLimits, maxBodyBytes int64) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		if maxBodyBytes > 0 {
+			request.Body = http.MaxBytesReader(writer, request.Body, maxBodyBytes)
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+				return
+			}
+
+			writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+			return
+		}
+
+		ctx := request.Context()
+		if limits != nil && limits.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+			defer cancel()
+		}
+
+		res, err := runSubprocess(ctx, name, &subprocessRequest{
+			Method: request.Method,
+			URL:    request.URL.String(),
+			Header: request.Header,
+			Body:   body,
+		})
+		if err != nil {
+			// a killed subprocess is reported like WithTimeout does.
+			if ctx.Err() == context.DeadlineExceeded {
+				e := &Error{Message: "subprocess exceeded the time limit", Type: ErrorTypeTimeout}
+				if limits != nil && limits.Timeout > 0 {
+					e = timeoutError(limits.Timeout)
+				}
+				writeError(writer, http.StatusGatewayTimeout, e)
+				return
+			}
+
+			writeError(writer, http.StatusInternalServerError, &Error{Message: "subprocess failed: " + err.Error(), Type: ErrorTypeInternal})
+			return
+		}
+
+		for key, values := range res.Header {
+			writer.Header()[key] = values
+		}
+		writer.WriteHeader(res.Status)
+		_, _ = writer.Write(res.Body)
+	}
+}
+
+// runSubprocess starts the subprocess, passes req to it and waits for
+// its response. The subprocess is killed if ctx is cancelled.
+func runSubprocess(ctx context.Context, name string, req *subprocessRequest) (*subprocessResponse, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	output, outputWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer output.Close()
+
+	cmd := exec.CommandContext(ctx, executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), subprocessEnv+"="+name)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{outputWriter}
+
+	if err := cmd.Start(); err != nil {
+		_ = outputWriter.Close()
+		return nil, err
+	}
+
+	// close our end of the writer so reading
+	// stops when the subprocess exits.
+	_ = outputWriter.Close()
+
+	var res subprocessResponse
+	decodeErr := json.NewDecoder(output).Decode(&res)
+
+	if err := cmd.Wait(); err != nil {
+		return nil, err
+	}
+
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return &res, nil
+}
+
+// ServeSubprocess has to be called at the start of main if functions
+// are registered with WithSubprocess. If the program has been started
+// as subprocess it executes the call with the function from r and
+// exits. Otherwise it returns immediately.
+//
+//	func main() {
+//		r := newRegistry()
+//		nra.ServeSubprocess(r)
+//
+//		http.Handle("/rpc/", r)
+//		...
+//	}
+func ServeSubprocess(r *Registry) {
+	if !isSubprocess() {
+		return
+	}
+
+	if err := r.serveSubprocess(os.Getenv(subprocessEnv), os.Stdin, os.NewFile(subprocessResponseFD, "nra-response")); err != nil {
+		_, _ = fmt.Fprintln(os.Stderr, "nra: subprocess failed:", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// serveSubprocess reads the request from in, calls the function
+// with the given name and writes the response to out.
+func (r *Registry) serveSubprocess(name string, in io.Reader, out io.Writer) error {
+	b, e := r.lookup(splitVersion(name))
+	if e != nil {
+		return fmt.Errorf("function '%s' isn't registered", name)
+	}
+
+	if b.options.sandbox != nil {
+		if err := applySandboxLimits(b.options.sandbox); err != nil {
+			return err
+		}
+	}
+
+	var req subprocessRequest
+	if err := json.NewDecoder(in).Decode(&req); err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(req.Method, req.URL, bytes.NewReader(req.Body))
+	if err != nil {
+		return err
+	}
+	request.Header = req.Header
+
+	writer := &bufferedResponse{header: http.Header{}, status: http.StatusOK}
+	b.handler(writer, request)
+
+	return json.NewEncoder(out).Encode(subprocessResponse{
+		Status: writer.status,
+		Header: writer.header,
+		Body:   writer.body.Bytes(),
+	})
+}
+
+// bufferedResponse is a http.ResponseWriter that keeps
+// the whole response in memory.
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+// Write implements http.ResponseWriter.
+func (b *bufferedResponse) Write(data []byte) (int, error) {
+	return b.body.Write(data)
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (b *bufferedResponse) WriteHeader(status int) {
+	b.status = status
+}
@@ -0,0 +1,145 @@
+package nra
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// subprocessRegistry is used by the tests and by the test
+// binary itself when it is started as subprocess.
+func subprocessRegistry() *Registry {
+	r := NewRegistry()
+	r.MustRegister("pid", func() (int, error) {
+		return os.Getpid(), nil
+	}, WithSubprocess())
+	r.MustRegister("echo", func(req *http.Request, s string) (string, error) {
+		return req.Header.Get("X-Test") + s, nil
+	}, WithSubprocess())
+	r.MustRegister("fail", func() error {
+		return errors.New("failed")
+	}, WithSubprocess())
+	r.MustRegister("small", func(s string) (string, error) {
+		return s, nil
+	}, WithSubprocess(), WithMaxBodyBytes(16))
+	r.MustRegister("crash", func() error {
+		// simulates a crash inside native code.
+		os.Exit(3)
+		return nil
+	}, WithSubprocess())
+	r.MustRegister("sleep", func() error {
+		time.Sleep(5 * time.Second)
+		return nil
+	}, WithSandbox(SandboxLimits{Timeout: 100 * time.Millisecond}))
+	r.MustRegister("alloc", func(mb int) (int, error) {
+		var data [][]byte
+		for i := 0; i < mb; i++ {
+			data = append(data, bytes.Repeat([]byte{1}, 1<<20))
+		}
+		return len(data), nil
+	}, WithSandbox(SandboxLimits{Memory: 256 << 20}))
+	return r
+}
+
+func TestMain(m *testing.M) {
+	ServeSubprocess(subprocessRegistry())
+	os.Exit(m.Run())
+}
+
+func TestWithNative(t *testing.T) {
+	h := MustBind(func(a []int) (int, error) {
+		return a[10], nil
+	}, WithNative())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("[[1]]")))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "\"type\":\"internal\"")
+	assert.Contains(t, rr.Body.String(), "function panicked: runtime error: index out of range")
+}
+
+func TestWithSubprocess(t *testing.T) {
+	_, err := Bind(func() error { return nil }, WithSubprocess())
+	assert.Error(t, err, "subprocess requires a registry")
+
+	for _, opt := range []Option{WithRateLimit(RateLimit{Rate: 1, Burst: 1}), WithMaxConcurrent(1, 0), WithCache(time.Minute)} {
+		assert.Error(t, NewRegistry().Register("limited", func() error { return nil }, WithSubprocess(), opt), "state across calls can't be kept by the child")
+	}
+	assert.Error(t, NewRegistry().Register("session", func(s *Session) error { return nil }, WithSubprocess()), "the session only exists in the parent")
+
+	r := subprocessRegistry()
+
+	call := func(name string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, bytes.NewBufferString(body))
+		req.Header.Set("X-Test", "header+")
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		return rr
+	}
+
+	rr := call("pid", "")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	pid, err := strconv.Atoi(rr.Body.String()[:rr.Body.Len()-1])
+	assert.NoError(t, err)
+	assert.NotEqual(t, os.Getpid(), pid, "function has to run in another process")
+
+	rr = call("echo", "[\"body\"]")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "\"header+body\"\n", rr.Body.String())
+
+	rr = call("fail", "")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"failed\",\"type\":\"application\"}}\n", rr.Body.String())
+
+	rr = call("small", "[\"short\"]")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "\"short\"\n", rr.Body.String())
+
+	rr = call("small", "[\"this body is too long\"]")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"request body exceeds the limit of 16 bytes\",\"type\":\"limit\"}}\n", rr.Body.String())
+
+	rr = call("crash", "")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"subprocess failed: exit status 3\",\"type\":\"internal\"}}\n", rr.Body.String())
+}
+
+func TestWithSandbox(t *testing.T) {
+	r := subprocessRegistry()
+
+	call := func(name string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/"+name, bytes.NewBufferString(body)))
+		return rr
+	}
+
+	start := time.Now()
+	rr := call("sleep", "")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+	assert.Equal(t, http.StatusGatewayTimeout, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"call exceeded the timeout of 100ms\",\"type\":\"timeout\"}}\n", rr.Body.String())
+
+	if runtime.GOOS == "windows" {
+		t.Skip("memory limits are not supported on windows")
+	}
+	if raceEnabled {
+		t.Skip("the race detector needs more memory than the limit")
+	}
+
+	rr = call("alloc", "[8]")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "8\n", rr.Body.String())
+
+	rr = call("alloc", "[1024]")
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), "subprocess failed")
+}
@@ -0,0 +1,333 @@
+// Package nratest provides helpers for testing functions bound with nra
+// and code that calls them, so tests don't have to build requests and
+// decode responses by hand:
+//
+//	sum, status := nratest.Call[int](t, nra.MustBind(add), 1, 2)
+//
+//	r := nra.NewRegistry()
+//	r.MustRegister("users.get", getUser)
+//	user, _ := nratest.Call[User](t, nratest.Func(r, "users.get"), 42)
+package nratest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/BigJk/nra"
+)
+
+// Func returns a handler that calls the function name of the
+// registry, so it can be passed to Call. The call goes through
+// the whole registry, including authentication and middleware.
+func Func(r *nra.Registry, name string) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		request.URL.Path = r.Prefix() + name
+		r.ServeHTTP(writer, request)
+	})
+}
+
+// WithHeader returns a handler that adds the header to every call
+// before passing it to h, e.g. the credentials of a user:
+//
+//	h := nratest.WithHeader(nratest.Func(r, "me"), http.Header{"Authorization": {"Bearer " + token}})
+func WithHeader(h http.Handler, header http.Header) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		for k, v := range header {
+			request.Header[k] = v
+		}
+		h.ServeHTTP(writer, request)
+	})
+}
+
+// Do calls the handler with the arguments encoded as JSON, the same
+// way the generated clients do, and returns the recorded response.
+// The test fails if the arguments can't be encoded.
+func Do(t testing.TB, handler http.Handler, args ...interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	body, err := json.Marshal(args)
+	if err != nil {
+		t.Fatalf("nratest: can't encode arguments: %v", err)
+	}
+
+	request := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	request.Header.Set("Content-Type", "application/json")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request)
+	return rr
+}
+
+// Call calls the handler with the arguments and returns the result
+// decoded into T and the status code. The result is the zero value
+// if the call failed. The test fails if the result can't be decoded.
+func Call[T any](t testing.TB, handler http.Handler, args ...interface{}) (T, int) {
+	t.Helper()
+
+	var result T
+	rr := Do(t, handler, args...)
+	if rr.Code < 200 || rr.Code >= 300 || rr.Body.Len() == 0 {
+		return result, rr.Code
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("nratest: can't decode result %q: %v", rr.Body.String(), err)
+	}
+	return result, rr.Code
+}
+
+// CallError calls the handler with the arguments and returns the
+// error of the response and the status code. The test fails if the
+// call succeeded.
+func CallError(t testing.TB, handler http.Handler, args ...interface{}) (*nra.Error, int) {
+	t.Helper()
+
+	rr := Do(t, handler, args...)
+	if rr.Code >= 200 && rr.Code < 300 {
+		t.Fatalf("nratest: call succeeded with status %d: %s", rr.Code, rr.Body.String())
+	}
+
+	var response struct {
+		Error *nra.Error `json:"error"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil || response.Error == nil {
+		t.Fatalf("nratest: response isn't a error: %s", rr.Body.String())
+	}
+	return response.Error, rr.Code
+}
+
+// Fake is a registry for testing code that calls functions. Functions
+// registered with it record their calls, which can be checked later.
+// Functions that only have to return something can be registered
+// with Stub:
+//
+//	f := nratest.NewFake()
+//	f.Stub("users.get", User{Name: "alice"}, nil)
+//	srv := httptest.NewServer(f)
+//
+//	// ... run the code calling the server ...
+//
+//	f.AssertCalled(t, "users.get", 42)
+type Fake struct {
+	*nra.Registry
+
+	mtx   sync.Mutex
+	calls []FakeCall
+}
+
+// FakeCall is a call recorded by a Fake.
+type FakeCall struct {
+	Name   string
+	Args   []json.RawMessage
+	Status int
+}
+
+// NewFake creates a Fake without any functions.
+func NewFake() *Fake {
+	return &Fake{Registry: nra.NewRegistry()}
+}
+
+// Register registers the function the same way nra.Registry does,
+// but records its calls.
+func (f *Fake) Register(name string, fn interface{}, opts ...nra.Option) error {
+	return f.Registry.Register(name, fn, append(opts, nra.WithMiddleware(f.record(name)))...)
+}
+
+// MustRegister is the same as Register but panics on errors.
+func (f *Fake) MustRegister(name string, fn interface{}, opts ...nra.Option) {
+	if err := f.Register(name, fn, opts...); err != nil {
+		panic("nra: register failed with: " + err.Error())
+	}
+}
+
+// Stub registers a function that takes any JSON arguments and returns
+// result and err. A nil result is sent as null. Registering name
+// again replaces the stub.
+func (f *Fake) Stub(name string, result interface{}, err error) {
+	stub := func(ctx context.Context, args []json.RawMessage) (interface{}, error) {
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// the arguments are only recorded, so they don't have
+	// to be converted to the parameters of a function.
+	f.Registry.Unregister(name)
+	f.MustRegister(name, func(args ...interface{}) (interface{}, error) {
+		return stub(context.Background(), nil)
+	}, nra.WithStatic(stub))
+}
+
+// record is the middleware recording the calls of the function name.
+func (f *Fake) record(name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			body, _ := io.ReadAll(request.Body)
+			request.Body = io.NopCloser(bytes.NewReader(body))
+
+			// a empty body is a call without arguments.
+			call := FakeCall{Name: name, Args: []json.RawMessage{}}
+			_ = json.Unmarshal(body, &call.Args)
+
+			w := &statusWriter{ResponseWriter: writer, status: http.StatusOK}
+			next.ServeHTTP(w, request)
+			call.Status = w.status
+
+			f.mtx.Lock()
+			f.calls = append(f.calls, call)
+			f.mtx.Unlock()
+		})
+	}
+}
+
+// Calls returns the calls of the function name in the order they were
+// made, or all calls if name is empty.
+func (f *Fake) Calls(name string) []FakeCall {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	var calls []FakeCall
+	for _, c := range f.calls {
+		if name == "" || c.Name == name {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// Reset forgets all recorded calls.
+func (f *Fake) Reset() {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.calls = nil
+}
+
+// AssertCalled checks that the function name was called with the
+// arguments at least once and reports a error to t otherwise. The
+// arguments are compared by their JSON encoding.
+func (f *Fake) AssertCalled(t testing.TB, name string, args ...interface{}) bool {
+	t.Helper()
+
+	if args == nil {
+		args = []interface{}{}
+	}
+	expected, err := normalize(args)
+	if err != nil {
+		t.Fatalf("nratest: can't encode arguments: %v", err)
+	}
+
+	calls := f.Calls(name)
+	for _, c := range calls {
+		if got, err := normalize(c.Args); err == nil && reflect.DeepEqual(got, expected) {
+			return true
+		}
+	}
+
+	if len(calls) == 0 {
+		t.Errorf("nratest: %s wasn't called", name)
+		return false
+	}
+	received := make([]string, len(calls))
+	for i, c := range calls {
+		data, _ := json.Marshal(c.Args)
+		received[i] = string(data)
+	}
+	data, _ := json.Marshal(args)
+	t.Errorf("nratest: %s wasn't called with %s, calls: %v", name, data, received)
+	return false
+}
+
+// AssertNotCalled checks that the function name wasn't
+// called and reports a error to t otherwise.
+func (f *Fake) AssertNotCalled(t testing.TB, name string) bool {
+	t.Helper()
+
+	if calls := f.Calls(name); len(calls) > 0 {
+		t.Errorf("nratest: %s was called %d times", name, len(calls))
+		return false
+	}
+	return true
+}
+
+// normalize decodes the JSON encoding of v generically,
+// so values that encode the same compare the same.
+func normalize(v interface{}) (interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// statusWriter keeps the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.status = code
+		w.wrote = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (w *statusWriter) Write(data []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(data)
+}
+
+// Fuzz fuzzes the decoding of the arguments of fn, bound with the
+// options, with the corpus of nra.FuzzCorpus as seeds. A call fails
+// the test if it panics or is answered with status 500:
+//
+//	func FuzzCreateUser(f *testing.F) {
+//	  nratest.Fuzz(f, createUser)
+//	}
+//
+// "go test" only calls fn with the seeds, "go test -fuzz" generates
+// more calls from them. fn is really called, so it shouldn't have
+// side effects that matter.
+func Fuzz(f *testing.F, fn interface{}, opts ...nra.Option) {
+	f.Helper()
+
+	h, err := nra.Bind(fn, opts...)
+	if err != nil {
+		f.Fatalf("nratest: can't bind function: %v", err)
+	}
+	corpus, err := nra.FuzzCorpus(fn)
+	if err != nil {
+		f.Fatalf("nratest: can't create corpus: %v", err)
+	}
+	for _, body := range corpus {
+		f.Add(body)
+	}
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		request := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		rr := httptest.NewRecorder()
+		h(rr, request)
+
+		if rr.Code == http.StatusInternalServerError {
+			t.Fatalf("nratest: call with %q failed with status 500: %s", body, rr.Body.String())
+		}
+	})
+}
@@ -0,0 +1,110 @@
+package nratest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/BigJk/nra"
+	"github.com/stretchr/testify/assert"
+)
+
+type user struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestCall(t *testing.T) {
+	sum, status := Call[int](t, nra.MustBind(func(a, b int) (int, error) { return a + b, nil }), 1, 2)
+	assert.Equal(t, 3, sum)
+	assert.Equal(t, http.StatusOK, status)
+
+	r := nra.NewRegistry()
+	r.SetAuthenticator(nra.AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		if request.Header.Get("Authorization") != "secret" {
+			return nil, nra.ErrUnauthenticated
+		}
+		return "alice", nil
+	}))
+	r.MustRegister("users.get", func(ctx context.Context, id int) (*user, error) {
+		if id == 0 {
+			return nil, errors.New("no such user")
+		}
+		return &user{ID: id, Name: nra.IdentityOf(ctx).(string)}, nil
+	})
+	get := WithHeader(Func(r, "users.get"), http.Header{"Authorization": {"secret"}})
+
+	u, status := Call[*user](t, get, 42)
+	assert.Equal(t, &user{ID: 42, Name: "alice"}, u)
+	assert.Equal(t, http.StatusOK, status)
+
+	u, status = Call[*user](t, get, 0)
+	assert.Nil(t, u)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	e, status := CallError(t, get, 0)
+	assert.Equal(t, "no such user", e.Message)
+	assert.Equal(t, nra.ErrorTypeApplication, e.Type)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	e, status = CallError(t, Func(r, "users.get"), 1)
+	assert.Equal(t, nra.ErrorTypeUnauthorized, e.Type)
+	assert.Equal(t, http.StatusUnauthorized, status)
+
+	e, _ = CallError(t, get, "a", "b")
+	assert.Equal(t, nra.ErrorTypeValidation, e.Type)
+}
+
+func TestFake(t *testing.T) {
+	f := NewFake()
+	f.Stub("users.get", user{ID: 1, Name: "alice"}, nil)
+	f.Stub("users.delete", nil, errors.New("not allowed"))
+	f.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	post := func(name string, body string) int {
+		res, err := http.Post(srv.URL+"/rpc/"+name, "application/json", strings.NewReader(body))
+		assert.NoError(t, err)
+		_ = res.Body.Close()
+		return res.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, post("users.get", `[42, {"full": true}]`))
+	assert.Equal(t, http.StatusBadRequest, post("users.delete", `[42]`))
+	assert.Equal(t, http.StatusOK, post("add", `[1, 2]`))
+
+	got, _ := Call[user](t, Func(f.Registry, "users.get"))
+	assert.Equal(t, user{ID: 1, Name: "alice"}, got)
+
+	assert.Len(t, f.Calls(""), 4)
+	assert.Len(t, f.Calls("users.get"), 2)
+	assert.Equal(t, http.StatusBadRequest, f.Calls("users.delete")[0].Status)
+	assert.Equal(t, `{"full": true}`, string(f.Calls("users.get")[0].Args[1]))
+
+	assert.True(t, f.AssertCalled(t, "users.get", 42, map[string]bool{"full": true}))
+	assert.True(t, f.AssertCalled(t, "users.get"))
+	assert.True(t, f.AssertCalled(t, "add", 1.0, 2))
+	assert.True(t, f.AssertNotCalled(t, "users.create"))
+
+	mock := &testing.T{}
+	assert.False(t, f.AssertCalled(mock, "users.get", 43))
+	assert.False(t, f.AssertCalled(mock, "users.create"))
+	assert.False(t, f.AssertNotCalled(mock, "add"))
+
+	f.Reset()
+	assert.Empty(t, f.Calls(""))
+}
+
+func FuzzUsers(f *testing.F) {
+	Fuzz(f, func(ctx context.Context, u user, tags []string, limit *int) (*user, error) {
+		if limit != nil && *limit < 0 {
+			return nil, errors.New("negative limit")
+		}
+		return &u, nil
+	})
+}
@@ -0,0 +1,225 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Observer is notified about every call of a function of a registry,
+// e.g. to trace or monitor them, see Registry.AddObserver.
+type Observer interface {
+	// StartCall is called before the function named name is called,
+	// even if the call is rejected later on. The returned request is
+	// used for the call, e.g. with a span in its context, and done is
+	// called once the response was written. done can be nil.
+	StartCall(request *http.Request, name string) (_ *http.Request, done func(CallInfo))
+}
+
+// ObserverFunc is a function used as Observer.
+type ObserverFunc func(request *http.Request, name string) (*http.Request, func(CallInfo))
+
+// StartCall implements Observer.
+func (f ObserverFunc) StartCall(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+	return f(request, name)
+}
+
+// CallInfo describes a finished call.
+type CallInfo struct {
+	// Name is the name of the called function.
+	Name string
+
+	// Args is the number of arguments the call was made with. It
+	// is 0 if the call was rejected before they were decoded.
+	Args int
+
+	// Status is the status code of the response.
+	Status int
+
+	// Error is the error sent to the client, or nil.
+	Error *Error
+
+	// Duration is the time from the start of the
+	// call until the response was written.
+	Duration time.Duration
+
+	// BytesIn is the number of bytes read from the request body.
+	BytesIn int64
+
+	// BytesOut is the number of bytes written to the response body.
+	BytesOut int64
+
+	// Panic is the value the function panicked with, or nil. The
+	// panic is passed on after the observers were notified.
+	Panic interface{}
+
+	// Sampled reports if the call was sampled, see WithSampleRate.
+	Sampled bool
+
+	// Identity is the identity the Authenticator returned for
+	// the call, or nil if it wasn't authenticated.
+	Identity interface{}
+
+	// CPUTime is the CPU time the function used. It is measured
+	// exactly on Linux for functions bound WithLockOSThread or
+	// WithOSThreadPool, for all others it is the time the function
+	// ran, including the time it waited. Goroutines started by the
+	// function aren't included. It is 0 if the function wasn't called.
+	CPUTime time.Duration
+}
+
+// AddObserver adds a observer that is notified about all calls of the
+// registry. Observers are started in the order they were added and
+// finished in reverse order.
+func (r *Registry) AddObserver(o Observer) {
+	r.observers = append(r.observers, o)
+}
+
+// callRecordKey is the context key of the callRecord.
+type callRecordKey struct{}
+
+// callRecord collects what the observers are told
+// about a call while it is handled.
+type callRecord struct {
+	args     int
+	sampled  bool
+	identity interface{}
+
+	// cpu is the CPU time of the call in nanoseconds. It is set
+	// atomically, as a call that timed out can still be running.
+	cpu int64
+
+	// onArgs is called with the context of the call after it was
+	// authenticated and the decoded arguments, which can't be kept
+	// as they are reused after the call.
+	onArgs func(ctx context.Context, args []interface{})
+}
+
+// observing reports if the calls of the registry have to be observed.
+func (r *Registry) observing() bool {
+	return len(r.observers) > 0 || r.logger != nil || r.audit != nil
+}
+
+// observe starts the observers of the call of the function name. The
+// returned writer and request have to be used for the call and finish
+// has to be deferred, so it also sees panics.
+func (r *Registry) observe(writer http.ResponseWriter, request *http.Request, name string) (_ http.ResponseWriter, _ *http.Request, finish func()) {
+	observers := r.observers[:len(r.observers):len(r.observers)]
+	if r.logger != nil {
+		observers = append(observers, r.logger)
+	}
+	if r.audit != nil {
+		observers = append(observers, r.audit)
+	}
+
+	w := &statusWriter{ResponseWriter: writer}
+	record := &callRecord{sampled: sample(request, r.sampleRateOf(name))}
+	request = request.WithContext(context.WithValue(request.Context(), callRecordKey{}, record))
+
+	body := &countingReader{ReadCloser: request.Body}
+	request.Body = body
+
+	done := make([]func(CallInfo), len(observers))
+	for i, o := range observers {
+		request, done[i] = o.StartCall(request, name)
+	}
+
+	start := time.Now()
+	return w, request, func() {
+		info := CallInfo{
+			Name:     name,
+			Args:     record.args,
+			Status:   w.statusCode(),
+			Error:    w.err,
+			Duration: time.Since(start),
+			BytesIn:  body.n,
+			BytesOut: w.size,
+			Sampled:  record.sampled,
+			Identity: record.identity,
+			CPUTime:  time.Duration(atomic.LoadInt64(&record.cpu)),
+		}
+
+		// the server aborts the response of a panicking call.
+		p := recover()
+		if p != nil {
+			info.Panic = p
+			if w.status == 0 {
+				info.Status = http.StatusInternalServerError
+			}
+		}
+
+		for i := len(done) - 1; i >= 0; i-- {
+			if done[i] != nil {
+				done[i](info)
+			}
+		}
+
+		if p != nil {
+			panic(p)
+		}
+	}
+}
+
+// countingReader counts the bytes read from a request body.
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+// Read implements io.Reader.
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// measureCPU returns call, which adds the CPU time it used to the
+// record. On a locked thread the CPU time of the thread is measured,
+// otherwise the time the call ran.
+func (record *callRecord) measureCPU(call func(), locked bool) func() {
+	return func() {
+		if start, ok := threadCPUTime(); ok && locked {
+			defer func() {
+				if end, ok := threadCPUTime(); ok {
+					atomic.AddInt64(&record.cpu, int64(end-start))
+				}
+			}()
+			call()
+			return
+		}
+
+		start := time.Now()
+		defer func() {
+			atomic.AddInt64(&record.cpu, int64(time.Since(start)))
+		}()
+		call()
+	}
+}
+
+// recordArgs tells the observers of the call which arguments it was
+// made with, either decoded or, for static handlers, as raw JSON.
+func recordArgs(request *http.Request, args []interface{}, raw []json.RawMessage) {
+	record, ok := request.Context().Value(callRecordKey{}).(*callRecord)
+	if !ok {
+		return
+	}
+
+	record.args = len(args)
+	if raw != nil {
+		record.args = len(raw)
+	}
+
+	if record.onArgs == nil {
+		return
+	}
+	if raw != nil {
+		args = make([]interface{}, len(raw))
+		for i := range raw {
+			_ = json.Unmarshal(raw[i], &args[i])
+		}
+	}
+	record.onArgs(request.Context(), args)
+}
@@ -0,0 +1,75 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type observerKey struct{}
+
+func TestObserver(t *testing.T) {
+	var order []string
+	var infos []CallInfo
+	observer := func(tag string) Observer {
+		return ObserverFunc(func(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+			order = append(order, "start "+tag)
+			request = request.WithContext(context.WithValue(request.Context(), observerKey{}, tag))
+			return request, func(info CallInfo) {
+				order = append(order, "done "+tag)
+				infos = append(infos, info)
+			}
+		})
+	}
+
+	r := NewRegistry()
+	r.AddObserver(observer("a"))
+	r.AddObserver(observer("b"))
+	r.MustRegister("tag", func(ctx context.Context, a, b int) (string, error) {
+		return ctx.Value(observerKey{}).(string), nil
+	})
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/tag", strings.NewReader("[1, 2]")))
+	assert.Equal(t, "\"b\"\n", rec.Body.String())
+	assert.Equal(t, []string{"start a", "start b", "done b", "done a"}, order)
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, "tag", infos[0].Name)
+		assert.Equal(t, 2, infos[0].Args)
+		assert.Equal(t, http.StatusOK, infos[0].Status)
+		assert.Nil(t, infos[0].Error)
+	}
+
+	infos = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/tag", strings.NewReader("[1]")))
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, 0, infos[0].Args)
+		assert.Equal(t, http.StatusBadRequest, infos[0].Status)
+		if assert.NotNil(t, infos[0].Error) {
+			assert.Equal(t, ErrorTypeValidation, infos[0].Error.Type)
+		}
+	}
+
+	// unknown functions aren't calls.
+	infos = nil
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/unknown", nil))
+	assert.Empty(t, infos)
+}
+
+func TestObserverPanic(t *testing.T) {
+	var info CallInfo
+	r := NewRegistry()
+	r.AddObserver(ObserverFunc(func(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+		return request, func(i CallInfo) { info = i }
+	}))
+	r.MustRegister("panic", func() error { panic("boom") })
+
+	assert.PanicsWithValue(t, "boom", func() {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/panic", nil))
+	})
+	assert.Equal(t, "boom", info.Panic)
+}
@@ -0,0 +1,209 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"reflect"
+)
+
+// OpenAPIName is the name under which the generated
+// OpenAPI document is served if it is enabled.
+const OpenAPIName = "openapi.json"
+
+// OpenAPIInfo is the general information about the
+// api that is included in the OpenAPI document.
+type OpenAPIInfo struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type openAPIDocument struct {
+	OpenAPI    string                     `json:"openapi"`
+	Info       OpenAPIInfo                `json:"info"`
+	Paths      map[string]openAPIPathItem `json:"paths"`
+	Components openAPIComponents          `json:"components"`
+}
+
+type openAPIPathItem struct {
+	Get  *openAPIOperation `json:"get,omitempty"`
+	Post *openAPIOperation `json:"post,omitempty"`
+}
+
+type openAPIOperation struct {
+	OperationID string                     `json:"operationId"`
+	Parameters  []openAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *openAPIRequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]openAPIResponse `json:"responses"`
+}
+
+type openAPIParameter struct {
+	Name        string                      `json:"name"`
+	In          string                      `json:"in"`
+	Description string                      `json:"description,omitempty"`
+	Required    bool                        `json:"required,omitempty"`
+	Schema      *schema                     `json:"schema,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIRequestBody struct {
+	Required bool                        `json:"required"`
+	Content  map[string]openAPIMediaType `json:"content"`
+}
+
+type openAPIResponse struct {
+	Ref         string                      `json:"$ref,omitempty"`
+	Description string                      `json:"description,omitempty"`
+	Content     map[string]openAPIMediaType `json:"content,omitempty"`
+}
+
+type openAPIMediaType struct {
+	Schema *schema `json:"schema"`
+}
+
+type openAPIComponents struct {
+	Schemas   map[string]*schema         `json:"schemas"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+// errorSchema is the schema of the error envelope.
+func errorSchema(g *schemaGenerator) *schema {
+	return &schema{
+		Type:       schemaType{"object"},
+		Properties: map[string]*schema{"error": {Ref: g.refPrefix + g.define(reflect.TypeOf(Error{}))}},
+		Required:   []string{"error"},
+	}
+}
+
+// argumentsSchemaOf returns the schema of the array of arguments of the
+// binding. Trailing optional arguments are only left out of minItems,
+// the elements of a variadic parameter follow as items.
+func argumentsSchemaOf(g *schemaGenerator, b *binding) *schema {
+	minArgs, maxArgs := b.minArgs, len(b.params)
+	body := &schema{Type: schemaType{"array"}, MinItems: &minArgs, MaxItems: &maxArgs}
+	for i, p := range b.params {
+		if b.variadic && i == len(b.params)-1 {
+			body.MaxItems = nil
+			body.Items = g.schemaOf(p.Elem())
+			break
+		}
+		body.PrefixItems = append(body.PrefixItems, g.schemaOf(p))
+	}
+	return body
+}
+
+// GenerateOpenAPI writes a OpenAPI 3.1 document describing all functions
+// of the registry to w. Every function is a POST operation on the prefix
+// plus its name. The request body is the array of arguments, the response
+// the JSON encoded return value or, on failure, the error envelope. Safe
+// functions additionally have a GET operation taking the arguments in
+// the args query parameter.
+func GenerateOpenAPI(w io.Writer, r *Registry, info OpenAPIInfo) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(openAPIOf(r, info, r.PublicPrefix()))
+}
+
+// openAPIOf creates the OpenAPI document of the registry
+// with the paths of the functions under the given prefix.
+func openAPIOf(r *Registry, info OpenAPIInfo, prefix string) *openAPIDocument {
+	g := newSchemaGenerator("#/components/schemas/")
+
+	doc := &openAPIDocument{
+		OpenAPI: "3.1.0",
+		Info:    info,
+		Paths:   map[string]openAPIPathItem{},
+		Components: openAPIComponents{
+			Schemas: g.defs,
+			Responses: map[string]openAPIResponse{
+				"Error": {
+					Description: "The call failed.",
+					Content:     map[string]openAPIMediaType{"application/json": {Schema: errorSchema(g)}},
+				},
+			},
+		},
+	}
+
+	names, functions := r.snapshot()
+	for _, name := range names {
+		b := functions[name]
+
+		minArgs := b.minArgs
+		body := argumentsSchemaOf(g, b)
+
+		success := openAPIResponse{Description: "The call succeeded."}
+		if b.result != nil {
+			success.Content = map[string]openAPIMediaType{"application/json": {Schema: g.schemaOf(b.result)}}
+		}
+
+		errorRef := openAPIResponse{Ref: "#/components/responses/Error"}
+		responses := map[string]openAPIResponse{
+			"200": success,
+			"400": errorRef,
+			"404": errorRef,
+			"500": errorRef,
+		}
+
+		// bulk calls with failed items are answered with 207.
+		if isBulk(b.result) {
+			responses["207"] = openAPIResponse{Description: "Some of the items failed.", Content: success.Content}
+		}
+
+		// results can be reduced to the fields the client needs.
+		var params []openAPIParameter
+		if b.result != nil && hasFields(b.result) {
+			params = append(params, openAPIParameter{
+				Name:        FieldsName,
+				In:          "query",
+				Description: "Comma separated fields of the result to return, e.g. id,name,address.city.",
+				Schema:      &schema{Type: schemaType{"string"}},
+			})
+		}
+
+		item := openAPIPathItem{
+			Post: &openAPIOperation{
+				OperationID: name,
+				Parameters:  params,
+				RequestBody: &openAPIRequestBody{
+					Required: minArgs > 0,
+					Content:  map[string]openAPIMediaType{"application/json": {Schema: body}},
+				},
+				Responses: responses,
+			},
+		}
+
+		// safe functions can also be called with the
+		// arguments as JSON in the query string.
+		if b.options.safe {
+			item.Get = &openAPIOperation{
+				OperationID: name + ".get",
+				Parameters: append([]openAPIParameter{{
+					Name:     QueryArgumentsName,
+					In:       "query",
+					Required: minArgs > 0,
+					Content:  map[string]openAPIMediaType{"application/json": {Schema: body}},
+				}}, params...),
+				Responses: responses,
+			}
+		}
+
+		doc.Paths[prefix+name] = item
+	}
+
+	return doc
+}
+
+// serveOpenAPI writes the OpenAPI document of the registry as response.
+func (r *Registry) serveOpenAPI(writer http.ResponseWriter, request *http.Request) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(openAPIOf(r, *r.openAPI, r.publicPrefixOf(request))); err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: err.Error(), Type: ErrorTypeInternal})
+		return
+	}
+	writer.Header().Set("Content-Type", "application/json")
+	_, _ = buf.WriteTo(writer)
+}
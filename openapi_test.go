@@ -0,0 +1,117 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type openAPINode struct {
+	Value int          `json:"value"`
+	Next  *openAPINode `json:"next,omitempty"`
+}
+
+func TestGenerateOpenAPI(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a int, b float64) (float64, error) { return 0, nil }, WithSafe())
+	r.MustRegister("walk", func(n openAPINode) ([]openAPINode, error) { return nil, nil })
+	r.MustRegister("ping", func() error { return nil })
+	r.MustRegister("join", func(sep string, items ...string) (string, error) { return "", nil })
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, GenerateOpenAPI(&buf, r, OpenAPIInfo{Title: "test", Version: "1.0.0"})) {
+		return
+	}
+
+	var doc map[string]interface{}
+	if !assert.NoError(t, json.Unmarshal(buf.Bytes(), &doc)) {
+		return
+	}
+
+	assert.Equal(t, "3.1.0", doc["openapi"])
+
+	paths := doc["paths"].(map[string]interface{})
+	assert.Len(t, paths, 4)
+
+	add := paths["/rpc/add"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "add", add["operationId"])
+	assert.JSONEq(t, `{
+		"required": true,
+		"content": {"application/json": {"schema": {
+			"type": "array",
+			"minItems": 2,
+			"maxItems": 2,
+			"prefixItems": [{"type": "integer", "format": "int32"}, {"type": "number", "format": "double"}]
+		}}}
+	}`, mustJSON(add["requestBody"]))
+
+	get := paths["/rpc/add"].(map[string]interface{})["get"].(map[string]interface{})
+	assert.Equal(t, "add.get", get["operationId"])
+	assert.JSONEq(t, `[{
+		"name": "args",
+		"in": "query",
+		"required": true,
+		"content": {"application/json": {"schema": {
+			"type": "array",
+			"minItems": 2,
+			"maxItems": 2,
+			"prefixItems": [{"type": "integer", "format": "int32"}, {"type": "number", "format": "double"}]
+		}}}
+	}]`, mustJSON(get["parameters"]))
+	assert.NotContains(t, paths["/rpc/ping"], "get")
+
+	walk := paths["/rpc/walk"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.JSONEq(t, `[{
+		"name": "_fields",
+		"in": "query",
+		"description": "Comma separated fields of the result to return, e.g. id,name,address.city.",
+		"schema": {"type": "string"}
+	}]`, mustJSON(walk["parameters"]))
+
+	join := paths["/rpc/join"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.JSONEq(t, `{
+		"required": true,
+		"content": {"application/json": {"schema": {
+			"type": "array",
+			"minItems": 1,
+			"prefixItems": [{"type": "string"}],
+			"items": {"type": "string"}
+		}}}
+	}`, mustJSON(join["requestBody"]))
+
+	ping := paths["/rpc/ping"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.JSONEq(t, `{"description": "The call succeeded."}`, mustJSON(ping["responses"].(map[string]interface{})["200"]))
+
+	schemas := doc["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+	assert.JSONEq(t, `{
+		"type": "object",
+		"properties": {
+			"value": {"type": "integer", "format": "int32"},
+			"next": {"anyOf": [{"$ref": "#/components/schemas/openAPINode"}, {"type": "null"}]}
+		},
+		"required": ["value"]
+	}`, mustJSON(schemas["openAPINode"]))
+	assert.Contains(t, schemas, "Error")
+}
+
+func TestRegistryOpenAPI(t *testing.T) {
+	r := NewRegistry()
+	r.EnableOpenAPI(OpenAPIInfo{Title: "test", Version: "1.0.0"})
+
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("GET", "/rpc/openapi.json", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"title": "test"`)
+}
+
+func mustJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return string(data)
+}
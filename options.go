@@ -0,0 +1,163 @@
+package nra
+
+import "time"
+
+// Option configures how a bound function is called. Options
+// can be passed to Bind, MustBind and Registry.Register.
+type Option func(*options)
+
+// options holds the configuration of a single binding.
+type options struct {
+	lockOSThread bool
+	threadPool   int
+	native       bool
+	subprocess   bool
+	sandbox      *SandboxLimits
+	memoryBudget uint64
+	idempotent   bool
+	safe         bool
+	static       StaticFunc
+	maxBodyBytes int64
+	compression  int
+	cacheTTL     time.Duration
+	dependsOn    []string
+	timeout      time.Duration
+
+	maxConcurrent   int
+	concurrencyWait time.Duration
+
+	rateLimit *RateLimit
+	priority  Priority
+	cors      *CORS
+
+	authenticator Authenticator
+	auditRedact   []string
+	transforms    []BodyTransform
+	sampleRate    *float64
+	middleware    []Middleware
+	version       int
+	deprecated    bool
+
+	idempotencyKey   bool
+	schemaValidation bool
+}
+
+// newOptions applies all opts to the default options.
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithLockOSThread locks the goroutine that calls the function
+// to its current OS thread for the duration of the call. Use it
+// for functions that call into cgo libraries which keep thread
+// local state and break if the call is migrated between threads.
+func WithLockOSThread() Option {
+	return func(o *options) {
+		o.lockOSThread = true
+	}
+}
+
+// WithOSThreadPool runs all calls of the function on a pool of size
+// goroutines that are each locked to their own OS thread for their
+// whole lifetime. Other than WithLockOSThread this guarantees that
+// the function is only ever called from the same set of threads,
+// which some native libraries require. With a size of 1 all calls
+// are executed one after another on a single dedicated thread. The
+// threads exit once the function is replaced or unregistered at its
+// Registry and the calls still running on them finished.
+func WithOSThreadPool(size int) Option {
+	return func(o *options) {
+		if size < 1 {
+			size = 1
+		}
+		o.threadPool = size
+	}
+}
+
+// WithNative marks the function as calling into native code. Panics
+// during the call are recovered and memory faults that happen in Go
+// code (e.g. when reading from pointers handed out by C) are turned
+// into panics with debug.SetPanicOnFault, so both result in a error
+// response of the type ErrorTypeInternal instead of a crashed server.
+//
+// A crash inside the native code itself can't be recovered. Combine
+// it with WithSubprocess if that has to be survived as well.
+func WithNative() Option {
+	return func(o *options) {
+		o.native = true
+	}
+}
+
+// WithSubprocess executes every call of the function in a child
+// process, so even a crash of native code only kills the child.
+// The child is the same executable started with the same arguments,
+// so ServeSubprocess has to be called at the start of main with the
+// registry the function is registered at. Only functions registered
+// at a Registry can be executed in a subprocess.
+//
+// The child only runs a single call, so it can't be combined with
+// WithRateLimit, WithMaxConcurrent, WithCache or a claims or session
+// parameter. IdentityOf is only set in the child if the function has
+// its own WithAuthenticator, the one of the Registry runs in the parent.
+func WithSubprocess() Option {
+	return func(o *options) {
+		o.subprocess = true
+	}
+}
+
+// WithCompression compresses the results of the function that are at
+// least minSize bytes large if the client accepts it, see RegisterCompressor.
+// A minSize of 0 uses DefaultCompressionMinSize. See also
+// Registry.SetCompression to compress the responses of all functions.
+func WithCompression(minSize int) Option {
+	return func(o *options) {
+		if minSize <= 0 {
+			minSize = DefaultCompressionMinSize
+		}
+		o.compression = minSize
+	}
+}
+
+// WithIdempotent marks the function as safe to call more than once
+// with the same arguments, e.g. because it only reads data. The
+// generated clients automatically retry calls of idempotent functions
+// if the server couldn't be reached or wasn't ready yet. Calls of all
+// other functions are never retried, as it is unknown if they already
+// had an effect.
+func WithIdempotent() Option {
+	return func(o *options) {
+		o.idempotent = true
+	}
+}
+
+// WithSafe marks the function as safe, meaning it doesn't change
+// anything and only reads data. Safe functions are idempotent and
+// can also be called with a GET request that passes the arguments
+// in the query string, so results can be cached by browsers or
+// linked to:
+//
+//	/rpc/add?args=[1,2]
+//
+// Functions that take a single struct can also be called with
+// its fields as query parameters, e.g. /rpc/search?text=go&limit=10.
+func WithSafe() Option {
+	return func(o *options) {
+		o.idempotent = true
+		o.safe = true
+	}
+}
+
+// WithMaxBodyBytes limits the size of the request body of calls to
+// n bytes. Larger bodies are rejected with status 413 and the type
+// ErrorTypeLimit before they are decoded completely, so a single large
+// request can't exhaust the memory of the server. See also
+// Registry.SetMaxBodyBytes for a limit for all functions.
+func WithMaxBodyBytes(n int64) Option {
+	return func(o *options) {
+		o.maxBodyBytes = n
+	}
+}
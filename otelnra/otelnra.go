@@ -0,0 +1,142 @@
+// Package otelnra traces the calls of a nra registry with OpenTelemetry.
+// It is a separate package, so the OpenTelemetry dependencies are only
+// built into programs that use it:
+//
+//	r := nra.NewRegistry()
+//	r.AddObserver(otelnra.NewObserver())
+package otelnra
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+
+	"github.com/BigJk/nra"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ScopeName is the instrumentation scope of the tracer.
+const ScopeName = "github.com/BigJk/nra/otelnra"
+
+// Attributes set on the spans in addition to the ones
+// of the OpenTelemetry RPC semantic conventions.
+const (
+	ArgsKey       = attribute.Key("nra.args")
+	ErrorTypeKey  = attribute.Key("nra.error.type")
+	RequestIDKey  = attribute.Key("nra.request_id")
+	StatusCodeKey = attribute.Key("http.status_code")
+)
+
+// Option configures the observer.
+type Option func(*config)
+
+type config struct {
+	provider    trace.TracerProvider
+	propagators propagation.TextMapPropagator
+}
+
+// WithTracerProvider sets the provider of the tracer. Defaults
+// to the global provider, see otel.SetTracerProvider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(c *config) {
+		c.provider = provider
+	}
+}
+
+// WithPropagators sets the propagators that extract the trace
+// context of the caller from the request headers. Defaults to the
+// global propagators, see otel.SetTextMapPropagator.
+func WithPropagators(propagators propagation.TextMapPropagator) Option {
+	return func(c *config) {
+		c.propagators = propagators
+	}
+}
+
+// observer starts a span for each call.
+type observer struct {
+	tracer      trace.Tracer
+	propagators propagation.TextMapPropagator
+}
+
+// NewObserver creates a nra.Observer that starts a server span for
+// every call, named by the called function. The span continues the
+// trace of the caller if the request carries its context and records
+// the error of the call, if it failed. Only server errors set the
+// status of the span to error. Functions can get the
+// span with trace.SpanFromContext to add their own events.
+//
+// Calls that aren't sampled, see nra.WithSampleRate, get no span.
+// Their context still carries a span context marked as not sampled,
+// so the decision is passed on to the services they call.
+func NewObserver(opts ...Option) nra.Observer {
+	c := config{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	if c.provider == nil {
+		c.provider = otel.GetTracerProvider()
+	}
+	if c.propagators == nil {
+		c.propagators = otel.GetTextMapPropagator()
+	}
+
+	return &observer{
+		tracer:      c.provider.Tracer(ScopeName),
+		propagators: c.propagators,
+	}
+}
+
+// StartCall implements nra.Observer.
+func (o *observer) StartCall(request *http.Request, name string) (*http.Request, func(nra.CallInfo)) {
+	ctx := o.propagators.Extract(request.Context(), propagation.HeaderCarrier(request.Header))
+	if !nra.Sampled(ctx) {
+		return request.WithContext(unsampled(ctx)), func(nra.CallInfo) {}
+	}
+
+	attributes := []attribute.KeyValue{
+		attribute.String("rpc.system", "nra"),
+		attribute.String("rpc.method", name),
+	}
+	if id := nra.RequestIDOf(ctx); id != "" {
+		attributes = append(attributes, RequestIDKey.String(id))
+	}
+
+	ctx, span := o.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer), trace.WithAttributes(attributes...))
+	return request.WithContext(ctx), func(info nra.CallInfo) {
+		defer span.End()
+
+		span.SetAttributes(ArgsKey.Int(info.Args), StatusCodeKey.Int(info.Status))
+		if info.Error != nil {
+			span.SetAttributes(ErrorTypeKey.String(string(info.Error.Type)))
+			span.RecordError(info.Error)
+		}
+
+		// like with HTTP, errors of the client don't
+		// make the span of the server a failure.
+		if info.Status >= http.StatusInternalServerError {
+			message := http.StatusText(info.Status)
+			if info.Error != nil {
+				message = info.Error.Message
+			}
+			span.SetStatus(codes.Error, message)
+		}
+	}
+}
+
+// unsampled returns ctx with the span context of the caller marked
+// as not sampled, or a new one if the caller didn't send any.
+func unsampled(ctx context.Context) context.Context {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		var traceID trace.TraceID
+		var spanID trace.SpanID
+		_, _ = rand.Read(traceID[:])
+		_, _ = rand.Read(spanID[:])
+		sc = trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID})
+	}
+	return trace.ContextWithSpanContext(ctx, sc.WithTraceFlags(sc.TraceFlags().WithSampled(false)))
+}
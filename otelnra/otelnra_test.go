@@ -0,0 +1,101 @@
+package otelnra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BigJk/nra"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestObserver(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	r := nra.NewRegistry()
+	r.EnableRequestIDs()
+	r.AddObserver(NewObserver(WithTracerProvider(provider), WithPropagators(propagation.TraceContext{})))
+	r.MustRegister("add", func(ctx context.Context, a, b int) (bool, error) {
+		return trace.SpanFromContext(ctx).SpanContext().IsValid(), nil
+	})
+	r.MustRegister("fail", func() error { return errors.New("failed") })
+	r.MustRegister("slow", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, nra.WithTimeout(time.Millisecond))
+
+	parent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest("POST", "/rpc/add", strings.NewReader("[1, 2]"))
+	req.Header.Set("traceparent", parent)
+	req.Header.Set(nra.RequestIDHeader, "abc")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, "true\n", rec.Body.String())
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/fail", nil))
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/slow", nil))
+
+	spans := recorder.Ended()
+	if !assert.Len(t, spans, 3) {
+		return
+	}
+
+	add := spans[0]
+	assert.Equal(t, "add", add.Name())
+	assert.Equal(t, trace.SpanKindServer, add.SpanKind())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", add.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", add.Parent().SpanID().String())
+	assert.Contains(t, add.Attributes(), attribute.String("rpc.method", "add"))
+	assert.Contains(t, add.Attributes(), ArgsKey.Int(2))
+	assert.Contains(t, add.Attributes(), StatusCodeKey.Int(http.StatusOK))
+	assert.Contains(t, add.Attributes(), RequestIDKey.String("abc"))
+	assert.Equal(t, codes.Unset, add.Status().Code)
+
+	fail := spans[1]
+	assert.Equal(t, "fail", fail.Name())
+	assert.False(t, fail.Parent().IsValid())
+	assert.Contains(t, fail.Attributes(), ErrorTypeKey.String(string(nra.ErrorTypeApplication)))
+	assert.Contains(t, fail.Attributes(), StatusCodeKey.Int(http.StatusBadRequest))
+	assert.Equal(t, codes.Unset, fail.Status().Code)
+	assert.Len(t, fail.Events(), 1)
+
+	slow := spans[2]
+	assert.Contains(t, slow.Attributes(), StatusCodeKey.Int(http.StatusGatewayTimeout))
+	assert.Equal(t, codes.Error, slow.Status().Code)
+}
+
+func TestObserverUnsampled(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	var sc trace.SpanContext
+	r := nra.NewRegistry()
+	r.AddObserver(NewObserver(WithTracerProvider(provider), WithPropagators(propagation.TraceContext{})))
+	r.MustRegister("hot", func(ctx context.Context) error {
+		sc = trace.SpanContextFromContext(ctx)
+		return nil
+	}, nra.WithSampleRate(0))
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/hot", nil))
+	assert.Empty(t, recorder.Ended())
+	assert.True(t, sc.IsValid())
+	assert.False(t, sc.IsSampled())
+
+	req := httptest.NewRequest("POST", "/rpc/hot", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Empty(t, recorder.Ended())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID().String())
+	assert.False(t, sc.IsSampled())
+}
@@ -0,0 +1,79 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// maxPooledBuffer is the capacity up to which buffers are put
+// back into the pool, so a single large result doesn't keep
+// its memory around.
+const maxPooledBuffer = 64 << 10
+
+// bufferPool holds the buffers results are encoded into. As the
+// buffers are reused they are already grown to a typical size.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// argsPool holds the slices JSON arguments are decoded into.
+var argsPool = sync.Pool{
+	New: func() interface{} {
+		args := make([]interface{}, 0, 8)
+		return &args
+	},
+}
+
+// decodeJSONArgs decodes the JSON arguments into a pooled slice.
+// The slice has to be given back with putArgs once the call is done.
+func decodeJSONArgs(r io.Reader) (*[]interface{}, error) {
+	pooled := argsPool.Get().(*[]interface{})
+	args := (*pooled)[:0]
+	err := json.NewDecoder(r).Decode(&args)
+
+	// keep the slice even if decoding grew it.
+	if args != nil {
+		*pooled = args
+	}
+	return pooled, err
+}
+
+// putArgs clears the arguments, so they can be collected,
+// and puts the slice back into the pool.
+func putArgs(pooled *[]interface{}) {
+	args := *pooled
+	for i := range args {
+		args[i] = nil
+	}
+	*pooled = args[:0]
+	argsPool.Put(pooled)
+}
+
+// writeResult encodes the result with the codec and writes it to the
+// response with the given status. Encoding into a buffer first means a result that can't be
+// encoded still gets a proper error response.
+func writeResult(writer http.ResponseWriter, status int, c Codec, result interface{}) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			bufferPool.Put(buf)
+		}
+	}()
+
+	if err := c.Encode(buf, result); err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: "can't encode result: " + err.Error(), Type: ErrorTypeInternal})
+		return
+	}
+
+	writer.Header().Set("Content-Type", c.ContentType())
+	writer.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+	writer.WriteHeader(status)
+	_, _ = writer.Write(buf.Bytes())
+}
@@ -0,0 +1,99 @@
+package nra
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type benchmarkResult struct {
+	ID    int      `json:"id"`
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags"`
+	Score float64  `json:"score"`
+}
+
+// benchmarkWriter is a http.ResponseWriter that can be reused,
+// so the benchmarks only measure the handler.
+type benchmarkWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *benchmarkWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *benchmarkWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *benchmarkWriter) WriteHeader(code int) {
+	w.code = code
+}
+
+func benchmarkHandler(b *testing.B, fn interface{}, body string) {
+	h := MustBind(fn)
+
+	reader := strings.NewReader(body)
+	req := httptest.NewRequest("POST", "/", nil)
+	req.Body = io.NopCloser(reader)
+	writer := &benchmarkWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(body)
+		writer.code = http.StatusOK
+		h(writer, req)
+		if writer.code != http.StatusOK {
+			b.Fatal(writer.code)
+		}
+	}
+}
+
+func BenchmarkBindScalar(b *testing.B) {
+	benchmarkHandler(b, func(a, c int) (int, error) {
+		return a + c, nil
+	}, "[1, 2]")
+}
+
+func BenchmarkBindStruct(b *testing.B) {
+	benchmarkHandler(b, func(id int, name string) ([]benchmarkResult, error) {
+		res := make([]benchmarkResult, 20)
+		for i := range res {
+			res[i] = benchmarkResult{ID: id + i, Name: name, Tags: []string{"a", "b", "c"}, Score: 1.5}
+		}
+		return res, nil
+	}, "[1, \"test\"]")
+}
+
+func TestWriteResult(t *testing.T) {
+	rr := httptest.NewRecorder()
+	writeResult(rr, http.StatusOK, jsonCodec, map[string]int{"a": 1})
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+	assert.Equal(t, "8", rr.Header().Get("Content-Length"))
+	assert.Equal(t, "{\"a\":1}\n", rr.Body.String())
+
+	// results that can't be encoded get a error instead of a partial body.
+	rr = httptest.NewRecorder()
+	writeResult(rr, http.StatusOK, jsonCodec, make(chan int))
+
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+	assert.Contains(t, rr.Body.String(), `"type":"internal"`)
+}
+
+func TestDecodeJSONArgs(t *testing.T) {
+	pooled, err := decodeJSONArgs(strings.NewReader(`[1, "a"]`))
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{1.0, "a"}, *pooled)
+
+	putArgs(pooled)
+	assert.Len(t, *pooled, 0)
+}
@@ -0,0 +1,80 @@
+package nra
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem()
+
+// protobufCodec encodes a single protobuf message. Other than the
+// other codecs the body isn't a array of arguments but the message
+// that is passed as the only argument, see binding.acceptsProtobuf.
+var protobufCodec Codec = protoCodec{}
+
+// protoCodec implements Codec for protobuf messages.
+type protoCodec struct{}
+
+// ContentType implements Codec.
+func (protoCodec) ContentType() string {
+	return "application/x-protobuf"
+}
+
+// Decode implements Codec. The arguments can't be decoded without
+// knowing the message type, see binding.decodeProtobuf.
+func (protoCodec) Decode(r io.Reader) ([]interface{}, error) {
+	return nil, errors.New("protobuf bodies need the message type")
+}
+
+// Encode implements Codec.
+func (protoCodec) Encode(w io.Writer, result interface{}) error {
+	msg, ok := result.(proto.Message)
+	if !ok {
+		return errors.New("result isn't a protobuf message")
+	}
+
+	data, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// acceptsProtobuf reports if the function can be called with a
+// protobuf body, which is the case if it takes a single message
+// and returns either a message or only a error.
+func (b *binding) acceptsProtobuf() bool {
+	if len(b.params) != 1 || b.variadic || !isProtoMessage(b.params[0]) {
+		return false
+	}
+	return b.result == nil || isProtoMessage(b.result)
+}
+
+// isProtoMessage reports if t is a pointer to a generated message.
+func isProtoMessage(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Implements(protoMessageType)
+}
+
+// decodeProtobuf decodes the body into a new message of the type
+// the function expects and returns it as the only argument.
+func (b *binding) decodeProtobuf(r io.Reader) ([]interface{}, error) {
+	if !b.acceptsProtobuf() {
+		return nil, errors.New("function can't be called with a protobuf body")
+	}
+
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := reflect.New(b.params[0].Elem()).Interface().(proto.Message)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return nil, err
+	}
+	return []interface{}{msg}, nil
+}
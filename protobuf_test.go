@@ -0,0 +1,69 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func TestBindProtobuf(t *testing.T) {
+	h := MustBind(func(s *wrapperspb.StringValue) (*wrapperspb.Int64Value, error) {
+		return wrapperspb.Int64(int64(len(s.GetValue()))), nil
+	})
+
+	body, err := proto.Marshal(wrapperspb.String("hello"))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	if !assert.Equal(t, http.StatusOK, rr.Code, rr.Body.String()) {
+		return
+	}
+	assert.Equal(t, "application/x-protobuf", rr.Header().Get("Content-Type"))
+
+	var result wrapperspb.Int64Value
+	if assert.NoError(t, proto.Unmarshal(rr.Body.Bytes(), &result)) {
+		assert.Equal(t, int64(5), result.GetValue())
+	}
+}
+
+func TestBindProtobufNotAccepted(t *testing.T) {
+	h := MustBind(func(a, b int) (int, error) { return a + b, nil })
+
+	req := httptest.NewRequest("POST", "/", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"function can't be called with a protobuf body\",\"type\":\"request\"}}\n", rr.Body.String())
+}
+
+func TestAcceptsProtobuf(t *testing.T) {
+	for _, c := range []struct {
+		fn       interface{}
+		expected bool
+	}{
+		{func(*wrapperspb.StringValue) error { return nil }, true},
+		{func(*http.Request, *wrapperspb.StringValue) (*wrapperspb.StringValue, error) { return nil, nil }, true},
+		{func(*wrapperspb.StringValue) (string, error) { return "", nil }, false},
+		{func(*wrapperspb.StringValue, int) error { return nil }, false},
+		{func(...*wrapperspb.StringValue) error { return nil }, false},
+	} {
+		b, err := newBinding(c.fn)
+		if assert.NoError(t, err) {
+			assert.Equal(t, c.expected, b.acceptsProtobuf())
+		}
+	}
+}
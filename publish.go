@@ -0,0 +1,120 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SchemaDocument is the schema of a registry as it is
+// published to a schema registry, see Registry.PublishSchema.
+type SchemaDocument struct {
+	// Service and Version are the title and the version
+	// of the OpenAPIInfo the schema was created with.
+	Service string `json:"service"`
+	Version string `json:"version"`
+
+	// Hash is the hex encoded SHA-256 of the OpenAPI document,
+	// so unchanged schemas are recognized without comparing them.
+	Hash string `json:"hash"`
+
+	// OpenAPI is the OpenAPI document of the registry.
+	OpenAPI json.RawMessage `json:"openapi"`
+}
+
+// SchemaDocument creates the schema of the registry with the
+// paths of the functions under the public prefix.
+func (r *Registry) SchemaDocument(info OpenAPIInfo) (*SchemaDocument, error) {
+	doc, err := json.Marshal(openAPIOf(r, info, r.PublicPrefix()))
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(doc)
+	return &SchemaDocument{
+		Service: info.Title,
+		Version: info.Version,
+		Hash:    hex.EncodeToString(hash[:]),
+		OpenAPI: doc,
+	}, nil
+}
+
+// SchemaPublisher configures where the schema of
+// a registry is published, see Registry.PublishSchema.
+type SchemaPublisher struct {
+	// URL is the endpoint the SchemaDocument is POSTed to as JSON.
+	URL string
+
+	// Info is the general information about the api.
+	Info OpenAPIInfo
+
+	// Header is added to the request, e.g. to authenticate.
+	Header http.Header
+
+	// Client sends the request. Defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// PublishSchema adds a start hook that POSTs the SchemaDocument of the
+// registry to a schema registry, so the api changes of all services can
+// be tracked in one place. Versions can then be compared with
+// "nra schema diff". The registry only becomes ready once the schema was
+// published, as Start fails if the schema registry doesn't answer with a
+// 2xx status:
+//
+//	r.PublishSchema(nra.SchemaPublisher{
+//		URL:  "https://schemas.example.com/services",
+//		Info: nra.OpenAPIInfo{Title: "billing", Version: version},
+//	})
+//
+// Functions have to be registered before Start is called.
+func (r *Registry) PublishSchema(p SchemaPublisher) {
+	r.OnStart(func(ctx context.Context) error {
+		if err := r.publishSchema(ctx, p); err != nil {
+			return fmt.Errorf("can't publish schema: %w", err)
+		}
+		return nil
+	})
+}
+
+// publishSchema sends the schema of the registry to the publisher.
+func (r *Registry) publishSchema(ctx context.Context, p SchemaPublisher) error {
+	doc, err := r.SchemaDocument(p.Info)
+	if err != nil {
+		return err
+	}
+
+	body, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, "POST", p.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range p.Header {
+		request.Header[key] = values
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("schema registry responded with %s", response.Status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package nra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishSchema(t *testing.T) {
+	var published SchemaDocument
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		assert.Equal(t, "POST", req.Method)
+		assert.Equal(t, "secret", req.Header.Get("X-Token"))
+		assert.NoError(t, json.NewDecoder(req.Body).Decode(&published))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.PublishSchema(SchemaPublisher{
+		URL:    server.URL,
+		Info:   OpenAPIInfo{Title: "calc", Version: "1.2.0"},
+		Header: http.Header{"X-Token": {"secret"}},
+	})
+
+	// the registry stays unavailable until the schema was published.
+	err := r.Start(context.Background())
+	if assert.Error(t, err) {
+		assert.Equal(t, "start hook failed: can't publish schema: schema registry responded with 500 Internal Server Error", err.Error())
+	}
+	assert.False(t, r.Ready())
+
+	status = http.StatusCreated
+	assert.NoError(t, r.Start(context.Background()))
+	assert.True(t, r.Ready())
+
+	assert.Equal(t, "calc", published.Service)
+	assert.Equal(t, "1.2.0", published.Version)
+	hash := sha256.Sum256(published.OpenAPI)
+	assert.Equal(t, hex.EncodeToString(hash[:]), published.Hash)
+
+	var doc struct {
+		Paths map[string]interface{} `json:"paths"`
+	}
+	assert.NoError(t, json.Unmarshal(published.OpenAPI, &doc))
+	assert.Contains(t, doc.Paths, "/rpc/add")
+}
@@ -0,0 +1,95 @@
+package nra
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"reflect"
+)
+
+// QueryArgumentsName is the query parameter that holds the JSON
+// encoded array of arguments when calling a function via GET.
+const QueryArgumentsName = "args"
+
+// queryArguments returns the arguments of a GET call. They are either
+// passed as JSON array in the args parameter or, for functions taking
+// a single struct, as one query parameter per field.
+func (b *binding) queryArguments(query url.Values) ([]interface{}, error) {
+	if values, ok := query[QueryArgumentsName]; ok {
+		var args []interface{}
+		if err := json.Unmarshal([]byte(values[0]), &args); err != nil {
+			return nil, errors.New("can't decode the " + QueryArgumentsName + " query parameter: " + err.Error())
+		}
+		return args, nil
+	}
+
+	if len(query) == 0 || len(query) == 1 && query.Has(FieldsName) {
+		return nil, nil
+	}
+
+	if len(b.params) != 1 || b.params[0].Kind() != reflect.Struct {
+		return nil, errors.New("named query parameters can only be used for functions taking a single struct")
+	}
+
+	fields := map[string]reflect.Type{}
+	for _, f := range jsonFields(b.params[0]) {
+		fields[f.name] = f.typ
+	}
+
+	object := map[string]interface{}{}
+	for key, values := range query {
+		if key == FieldsName {
+			continue
+		}
+
+		t, ok := fields[key]
+		if !ok {
+			return nil, errors.New("unknown query parameter '" + key + "'")
+		}
+
+		value, err := queryValue(values, t)
+		if err != nil {
+			return nil, errors.New("invalid value of query parameter '" + key + "': " + err.Error())
+		}
+		object[key] = value
+	}
+	return []interface{}{object}, nil
+}
+
+// queryValue converts the values of a query parameter to the generic
+// JSON value for a field of type t. Strings are taken as they are, so
+// they don't need quotes, everything else has to be valid JSON. Slices
+// can also be passed by repeating the parameter.
+func queryValue(values []string, t reflect.Type) (interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8 && (len(values) > 1 || isQueryString(t.Elem())) {
+		list := make([]interface{}, len(values))
+		for i := range values {
+			value, err := queryValue(values[i:i+1], t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			list[i] = value
+		}
+		return list, nil
+	}
+
+	if isQueryString(t) {
+		return values[0], nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(values[0]), &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// isQueryString reports if values of type t are passed
+// without quotes in the query string.
+func isQueryString(t reflect.Type) bool {
+	return t.Kind() == reflect.String || t == timeType
+}
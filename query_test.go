@@ -0,0 +1,56 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type querySearch struct {
+	Text  string   `json:"text"`
+	Limit int      `json:"limit" default:"10"`
+	Exact *bool    `json:"exact"`
+	Tags  []string `json:"tags"`
+	IDs   []int    `json:"ids"`
+}
+
+func TestBindGet(t *testing.T) {
+	add := MustBind(func(a, b int) (int, error) { return a + b, nil }, WithSafe())
+	search := MustBind(func(s querySearch) (querySearch, error) { return s, nil }, WithSafe())
+
+	for _, c := range []struct {
+		name     string
+		handler  http.HandlerFunc
+		query    string
+		code     int
+		expected string
+	}{
+		{"args", add, "args=" + url.QueryEscape("[1,2]"), http.StatusOK, "3\n"},
+		{"no_args", add, "", http.StatusBadRequest, "{\"error\":{\"message\":\"number of arguments mismatch. got=0 expected=2\",\"type\":\"validation\"}}\n"},
+		{"invalid_args", add, "args=1", http.StatusBadRequest, "{\"error\":{\"message\":\"can't decode the args query parameter: json: cannot unmarshal number into Go value of type []interface {}\",\"type\":\"request\"}}\n"},
+		{"named_not_struct", add, "a=1", http.StatusBadRequest, "{\"error\":{\"message\":\"named query parameters can only be used for functions taking a single struct\",\"type\":\"request\"}}\n"},
+		{"named", search, "text=123&exact=true&tags=a&ids=1&ids=2", http.StatusOK, "{\"text\":\"123\",\"limit\":10,\"exact\":true,\"tags\":[\"a\"],\"ids\":[1,2]}\n"},
+		{"named_json_slice", search, "ids=" + url.QueryEscape("[3,4]") + "&limit=5", http.StatusOK, "{\"text\":\"\",\"limit\":5,\"exact\":null,\"tags\":null,\"ids\":[3,4]}\n"},
+		{"named_unknown", search, "page=2", http.StatusBadRequest, "{\"error\":{\"message\":\"unknown query parameter 'page'\",\"type\":\"request\"}}\n"},
+		{"named_invalid", search, "limit=ten", http.StatusBadRequest, "{\"error\":{\"message\":\"invalid value of query parameter 'limit': invalid character 'e' in literal true (expecting 'r')\",\"type\":\"request\"}}\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			c.handler.ServeHTTP(rr, httptest.NewRequest("GET", "/?"+c.query, nil))
+			assert.Equal(t, c.code, rr.Code)
+			assert.Equal(t, c.expected, rr.Body.String())
+		})
+	}
+}
+
+func TestBindGetNotSafe(t *testing.T) {
+	h := MustBind(func(a, b int) (int, error) { return a + b, nil }, WithIdempotent())
+
+	rr := httptest.NewRecorder()
+	h.ServeHTTP(rr, httptest.NewRequest("GET", "/?args=[1,2]", nil))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"only POST requests are permitted\",\"type\":\"request\"}}\n", rr.Body.String())
+}
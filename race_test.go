@@ -0,0 +1,8 @@
+//go:build race
+// +build race
+
+package nra
+
+// raceEnabled reports if the tests run with the race detector,
+// which needs too much memory for the sandbox limits.
+const raceEnabled = true
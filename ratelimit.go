@@ -0,0 +1,158 @@
+package nra
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimit configures a token bucket per client. Every call takes a
+// token and the bucket is refilled with Rate tokens per second up to
+// Burst tokens. Calls finding the bucket empty are answered with status
+// 429, the type ErrorTypeLimit and a Retry-After header.
+type RateLimit struct {
+	// Rate is the number of calls per second a client can make.
+	Rate float64
+
+	// Burst is the number of calls a client can make at once.
+	// It is at least 1.
+	Burst int
+
+	// Key returns the key of the client a request is counted for.
+	// It defaults to RateLimitByIP. Requests with a empty key share
+	// a bucket.
+	Key func(request *http.Request) string
+}
+
+// RateLimitByIP counts requests by the IP address they come from.
+// Behind a reverse proxy all requests come from the proxy, so use
+// RateLimitByHeader with a header the proxy sets instead.
+func RateLimitByIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
+
+// RateLimitByHeader counts requests by the value of the header, e.g.
+// a API key or the client IP set by a proxy. Requests without the
+// header are counted by their IP.
+func RateLimitByHeader(name string) func(request *http.Request) string {
+	return func(request *http.Request) string {
+		if value := request.Header.Get(name); value != "" {
+			return name + ":" + value
+		}
+		return RateLimitByIP(request)
+	}
+}
+
+// WithRateLimit limits how often each client can call the function.
+// See Registry.SetRateLimit for a limit of all functions.
+func WithRateLimit(limit RateLimit) Option {
+	return func(o *options) {
+		o.rateLimit = &limit
+	}
+}
+
+// minSweep is the number of buckets from which on
+// full buckets are removed from a rateLimiter.
+const minSweep = 1024
+
+// rateLimiter holds the token buckets of a RateLimit.
+type rateLimiter struct {
+	limit RateLimit
+	now   func() time.Time
+
+	mtx     sync.Mutex
+	buckets map[string]*tokenBucket
+	sweepAt int
+}
+
+// tokenBucket is the bucket of a single client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter creates the limiter of the RateLimit.
+// A nil limit returns a nil rateLimiter.
+func newRateLimiter(limit *RateLimit) *rateLimiter {
+	if limit == nil {
+		return nil
+	}
+
+	l := &rateLimiter{limit: *limit, now: time.Now, buckets: map[string]*tokenBucket{}, sweepAt: minSweep}
+	if l.limit.Burst < 1 {
+		l.limit.Burst = 1
+	}
+	if l.limit.Key == nil {
+		l.limit.Key = RateLimitByIP
+	}
+	return l
+}
+
+// allow takes a token from the bucket of the client of the request,
+// prefixed by the function name. If the bucket is empty it returns
+// how long the client has to wait until the next token is available.
+// A nil rateLimiter allows all calls.
+func (l *rateLimiter) allow(name string, request *http.Request) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	key := name + "\x00" + l.limit.Key(request)
+	now := l.now()
+	burst := float64(l.limit.Burst)
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	b, ok := l.buckets[key]
+	if !ok {
+		l.sweep(now)
+		b = &tokenBucket{tokens: burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if l.limit.Rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - b.tokens) / l.limit.Rate * float64(time.Second))
+}
+
+// sweep removes the buckets that are full again once there are too
+// many of them, as they behave the same as a new bucket.
+func (l *rateLimiter) sweep(now time.Time) {
+	if len(l.buckets) < l.sweepAt {
+		return
+	}
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.limit.Rate >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+
+	l.sweepAt = 2 * len(l.buckets)
+	if l.sweepAt < minSweep {
+		l.sweepAt = minSweep
+	}
+}
+
+// writeRateLimited answers a call that exceeded the rate limit.
+func writeRateLimited(writer http.ResponseWriter, wait time.Duration) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	writeError(writer, http.StatusTooManyRequests, &Error{Message: "rate limit exceeded", Type: ErrorTypeLimit})
+}
@@ -0,0 +1,78 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := newRateLimiter(&RateLimit{Rate: 2, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	a := httptest.NewRequest("POST", "/", nil)
+	a.RemoteAddr = "192.0.2.1:1234"
+	b := httptest.NewRequest("POST", "/", nil)
+	b.RemoteAddr = "192.0.2.2:1234"
+
+	ok, _ := l.allow("add", a)
+	assert.True(t, ok)
+	ok, _ = l.allow("add", a)
+	assert.True(t, ok)
+
+	ok, wait := l.allow("add", a)
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// other clients and functions have their own bucket.
+	ok, _ = l.allow("add", b)
+	assert.True(t, ok)
+	ok, _ = l.allow("sub", a)
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.allow("add", a)
+	assert.True(t, ok)
+}
+
+func TestRateLimitByHeader(t *testing.T) {
+	key := RateLimitByHeader("X-Api-Key")
+
+	req := httptest.NewRequest("POST", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	assert.Equal(t, "192.0.2.1", key(req))
+
+	req.Header.Set("X-Api-Key", "secret")
+	assert.Equal(t, "X-Api-Key:secret", key(req))
+}
+
+func TestRateLimit(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("ping", func() error { return nil })
+	r.MustRegister("slow", func() error { return nil }, WithRateLimit(RateLimit{Rate: 0.1, Burst: 1}))
+	r.SetRateLimit(RateLimit{Rate: 0.5, Burst: 2})
+
+	call := func(name string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/"+name, nil))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, call("ping").Code)
+	assert.Equal(t, http.StatusOK, call("ping").Code)
+
+	rr := call("ping")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "2", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "{\"error\":{\"message\":\"rate limit exceeded\",\"type\":\"limit\"}}\n", rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, call("slow").Code)
+
+	rr = call("slow")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("Retry-After"))
+}
@@ -0,0 +1,280 @@
+package nra
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Recording is a call and its response as written by
+// Registry.SetRecording and served by Registry.SetReplay.
+// Recordings are kept one per line in a file per function,
+// named like the function with the extension ".jsonl", so
+// they can be edited by hand or created for functions that
+// don't exist yet.
+type Recording struct {
+	Function string `json:"function"`
+	Version  int    `json:"version,omitempty"`
+	Query    string `json:"query,omitempty"`
+
+	// Args is the body of the call if it is JSON,
+	// otherwise the body is in RawArgs.
+	Args    json.RawMessage `json:"args,omitempty"`
+	RawArgs []byte          `json:"raw_args,omitempty"`
+
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+
+	// Result is the body of the response if it is JSON,
+	// otherwise the body is in RawResult.
+	Result    json.RawMessage `json:"result,omitempty"`
+	RawResult []byte          `json:"raw_result,omitempty"`
+
+	Recorded time.Time `json:"recorded"`
+}
+
+// unrecordedHeaders are the response headers that belong to a
+// single call, or are set again by the registry on replay. The
+// body is recorded before it is compressed, so the encoding is
+// left out as well.
+var unrecordedHeaders = []string{RequestIDHeader, "Set-Cookie", "Vary", "Date", "Content-Length", "Content-Encoding"}
+
+// recordingFile returns the file the recordings of
+// the version of the function are kept in.
+func recordingFile(dir string, name string, version int) string {
+	return filepath.Join(dir, strings.ReplaceAll(versionedName(name, version), "/", "_")+".jsonl")
+}
+
+// recordingKey returns the key a call is matched by on replay. JSON
+// arguments are compared by their content, not their formatting.
+func recordingKey(query string, body []byte) string {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err == nil {
+		if canonical, err := json.Marshal(value); err == nil {
+			body = canonical
+		}
+	}
+	return query + "\x00" + string(body)
+}
+
+// recorder appends the calls of a registry to the files in dir.
+type recorder struct {
+	dir string
+	mtx sync.Mutex
+}
+
+// SetRecording writes every call of the registry and its response to
+// a file per function in dir, see Recording, e.g. to capture a session
+// with the real backend for SetReplay. Recordings are appended, so dir
+// can be shared by more than one run. Responses are recorded as they
+// leave the function, authentication or rate limits rejecting a call
+// aren't recorded. Functions with a *Upload argument are neither
+// recorded nor replayed, as their body is streamed to them. Calling it
+// with a empty dir stops the recording.
+//
+// Recordings contain the arguments and results as they were sent, so
+// they shouldn't be made with production data.
+func (r *Registry) SetRecording(dir string) error {
+	if dir == "" {
+		r.recorder = nil
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	r.recorder = &recorder{dir: dir}
+	return nil
+}
+
+// record calls the handler and appends the call and its response to
+// the recordings of the function. Failed writes are logged if a
+// logger is set.
+func (rc *recorder) record(writer http.ResponseWriter, request *http.Request, name string, version int, logger *callLogger, handler http.HandlerFunc) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+			return
+		}
+		writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+		return
+	}
+	_ = request.Body.Close()
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	w := &captureWriter{ResponseWriter: writer}
+	handler(w, request)
+
+	// a 304 depends on what the client had cached before.
+	if w.statusCode() == http.StatusNotModified {
+		return
+	}
+
+	rec := Recording{
+		Function: name,
+		Version:  version,
+		Query:    request.URL.RawQuery,
+		Status:   w.statusCode(),
+		Header:   w.Header().Clone(),
+		Recorded: time.Now(),
+	}
+	for _, h := range unrecordedHeaders {
+		rec.Header.Del(h)
+	}
+	if len(rec.Header) == 0 {
+		rec.Header = nil
+	}
+	if json.Valid(body) {
+		rec.Args = body
+	} else {
+		rec.RawArgs = body
+	}
+	if result := w.body.Bytes(); json.Valid(result) {
+		rec.Result = result
+	} else {
+		rec.RawResult = result
+	}
+
+	if err := rc.write(rec); err != nil && logger != nil {
+		logger.logger.LogAttrs(request.Context(), slog.LevelWarn, "recording failed", slog.String("function", name), slog.String("error", err.Error()))
+	}
+}
+
+// write appends the recording to the file of its function.
+func (rc *recorder) write(rec Recording) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	rc.mtx.Lock()
+	defer rc.mtx.Unlock()
+
+	f, err := os.OpenFile(recordingFile(rc.dir, rec.Function, rec.Version), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// replayer serves recorded responses instead of calling the functions.
+type replayer struct {
+	// recordings by the versioned name of their
+	// function and the key of the call.
+	recordings map[string]map[string]Recording
+}
+
+// SetReplay answers all calls with the responses recorded in dir, see
+// SetRecording, without calling the functions, e.g. to develop a
+// frontend against a frozen backend or to make integration tests
+// deterministic. Calls are matched by their function, version, query
+// and arguments. The caller is ignored, so a recording is served to
+// every identity. If more than one recording matches the last one is
+// served, calls without a recording fail with status 404. Everything
+// in front of the functions, like authentication and rate limits,
+// still applies. Calling it with a empty dir stops the replay.
+func (r *Registry) SetReplay(dir string) error {
+	if dir == "" {
+		r.replayer = nil
+		return nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if err != nil {
+		return err
+	}
+
+	rp := &replayer{recordings: map[string]map[string]Recording{}}
+	for _, file := range files {
+		if err := rp.load(file); err != nil {
+			return err
+		}
+	}
+	r.replayer = rp
+	return nil
+}
+
+// load reads the recordings of a file.
+func (rp *replayer) load(file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var rec Recording
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return errors.New(file + ":" + strconv.Itoa(line) + ": " + err.Error())
+		}
+
+		name := versionedName(rec.Function, rec.Version)
+		if rp.recordings[name] == nil {
+			rp.recordings[name] = map[string]Recording{}
+		}
+		args := []byte(rec.Args)
+		if len(args) == 0 {
+			args = rec.RawArgs
+		}
+		rp.recordings[name][recordingKey(rec.Query, args)] = rec
+	}
+	return scanner.Err()
+}
+
+// serve writes the recorded response of the call.
+func (rp *replayer) serve(writer http.ResponseWriter, request *http.Request, name string, version int) {
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+			return
+		}
+		writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+		return
+	}
+
+	rec, ok := rp.recordings[versionedName(name, version)][recordingKey(request.URL.RawQuery, body)]
+	if !ok {
+		writeError(writer, http.StatusNotFound, &Error{Message: "no recorded response of '" + name + "' for the arguments", Type: ErrorTypeNotFound})
+		return
+	}
+
+	for k, v := range rec.Header {
+		writer.Header()[k] = v
+	}
+	result := []byte(rec.Result)
+	if len(result) == 0 {
+		result = rec.RawResult
+	}
+	status := rec.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	writer.Header().Set("Content-Length", strconv.Itoa(len(result)))
+	writer.WriteHeader(status)
+	_, _ = writer.Write(result)
+}
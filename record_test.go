@@ -0,0 +1,117 @@
+package nra
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	calls := 0
+	add := func(a, b int) (int, error) {
+		calls++
+		if a < 0 {
+			return 0, errors.New("negative")
+		}
+		return a + b, nil
+	}
+
+	call := func(r *Registry, path string, body string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", path, strings.NewReader(body)))
+		return rr
+	}
+
+	r := NewRegistry()
+	r.MustRegister("add", add)
+	r.MustRegister("add", func(a, b, c int) (int, error) { return a + b + c, nil }, WithVersion(2))
+	assert.NoError(t, r.SetRecording(dir))
+	assert.Contains(t, r.Description().Middleware, "recording")
+
+	assert.Equal(t, "3\n", call(r, "/rpc/add", "[1, 2]").Body.String())
+	assert.Equal(t, http.StatusBadRequest, call(r, "/rpc/add", "[-1, 2]").Code)
+	assert.Equal(t, "6\n", call(r, "/rpc/v2/add", "[1, 2, 3]").Body.String())
+	assert.Equal(t, 2, calls)
+
+	// unknown functions aren't recorded.
+	call(r, "/rpc/sub", "[1, 2]")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "add.jsonl"), filepath.Join(dir, "v2_add.jsonl")}, files)
+
+	data, err := os.ReadFile(filepath.Join(dir, "add.jsonl"))
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(string(data)), "\n"), 2)
+	assert.Contains(t, string(data), `"args":[1,2],"status":200,"header":{"Content-Type":["application/json"]},"result":3`)
+
+	// the replay doesn't need the functions, only the names.
+	r = NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) {
+		t.Fatal("function called on replay")
+		return 0, nil
+	})
+	r.MustRegister("add", func(a, b, c int) (int, error) { return 0, nil }, WithVersion(2))
+	assert.NoError(t, r.SetReplay(dir))
+
+	rr := call(r, "/rpc/add", "[ 1,2 ]")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3", rr.Body.String())
+	assert.Equal(t, "application/json", rr.Header().Get("Content-Type"))
+
+	rr = call(r, "/rpc/add", "[-1, 2]")
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "negative")
+
+	assert.Equal(t, "6", call(r, "/rpc/v2/add", "[1, 2, 3]").Body.String())
+
+	rr = call(r, "/rpc/add", "[2, 2]")
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Contains(t, rr.Body.String(), "no recorded response of 'add'")
+
+	// later recordings win.
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "add.jsonl"), append(data, []byte(`{"function":"add","args":[1,2],"result":4}`+"\n")...), 0o644))
+	assert.NoError(t, r.SetReplay(dir))
+	assert.Equal(t, "4", call(r, "/rpc/add", "[1, 2]").Body.String())
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "broken.jsonl"), []byte("{"), 0o644))
+	assert.ErrorContains(t, r.SetReplay(dir), "broken.jsonl:1")
+
+	assert.NoError(t, r.SetReplay(""))
+	assert.Equal(t, "0\n", call(r, "/rpc/v2/add", "[1, 2, 3]").Body.String())
+}
+
+func TestRecordCompressed(t *testing.T) {
+	dir := t.TempDir()
+	text := strings.Repeat("a", 2048)
+
+	r := NewRegistry()
+	r.SetCompression(0)
+	r.MustRegister("text", func() (string, error) { return text, nil })
+	assert.NoError(t, r.SetRecording(dir))
+
+	req := httptest.NewRequest("POST", "/rpc/text", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, "gzip", rr.Header().Get("Content-Encoding"))
+
+	// the replay is plain for clients that don't accept gzip.
+	r = NewRegistry()
+	r.MustRegister("text", func() (string, error) { return "", nil })
+	assert.NoError(t, r.SetReplay(dir))
+
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/text", nil))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Empty(t, rr.Header().Get("Content-Encoding"))
+	assert.Equal(t, "\""+text+"\"", rr.Body.String())
+}
@@ -0,0 +1,593 @@
+package nra
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPrefix is the path prefix under which a Registry
+// expects its functions to be called.
+const DefaultPrefix = "/rpc/"
+
+// Registry is a named collection of bound functions. It
+// implements http.Handler and routes each request to the
+// function named by the last part of the path, so a single
+// registry can be mounted instead of binding every function
+// by hand:
+//
+//	r := nra.NewRegistry()
+//	r.MustRegister("add", addFunction)
+//	http.Handle("/rpc/", r)
+//
+// Because the registry knows about the signatures of all its
+// functions it can also be used to generate clients.
+type Registry struct {
+	prefix string
+
+	// mtx guards functions and versions, so functions can be
+	// registered and removed while the registry serves calls.
+	mtx       sync.RWMutex
+	functions map[string]*binding
+
+	// all versions of the functions, the default
+	// version is also in functions.
+	versions map[string]map[int]*binding
+
+	// optional endpoints served under the prefix.
+	clientJS      bool
+	debugOverlay  bool
+	openAPI       *OpenAPIInfo
+	introspection bool
+	jsonSchemas   bool
+	health        health
+
+	lifecycle lifecycle
+
+	maxBodyBytes int64
+	compression  int
+
+	// the path the registry is reachable under from the outside.
+	basePath             string
+	trustForwardedPrefix bool
+
+	accessLog *AccessLogger
+	rateLimit *rateLimiter
+	shedder   *loadShedder
+	cors      *CORS
+	csrf      *CSRF
+
+	authenticator Authenticator
+	sessions      *Sessions
+	sessionKeys   sessionKeys
+	urlKeys       sessionKeys
+
+	encryptionKeys []*rsa.PrivateKey
+	resolvers      resolvers
+	requestIDs     bool
+	observers      []Observer
+	logger         *callLogger
+	audit          *auditor
+	transforms     []BodyTransform
+	sampleRate     *float64
+
+	idempotency     *Idempotency
+	idempotencyOnce sync.Once
+
+	recorder *recorder
+	replayer *replayer
+
+	// set for registries created by ImportSchema, which
+	// have no private key and rebuilt types without names.
+	importedKey string
+	typeNames   map[reflect.Type]string
+}
+
+// ClientJSName is the name under which the generated
+// Javascript client is served if it is enabled.
+const ClientJSName = "client.js"
+
+// NewRegistry creates a empty registry that serves
+// its functions under DefaultPrefix.
+func NewRegistry() *Registry {
+	return &Registry{
+		prefix:    DefaultPrefix,
+		functions: map[string]*binding{},
+		versions:  map[string]map[int]*binding{},
+	}
+}
+
+// Register binds fn and makes it callable under the given name.
+// See Bind for which functions are valid. Functions can be
+// registered while the registry is serving calls.
+func (r *Registry) Register(name string, fn interface{}, opts ...Option) error {
+	b, err := r.newBinding(name, fn, opts)
+	if err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.registered(name, b.options.apiVersion()); err != nil {
+		return err
+	}
+	r.addFunction(name, b)
+	return nil
+}
+
+// Replace is the same as Register, but if the version of the function
+// is already registered it is replaced. The switch is atomic: calls
+// that already started finish with the old function, all later calls
+// get the new one. This allows plugins to swap their functions
+// without restarting the server.
+func (r *Registry) Replace(name string, fn interface{}, opts ...Option) error {
+	b, err := r.newBinding(name, fn, opts)
+	if err != nil {
+		return err
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.addFunction(name, b)
+	return nil
+}
+
+// Unregister removes all versions of the function name and reports if
+// it was registered. Calls that already started still finish, later
+// calls fail with ErrorTypeNotFound.
+func (r *Registry) Unregister(name string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if _, ok := r.functions[name]; !ok {
+		return false
+	}
+	for _, b := range r.versions[name] {
+		b.close()
+	}
+	delete(r.functions, name)
+	delete(r.versions, name)
+	return true
+}
+
+// newBinding checks the name and binds fn for the registry.
+func (r *Registry) newBinding(name string, fn interface{}, opts []Option) (*binding, error) {
+	if len(name) == 0 || strings.Contains(name, "/") {
+		return nil, errors.New("name can't be empty or contain a '/'")
+	}
+
+	b, err := newBinding(fn, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// inside the subprocess itself the function is called directly.
+	if b.options.subprocess && !isSubprocess() {
+		b.handler = withMiddleware(subprocessHandler(versionedName(name, b.options.apiVersion()), b.options.sandbox, b.options.maxBodyBytes), b.options.middleware)
+	}
+	return b, nil
+}
+
+// MustRegister is the same as Register but panics
+// if the function can't be registered.
+func (r *Registry) MustRegister(name string, fn interface{}, opts ...Option) {
+	if err := r.Register(name, fn, opts...); err != nil {
+		panic("nra: register failed with: " + err.Error())
+	}
+}
+
+// EnableClientJS makes the registry serve the Javascript client
+// generated by GenerateJavaScript under the prefix, e.g. /rpc/client.js,
+// so it can be included directly in your html:
+//
+//	<script src="/rpc/client.js"></script>
+func (r *Registry) EnableClientJS() {
+	r.clientJS = true
+}
+
+// EnableDebugOverlay makes the generated Javascript client show a
+// overlay with the recent calls as soon as it is loaded. Every call
+// lists its arguments, result or error, duration and request id, see
+// EnableRequestIDs, and can be copied as curl command. Only enable it
+// during development, as the overlay shows the headers of the calls,
+// including credentials set in rpc.headers.
+func (r *Registry) EnableDebugOverlay() {
+	r.debugOverlay = true
+}
+
+// EnableOpenAPI makes the registry serve the OpenAPI document
+// generated by GenerateOpenAPI under the prefix, e.g. /rpc/openapi.json.
+func (r *Registry) EnableOpenAPI(info OpenAPIInfo) {
+	r.openAPI = &info
+}
+
+// SetCompression compresses all responses of the registry that are at
+// least minSize bytes large if the client accepts it, which pays off for
+// functions returning large JSON arrays. Only responses with a known
+// Content-Length are compressed. A minSize of 0 uses
+// DefaultCompressionMinSize.
+func (r *Registry) SetCompression(minSize int) {
+	if minSize <= 0 {
+		minSize = DefaultCompressionMinSize
+	}
+	r.compression = minSize
+}
+
+// SetMaxBodyBytes limits the size of the request body of calls of all
+// functions to n bytes, the same as WithMaxBodyBytes does for a single
+// function. If both are set the smaller limit applies.
+func (r *Registry) SetMaxBodyBytes(n int64) {
+	r.maxBodyBytes = n
+}
+
+// SetRateLimit limits how often each client can call the functions of
+// the registry. Every function has its own bucket per client, so a
+// client calling one function a lot can still call the others. Limits
+// set with WithRateLimit apply in addition.
+func (r *Registry) SetRateLimit(limit RateLimit) {
+	r.rateLimit = newRateLimiter(&limit)
+}
+
+// EnableCORS makes the registry answer CORS preflight requests and add
+// the CORS headers to all its responses, so browser apps hosted on
+// other origins can call the functions:
+//
+//	r.EnableCORS(nra.CORS{
+//		AllowedOrigins: []string{"https://app.example.com"},
+//		MaxAge:         time.Hour,
+//	})
+func (r *Registry) EnableCORS(cors CORS) {
+	r.cors = &cors
+}
+
+// SetAccessLogger makes the registry write a line for every
+// request it serves to the logger.
+//
+//	r.SetAccessLogger(nra.NewAccessLogger(os.Stdout, nra.CombinedLogFormat))
+func (r *Registry) SetAccessLogger(l *AccessLogger) {
+	r.accessLog = l
+}
+
+// Prefix returns the path prefix the registry is served under.
+func (r *Registry) Prefix() string {
+	return r.prefix
+}
+
+// SetBasePath sets the path a reverse proxy serves the registry under,
+// if it strips it before passing the request on. The generated clients,
+// the introspection and the OpenAPI document then use the base path plus
+// the prefix, e.g. /api/rpc/, while the registry still routes on /rpc/.
+func (r *Registry) SetBasePath(path string) {
+	r.basePath = strings.TrimSuffix(path, "/")
+}
+
+// TrustForwardedPrefix makes the endpoints served by the registry use
+// the X-Forwarded-Prefix header of the request as base path, so the same
+// server can be reached under different paths. Only enable it if the
+// server is behind a proxy that sets or removes the header, as clients
+// could otherwise send it themselves.
+func (r *Registry) TrustForwardedPrefix() {
+	r.trustForwardedPrefix = true
+}
+
+// PublicPrefix returns the path prefix clients call the
+// functions under, which is the base path plus the prefix.
+func (r *Registry) PublicPrefix() string {
+	return r.basePath + r.prefix
+}
+
+// publicPrefixOf returns the path prefix clients call the functions
+// under for the request. A X-Forwarded-Prefix header that isn't a
+// plain absolute path is ignored, so it can't point to another host.
+func (r *Registry) publicPrefixOf(request *http.Request) string {
+	if !r.trustForwardedPrefix {
+		return r.PublicPrefix()
+	}
+
+	base := strings.TrimSuffix(request.Header.Get("X-Forwarded-Prefix"), "/")
+	if base == "" || !validBasePath.MatchString(base) {
+		return r.PublicPrefix()
+	}
+	return base + r.prefix
+}
+
+// validBasePath matches absolute paths made of common path characters.
+var validBasePath = regexp.MustCompile(`^(/[A-Za-z0-9._~-]+)+$`)
+
+// Names returns the sorted names of all registered functions.
+func (r *Registry) Names() []string {
+	names, _ := r.snapshot()
+	return names
+}
+
+// function returns the default version of the function name.
+func (r *Registry) function(name string) (*binding, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	b, ok := r.functions[name]
+	return b, ok
+}
+
+// snapshot returns the sorted names of all functions and their
+// default versions, so they can be iterated without holding the
+// lock while functions are registered or removed.
+func (r *Registry) snapshot() ([]string, map[string]*binding) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	names := make([]string, 0, len(r.functions))
+	functions := make(map[string]*binding, len(r.functions))
+	for name, b := range r.functions {
+		names = append(names, name)
+		functions[name] = b
+	}
+	sort.Strings(names)
+	return names, functions
+}
+
+// ServeHTTP calls the function that is named by the request path.
+func (r *Registry) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if r.requestIDs {
+		request = withRequestID(writer, request)
+	}
+
+	if r.accessLog != nil {
+		w := &statusWriter{ResponseWriter: writer}
+		defer func() {
+			name, _ := r.route(request)
+			if _, ok := r.function(name); !ok || !strings.HasPrefix(request.URL.Path, r.prefix) {
+				name = ""
+			}
+			r.accessLog.log(request, w, name)
+		}()
+		writer = w
+	}
+
+	// the access log sees the size that was actually sent.
+	if r.compression > 0 {
+		var done func()
+		writer, done = withCompression(writer, request, r.compression)
+		defer done()
+	}
+
+	if !strings.HasPrefix(request.URL.Path, r.prefix) {
+		writeError(writer, http.StatusNotFound, &Error{Message: "path is not under " + r.prefix, Type: ErrorTypeRequest})
+		return
+	}
+
+	// preflight requests don't call anything, so they
+	// are answered even if the registry isn't ready.
+	if r.cors != nil && r.cors.handle(writer, request, "GET, POST") {
+		return
+	}
+
+	// probes have to be answered most of all while
+	// the registry isn't ready.
+	if r.serveHealth(writer, request, strings.TrimPrefix(request.URL.Path, r.prefix)) {
+		return
+	}
+
+	if !r.lifecycle.ready() {
+		r.lifecycle.writeNotReady(writer)
+		return
+	}
+
+	// every response hands out the double-submit token, so
+	// loading the client is enough to be able to call.
+	r.CSRFToken(writer, request)
+
+	name := strings.TrimPrefix(request.URL.Path, r.prefix)
+	switch {
+	case r.clientJS && name == ClientJSName:
+		r.serveClientJS(writer, request)
+		return
+	case r.openAPI != nil && name == OpenAPIName:
+		r.serveOpenAPI(writer, request)
+		return
+	case r.introspection && name == MethodsName:
+		r.serveMethods(writer, request)
+		return
+	case r.jsonSchemas && (name == JSONSchemaName || strings.HasPrefix(name, JSONSchemaName+"/")):
+		r.serveJSONSchemas(writer, request, strings.TrimPrefix(strings.TrimPrefix(name, JSONSchemaName), "/"))
+		return
+	case len(r.encryptionKeys) > 0 && name == EncryptionKeyName:
+		r.serveEncryptionKey(writer, request)
+		return
+	}
+
+	name, version := r.route(request)
+	b, err := r.lookup(name, version)
+	if err != nil {
+		writeError(writer, http.StatusNotFound, err)
+		return
+	}
+	if b.options.deprecated {
+		writeDeprecation(writer, name, b)
+	}
+
+	// a signed url stands in for the credentials.
+	if isSignedCall(request) {
+		var err *Error
+		if request, err = r.verifySignedCall(request, versionedName(name, b.options.apiVersion())); err != nil {
+			writeError(writer, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	// running calls are counted, so Shutdown can wait for them.
+	ctx, done, ok := r.lifecycle.begin(request.Context(), name)
+	if !ok {
+		r.lifecycle.writeNotReady(writer)
+		return
+	}
+	defer done()
+	request = request.WithContext(ctx)
+
+	if r.observing() {
+		var finish func()
+		writer, request, finish = r.observe(writer, request, name)
+		defer finish()
+	}
+
+	if r.csrf != nil && !signed(request.Context()) && inProcessOf(request.Context()) == nil {
+		if err := r.checkCSRF(request); err != nil {
+			writeError(writer, http.StatusForbidden, err)
+			return
+		}
+	}
+
+	if r.shedder != nil {
+		if !r.shedder.admit(name, b.options.priority) {
+			r.shedder.writeShed(writer)
+			return
+		}
+		defer r.shedder.done()
+	}
+
+	if ok, wait := r.rateLimit.allow(name, request); !ok {
+		writeRateLimited(writer, wait)
+		return
+	}
+
+	// functions with their own authenticator run it in their handler.
+	if r.authenticator != nil && b.options.authenticator == nil && !signed(request.Context()) {
+		if request = authenticate(r.authenticator, writer, request); request == nil {
+			return
+		}
+	}
+
+	if r.sessions != nil {
+		request = r.withSession(request)
+	}
+	if r.resolvers != nil {
+		request = r.withResolvers(request)
+	}
+	if len(r.encryptionKeys) > 0 {
+		request = r.withEncryptionKeys(request)
+	}
+
+	if r.maxBodyBytes > 0 {
+		request.Body = http.MaxBytesReader(writer, request.Body, r.maxBodyBytes)
+	}
+
+	call := func(writer http.ResponseWriter, request *http.Request) {
+		if err := transformBody(request, r.transforms); err != nil {
+			writeError(writer, http.StatusBadRequest, err)
+			return
+		}
+		b.handler(writer, request)
+	}
+
+	// recordings have the body as it was sent, before any transform.
+	// Direct in process calls have no body, so they are left out, and
+	// uploads are streamed, so they would have to be kept in memory.
+	if !inProcessOf(request.Context()).isDirect() && !b.upload {
+		version := b.options.apiVersion()
+		if rp := r.replayer; rp != nil {
+			call = func(writer http.ResponseWriter, request *http.Request) {
+				rp.serve(writer, request, name, version)
+			}
+		} else if rc := r.recorder; rc != nil {
+			handler := call
+			call = func(writer http.ResponseWriter, request *http.Request) {
+				rc.record(writer, request, name, version, r.logger, handler)
+			}
+		}
+	}
+
+	// the key identifies the body as it was sent.
+	if b.options.idempotencyKey {
+		r.serveIdempotent(writer, request, versionedName(name, b.options.apiVersion()), b.options.authenticator, call)
+		return
+	}
+	call(writer, request)
+}
+
+// maxSuggestions is the maximum number of names
+// suggested for a unknown function.
+const maxSuggestions = 3
+
+// notFoundError creates the error for a unknown function
+// including suggestions for similar registered names.
+func (r *Registry) notFoundError(name string) *Error {
+	suggestions := r.suggest(name)
+
+	message := "unknown function '" + name + "'"
+	if len(suggestions) > 0 {
+		message += ", did you mean '" + suggestions[0] + "'?"
+	}
+
+	return &Error{Message: message, Type: ErrorTypeNotFound, Suggestions: suggestions}
+}
+
+// suggest returns the registered names that are close to name,
+// ordered by their edit distance. Names are compared case
+// insensitive, so a wrongly cased name is always suggested.
+func (r *Registry) suggest(name string) []string {
+	type candidate struct {
+		name     string
+		distance int
+	}
+
+	maxDistance := len(name) / 3
+	if maxDistance < 2 {
+		maxDistance = 2
+	}
+
+	var candidates []candidate
+	for _, other := range r.Names() {
+		if d := levenshtein(strings.ToLower(name), strings.ToLower(other)); d <= maxDistance {
+			candidates = append(candidates, candidate{name: other, distance: d})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	var suggestions []string
+	for i := 0; i < len(candidates) && i < maxSuggestions; i++ {
+		suggestions = append(suggestions, candidates[i].name)
+	}
+	return suggestions
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			cur[j] = prev[j] + 1
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+			if prev[j-1]+cost < cur[j] {
+				cur[j] = prev[j-1] + cost
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(rb)]
+}
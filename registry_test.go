@@ -0,0 +1,155 @@
+package nra
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) {
+		return a + b, nil
+	})
+
+	assert.Error(t, r.Register("add", func() error { return nil }), "duplicate name")
+	assert.Error(t, r.Register("a/b", func() error { return nil }), "invalid name")
+	assert.Error(t, r.Register("invalid", 10), "not a function")
+	assert.Equal(t, []string{"add"}, r.Names())
+
+	req := httptest.NewRequest("POST", "/rpc/add", bytes.NewBufferString("[1, 2]"))
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3\n", rr.Body.String())
+
+	req = httptest.NewRequest("POST", "/rpc/sub", bytes.NewBufferString("[1, 2]"))
+	rr = httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"unknown function 'sub'\",\"type\":\"not_found\"}}\n", rr.Body.String())
+}
+
+func TestRegistrySuggestions(t *testing.T) {
+	r := NewRegistry()
+	for _, name := range []string{"getUser", "getUsers", "deleteUser", "ping"} {
+		r.MustRegister(name, func() error { return nil })
+	}
+
+	assert.Equal(t, []string{"getUser", "getUsers"}, r.suggest("getUsr"))
+	assert.Equal(t, []string{"getUser", "getUsers"}, r.suggest("getuser"))
+	assert.Empty(t, r.suggest("somethingElse"))
+
+	req := httptest.NewRequest("POST", "/rpc/getUsr", nil)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusNotFound, rr.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"unknown function 'getUsr', did you mean 'getUser'?\",\"type\":\"not_found\",\"suggestions\":[\"getUser\",\"getUsers\"]}}\n", rr.Body.String())
+}
+
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("abc", "abc"))
+	assert.Equal(t, 3, levenshtein("", "abc"))
+	assert.Equal(t, 1, levenshtein("getUser", "getUsers"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
+
+func TestRegistryBasePath(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.EnableClientJS()
+	r.EnableIntrospection()
+	r.EnableOpenAPI(OpenAPIInfo{Title: "test", Version: "1"})
+	r.SetBasePath("/api/")
+
+	assert.Equal(t, "/api/rpc/", r.PublicPrefix())
+	assert.Equal(t, "/api/rpc/add", r.Methods()[0].Path)
+
+	get := func(path string, forwarded string) string {
+		req := httptest.NewRequest("GET", path, nil)
+		if forwarded != "" {
+			req.Header.Set("X-Forwarded-Prefix", forwarded)
+		}
+
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		return rr.Body.String()
+	}
+
+	assert.Contains(t, get("/rpc/client.js", ""), `var prefix = "/api/rpc/";`)
+	assert.Contains(t, get("/rpc/openapi.json", ""), `"/api/rpc/add"`)
+	assert.Contains(t, get("/rpc/_methods", "/edge"), `"path":"/api/rpc/add"`, "the header isn't trusted by default")
+
+	r.TrustForwardedPrefix()
+
+	assert.Contains(t, get("/rpc/client.js", "/edge/"), `var prefix = "/edge/rpc/";`)
+	assert.Contains(t, get("/rpc/openapi.json", "/edge"), `"/edge/rpc/add"`)
+	assert.Contains(t, get("/rpc/_methods", "/edge"), `"path":"/edge/rpc/add"`)
+	assert.Contains(t, get("/rpc/client.js", "//evil.example"), `var prefix = "/api/rpc/";`)
+	assert.Contains(t, get("/rpc/client.js", "/a\"b"), `var prefix = "/api/rpc/";`)
+}
+
+func TestRegistryReplaceUnregister(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("add", func(a, b int) (int, error) { return a * b, nil }, WithVersion(2))
+
+	call := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", path, strings.NewReader("[2, 3]")))
+		return rec
+	}
+
+	assert.NoError(t, r.Replace("add", func(a, b int) (int, error) { return a - b, nil }))
+	assert.Equal(t, "-1\n", call("/rpc/add").Body.String())
+	assert.Equal(t, "6\n", call("/rpc/v2/add").Body.String())
+
+	assert.NoError(t, r.Replace("sub", func(a, b int) (int, error) { return a - b, nil }))
+	assert.Equal(t, "-1\n", call("/rpc/sub").Body.String())
+	assert.Error(t, r.Replace("", func() error { return nil }))
+
+	assert.True(t, r.Unregister("add"))
+	assert.False(t, r.Unregister("add"))
+	assert.Equal(t, http.StatusNotFound, call("/rpc/add").Code)
+	assert.Equal(t, http.StatusNotFound, call("/rpc/v2/add").Code)
+	assert.Equal(t, []string{"sub"}, r.Names())
+
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	assert.Equal(t, "5\n", call("/rpc/add").Body.String())
+}
+
+func TestRegistryConcurrentChanges(t *testing.T) {
+	r := NewRegistry()
+	r.EnableIntrospection()
+	r.MustRegister("get", func() (int, error) { return 1, nil })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rec := httptest.NewRecorder()
+				r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/get", nil))
+				assert.Contains(t, []int{http.StatusOK, http.StatusNotFound}, rec.Code)
+
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/rpc/_methods", nil))
+				_ = GenerateTypeScript(io.Discard, r)
+			}
+		}()
+	}
+
+	for j := 0; j < 50; j++ {
+		n := j
+		r.Unregister("get")
+		_ = r.Replace("get", func() (int, error) { return n, nil })
+	}
+	wg.Wait()
+}
@@ -0,0 +1,54 @@
+package nra
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"regexp"
+)
+
+// RequestIDHeader is the header that carries the id of a request.
+const RequestIDHeader = "X-Request-ID"
+
+// EnableRequestIDs gives every request a id, so failures reported by
+// clients can be found in the logs. A id sent in the X-Request-ID header,
+// e.g. by a proxy, is kept, otherwise a random one is generated. The id
+// is sent back in the X-Request-ID header, included in error responses,
+// written to the access log and available to functions with RequestIDOf.
+func (r *Registry) EnableRequestIDs() {
+	r.requestIDs = true
+}
+
+// requestIDKey is the context key of the request id.
+type requestIDKey struct{}
+
+// RequestIDOf returns the id of the request the call ctx
+// belongs to, or a empty string if it doesn't have one.
+func RequestIDOf(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// validRequestID matches the request ids that are accepted from
+// clients, so they can't inject anything into logs or headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// withRequestID attaches the id of the request to its context
+// and sets the response header.
+func withRequestID(writer http.ResponseWriter, request *http.Request) *http.Request {
+	id := request.Header.Get(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+
+	writer.Header().Set(RequestIDHeader, id)
+	return request.WithContext(context.WithValue(request.Context(), requestIDKey{}, id))
+}
+
+// newRequestID creates a random request id.
+func newRequestID() string {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,82 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDs(t *testing.T) {
+	shared := &Error{Message: "shared", Type: ErrorTypeApplication}
+
+	var out bytes.Buffer
+	r := NewRegistry()
+	r.EnableRequestIDs()
+	r.SetAccessLogger(NewAccessLogger(&out, CommonLogFormat))
+	r.MustRegister("id", func(ctx context.Context) (string, error) { return RequestIDOf(ctx), nil })
+	r.MustRegister("fail", func() error { return shared })
+
+	for _, test := range []struct {
+		Name     string
+		Header   string
+		Accepted bool
+	}{
+		{Name: "generated"},
+		{Name: "accepted", Header: "abc-123", Accepted: true},
+		{Name: "invalid", Header: "abc\"123"},
+		{Name: "too long", Header: strings.Repeat("a", 129)},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			out.Reset()
+
+			req := httptest.NewRequest("POST", "/rpc/id", nil)
+			req.Header.Set(RequestIDHeader, test.Header)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			id := rec.Header().Get(RequestIDHeader)
+			assert.True(t, validRequestID.MatchString(id))
+			if test.Accepted {
+				assert.Equal(t, test.Header, id)
+			} else {
+				assert.NotEqual(t, test.Header, id)
+			}
+
+			var result string
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+			assert.Equal(t, id, result)
+			assert.True(t, strings.HasSuffix(out.String(), `"id" "`+id+"\"\n"))
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		req := httptest.NewRequest("POST", "/rpc/fail", nil)
+		req.Header.Set(RequestIDHeader, "abc-123")
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		var e struct {
+			Error Error `json:"error"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &e))
+		assert.Equal(t, "abc-123", e.Error.RequestID)
+		assert.Empty(t, shared.RequestID)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		r := NewRegistry()
+		r.MustRegister("fail", func() error { return errors.New("failed") })
+
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/fail", nil))
+
+		assert.Empty(t, rec.Header().Get(RequestIDHeader))
+		assert.NotContains(t, rec.Body.String(), "request_id")
+	})
+}
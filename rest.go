@@ -0,0 +1,254 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// REST maps the routes of a existing REST API onto the functions of a
+// registry, so the old URLs keep working while clients are migrated to
+// nra one by one:
+//
+//	rest := nra.NewREST(r)
+//	rest.MustHandle("GET /users/{id}", "users.get")
+//	rest.MustHandle("PUT /users/{id}", "users.update", "{id}", "body")
+//	rest.MustHandle("GET /users", "users.list", "?page", "?limit")
+//	http.Handle("/api/", http.StripPrefix("/api", rest))
+//
+// The arguments of the call are taken from the request as listed when
+// the route is added. Calls go through the registry, so authentication,
+// limits and the access log apply the same as for direct calls.
+type REST struct {
+	registry *Registry
+	routes   []restRoute
+}
+
+// restRoute is a route mapped onto a function.
+type restRoute struct {
+	method   string
+	segments []string
+	name     string
+	sources  []string
+}
+
+// NewREST creates a empty REST adapter for the functions of r.
+func NewREST(r *Registry) *REST {
+	return &REST{registry: r}
+}
+
+// Handle maps the route, a method and a path template like
+// "PUT /users/{id}", onto the function registered under name. Each
+// source is one argument of the call in order:
+//
+//	"{id}"   the path parameter id
+//	"?page"  the query parameter page, null if it is missing
+//	"body"   the JSON body of the request, null if it is empty
+//
+// Parameters other than strings are parsed as JSON, the same as named
+// query parameters of GET calls. Without sources the path parameters
+// are passed in order, followed by the body for methods other than GET
+// if the function takes more arguments.
+func (rest *REST) Handle(route string, name string, sources ...string) error {
+	method, path, ok := strings.Cut(route, " ")
+	if !ok || !strings.HasPrefix(path, "/") {
+		return errors.New("route '" + route + "' isn't a method followed by a path")
+	}
+
+	b, ok := rest.registry.function(name)
+	if !ok {
+		return errors.New("function with the name '" + name + "' isn't registered")
+	}
+
+	rt := restRoute{method: strings.ToUpper(method), segments: strings.Split(strings.Trim(path, "/"), "/"), name: name, sources: sources}
+
+	params := map[string]bool{}
+	var ordered []string
+	for _, s := range rt.segments {
+		if isPathParam(s) {
+			params[s] = true
+			ordered = append(ordered, s)
+		}
+	}
+
+	if len(sources) == 0 {
+		rt.sources = ordered
+		if rt.method != "GET" && (b.variadic || len(b.params) > len(ordered)) {
+			rt.sources = append(rt.sources, "body")
+		}
+	}
+
+	if !b.variadic && len(rt.sources) > len(b.params) {
+		return fmt.Errorf("route '%s' passes %d arguments but '%s' takes %s", route, len(rt.sources), name, b.expectedArgs())
+	}
+
+	for _, s := range rt.sources {
+		switch {
+		case s == "body" || strings.HasPrefix(s, "?") && len(s) > 1:
+		case isPathParam(s):
+			if !params[s] {
+				return errors.New("route '" + route + "' has no path parameter " + s)
+			}
+		default:
+			return errors.New("unknown argument source '" + s + "'")
+		}
+	}
+
+	rest.routes = append(rest.routes, rt)
+	return nil
+}
+
+// MustHandle is the same as Handle but panics
+// if the route can't be added.
+func (rest *REST) MustHandle(route string, name string, sources ...string) {
+	if err := rest.Handle(route, name, sources...); err != nil {
+		panic("nra: handle failed with: " + err.Error())
+	}
+}
+
+// isPathParam checks if the segment of a path template is a parameter.
+func isPathParam(segment string) bool {
+	return len(segment) > 2 && strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")
+}
+
+// match returns the path parameters if the path matches the route.
+func (rt *restRoute) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	for i, s := range rt.segments {
+		switch {
+		case isPathParam(s):
+			params[s] = segments[i]
+		case s != segments[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// ServeHTTP calls the function the route of the request is mapped onto.
+func (rest *REST) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	segments := strings.Split(strings.Trim(request.URL.Path, "/"), "/")
+
+	var allowed []string
+	for i := range rest.routes {
+		rt := &rest.routes[i]
+		params, ok := rt.match(segments)
+		if !ok {
+			continue
+		}
+		if rt.method != request.Method {
+			allowed = append(allowed, rt.method)
+			continue
+		}
+
+		rest.call(writer, request, rt, params)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		writer.Header().Set("Allow", strings.Join(allowed, ", "))
+		writeError(writer, http.StatusMethodNotAllowed, &Error{Message: "only " + strings.Join(allowed, ", ") + " requests are permitted", Type: ErrorTypeRequest})
+		return
+	}
+	writeError(writer, http.StatusNotFound, &Error{Message: "no route for " + request.Method + " " + request.URL.Path, Type: ErrorTypeNotFound})
+}
+
+// call collects the arguments of the route and calls its function
+// through the registry as a JSON POST request.
+func (rest *REST) call(writer http.ResponseWriter, request *http.Request, rt *restRoute, params map[string]string) {
+	b, ok := rest.registry.function(rt.name)
+	if !ok {
+		writeError(writer, http.StatusNotFound, rest.registry.notFoundError(rt.name))
+		return
+	}
+
+	args := make([]interface{}, len(rt.sources))
+	for i, s := range rt.sources {
+		var raw string
+		switch {
+		case s == "body":
+			// the body is limited before it is read, the
+			// registry only limits the re-encoded call.
+			if limit := rest.registry.maxBodyBytes; limit > 0 {
+				request.Body = http.MaxBytesReader(writer, request.Body, limit)
+			}
+			data, err := io.ReadAll(request.Body)
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					writeError(writer, http.StatusRequestEntityTooLarge, &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit})
+					return
+				}
+				writeError(writer, http.StatusBadRequest, &Error{Message: err.Error(), Type: ErrorTypeRequest})
+				return
+			}
+			if len(bytes.TrimSpace(data)) > 0 {
+				if err := json.Unmarshal(data, &args[i]); err != nil {
+					writeError(writer, http.StatusBadRequest, &Error{Message: "can't decode the body: " + err.Error(), Type: ErrorTypeRequest})
+					return
+				}
+			}
+			continue
+		case isPathParam(s):
+			raw = params[s]
+		default:
+			values, ok := request.URL.Query()[s[1:]]
+			if !ok {
+				continue
+			}
+			raw = values[0]
+		}
+
+		value, err := queryValue([]string{raw}, b.paramType(i))
+		if err != nil {
+			writeError(writer, http.StatusBadRequest, &Error{Message: "invalid value of " + s + ": " + err.Error(), Type: ErrorTypeConversion, Argument: i + 1})
+			return
+		}
+		args[i] = value
+	}
+
+	// trailing missing arguments are omitted,
+	// so they can be optional pointers.
+	for len(args) > 0 && args[len(args)-1] == nil {
+		args = args[:len(args)-1]
+	}
+
+	body, err := json.Marshal(args)
+	if err != nil {
+		writeError(writer, http.StatusInternalServerError, &Error{Message: err.Error(), Type: ErrorTypeInternal})
+		return
+	}
+
+	call := request.Clone(request.Context())
+	call.URL.Path = rest.registry.prefix + rt.name
+	call.URL.RawPath = ""
+	call.Header.Del("Content-Encoding")
+
+	// GET routes of safe functions stay GET requests,
+	// so they are treated as reads by the registry.
+	if request.Method == "GET" && b.options.safe {
+		call.URL.RawQuery = url.Values{QueryArgumentsName: {string(body)}}.Encode()
+		call.Body = http.NoBody
+		call.ContentLength = 0
+	} else {
+		call.Method = "POST"
+		call.URL.RawQuery = ""
+		call.Body = io.NopCloser(bytes.NewReader(body))
+		call.ContentLength = int64(len(body))
+		call.Header.Set("Content-Type", "application/json")
+	}
+
+	rest.registry.ServeHTTP(writer, call)
+}
@@ -0,0 +1,118 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type restUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestREST(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("users.get", func(id int) (*restUser, error) { return &restUser{ID: id, Name: "alice"}, nil }, WithSafe())
+	r.MustRegister("users.update", func(id int, u restUser) (*restUser, error) { u.ID = id; return &u, nil })
+	r.MustRegister("users.list", func(page int, filter *string) (string, error) {
+		if filter == nil {
+			return strings.Repeat("p", page), nil
+		}
+		return strings.Repeat("p", page) + *filter, nil
+	})
+	r.EnableCSRF(CSRF{})
+
+	rest := NewREST(r)
+	rest.MustHandle("GET /users/{id}", "users.get")
+	rest.MustHandle("PUT /users/{id}", "users.update")
+	rest.MustHandle("GET /users", "users.list", "?page", "?filter")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		code   int
+		result string
+	}{
+		{name: "path", method: "GET", path: "/users/7", code: http.StatusOK, result: `{"id":7,"name":"alice"}`},
+		{name: "body", method: "PUT", path: "/users/7", body: `{"name":"bob"}`, code: http.StatusOK, result: `{"id":7,"name":"bob"}`},
+		{name: "query", method: "GET", path: "/users?page=2&filter=x", code: http.StatusOK, result: `"ppx"`},
+		{name: "optional query", method: "GET", path: "/users?page=3", code: http.StatusOK, result: `"ppp"`},
+		{name: "invalid path", method: "GET", path: "/users/abc", code: http.StatusBadRequest, result: `{"error":{"message":"invalid value of {id}: invalid character 'a' looking for beginning of value","type":"conversion","argument":1}}`},
+		{name: "method", method: "DELETE", path: "/users/7", code: http.StatusMethodNotAllowed},
+		{name: "not found", method: "GET", path: "/posts/7", code: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+			req.Header.Set("X-CSRF-Token", "1")
+			rec := httptest.NewRecorder()
+			rest.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.code, rec.Code)
+			if test.result != "" {
+				assert.Equal(t, test.result+"\n", rec.Body.String())
+			}
+		})
+	}
+
+	// safe GET routes aren't CSRF checked, the same as direct calls.
+	rec := httptest.NewRecorder()
+	rest.ServeHTTP(rec, httptest.NewRequest("GET", "/users/7", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	rest.ServeHTTP(rec, httptest.NewRequest("DELETE", "/users/7", nil))
+	assert.Equal(t, "GET, PUT", rec.Header().Get("Allow"))
+}
+
+func TestRESTHandleErrors(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	rest := NewREST(r)
+
+	tests := []struct {
+		route   string
+		name    string
+		sources []string
+		err     string
+	}{
+		{route: "/add", name: "add", err: "isn't a method followed by a path"},
+		{route: "POST /add", name: "sub", err: "'sub' isn't registered"},
+		{route: "POST /add/{a}", name: "add", sources: []string{"{b}"}, err: "has no path parameter {b}"},
+		{route: "POST /add", name: "add", sources: []string{"?a", "?b", "?c"}, err: "passes 3 arguments but 'add' takes 2"},
+		{route: "POST /add", name: "add", sources: []string{"header"}, err: "unknown argument source 'header'"},
+	}
+
+	for _, test := range tests {
+		err := rest.Handle(test.route, test.name, test.sources...)
+		if assert.Error(t, err, test.route) {
+			assert.Contains(t, err.Error(), test.err)
+		}
+	}
+}
+
+func TestRESTMaxBodyBytes(t *testing.T) {
+	r := NewRegistry()
+	r.SetMaxBodyBytes(16)
+	r.MustRegister("echo", func(s string) (string, error) { return s, nil })
+
+	rest := NewREST(r)
+	rest.MustHandle("POST /echo", "echo", "body")
+
+	rec := httptest.NewRecorder()
+	rest.ServeHTTP(rec, httptest.NewRequest("POST", "/echo", strings.NewReader(`"hello"`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "\"hello\"\n", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	rest.ServeHTTP(rec, httptest.NewRequest("POST", "/echo", strings.NewReader(`"`+strings.Repeat("a", 64)+`"`)))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	assert.Equal(t, "{\"error\":{\"message\":\"request body exceeds the limit of 16 bytes\",\"type\":\"limit\"}}\n", rec.Body.String())
+}
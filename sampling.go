@@ -0,0 +1,103 @@
+package nra
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// SampledHeader carries the sampling decision of a call ("1" or "0")
+// to other services, see Sampled. A W3C traceparent header takes
+// precedence over it.
+const SampledHeader = "X-Sampled"
+
+// SetSampleRate sets the fraction of calls, between 0 and 1, that are
+// sampled, unless the function has its own rate set by WithSampleRate.
+// Defaults to 1, so all calls are sampled.
+func (r *Registry) SetSampleRate(rate float64) {
+	r.sampleRate = &rate
+}
+
+// WithSampleRate sets the fraction of calls of the function, between
+// 0 and 1, that are sampled, e.g. 1 for rare admin calls and 0.01 for
+// hot reads. Unsampled calls aren't traced and only logged if they
+// fail or are slow. Metrics and the audit trail still see all calls.
+func WithSampleRate(rate float64) Option {
+	return func(o *options) {
+		o.sampleRate = &rate
+	}
+}
+
+// Sampled reports if the call ctx belongs to is sampled. To keep the
+// decision consistent across services pass it on in the SampledHeader,
+// or the traceparent header if tracing is used.
+func Sampled(ctx context.Context) bool {
+	record, ok := ctx.Value(callRecordKey{}).(*callRecord)
+	return !ok || record.sampled
+}
+
+// sampleRateOf returns the sample rate of the function name.
+func (r *Registry) sampleRateOf(name string) float64 {
+	if b, ok := r.function(name); ok && b.options.sampleRate != nil {
+		return *b.options.sampleRate
+	}
+	if r.sampleRate != nil {
+		return *r.sampleRate
+	}
+	return 1
+}
+
+// sample decides if the call is sampled. A decision made by the caller
+// is kept, otherwise calls with the same request id get the same
+// decision, so every service with the same rate agrees.
+func sample(request *http.Request, rate float64) bool {
+	if sampled, ok := traceparentSampled(request.Header.Get("traceparent")); ok {
+		return sampled
+	}
+
+	switch request.Header.Get(SampledHeader) {
+	case "1":
+		return true
+	case "0":
+		return false
+	}
+
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	}
+
+	if id := RequestIDOf(request.Context()); id != "" {
+		sum := sha256.Sum256([]byte(id))
+		return float64(binary.BigEndian.Uint64(sum[:8])) < rate*math.MaxUint64
+	}
+	return rand.Float64() < rate
+}
+
+// traceparentSampled returns the sampled flag of
+// a W3C traceparent header, if it is valid.
+func traceparentSampled(header string) (bool, bool) {
+	parts := strings.Split(header, "-")
+	if len(parts) != 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return false, false
+	}
+
+	var flags byte
+	for _, c := range []byte(parts[3]) {
+		switch {
+		case c >= '0' && c <= '9':
+			flags = flags<<4 | (c - '0')
+		case c >= 'a' && c <= 'f':
+			flags = flags<<4 | (c - 'a' + 10)
+		default:
+			return false, false
+		}
+	}
+	return flags&1 == 1, true
+}
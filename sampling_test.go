@@ -0,0 +1,103 @@
+package nra
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSample(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  http.Header
+		rate    float64
+		sampled bool
+	}{
+		{"default", http.Header{}, 1, true},
+		{"never", http.Header{}, 0, false},
+		{"traceparent sampled", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}, 0, true},
+		{"traceparent not sampled", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}}, 1, false},
+		{"traceparent over header", http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"}, SampledHeader: {"1"}}, 1, false},
+		{"invalid traceparent", http.Header{"Traceparent": {"00-xyz-01"}}, 0, false},
+		{"header sampled", http.Header{SampledHeader: {"1"}}, 0, true},
+		{"header not sampled", http.Header{SampledHeader: {"0"}}, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/rpc/add", nil)
+			req.Header = test.header
+			assert.Equal(t, test.sampled, sample(req, test.rate))
+		})
+	}
+
+	// the same request id always gets the same decision.
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		req := httptest.NewRequest("POST", "/rpc/add", nil)
+		req.Header.Set(RequestIDHeader, "id-"+strconv.Itoa(i))
+		req = withRequestID(httptest.NewRecorder(), req)
+
+		first := sample(req, 0.25)
+		assert.Equal(t, first, sample(req, 0.25))
+		if first {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 250, sampled, 60)
+}
+
+func TestSampleRate(t *testing.T) {
+	var out bytes.Buffer
+	var infos []CallInfo
+
+	r := NewRegistry()
+	r.SetSampleRate(0)
+	r.SetLogger(slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	r.AddObserver(ObserverFunc(func(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+		return request, func(info CallInfo) { infos = append(infos, info) }
+	}))
+	r.MustRegister("hot", func(req *http.Request) (bool, error) { return Sampled(req.Context()), nil })
+	r.MustRegister("fail", func() error { return errors.New("failed") })
+	r.MustRegister("admin", func(req *http.Request) (bool, error) { return Sampled(req.Context()), nil }, WithSampleRate(1))
+
+	call := func(name string) string {
+		out.Reset()
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/"+name, nil))
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "false\n", call("hot"))
+	assert.Empty(t, out.String())
+
+	call("fail")
+	assert.NotContains(t, out.String(), "call started")
+	assert.Contains(t, out.String(), "call failed")
+
+	assert.Equal(t, "true\n", call("admin"))
+	assert.Contains(t, out.String(), "call started")
+	assert.Contains(t, out.String(), "call finished")
+
+	// observers see all calls.
+	if assert.Len(t, infos, 3) {
+		assert.False(t, infos[0].Sampled)
+		assert.False(t, infos[1].Sampled)
+		assert.True(t, infos[2].Sampled)
+	}
+
+	// the decision of the caller is kept.
+	out.Reset()
+	req := httptest.NewRequest("POST", "/rpc/hot", nil)
+	req.Header.Set(SampledHeader, "1")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, "true\n", rec.Body.String())
+	assert.Contains(t, out.String(), "call finished")
+}
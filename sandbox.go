@@ -0,0 +1,36 @@
+package nra
+
+import (
+	"time"
+)
+
+// SandboxLimits restricts the resources a function executed in a
+// subprocess can use. A zero value means the resource is unlimited.
+type SandboxLimits struct {
+	// Timeout is the maximum wall time of a call. The subprocess
+	// is killed if it takes longer and the call fails with status
+	// 504 and ErrorTypeTimeout, the same as with WithTimeout.
+	Timeout time.Duration
+
+	// CPUTime is the maximum CPU time the subprocess can use. It
+	// is rounded up to full seconds. Only supported on unix.
+	CPUTime time.Duration
+
+	// Memory is the maximum size of the data segment in bytes the
+	// subprocess can allocate. It includes the memory used by the
+	// Go runtime itself, so it shouldn't be set too low. Only
+	// supported on unix.
+	Memory uint64
+}
+
+// WithSandbox executes every call of the function in a subprocess like
+// WithSubprocess, but also restricts the resources the subprocess can
+// use. This way plugin provided or user scripted functions can't crash
+// or starve the server. If a limit is exceeded the subprocess is killed
+// and the call fails with ErrorTypeInternal.
+func WithSandbox(limits SandboxLimits) Option {
+	return func(o *options) {
+		o.subprocess = true
+		o.sandbox = &limits
+	}
+}
@@ -0,0 +1,16 @@
+//go:build windows || plan9 || js || wasip1
+// +build windows plan9 js wasip1
+
+package nra
+
+import (
+	"errors"
+)
+
+// applySandboxLimits isn't supported on this platform.
+func applySandboxLimits(limits *SandboxLimits) error {
+	if limits.CPUTime > 0 || limits.Memory > 0 {
+		return errors.New("cpu and memory limits are not supported on this platform")
+	}
+	return nil
+}
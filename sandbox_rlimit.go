@@ -0,0 +1,11 @@
+//go:build !windows && !plan9 && !js && !wasip1 && !freebsd && !dragonfly
+// +build !windows,!plan9,!js,!wasip1,!freebsd,!dragonfly
+
+package nra
+
+import "syscall"
+
+// rlimit returns a resource limit with the same soft and hard limit.
+func rlimit(limit uint64) *syscall.Rlimit {
+	return &syscall.Rlimit{Cur: limit, Max: limit}
+}
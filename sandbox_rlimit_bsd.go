@@ -0,0 +1,18 @@
+//go:build freebsd || dragonfly
+// +build freebsd dragonfly
+
+package nra
+
+import (
+	"math"
+	"syscall"
+)
+
+// rlimit returns a resource limit with the same soft and hard limit.
+// The limits are signed on these systems, larger ones are capped.
+func rlimit(limit uint64) *syscall.Rlimit {
+	if limit > math.MaxInt64 {
+		limit = math.MaxInt64
+	}
+	return &syscall.Rlimit{Cur: int64(limit), Max: int64(limit)}
+}
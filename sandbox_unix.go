@@ -0,0 +1,28 @@
+//go:build !windows && !plan9 && !js && !wasip1
+// +build !windows,!plan9,!js,!wasip1
+
+package nra
+
+import (
+	"syscall"
+)
+
+// applySandboxLimits sets the limits as resource limits of the current
+// process. The soft and hard limit are the same, so the process is killed
+// as soon as a limit is reached.
+func applySandboxLimits(limits *SandboxLimits) error {
+	if limits.CPUTime > 0 {
+		seconds := uint64((limits.CPUTime + 999999999) / 1000000000)
+		if err := syscall.Setrlimit(syscall.RLIMIT_CPU, rlimit(seconds)); err != nil {
+			return err
+		}
+	}
+
+	if limits.Memory > 0 {
+		if err := syscall.Setrlimit(syscall.RLIMIT_DATA, rlimit(limits.Memory)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
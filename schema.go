@@ -0,0 +1,227 @@
+package nra
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schema is a JSON Schema (draft 2020-12, the dialect used by
+// OpenAPI 3.1) describing the JSON encoding of a Go type.
+type schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 schemaType         `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *schema            `json:"items,omitempty"`
+	PrefixItems          []*schema          `json:"prefixItems,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	Properties           map[string]*schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *schema            `json:"additionalProperties,omitempty"`
+	AnyOf                []*schema          `json:"anyOf,omitempty"`
+	Default              interface{}        `json:"default,omitempty"`
+}
+
+// schemaType is the type keyword of a schema. It is encoded as
+// a single string or, if the value can also be null, as array.
+type schemaType []string
+
+// MarshalJSON implements json.Marshaler.
+func (t schemaType) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// nullable returns a schema that also allows null.
+func (s *schema) nullable() *schema {
+	if len(s.Type) > 0 {
+		s.Type = append(s.Type, "null")
+		return s
+	}
+	if s.Ref == "" && s.AnyOf == nil {
+		// empty schema already allows everything.
+		return s
+	}
+	return &schema{AnyOf: []*schema{s, {Type: schemaType{"null"}}}}
+}
+
+// schemaGenerator creates schemas for Go types. Named structs
+// are collected as definitions and referenced via $ref.
+type schemaGenerator struct {
+	refPrefix string
+	defs      map[string]*schema
+	names     map[reflect.Type]string
+
+	// optionalDefaults leaves fields with a default out of the
+	// required fields, as they can be missing in arguments.
+	optionalDefaults bool
+}
+
+// newSchemaGenerator creates a generator whose references
+// point to refPrefix + name, e.g. "#/components/schemas/".
+func newSchemaGenerator(refPrefix string) *schemaGenerator {
+	return &schemaGenerator{
+		refPrefix: refPrefix,
+		defs:      map[string]*schema{},
+		names:     map[reflect.Type]string{},
+	}
+}
+
+// schemaOf returns the schema of the JSON encoding of t.
+func (g *schemaGenerator) schemaOf(t reflect.Type) *schema {
+	if t == timeType {
+		return &schema{Type: schemaType{"string"}, Format: "date-time"}
+	}
+
+	// uploads are streamed in their own format, see Upload.
+	if isUpload(t) {
+		return &schema{Type: schemaType{"string"}, Format: "binary"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &schema{Type: schemaType{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &schema{Type: schemaType{"integer"}, Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &schema{Type: schemaType{"integer"}, Format: "int64"}
+	case reflect.Float32:
+		return &schema{Type: schemaType{"number"}, Format: "float"}
+	case reflect.Float64:
+		return &schema{Type: schemaType{"number"}, Format: "double"}
+	case reflect.String:
+		return &schema{Type: schemaType{"string"}}
+	case reflect.Ptr:
+		return g.schemaOf(t.Elem()).nullable()
+	case reflect.Slice:
+		// []byte is encoded as base64 string.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return (&schema{Type: schemaType{"string"}, Format: "byte"}).nullable()
+		}
+		return (&schema{Type: schemaType{"array"}, Items: g.schemaOf(t.Elem())}).nullable()
+	case reflect.Array:
+		n := t.Len()
+		return &schema{Type: schemaType{"array"}, Items: g.schemaOf(t.Elem()), MinItems: &n, MaxItems: &n}
+	case reflect.Map:
+		return (&schema{Type: schemaType{"object"}, AdditionalProperties: g.schemaOf(t.Elem())}).nullable()
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.objectOf(t)
+		}
+		return &schema{Ref: g.refPrefix + g.define(t)}
+	}
+
+	// interface{} and everything else can't be described further.
+	return &schema{}
+}
+
+// define adds the definition of the named struct t
+// (if not already done) and returns its name.
+func (g *schemaGenerator) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	base := schemaName(t.Name())
+	name := base
+	for i := 2; g.defs[name] != nil; i++ {
+		name = fmt.Sprintf("%s%d", base, i)
+	}
+
+	// register the name before generating the properties
+	// so recursive types reference themselves.
+	g.names[t] = name
+	g.defs[name] = &schema{}
+	g.defs[name] = g.objectOf(t)
+
+	return name
+}
+
+// objectOf returns the inline object schema of the struct t.
+// Fields without omitempty are always encoded and thus required.
+func (g *schemaGenerator) objectOf(t reflect.Type) *schema {
+	s := &schema{Type: schemaType{"object"}, Properties: map[string]*schema{}}
+	for _, f := range jsonFields(t) {
+		s.Properties[f.name] = g.schemaOf(f.typ)
+		value, hasDefault, err := defaultOf(f.field)
+		if hasDefault && err == nil {
+			s.Properties[f.name].Default = value
+		}
+		if !f.omitEmpty && !(hasDefault && g.optionalDefaults) {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	return s
+}
+
+var invalidSchemaName = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// schemaName turns the Go type name into a valid
+// component name, e.g. for generic instantiations.
+func schemaName(name string) string {
+	return invalidSchemaName.ReplaceAllString(name, "_")
+}
+
+// jsonField is a struct field how it appears in the JSON encoding.
+type jsonField struct {
+	name      string
+	typ       reflect.Type
+	omitEmpty bool
+	field     reflect.StructField
+}
+
+// jsonFields returns the fields of the struct t the same way
+// encoding/json would encode them. Embedded structs without a
+// name in their tag are flattened into the parent.
+func jsonFields(t reflect.Type) []jsonField {
+	var fields []jsonField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, opts := tag, ""
+		if idx := strings.Index(tag, ","); idx >= 0 {
+			name, opts = tag[:idx], tag[idx+1:]
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, jsonFields(ft)...)
+				continue
+			}
+		}
+
+		// skip unexported fields.
+		if f.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+
+		fields = append(fields, jsonField{
+			name:      name,
+			typ:       f.Type,
+			omitEmpty: strings.Contains(","+opts+",", ",omitempty,"),
+			field:     f,
+		})
+	}
+	return fields
+}
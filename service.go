@@ -0,0 +1,67 @@
+package nra
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RegisterService registers every exported method of svc under
+// "<name>.<Method>", e.g. /rpc/users.Create. The methods are bound
+// to svc, so they share its state, and svc has to be a pointer if
+// the methods have pointer receivers. All methods have to be valid
+// functions, see Bind, and get the same options:
+//
+//	type Users struct{ db *sql.DB }
+//
+//	func (u *Users) Create(ctx context.Context, name string) (*User, error) { ... }
+//	func (u *Users) Delete(ctx context.Context, id int) error { ... }
+//
+//	r.MustRegisterService("users", &Users{db: db})
+//
+// Either all methods are registered or none.
+func (r *Registry) RegisterService(name string, svc interface{}, opts ...Option) error {
+	if len(name) == 0 || strings.Contains(name, "/") {
+		return errors.New("name can't be empty or contain a '/'")
+	}
+
+	v := reflect.ValueOf(svc)
+	if !v.IsValid() || v.NumMethod() == 0 {
+		return fmt.Errorf("service '%s' doesn't have exported methods", name)
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	// all methods are checked before the first is registered.
+	bindings := map[string]*binding{}
+	for i := 0; i < v.NumMethod(); i++ {
+		method := v.Type().Method(i)
+		full := name + "." + method.Name
+		b, err := newBinding(v.Method(i).Interface(), opts...)
+		if err != nil {
+			return fmt.Errorf("method %s of service '%s': %w", method.Name, name, err)
+		}
+		if err := r.registered(full, b.options.apiVersion()); err != nil {
+			return err
+		}
+		if b.options.subprocess {
+			return errors.New("WithSubprocess can't be used with a service")
+		}
+		bindings[full] = b
+	}
+
+	for full, b := range bindings {
+		r.addFunction(full, b)
+	}
+	return nil
+}
+
+// MustRegisterService is the same as RegisterService
+// but panics if the service can't be registered.
+func (r *Registry) MustRegisterService(name string, svc interface{}, opts ...Option) {
+	if err := r.RegisterService(name, svc, opts...); err != nil {
+		panic("nra: register service failed with: " + err.Error())
+	}
+}
@@ -0,0 +1,78 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type counterService struct {
+	count int
+}
+
+func (c *counterService) Add(ctx context.Context, n int) (int, error) {
+	c.count += n
+	return c.count, nil
+}
+
+func (c *counterService) Reset() error {
+	c.count = 0
+	return nil
+}
+
+// helper isn't exported, so it isn't registered.
+func (c *counterService) helper() {}
+
+type brokenService struct{}
+
+func (brokenService) Fine() error { return nil }
+func (brokenService) Close()      {}
+
+func TestRegisterService(t *testing.T) {
+	svc := &counterService{}
+	r := NewRegistry()
+	assert.NoError(t, r.RegisterService("counter", svc, WithIdempotent()))
+	assert.Equal(t, []string{"counter.Add", "counter.Reset"}, r.Names())
+	assert.True(t, r.functions["counter.Add"].options.idempotent)
+
+	call := func(name, body string) string {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body)))
+		assert.Equal(t, http.StatusOK, rec.Code)
+		return rec.Body.String()
+	}
+
+	assert.Equal(t, "2\n", call("counter.Add", "[2]"))
+	assert.Equal(t, "5\n", call("counter.Add", "[3]"))
+	assert.Equal(t, 5, svc.count)
+	call("counter.Reset", "")
+	assert.Equal(t, 0, svc.count)
+}
+
+func TestRegisterServiceErrors(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("counter.Add", func() error { return nil })
+
+	for _, test := range []struct {
+		Name    string
+		Service interface{}
+		Error   string
+	}{
+		{Name: "counter", Service: &counterService{}, Error: "function with the name 'counter.Add' is already registered"},
+		{Name: "broken", Service: brokenService{}, Error: "method Close of service 'broken': fn doesn't return 1 or 2 values"},
+		{Name: "value", Service: counterService{}, Error: "service 'value' doesn't have exported methods"},
+		{Name: "nil", Service: nil, Error: "service 'nil' doesn't have exported methods"},
+		{Name: "a/b", Service: &counterService{}, Error: "name can't be empty or contain a '/'"},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			assert.EqualError(t, r.RegisterService(test.Name, test.Service), test.Error)
+		})
+	}
+
+	// nothing of a broken service is registered.
+	assert.Equal(t, []string{"counter.Add"}, r.Names())
+}
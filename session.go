@@ -0,0 +1,493 @@
+package nra
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SessionStore keeps the data of sessions. Load returns nil data
+// without a error if the session doesn't exist or has expired.
+type SessionStore interface {
+	Load(ctx context.Context, id string) ([]byte, error)
+	Save(ctx context.Context, id string, data []byte, ttl time.Duration) error
+	Delete(ctx context.Context, id string) error
+}
+
+// Sessions configures cookie based sessions, see Registry.EnableSessions.
+type Sessions struct {
+	// Store keeps the data of the sessions. Defaults to
+	// a MemoryStore, which is lost on restart.
+	Store SessionStore
+
+	// Cookie is the name of the cookie holding the
+	// session id. Defaults to DefaultSessionCookie.
+	Cookie string
+
+	// TTL is how long a session is kept after it was
+	// last changed. Defaults to DefaultSessionTTL.
+	TTL time.Duration
+
+	// Path is the path of the cookie. Defaults to "/".
+	Path string
+
+	// Insecure allows the cookie to be sent without TLS,
+	// e.g. for local development.
+	Insecure bool
+
+	// Keys sign the session cookie, so ids that weren't handed
+	// out by the server are rejected without asking the store.
+	// The first key signs, all of them are accepted. See
+	// Registry.RotateSessionKeys.
+	Keys [][]byte
+}
+
+const (
+	// DefaultSessionCookie is the name of the session cookie
+	// if Sessions.Cookie isn't set.
+	DefaultSessionCookie = "nra_session"
+
+	// DefaultSessionTTL is how long sessions are
+	// kept if Sessions.TTL isn't set.
+	DefaultSessionTTL = 24 * time.Hour
+)
+
+// EnableSessions gives every call a cookie based session. Functions get
+// it by taking a *Session parameter after the context or request, or
+// with SessionOf:
+//
+//	func Login(ctx context.Context, session *nra.Session, name, password string) error {
+//		...
+//		session.Renew()
+//		return session.Set("user", user.ID)
+//	}
+//
+// The session is only loaded if it is used, and only saved if it was
+// changed. The cookie is HttpOnly, so it isn't readable by Javascript.
+func (r *Registry) EnableSessions(config Sessions) {
+	if config.Store == nil {
+		config.Store = NewMemoryStore()
+	}
+	if config.Cookie == "" {
+		config.Cookie = DefaultSessionCookie
+	}
+	if config.TTL <= 0 {
+		config.TTL = DefaultSessionTTL
+	}
+	if config.Path == "" {
+		config.Path = "/"
+	}
+	r.sessions = &config
+	r.sessionKeys.set(config.Keys)
+}
+
+// RotateSessionKeys replaces the keys that sign the session cookies,
+// see Sessions.Keys. New cookies are signed with the first key, while
+// cookies signed with any of the others are still accepted and signed
+// again with the first key on their next call. To rotate, pass the new
+// key followed by the current ones and drop the old keys once all
+// clients had the chance to call:
+//
+//	r.RotateSessionKeys(newKey, currentKey)
+//
+// It is safe to call while the registry serves requests. Sessions whose
+// cookie was signed by a key that isn't passed anymore are lost.
+func (r *Registry) RotateSessionKeys(keys ...[]byte) {
+	r.sessionKeys.set(keys)
+}
+
+// sessionKeys are the keys that sign the session cookies.
+type sessionKeys struct {
+	mtx  sync.RWMutex
+	keys [][]byte
+}
+
+// set replaces the keys.
+func (k *sessionKeys) set(keys [][]byte) {
+	k.mtx.Lock()
+	defer k.mtx.Unlock()
+	k.keys = append([][]byte(nil), keys...)
+}
+
+// sign returns the cookie value of the session id. Without
+// keys the cookie value is the id itself.
+func (k *sessionKeys) sign(id string) string {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	if len(k.keys) == 0 {
+		return id
+	}
+	return id + "." + base64.RawURLEncoding.EncodeToString(sessionMAC(k.keys[0], id))
+}
+
+// verify returns the session id of the cookie value and
+// reports if it was signed with the first key. It fails if
+// the signature doesn't match any of the keys.
+func (k *sessionKeys) verify(value string) (id string, current bool, ok bool) {
+	k.mtx.RLock()
+	defer k.mtx.RUnlock()
+
+	if len(k.keys) == 0 {
+		return value, true, true
+	}
+
+	id, signature, found := strings.Cut(value, ".")
+	if !found {
+		return "", false, false
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return "", false, false
+	}
+
+	for i, key := range k.keys {
+		if hmac.Equal(mac, sessionMAC(key, id)) {
+			return id, i == 0, true
+		}
+	}
+	return "", false, false
+}
+
+// sessionMAC computes the signature of the session id.
+func sessionMAC(key []byte, id string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return mac.Sum(nil)
+}
+
+// Session is the data a client keeps between calls. Values are
+// stored as JSON, so they are read back by decoding them into a
+// value of the type they were set with. It is safe to use from
+// multiple goroutines of a call.
+type Session struct {
+	mtx     sync.Mutex
+	id      string
+	values  map[string]json.RawMessage
+	changed bool
+	renewed string
+	deleted bool
+}
+
+// ID returns the id of the session. It is empty for
+// a new session that hasn't been saved yet.
+func (s *Session) ID() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.id
+}
+
+// Get decodes the value of key into v, which has to be a pointer.
+// It returns false if the session doesn't have the key or its value
+// doesn't fit v.
+func (s *Session) Get(key string, v interface{}) bool {
+	s.mtx.Lock()
+	data, ok := s.values[key]
+	s.mtx.Unlock()
+
+	return ok && json.Unmarshal(data, v) == nil
+}
+
+// Set sets the value of key. It fails if the
+// value can't be encoded as JSON.
+func (s *Session) Set(key string, value interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.values[key] = data
+	s.changed = true
+	return nil
+}
+
+// Delete removes the key from the session.
+func (s *Session) Delete(key string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if _, ok := s.values[key]; ok {
+		delete(s.values, key)
+		s.changed = true
+	}
+}
+
+// Renew gives the session a new id while keeping its values. Call
+// it when the privileges of the client change, e.g. after a login,
+// so a id an attacker got hold of before is worthless.
+func (s *Session) Renew() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.renewed == "" {
+		s.renewed = s.id
+	}
+	s.id = ""
+	s.changed = true
+}
+
+// Destroy removes all values and deletes the
+// session from the store, e.g. on a logout.
+func (s *Session) Destroy() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.values = map[string]json.RawMessage{}
+	s.deleted = true
+}
+
+// sessionKey is the context key of the sessionHolder.
+type sessionKey struct{}
+
+// sessionHolder loads the session of a request on first use.
+type sessionHolder struct {
+	config  *Sessions
+	keys    *sessionKeys
+	request *http.Request
+
+	once    sync.Once
+	session *Session
+	err     error
+}
+
+// withSession prepares the request to load its session on first use.
+func (r *Registry) withSession(request *http.Request) *http.Request {
+	holder := &sessionHolder{config: r.sessions, keys: &r.sessionKeys, request: request}
+	return request.WithContext(context.WithValue(request.Context(), sessionKey{}, holder))
+}
+
+// SessionOf returns the session of the call ctx belongs to. It
+// fails if the registry doesn't have sessions enabled, or the
+// session can't be loaded from the store.
+func SessionOf(ctx context.Context) (*Session, error) {
+	holder, ok := ctx.Value(sessionKey{}).(*sessionHolder)
+	if !ok {
+		return nil, errors.New("sessions aren't enabled")
+	}
+
+	holder.once.Do(func() {
+		holder.session, holder.err = holder.load(ctx)
+	})
+	return holder.session, holder.err
+}
+
+// load loads the session named by the cookie of the request, or
+// creates a new one if it doesn't have one or it has expired.
+func (h *sessionHolder) load(ctx context.Context) (*Session, error) {
+	s := &Session{values: map[string]json.RawMessage{}}
+
+	cookie, err := h.request.Cookie(h.config.Cookie)
+	if err != nil || cookie.Value == "" {
+		return s, nil
+	}
+
+	id, current, ok := h.keys.verify(cookie.Value)
+	if !ok {
+		return s, nil
+	}
+
+	data, err := h.config.Store.Load(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return s, nil
+	}
+
+	if err := json.Unmarshal(data, &s.values); err != nil {
+		return nil, err
+	}
+	s.id = id
+
+	// a cookie signed with a old key gets signed with the current one.
+	s.changed = !current
+	return s, nil
+}
+
+// commitSession saves the changes of the session of the request, if
+// it was used, and sets or removes the cookie. It has to be called
+// before anything is written.
+func commitSession(writer http.ResponseWriter, request *http.Request) error {
+	holder, ok := request.Context().Value(sessionKey{}).(*sessionHolder)
+	if !ok || holder.session == nil {
+		return nil
+	}
+
+	s := holder.session
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	// the store is still updated if the call was cancelled.
+	ctx := context.Background()
+	config := holder.config
+
+	cookie := &http.Cookie{
+		Name:     config.Cookie,
+		Path:     config.Path,
+		Secure:   !config.Insecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if s.renewed != "" || s.deleted {
+		old := s.renewed
+		if s.deleted && s.id != "" {
+			old = s.id
+		}
+		if old != "" {
+			if err := config.Store.Delete(ctx, old); err != nil {
+				return err
+			}
+		}
+	}
+
+	if s.deleted {
+		cookie.MaxAge = -1
+		http.SetCookie(writer, cookie)
+		return nil
+	}
+
+	if !s.changed {
+		return nil
+	}
+
+	if s.id == "" {
+		id, err := newSessionID()
+		if err != nil {
+			return err
+		}
+		s.id = id
+	}
+
+	data, err := json.Marshal(s.values)
+	if err != nil {
+		return err
+	}
+	if err := config.Store.Save(ctx, s.id, data, config.TTL); err != nil {
+		return err
+	}
+
+	cookie.Value = holder.keys.sign(s.id)
+	cookie.MaxAge = int(config.TTL.Seconds())
+	http.SetCookie(writer, cookie)
+	return nil
+}
+
+// newSessionID creates a random session id.
+func newSessionID() (string, error) {
+	var id [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(id[:]), nil
+}
+
+var sessionType = reflect.TypeOf((*Session)(nil))
+
+// MemoryStore is a SessionStore that keeps the
+// sessions in memory, e.g. for desktop apps.
+type MemoryStore struct {
+	mtx      sync.Mutex
+	sessions map[string]memorySession
+	saves    int
+	now      func() time.Time
+}
+
+// memorySession is a session in a MemoryStore.
+type memorySession struct {
+	data    []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: map[string]memorySession{}, now: time.Now}
+}
+
+// Load implements SessionStore.
+func (m *MemoryStore) Load(ctx context.Context, id string) ([]byte, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	s, ok := m.sessions[id]
+	if !ok || m.now().After(s.expires) {
+		return nil, nil
+	}
+	return s.data, nil
+}
+
+// Save implements SessionStore.
+func (m *MemoryStore) Save(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	now := m.now()
+	m.sessions[id] = memorySession{data: data, expires: now.Add(ttl)}
+
+	// expired sessions are removed every now and then.
+	if m.saves++; m.saves%1024 == 0 {
+		for id, s := range m.sessions {
+			if now.After(s.expires) {
+				delete(m.sessions, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete implements SessionStore.
+func (m *MemoryStore) Delete(ctx context.Context, id string) error {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	delete(m.sessions, id)
+	return nil
+}
+
+// RedisClient are the commands of a Redis client a RedisStore needs.
+// Wrap the client of your Redis library in it, Get has to return nil
+// data without a error if the key doesn't exist.
+type RedisClient interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Del(ctx context.Context, key string) error
+}
+
+// RedisStore is a SessionStore that keeps the sessions in
+// Redis, so they are shared by all instances of a server.
+type RedisStore struct {
+	client RedisClient
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore that stores the
+// sessions under keys starting with prefix.
+func NewRedisStore(client RedisClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+// Load implements SessionStore.
+func (s *RedisStore) Load(ctx context.Context, id string) ([]byte, error) {
+	return s.client.Get(ctx, s.prefix+id)
+}
+
+// Save implements SessionStore.
+func (s *RedisStore) Save(ctx context.Context, id string, data []byte, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+id, data, ttl)
+}
+
+// Delete implements SessionStore.
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+	return s.client.Del(ctx, s.prefix+id)
+}
@@ -0,0 +1,210 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessions(t *testing.T) {
+	store := NewMemoryStore()
+
+	r := NewRegistry()
+	r.EnableSessions(Sessions{Store: store, TTL: time.Hour})
+	r.MustRegister("login", func(ctx context.Context, s *Session, name string) error {
+		s.Renew()
+		return s.Set("user", name)
+	})
+	r.MustRegister("whoami", func(s *Session) (string, error) {
+		var name string
+		s.Get("user", &name)
+		return name, nil
+	})
+	r.MustRegister("count", func(ctx context.Context) (int, error) {
+		s, err := SessionOf(ctx)
+		if err != nil {
+			return 0, err
+		}
+		var n int
+		s.Get("count", &n)
+		return n + 1, s.Set("count", n+1)
+	})
+	r.MustRegister("logout", func(s *Session) error {
+		s.Destroy()
+		return nil
+	})
+
+	var cookie *http.Cookie
+	call := func(name, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+			cookie = cookies[0]
+		}
+		return rec
+	}
+
+	// reading a new session doesn't create it.
+	rec := call("whoami", "")
+	assert.Equal(t, "\"\"\n", rec.Body.String())
+	assert.Nil(t, cookie)
+
+	rec = call("login", `["alice"]`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if !assert.NotNil(t, cookie) {
+		return
+	}
+	assert.Equal(t, DefaultSessionCookie, cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.True(t, cookie.Secure)
+	assert.Equal(t, 3600, cookie.MaxAge)
+
+	rec = call("whoami", "")
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+
+	rec = call("count", "")
+	assert.Equal(t, "1\n", rec.Body.String())
+	rec = call("count", "")
+	assert.Equal(t, "2\n", rec.Body.String())
+
+	// a renewed session gets a new id and the old one is gone.
+	old := cookie.Value
+	call("login", `["bob"]`)
+	assert.NotEqual(t, old, cookie.Value)
+	data, _ := store.Load(context.Background(), old)
+	assert.Nil(t, data)
+	rec = call("count", "")
+	assert.Equal(t, "3\n", rec.Body.String())
+
+	id := cookie.Value
+	call("logout", "")
+	assert.Equal(t, -1, cookie.MaxAge)
+	data, _ = store.Load(context.Background(), id)
+	assert.Nil(t, data)
+}
+
+func TestSessionKeys(t *testing.T) {
+	store := NewMemoryStore()
+	oldKey, newKey := []byte("old"), []byte("new")
+
+	r := NewRegistry()
+	r.EnableSessions(Sessions{Store: store, Keys: [][]byte{oldKey}})
+	r.MustRegister("login", func(s *Session, name string) error { return s.Set("user", name) })
+	r.MustRegister("whoami", func(s *Session) (string, error) {
+		var name string
+		s.Get("user", &name)
+		return name, nil
+	})
+
+	call := func(name, body string, cookie *http.Cookie) (*httptest.ResponseRecorder, *http.Cookie) {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		if cookies := rec.Result().Cookies(); len(cookies) > 0 {
+			return rec, cookies[0]
+		}
+		return rec, nil
+	}
+
+	_, signed := call("login", `["alice"]`, nil)
+	if !assert.NotNil(t, signed) {
+		return
+	}
+	id, _, _ := strings.Cut(signed.Value, ".")
+	data, _ := store.Load(context.Background(), id)
+	assert.NotNil(t, data)
+
+	// a forged or unsigned id isn't loaded.
+	for _, value := range []string{id, id + ".AAAA", "other." + strings.SplitN(signed.Value, ".", 2)[1]} {
+		rec, _ := call("whoami", "", &http.Cookie{Name: DefaultSessionCookie, Value: value})
+		assert.Equal(t, "\"\"\n", rec.Body.String(), value)
+	}
+
+	// after the rotation the old cookie still works and gets signed again.
+	r.RotateSessionKeys(newKey, oldKey)
+	rec, resigned := call("whoami", "", signed)
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+	if !assert.NotNil(t, resigned) {
+		return
+	}
+	assert.NotEqual(t, signed.Value, resigned.Value)
+	assert.True(t, strings.HasPrefix(resigned.Value, id+"."))
+
+	rec, again := call("whoami", "", resigned)
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+	assert.Nil(t, again)
+
+	// once the old key is dropped its cookies are worthless.
+	r.RotateSessionKeys(newKey)
+	rec, _ = call("whoami", "", signed)
+	assert.Equal(t, "\"\"\n", rec.Body.String())
+	rec, _ = call("whoami", "", resigned)
+	assert.Equal(t, "\"alice\"\n", rec.Body.String())
+}
+
+func TestSessionsDisabled(t *testing.T) {
+	h := MustBind(func(s *Session) error { return nil })
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("POST", "/", nil))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, `{"error":{"message":"can't load session: sessions aren't enabled","type":"internal"}}`+"\n", rec.Body.String())
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	assert.NoError(t, store.Save(ctx, "a", []byte("{}"), time.Minute))
+
+	data, err := store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("{}"), data)
+
+	now = now.Add(2 * time.Minute)
+	data, err = store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+}
+
+type testRedis map[string][]byte
+
+func (r testRedis) Get(ctx context.Context, key string) ([]byte, error) { return r[key], nil }
+func (r testRedis) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	r[key] = value
+	return nil
+}
+func (r testRedis) Del(ctx context.Context, key string) error {
+	delete(r, key)
+	return nil
+}
+
+func TestRedisStore(t *testing.T) {
+	client := testRedis{}
+	store := NewRedisStore(client, "session:")
+
+	ctx := context.Background()
+	assert.NoError(t, store.Save(ctx, "a", []byte("{}"), time.Minute))
+	assert.Equal(t, []byte("{}"), client["session:a"])
+
+	data, err := store.Load(ctx, "a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("{}"), data)
+
+	assert.NoError(t, store.Delete(ctx, "a"))
+	assert.Empty(t, client)
+}
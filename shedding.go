@@ -0,0 +1,200 @@
+package nra
+
+import (
+	"math"
+	"net/http"
+	"runtime"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Priority decides which calls are shed first if the server
+// is overloaded, see Registry.SetLoadShedding.
+type Priority int
+
+const (
+	// PriorityLow calls are shed first, e.g. prefetching or reports.
+	PriorityLow Priority = -1
+
+	// PriorityNormal is the priority of all functions by default.
+	PriorityNormal Priority = 0
+
+	// PriorityHigh calls are shed last, e.g. logins or payments.
+	PriorityHigh Priority = 1
+)
+
+// WithPriority sets the priority of the function for load shedding.
+func WithPriority(p Priority) Option {
+	return func(o *options) {
+		o.priority = p
+	}
+}
+
+// heapObjectsMetric is the memory occupied by live
+// and not yet collected objects on the heap.
+const heapObjectsMetric = "/memory/classes/heap/objects:bytes"
+
+// LoadShedding configures when the registry is overloaded. As long
+// as one of the limits is exceeded, calls of functions with a priority
+// below MinPriority are answered with status 503, the type
+// ErrorTypeUnavailable and a Retry-After header instead of adding to
+// the load. Limits that are 0 aren't checked.
+type LoadShedding struct {
+	// MaxGoroutines is the number of goroutines of the process.
+	MaxGoroutines int
+
+	// MaxInFlight is the number of calls of the registry that
+	// are running or waiting, e.g. for a concurrency limit.
+	MaxInFlight int
+
+	// MaxHeapBytes is the size of the heap of the process.
+	MaxHeapBytes uint64
+
+	// MinPriority is the lowest priority that isn't shed. The
+	// default PriorityNormal only sheds PriorityLow calls.
+	MinPriority Priority
+
+	// RetryAfter is sent to shed clients. It defaults to 1s.
+	RetryAfter time.Duration
+
+	// Interval is how often the goroutines and the heap are
+	// checked. It defaults to 100ms.
+	Interval time.Duration
+}
+
+// SheddingStats describes the load shedding of a registry.
+type SheddingStats struct {
+	// Overloaded is true if a limit is currently exceeded.
+	Overloaded bool `json:"overloaded"`
+
+	// Reason names the exceeded limit, e.g. "goroutines",
+	// "in_flight" or "heap".
+	Reason string `json:"reason,omitempty"`
+
+	// InFlight is the number of calls currently running.
+	InFlight int64 `json:"in_flight"`
+
+	// Shed is the number of calls that were shed so far,
+	// by the name of the function.
+	Shed map[string]uint64 `json:"shed"`
+}
+
+// loadShedder decides if calls have to be shed.
+type loadShedder struct {
+	config   LoadShedding
+	inFlight int64
+
+	mtx     sync.Mutex
+	checked time.Time
+	reason  string
+	shed    map[string]uint64
+	now     func() time.Time
+}
+
+// newLoadShedder creates the shedder with the defaults applied.
+func newLoadShedder(config LoadShedding) *loadShedder {
+	if config.RetryAfter <= 0 {
+		config.RetryAfter = time.Second
+	}
+	if config.Interval <= 0 {
+		config.Interval = 100 * time.Millisecond
+	}
+	return &loadShedder{config: config, shed: map[string]uint64{}, now: time.Now}
+}
+
+// SetLoadShedding makes the registry shed low priority calls while the
+// server is overloaded, so high priority functions stay responsive.
+//
+//	r.SetLoadShedding(nra.LoadShedding{MaxInFlight: 500, MaxHeapBytes: 2 << 30})
+//	r.MustRegister("login", login, nra.WithPriority(nra.PriorityHigh))
+//	r.MustRegister("report", report, nra.WithPriority(nra.PriorityLow))
+func (r *Registry) SetLoadShedding(config LoadShedding) {
+	r.shedder = newLoadShedder(config)
+}
+
+// SheddingStats returns the current state of the load shedding.
+func (r *Registry) SheddingStats() SheddingStats {
+	if r.shedder == nil {
+		return SheddingStats{Shed: map[string]uint64{}}
+	}
+	return r.shedder.stats()
+}
+
+// overloaded returns the exceeded limit or a empty string. The
+// goroutines and heap are only checked once per interval.
+func (s *loadShedder) overloaded() string {
+	if s.config.MaxInFlight > 0 && atomic.LoadInt64(&s.inFlight) > int64(s.config.MaxInFlight) {
+		return "in_flight"
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	now := s.now()
+	if now.Sub(s.checked) < s.config.Interval {
+		return s.reason
+	}
+	s.checked = now
+
+	s.reason = ""
+	switch {
+	case s.config.MaxGoroutines > 0 && runtime.NumGoroutine() > s.config.MaxGoroutines:
+		s.reason = "goroutines"
+	case s.config.MaxHeapBytes > 0 && heapBytes() > s.config.MaxHeapBytes:
+		s.reason = "heap"
+	}
+	return s.reason
+}
+
+// heapBytes returns the size of the heap.
+func heapBytes() uint64 {
+	sample := []metrics.Sample{{Name: heapObjectsMetric}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 {
+		return 0
+	}
+	return sample[0].Value.Uint64()
+}
+
+// admit reports if the call of the function with the priority
+// can be executed. Calls that are admitted count as in flight
+// until done is called.
+func (s *loadShedder) admit(name string, priority Priority) bool {
+	if priority < s.config.MinPriority && s.overloaded() != "" {
+		s.mtx.Lock()
+		s.shed[name]++
+		s.mtx.Unlock()
+		return false
+	}
+
+	atomic.AddInt64(&s.inFlight, 1)
+	return true
+}
+
+// done marks a admitted call as done.
+func (s *loadShedder) done() {
+	atomic.AddInt64(&s.inFlight, -1)
+}
+
+// stats returns the SheddingStats of the shedder.
+func (s *loadShedder) stats() SheddingStats {
+	reason := s.overloaded()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	shed := make(map[string]uint64, len(s.shed))
+	for name, n := range s.shed {
+		shed[name] = n
+	}
+	return SheddingStats{Overloaded: reason != "", Reason: reason, InFlight: atomic.LoadInt64(&s.inFlight), Shed: shed}
+}
+
+// writeShed answers a call that was shed.
+func (s *loadShedder) writeShed(writer http.ResponseWriter) {
+	writer.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(s.config.RetryAfter.Seconds()))))
+	writeError(writer, http.StatusServiceUnavailable, &Error{Message: "server is overloaded", Type: ErrorTypeUnavailable})
+}
@@ -0,0 +1,80 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedding(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	r := NewRegistry()
+	r.MustRegister("block", func() error {
+		started <- struct{}{}
+		<-release
+		return nil
+	})
+	r.MustRegister("report", func() error { return nil }, WithPriority(PriorityLow))
+	r.MustRegister("login", func() error { return nil }, WithPriority(PriorityHigh))
+	r.MustRegister("list", func() error { return nil })
+	r.SetLoadShedding(LoadShedding{MaxInFlight: 1})
+
+	call := func(name string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/"+name, nil))
+		return rr
+	}
+
+	assert.Equal(t, http.StatusOK, call("report").Code)
+
+	// two blocking calls exceed the limit of calls in flight.
+	done := make(chan struct{})
+	for i := 0; i < 2; i++ {
+		go func() {
+			call("block")
+			done <- struct{}{}
+		}()
+		<-started
+	}
+
+	rr := call("report")
+	assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	assert.Equal(t, "{\"error\":{\"message\":\"server is overloaded\",\"type\":\"unavailable\"}}\n", rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, call("list").Code)
+	assert.Equal(t, http.StatusOK, call("login").Code)
+
+	stats := r.SheddingStats()
+	assert.True(t, stats.Overloaded)
+	assert.Equal(t, "in_flight", stats.Reason)
+	assert.Equal(t, int64(2), stats.InFlight)
+	assert.Equal(t, map[string]uint64{"report": 1}, stats.Shed)
+
+	close(release)
+	<-done
+	<-done
+
+	stats = r.SheddingStats()
+	assert.False(t, stats.Overloaded)
+	assert.Equal(t, int64(0), stats.InFlight)
+	assert.Equal(t, http.StatusOK, call("report").Code)
+}
+
+func TestLoadSheddingGoroutines(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("report", func() error { return nil }, WithPriority(PriorityLow))
+	r.MustRegister("list", func() error { return nil })
+	r.SetLoadShedding(LoadShedding{MaxGoroutines: 1, MinPriority: PriorityHigh})
+
+	for _, name := range []string{"report", "list"} {
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/"+name, nil))
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+	}
+	assert.Equal(t, "goroutines", r.SheddingStats().Reason)
+}
@@ -0,0 +1,160 @@
+package nra
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query parameters of signed URLs, see Registry.SignURL.
+const (
+	SignedArgsName      = "args"
+	SignedExpiresName   = "expires"
+	SignedSignatureName = "signature"
+)
+
+// SetURLKeys sets the keys that sign the URLs created by SignURL. The
+// first key signs, URLs signed with any of them are accepted, so keys
+// can be rotated the same way as with RotateSessionKeys. Without keys
+// no URLs can be signed. It is safe to call while the registry serves
+// requests.
+func (r *Registry) SetURLKeys(keys ...[]byte) {
+	r.urlKeys.set(keys)
+}
+
+// SignURL creates a URL that calls the function name with args when it
+// is fetched with GET, until ttl passed. The URL carries its own
+// authorization, so it can be used in a link, opened in a new tab or
+// sent by mail without handing out credentials:
+//
+//	link, err := r.SignURL("downloadReport", []interface{}{reportID}, time.Hour)
+//
+// Calls via a signed URL skip the authentication and the CSRF check
+// but nothing else, e.g. rate limits still apply. Anyone with the URL
+// can make the call until it expires, so keep ttl short. Functions can
+// return a *File to send a download instead of a encoded result.
+//
+// The URL calls the default version of the function, or the version
+// in the name, e.g. "v2/downloadReport", see WithVersion. The version
+// is signed as well, so the URL can't be used to call another one.
+func (r *Registry) SignURL(name string, args []interface{}, ttl time.Duration) (string, error) {
+	base, version := splitVersion(name)
+	b, e := r.lookup(base, version)
+	if e != nil {
+		return "", errors.New(e.Message)
+	}
+	name = versionedName(base, b.options.apiVersion())
+	if args == nil {
+		args = []interface{}{}
+	}
+
+	encoded, err := json.Marshal(args)
+	if err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(ttl).Unix(), 10)
+
+	r.urlKeys.mtx.RLock()
+	defer r.urlKeys.mtx.RUnlock()
+
+	if len(r.urlKeys.keys) == 0 {
+		return "", errors.New("can't sign url without keys, see SetURLKeys")
+	}
+	signature := sessionMAC(r.urlKeys.keys[0], signedPayload(name, string(encoded), expires))
+
+	query := url.Values{}
+	query.Set(SignedArgsName, string(encoded))
+	query.Set(SignedExpiresName, expires)
+	query.Set(SignedSignatureName, base64.RawURLEncoding.EncodeToString(signature))
+	return r.PublicPrefix() + name + "?" + query.Encode(), nil
+}
+
+// signedPayload is what the signature of a URL covers. The name
+// includes the version, see versionedName.
+func signedPayload(name string, args string, expires string) string {
+	return name + "\n" + args + "\n" + expires
+}
+
+// isSignedCall reports if the request is a call via a signed URL.
+func isSignedCall(request *http.Request) bool {
+	return request.Method == "GET" && request.URL.Query().Has(SignedSignatureName)
+}
+
+// signedCallKey is the context key marking calls via a signed URL.
+type signedCallKey struct{}
+
+// signed reports if the call of ctx was made via a signed URL.
+func signed(ctx context.Context) bool {
+	return ctx.Value(signedCallKey{}) != nil
+}
+
+// verifySignedCall checks the signature of the URL for the versioned
+// name of the called function and turns the request into the POST
+// request of the call it stands for.
+func (r *Registry) verifySignedCall(request *http.Request, name string) (*http.Request, *Error) {
+	query := request.URL.Query()
+	args, expires := query.Get(SignedArgsName), query.Get(SignedExpiresName)
+
+	mac, err := base64.RawURLEncoding.DecodeString(query.Get(SignedSignatureName))
+	if err != nil {
+		return nil, &Error{Message: "invalid url signature", Type: ErrorTypeForbidden}
+	}
+
+	valid := false
+	r.urlKeys.mtx.RLock()
+	for _, key := range r.urlKeys.keys {
+		if hmac.Equal(mac, sessionMAC(key, signedPayload(name, args, expires))) {
+			valid = true
+			break
+		}
+	}
+	r.urlKeys.mtx.RUnlock()
+	if !valid {
+		return nil, &Error{Message: "invalid url signature", Type: ErrorTypeForbidden}
+	}
+
+	if unix, err := strconv.ParseInt(expires, 10, 64); err != nil || time.Now().Unix() > unix {
+		return nil, &Error{Message: "signed url has expired", Type: ErrorTypeForbidden}
+	}
+
+	call := request.Clone(context.WithValue(request.Context(), signedCallKey{}, true))
+	call.Method = "POST"
+	call.URL.RawQuery = ""
+	call.Header.Set("Content-Type", "application/json")
+	call.Body = io.NopCloser(strings.NewReader(args))
+	call.ContentLength = int64(len(args))
+	return call, nil
+}
+
+// File is a result that is sent as it is instead of being encoded,
+// e.g. a report downloaded via a signed URL. With a name the browser
+// saves it as a file of that name instead of showing it.
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+}
+
+// writeFile writes the file as response.
+func writeFile(writer http.ResponseWriter, status int, f *File) {
+	contentType := f.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	writer.Header().Set("Content-Type", contentType)
+	writer.Header().Set("Content-Length", strconv.Itoa(len(f.Data)))
+	if f.Name != "" {
+		writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": f.Name}))
+	}
+	writer.WriteHeader(status)
+	_, _ = writer.Write(f.Data)
+}
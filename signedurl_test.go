@@ -0,0 +1,107 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignURL(t *testing.T) {
+	r := NewRegistry()
+	r.SetAuthenticator(BearerTokens(map[string]interface{}{"secret": "admin"}))
+	r.EnableCSRF(CSRF{})
+	r.MustRegister("report", func(id int, format string) (*File, error) {
+		return &File{Name: "report-" + format + ".csv", ContentType: "text/csv", Data: []byte("id\n" + strings.Repeat("x", id))}, nil
+	})
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+
+	_, err := r.SignURL("report", []interface{}{3, "full"}, time.Minute)
+	assert.Error(t, err, "no keys")
+
+	r.SetURLKeys([]byte("key"))
+	_, err = r.SignURL("missing", nil, time.Minute)
+	assert.Error(t, err)
+
+	link, err := r.SignURL("report", []interface{}{3, "full"}, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(link, "/rpc/report?"))
+
+	get := func(link string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, httptest.NewRequest("GET", link, nil))
+		return rec
+	}
+
+	rec := get(link)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "id\nxxx", rec.Body.String())
+	assert.Equal(t, "text/csv", rec.Header().Get("Content-Type"))
+	assert.Equal(t, "attachment; filename=report-full.csv", rec.Header().Get("Content-Disposition"))
+
+	// the url is only valid for the function and arguments it was signed for.
+	u, _ := url.Parse(link)
+	query := u.Query()
+	query.Set(SignedArgsName, `[4, "full"]`)
+	assert.Equal(t, http.StatusForbidden, get("/rpc/report?"+query.Encode()).Code)
+	assert.Equal(t, http.StatusForbidden, get("/rpc/add?"+u.RawQuery).Code)
+
+	expired, _ := r.SignURL("add", []interface{}{1, 2}, -time.Minute)
+	rec = get(expired)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "signed url has expired")
+
+	// old keys are still accepted after a rotation.
+	added, _ := r.SignURL("add", []interface{}{1, 2}, time.Minute)
+	r.SetURLKeys([]byte("new"), []byte("key"))
+	assert.Equal(t, "3\n", get(added).Body.String())
+	r.SetURLKeys([]byte("new"))
+	assert.Equal(t, http.StatusForbidden, get(added).Code)
+
+	// without a signature the authentication still applies.
+	assert.Equal(t, http.StatusUnauthorized, get("/rpc/add?args=[1,2]").Code)
+}
+
+func TestSignURLVersion(t *testing.T) {
+	r := NewRegistry()
+	r.SetURLKeys([]byte("key"))
+	r.MustRegister("report", func(id int) (string, error) { return "v1", nil })
+	r.MustRegister("report", func(id int) (string, error) { return "v2", nil }, WithVersion(2))
+
+	get := func(link string, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", link, nil)
+		if version != "" {
+			req.Header.Set(APIVersionHeader, version)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	link, err := r.SignURL("report", []interface{}{1}, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "\"v1\"\n", get(link, "").Body.String())
+
+	// the version is signed, so the url can't call another one.
+	u, _ := url.Parse(link)
+	assert.Equal(t, http.StatusForbidden, get(link, "2").Code)
+	assert.Equal(t, http.StatusForbidden, get("/rpc/v2/report?"+u.RawQuery, "").Code)
+
+	link, err = r.SignURL("v2/report", []interface{}{1}, time.Minute)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(link, "/rpc/v2/report?"))
+	assert.Equal(t, "\"v2\"\n", get(link, "").Body.String())
+
+	_, err = r.SignURL("v3/report", nil, time.Minute)
+	assert.EqualError(t, err, "function 'report' has no version 3")
+}
@@ -0,0 +1,422 @@
+package nra
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"unicode"
+)
+
+// snapshotFormat is the version of the format written by ExportSchema.
+const snapshotFormat = 1
+
+// registrySnapshot is everything the client generators need from a
+// registry, see ExportSchema.
+type registrySnapshot struct {
+	Format        int                       `json:"format"`
+	Prefix        string                    `json:"prefix"`
+	BasePath      string                    `json:"base_path,omitempty"`
+	CSRFHeader    string                    `json:"csrf_header,omitempty"`
+	CSRFCookie    string                    `json:"csrf_cookie,omitempty"`
+	DebugOverlay  bool                      `json:"debug_overlay,omitempty"`
+	EncryptionKey string                    `json:"encryption_key,omitempty"`
+	Functions     []snapshotFunction        `json:"functions"`
+	Types         map[string]snapshotStruct `json:"types,omitempty"`
+}
+
+// snapshotFunction is the signature and the options of a function
+// that change the generated clients.
+type snapshotFunction struct {
+	Name           string          `json:"name"`
+	Params         []*snapshotType `json:"params"`
+	Variadic       bool            `json:"variadic,omitempty"`
+	Result         *snapshotType   `json:"result,omitempty"`
+	Version        int             `json:"version,omitempty"`
+	Safe           bool            `json:"safe,omitempty"`
+	Idempotent     bool            `json:"idempotent,omitempty"`
+	IdempotencyKey bool            `json:"idempotency_key,omitempty"`
+	Deprecated     bool            `json:"deprecated,omitempty"`
+}
+
+// snapshotType is a Go type. Named structs are referenced by
+// their key in the types of the snapshot.
+type snapshotType struct {
+	Kind   string          `json:"kind"`
+	Ref    string          `json:"ref,omitempty"`
+	Elem   *snapshotType   `json:"elem,omitempty"`
+	Key    *snapshotType   `json:"key,omitempty"`
+	Len    int             `json:"len,omitempty"`
+	Fields []snapshotField `json:"fields,omitempty"`
+}
+
+// snapshotStruct is a named struct.
+type snapshotStruct struct {
+	Name   string          `json:"name"`
+	Fields []snapshotField `json:"fields"`
+}
+
+// snapshotField is a field of a struct with all of its tags.
+type snapshotField struct {
+	Name string        `json:"name"`
+	Type *snapshotType `json:"type"`
+	Tag  string        `json:"tag,omitempty"`
+}
+
+// snapshotKinds are the kinds that are written by their name.
+var snapshotKinds = map[reflect.Kind]string{
+	reflect.Bool: "bool", reflect.String: "string",
+	reflect.Int: "int", reflect.Int8: "int8", reflect.Int16: "int16", reflect.Int32: "int32", reflect.Int64: "int64",
+	reflect.Uint: "uint", reflect.Uint8: "uint8", reflect.Uint16: "uint16", reflect.Uint32: "uint32", reflect.Uint64: "uint64",
+	reflect.Float32: "float32", reflect.Float64: "float64",
+}
+
+// snapshotKindTypes are the types of the kinds written by their name.
+var snapshotKindTypes = map[string]reflect.Type{
+	"bool": reflect.TypeOf(false), "string": reflect.TypeOf(""),
+	"int": reflect.TypeOf(int(0)), "int8": reflect.TypeOf(int8(0)), "int16": reflect.TypeOf(int16(0)), "int32": reflect.TypeOf(int32(0)), "int64": reflect.TypeOf(int64(0)),
+	"uint": reflect.TypeOf(uint(0)), "uint8": reflect.TypeOf(uint8(0)), "uint16": reflect.TypeOf(uint16(0)), "uint32": reflect.TypeOf(uint32(0)), "uint64": reflect.TypeOf(uint64(0)),
+	"float32": reflect.TypeOf(float32(0)), "float64": reflect.TypeOf(float64(0)),
+	"time": timeType, "duration": durationType, "interface": interfaceType,
+}
+
+// interfaceType is the type of a empty interface.
+var interfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+
+// errorType is the type of the error interface.
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// errImported is returned by the functions of a imported registry.
+var errImported = errors.New("functions of a imported schema can't be called")
+
+// ExportSchema writes everything the client generators need to know
+// about the registry to file: the signatures of the default versions of
+// all functions, the struct types with their tags and the settings that
+// end up in the clients. ImportSchema reads it back, so clients can be
+// generated where the backend can't be imported or run, e.g. in the CI
+// of a separate frontend repository:
+//
+//	// in the backend
+//	r.ExportSchema("nra.schema.json")
+//
+//	// in the frontend
+//	nra client -ts src/api.ts nra.schema.json
+//
+// Functions that take a *Upload are left out.
+func (r *Registry) ExportSchema(file string) error {
+	key, err := r.publicKey()
+	if err != nil {
+		return err
+	}
+
+	s := registrySnapshot{
+		Format:        snapshotFormat,
+		Prefix:        r.prefix,
+		BasePath:      r.basePath,
+		DebugOverlay:  r.debugOverlay,
+		EncryptionKey: key,
+		Functions:     []snapshotFunction{},
+		Types:         map[string]snapshotStruct{},
+	}
+
+	if r.csrf != nil {
+		s.CSRFHeader, s.CSRFCookie = r.csrf.Header, r.csrf.Cookie
+	}
+
+	names, functions := r.snapshot()
+	for _, name := range names {
+		b := functions[name]
+		if b.upload {
+			continue
+		}
+
+		f := snapshotFunction{
+			Name:           name,
+			Params:         []*snapshotType{},
+			Variadic:       b.variadic,
+			Version:        b.options.version,
+			Safe:           b.options.safe,
+			Idempotent:     b.options.idempotent,
+			IdempotencyKey: b.options.idempotencyKey,
+			Deprecated:     b.options.deprecated,
+		}
+		for _, p := range b.params {
+			f.Params = append(f.Params, s.typeOf(p))
+		}
+		if b.result != nil {
+			f.Result = s.typeOf(b.result)
+		}
+		s.Functions = append(s.Functions, f)
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(file, append(data, '\n'), 0o644)
+}
+
+// typeOf returns the snapshot of t and adds the
+// named structs it uses to the types.
+func (s *registrySnapshot) typeOf(t reflect.Type) *snapshotType {
+	switch t {
+	case timeType:
+		return &snapshotType{Kind: "time"}
+	case durationType:
+		return &snapshotType{Kind: "duration"}
+	}
+	if kind, ok := snapshotKinds[t.Kind()]; ok {
+		return &snapshotType{Kind: kind}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return &snapshotType{Kind: "ptr", Elem: s.typeOf(t.Elem())}
+	case reflect.Slice:
+		return &snapshotType{Kind: "slice", Elem: s.typeOf(t.Elem())}
+	case reflect.Array:
+		return &snapshotType{Kind: "array", Elem: s.typeOf(t.Elem()), Len: t.Len()}
+	case reflect.Map:
+		return &snapshotType{Kind: "map", Key: s.typeOf(t.Key()), Elem: s.typeOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return &snapshotType{Kind: "struct", Fields: s.fieldsOf(t)}
+		}
+
+		ref := t.PkgPath() + "." + t.Name()
+		if _, ok := s.Types[ref]; !ok {
+			// the entry is added before the fields, so
+			// recursive structs reference themselves.
+			s.Types[ref] = snapshotStruct{Name: t.Name()}
+			fields := s.fieldsOf(t)
+			s.Types[ref] = snapshotStruct{Name: t.Name(), Fields: fields}
+		}
+		return &snapshotType{Kind: "struct", Ref: ref}
+	}
+	return &snapshotType{Kind: "interface"}
+}
+
+// fieldsOf returns the fields of the struct t as they are encoded.
+func (s *registrySnapshot) fieldsOf(t reflect.Type) []snapshotField {
+	fields := []snapshotField{}
+	for _, f := range jsonFields(t) {
+		fields = append(fields, snapshotField{Name: f.field.Name, Type: s.typeOf(f.typ), Tag: string(f.field.Tag)})
+	}
+	return fields
+}
+
+// ImportSchema creates a registry from a file written by ExportSchema,
+// which can be passed to GenerateJavaScript and GenerateTypeScript
+// without the Go code of the functions. The types are rebuilt from the
+// file, so they only have the fields and tags of the originals. The
+// functions of the registry fail if they are called.
+func ImportSchema(file string) (*Registry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var s registrySnapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if s.Format != snapshotFormat {
+		return nil, fmt.Errorf("%s: unsupported format %d", file, s.Format)
+	}
+
+	r := NewRegistry()
+	r.prefix = s.Prefix
+	r.basePath = s.BasePath
+	if s.CSRFHeader != "" {
+		r.csrf = &CSRF{Header: s.CSRFHeader, Cookie: s.CSRFCookie}
+	}
+	r.debugOverlay = s.DebugOverlay
+	r.importedKey = s.EncryptionKey
+
+	im := &snapshotImport{snapshot: &s, types: map[string]reflect.Type{}, building: map[string]bool{}, names: map[reflect.Type]string{}}
+	for _, f := range s.Functions {
+		fn, err := im.funcOf(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: function %s: %w", file, f.Name, err)
+		}
+
+		var opts []Option
+		if f.Version > 0 {
+			opts = append(opts, WithVersion(f.Version))
+		}
+		if f.Safe {
+			opts = append(opts, WithSafe())
+		}
+		if f.Idempotent {
+			opts = append(opts, WithIdempotent())
+		}
+		if f.IdempotencyKey {
+			opts = append(opts, WithIdempotencyKey())
+		}
+		if f.Deprecated {
+			opts = append(opts, WithDeprecated())
+		}
+		if err := r.Register(f.Name, fn.Interface(), opts...); err != nil {
+			return nil, fmt.Errorf("%s: function %s: %w", file, f.Name, err)
+		}
+	}
+	r.typeNames = im.names
+	return r, nil
+}
+
+// snapshotImport rebuilds the types of a snapshot.
+type snapshotImport struct {
+	snapshot *registrySnapshot
+	types    map[string]reflect.Type
+	building map[string]bool
+
+	// the names of the named structs, which the
+	// rebuilt types don't have, see Registry.typeName.
+	names map[reflect.Type]string
+}
+
+// funcOf returns a function with the signature of f,
+// which fails with errImported when it is called.
+func (im *snapshotImport) funcOf(f snapshotFunction) (reflect.Value, error) {
+	var in, out []reflect.Type
+	for _, p := range f.Params {
+		t, err := im.typeOf(p)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		in = append(in, t)
+	}
+	if f.Variadic && (len(in) == 0 || in[len(in)-1].Kind() != reflect.Slice) {
+		return reflect.Value{}, errors.New("variadic parameter isn't a slice")
+	}
+	if f.Result != nil {
+		t, err := im.typeOf(f.Result)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		out = append(out, t)
+	}
+	out = append(out, errorType)
+
+	fnType := reflect.FuncOf(in, out, f.Variadic)
+	return reflect.MakeFunc(fnType, func([]reflect.Value) []reflect.Value {
+		results := make([]reflect.Value, len(out))
+		for i, t := range out[:len(out)-1] {
+			results[i] = reflect.Zero(t)
+		}
+		results[len(out)-1] = reflect.ValueOf(&errImported).Elem()
+		return results
+	}), nil
+}
+
+// typeOf rebuilds the type. Recursive structs can't be built
+// with reflect, so they are a empty interface where they recur.
+func (im *snapshotImport) typeOf(t *snapshotType) (reflect.Type, error) {
+	if t == nil {
+		return nil, errors.New("missing type")
+	}
+	if typ, ok := snapshotKindTypes[t.Kind]; ok {
+		return typ, nil
+	}
+
+	switch t.Kind {
+	case "ptr", "slice", "array":
+		elem, err := im.typeOf(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.Kind == "ptr" && elem == interfaceType:
+			return elem, nil
+		case t.Kind == "ptr":
+			return reflect.PtrTo(elem), nil
+		case t.Kind == "slice":
+			return reflect.SliceOf(elem), nil
+		case t.Len < 0:
+			return nil, errors.New("negative array length")
+		}
+		return reflect.ArrayOf(t.Len, elem), nil
+	case "map":
+		key, err := im.typeOf(t.Key)
+		if err != nil {
+			return nil, err
+		}
+		elem, err := im.typeOf(t.Elem)
+		if err != nil {
+			return nil, err
+		}
+		if key.Kind() != reflect.String && !isNumber(key.Kind()) {
+			return nil, fmt.Errorf("invalid map key %s", key)
+		}
+		return reflect.MapOf(key, elem), nil
+	case "struct":
+		if t.Ref == "" {
+			return im.structOf(t.Fields)
+		}
+		if typ, ok := im.types[t.Ref]; ok {
+			return typ, nil
+		}
+		if im.building[t.Ref] {
+			return interfaceType, nil
+		}
+
+		def, ok := im.snapshot.Types[t.Ref]
+		if !ok {
+			return nil, fmt.Errorf("unknown type %s", t.Ref)
+		}
+		im.building[t.Ref] = true
+		typ, err := im.structOf(def.Fields)
+		delete(im.building, t.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", def.Name, err)
+		}
+		im.types[t.Ref] = typ
+		if _, ok := im.names[typ]; !ok {
+			im.names[typ] = def.Name
+		}
+		return typ, nil
+	}
+	return nil, fmt.Errorf("unknown kind %q", t.Kind)
+}
+
+// structOf builds a struct with the fields.
+func (im *snapshotImport) structOf(fields []snapshotField) (typ reflect.Type, err error) {
+	var structFields []reflect.StructField
+	seen := map[string]bool{}
+	for _, f := range fields {
+		if !isExportedName(f.Name) || seen[f.Name] {
+			return nil, fmt.Errorf("invalid field name %q", f.Name)
+		}
+		seen[f.Name] = true
+
+		ft, err := im.typeOf(f.Type)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		structFields = append(structFields, reflect.StructField{Name: f.Name, Type: ft, Tag: reflect.StructTag(f.Tag)})
+	}
+	return reflect.StructOf(structFields), nil
+}
+
+// isExportedName reports if name is a exported Go identifier.
+func isExportedName(name string) bool {
+	for i, c := range name {
+		switch {
+		case i == 0 && !unicode.IsUpper(c):
+			return false
+		case c != '_' && !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+	return name != ""
+}
+
+// typeName returns the name of the named struct t, which is
+// the name of the original type for imported registries.
+func (r *Registry) typeName(t reflect.Type) string {
+	if name, ok := r.typeNames[t]; ok {
+		return name
+	}
+	return t.Name()
+}
@@ -0,0 +1,123 @@
+package nra
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type snapshotAddress struct {
+	Street string `json:"street" label:"Street name"`
+	Zip    string `json:"zip,omitempty" form:"pattern=^[0-9]+$"`
+}
+
+type snapshotUser struct {
+	Name     string                      `json:"name"`
+	Password string                      `json:"password" encrypt:"true"`
+	Limit    int                         `json:"limit" default:"10"`
+	Born     *time.Time                  `json:"born"`
+	Timeout  time.Duration               `json:"timeout"`
+	Address  snapshotAddress             `json:"address"`
+	Previous []snapshotAddress           `json:"previous"`
+	Scores   map[string]float64          `json:"scores"`
+	Avatar   []byte                      `json:"avatar"`
+	Pair     [2]int8                     `json:"pair"`
+	Meta     struct{ Source string }     `json:"meta"`
+	Extra    map[string]*snapshotAddress `json:"extra,omitempty"`
+}
+
+type snapshotNode struct {
+	Value    int            `json:"value"`
+	Children []snapshotNode `json:"children"`
+}
+
+func TestExportSchema(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	r := NewRegistry()
+	r.SetBasePath("/api")
+	r.EnableCSRF(CSRF{Cookie: "csrf"})
+	r.EnableDebugOverlay()
+	r.EnableIdempotency(Idempotency{})
+	r.SetEncryptionKeys(key)
+	r.MustRegister("users.create", func(u snapshotUser, notify *bool) (snapshotUser, error) { return u, nil }, WithIdempotencyKey())
+	r.MustRegister("users.get", func(id int) (*snapshotUser, error) { return nil, nil }, WithSafe(), WithIdempotent())
+	r.MustRegister("users.tag", func(id uint, tags ...string) error { return nil }, WithDeprecated())
+	r.MustRegister("ingest", func(u *Upload[snapshotAddress]) error { return nil })
+
+	file := filepath.Join(t.TempDir(), "schema.json")
+	if !assert.NoError(t, r.ExportSchema(file)) {
+		return
+	}
+	imported, err := ImportSchema(file)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	// uploads are left out, everything else is the same.
+	r.Unregister("ingest")
+
+	var js, importedJS bytes.Buffer
+	assert.NoError(t, GenerateJavaScript(&js, r))
+	assert.NoError(t, GenerateJavaScript(&importedJS, imported))
+	assert.Equal(t, js.String(), importedJS.String())
+
+	var ts, importedTS bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&ts, r, WithTypeScriptForms()))
+	assert.NoError(t, GenerateTypeScript(&importedTS, imported, WithTypeScriptForms()))
+	assert.Equal(t, ts.String(), importedTS.String())
+	assert.Contains(t, importedTS.String(), "export interface snapshotUser {")
+
+	// the functions of a imported registry can't be called.
+	rr := httptest.NewRecorder()
+	imported.ServeHTTP(rr, httptest.NewRequest("POST", "/rpc/users.get", strings.NewReader("[1]")))
+	assert.NotEqual(t, http.StatusOK, rr.Code)
+}
+
+func TestImportSchema(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("tree", func(n snapshotNode) (snapshotNode, error) { return n, nil })
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "schema.json")
+	if !assert.NoError(t, r.ExportSchema(file)) {
+		return
+	}
+
+	// recursive structs end in a empty interface.
+	imported, err := ImportSchema(file)
+	if assert.NoError(t, err) {
+		var ts bytes.Buffer
+		assert.NoError(t, GenerateTypeScript(&ts, imported))
+		assert.Contains(t, ts.String(), "export interface snapshotNode {\n  value: number;\n  children: Array<any>;\n}")
+	}
+
+	for name, content := range map[string]string{
+		"format.json":   `{"format":2,"functions":[]}`,
+		"kind.json":     `{"format":1,"functions":[{"name":"a","params":[{"kind":"chan"}]}]}`,
+		"ref.json":      `{"format":1,"functions":[{"name":"a","params":[{"kind":"struct","ref":"x.Missing"}]}]}`,
+		"field.json":    `{"format":1,"functions":[{"name":"a","params":[{"kind":"struct","fields":[{"name":"lower","type":{"kind":"int"}}]}]}]}`,
+		"variadic.json": `{"format":1,"functions":[{"name":"a","params":[{"kind":"int"}],"variadic":true}]}`,
+		"invalid.json":  `{`,
+	} {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+		_, err := ImportSchema(file)
+		assert.Error(t, err, name)
+	}
+
+	_, err = ImportSchema(filepath.Join(dir, "missing.json"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// StaticFunc is a call of a bound function without reflection, as it
+// is generated by "nra handlers". It decodes the raw JSON arguments
+// with DecodeArgument, calls the function and returns its result.
+type StaticFunc func(ctx context.Context, args []json.RawMessage) (interface{}, error)
+
+// WithStatic calls the function through call instead of reflect.Call
+// for JSON requests. The number of arguments is already checked before
+// call is invoked. Requests in other formats than JSON are still
+// handled the reflective way, so the bound function has to match call.
+//
+// Use "go run github.com/BigJk/nra/cmd/nra handlers" to generate it.
+func WithStatic(call StaticFunc) Option {
+	return func(o *options) {
+		o.static = call
+	}
+}
+
+// DecodeArgument decodes the i-th raw argument into v, which has to be
+// a pointer. Arguments are converted and validated the same way Bind
+// converts and validates them.
+// If the argument wasn't passed v is left untouched, so it keeps its
+// zero value. The returned error is a *Error describing the argument.
+func DecodeArgument(args []json.RawMessage, i int, v interface{}) error {
+	if i >= len(args) {
+		return nil
+	}
+
+	var arg interface{}
+	if err := json.Unmarshal(args[i], &arg); err != nil {
+		return &Error{Message: fmt.Sprintf("can't decode %d. argument: %v", i+1, err), Type: ErrorTypeRequest, Argument: i + 1}
+	}
+
+	target := reflect.ValueOf(v).Elem()
+	value, err := decoderOf(target.Type())(i+1, arg)
+	if err != nil {
+		return err
+	}
+
+	// invalid rules already failed when the function was bound.
+	if validate, _ := validatorFor(target.Type()); validate != nil {
+		if err := validateArg(i+1, value, validate); err != nil {
+			return err
+		}
+	}
+
+	target.Set(value)
+	return nil
+}
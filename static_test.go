@@ -0,0 +1,110 @@
+package nra
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func staticAdd(ctx context.Context, args []json.RawMessage) (interface{}, error) {
+	var a, b int
+	if err := DecodeArgument(args, 0, &a); err != nil {
+		return nil, err
+	}
+	if err := DecodeArgument(args, 1, &b); err != nil {
+		return nil, err
+	}
+	return a + b + 1000, nil
+}
+
+func TestWithStatic(t *testing.T) {
+	add := func(a, b int) (int, error) {
+		return a + b, nil
+	}
+
+	h := MustBind(add, WithStatic(staticAdd))
+
+	tests := []struct {
+		Name        string
+		ContentType string
+		Input       string
+		Code        int
+		Expected    string
+	}{
+		{Name: "static", Input: "[1, 2]", Code: http.StatusOK, Expected: "1003\n"},
+		{Name: "json_content_type", ContentType: "application/json", Input: "[1, 2]", Code: http.StatusOK, Expected: "1003\n"},
+		{Name: "arg_count", Input: "[1]", Code: http.StatusBadRequest, Expected: "number of arguments mismatch"},
+		{Name: "conversion", Input: "[1, \"a\"]", Code: http.StatusBadRequest, Expected: `"type":"conversion"`},
+		{Name: "invalid_body", Input: "[1,", Code: http.StatusBadRequest, Expected: `"type":"request"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/", strings.NewReader(test.Input))
+			if test.ContentType != "" {
+				req.Header.Set("Content-Type", test.ContentType)
+			}
+
+			rr := httptest.NewRecorder()
+			h(rr, req)
+
+			assert.Equal(t, test.Code, rr.Code)
+			assert.Contains(t, rr.Body.String(), test.Expected)
+		})
+	}
+}
+
+func TestWithStaticOtherCodec(t *testing.T) {
+	add := func(a, b int) (int, error) {
+		return a + b, nil
+	}
+
+	h := MustBind(add, WithStatic(staticAdd))
+
+	// requests in other formats use the reflective call.
+	body, _ := cborEncMode.Marshal([]interface{}{1, 2})
+	req := httptest.NewRequest("POST", "/", strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/cbor")
+	req.Header.Set("Accept", "application/json")
+
+	rr := httptest.NewRecorder()
+	h(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "3\n", rr.Body.String())
+}
+
+func TestDecodeArgument(t *testing.T) {
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y" default:"5"`
+	}
+
+	args := []json.RawMessage{json.RawMessage(`{"x": 1}`), json.RawMessage(`"abc"`)}
+
+	var p point
+	assert.NoError(t, DecodeArgument(args, 0, &p))
+	assert.Equal(t, point{X: 1, Y: 5}, p)
+
+	var s string
+	assert.NoError(t, DecodeArgument(args, 1, &s))
+	assert.Equal(t, "abc", s)
+
+	// missing arguments keep their value.
+	n := 7
+	assert.NoError(t, DecodeArgument(args, 2, &n))
+	assert.Equal(t, 7, n)
+
+	err := DecodeArgument(args, 1, &n)
+	if assert.Error(t, err) {
+		e, ok := err.(*Error)
+		assert.True(t, ok)
+		assert.Equal(t, ErrorTypeConversion, e.Type)
+		assert.Equal(t, 2, e.Argument)
+	}
+}
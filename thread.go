@@ -0,0 +1,118 @@
+package nra
+
+import (
+	"runtime"
+	"sync"
+)
+
+// executor runs the call of a bound function.
+type executor func(call func())
+
+// directExecutor runs the call on the current goroutine.
+func directExecutor(call func()) {
+	call()
+}
+
+// lockedExecutor runs the call on the current goroutine
+// while it is locked to its OS thread.
+func lockedExecutor(call func()) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	call()
+}
+
+// newExecutor returns the executor matching the options and
+// the thread pool it runs on, if any.
+func newExecutor(o *options) (executor, *threadPool) {
+	switch {
+	case o.threadPool > 0:
+		p := newThreadPool(o.threadPool)
+		return p.run, p
+	case o.lockOSThread:
+		return lockedExecutor, nil
+	}
+	return directExecutor, nil
+}
+
+// threadPool runs calls on a fixed set of goroutines
+// that are each locked to their own OS thread.
+type threadPool struct {
+	calls chan func()
+
+	mtx     sync.Mutex
+	running int
+	closed  bool
+}
+
+// newThreadPool starts a pool with size locked goroutines.
+func newThreadPool(size int) *threadPool {
+	p := &threadPool{calls: make(chan func())}
+	for i := 0; i < size; i++ {
+		go p.worker()
+	}
+	return p
+}
+
+// worker locks itself to its thread and executes calls. It never
+// unlocks the thread, so the thread is never used by anything else
+// and exits together with the worker once the pool is closed.
+func (p *threadPool) worker() {
+	runtime.LockOSThread()
+	for call := range p.calls {
+		call()
+	}
+}
+
+// run executes call on one of the pool threads and waits for it to
+// finish. A panic inside call is passed on to the calling goroutine
+// so it doesn't take down the worker or the whole process. Calls
+// after the pool is closed run on their own locked thread.
+func (p *threadPool) run(call func()) {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		lockedExecutor(call)
+		return
+	}
+	p.running++
+	p.mtx.Unlock()
+	defer p.done()
+
+	done := make(chan interface{}, 1)
+	p.calls <- func() {
+		defer func() {
+			done <- recover()
+		}()
+		call()
+	}
+
+	if err := <-done; err != nil {
+		panic(err)
+	}
+}
+
+// done marks a call as finished and stops the
+// workers if it was the last one of a closed pool.
+func (p *threadPool) done() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.running--
+	if p.closed && p.running == 0 {
+		close(p.calls)
+	}
+}
+
+// close stops the workers once the running calls finished.
+func (p *threadPool) close() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	if p.closed {
+		return
+	}
+	p.closed = true
+	if p.running == 0 {
+		close(p.calls)
+	}
+}
@@ -0,0 +1,102 @@
+package nra
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThreadPoolPanic(t *testing.T) {
+	p := newThreadPool(1)
+	assert.PanicsWithValue(t, "boom", func() {
+		p.run(func() { panic("boom") })
+	})
+
+	// the worker has to survive the panic.
+	ran := false
+	p.run(func() { ran = true })
+	assert.True(t, ran)
+}
+
+func TestThreadPoolClose(t *testing.T) {
+	p := newThreadPool(2)
+
+	started, release := make(chan struct{}), make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		p.run(func() {
+			close(started)
+			<-release
+		})
+		close(finished)
+	}()
+	<-started
+
+	// running calls finish on the pool, later calls
+	// run on their own thread.
+	p.close()
+	p.close()
+	ran := false
+	p.run(func() { ran = true })
+	assert.True(t, ran)
+
+	close(release)
+	<-finished
+
+	_, ok := <-p.calls
+	assert.False(t, ok, "the workers are stopped after the last call")
+}
+
+func TestRegistryClosesThreadPool(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("f", func() error { return nil }, WithOSThreadPool(1))
+	first := r.functions["f"].pool
+
+	assert.NoError(t, r.Replace("f", func() error { return nil }, WithOSThreadPool(1)))
+	assert.True(t, first.closed, "the replaced function releases its threads")
+
+	second := r.functions["f"].pool
+	assert.False(t, second.closed)
+	assert.True(t, r.Unregister("f"))
+	assert.True(t, second.closed)
+}
+
+func TestWithOSThreadPool(t *testing.T) {
+	running, maxRunning := 0, 0
+	var mtx sync.Mutex
+
+	h := MustBind(func(a int) (int, error) {
+		mtx.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mtx.Unlock()
+
+		defer func() {
+			mtx.Lock()
+			running--
+			mtx.Unlock()
+		}()
+		return a, nil
+	}, WithOSThreadPool(1))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest("POST", "/", bytes.NewBufferString("[1]")))
+			assert.Equal(t, http.StatusOK, rr.Code)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, maxRunning, "a pool with a single thread executes calls one after another")
+}
@@ -0,0 +1,50 @@
+package nra
+
+import (
+	"reflect"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// convertTime converts the generic value to time.Time or time.Duration
+// if target is one of them. A time can be passed as RFC3339 string or
+// as milliseconds since the epoch, a duration as string like "1m30s".
+// Durations passed as number are nanoseconds and need no conversion.
+func convertTime(value interface{}, target reflect.Type) (interface{}, bool, error) {
+	switch target {
+	case timeType:
+		switch v := value.(type) {
+		case string:
+			t, err := time.Parse(time.RFC3339Nano, v)
+			return t, true, err
+		case float64:
+			return time.Unix(0, int64(v*float64(time.Millisecond))), true, nil
+		}
+
+		if rv := reflect.ValueOf(value); rv.IsValid() && isNumber(rv.Kind()) {
+			ms := rv.Convert(reflect.TypeOf(int64(0))).Int()
+			return time.Unix(0, ms*int64(time.Millisecond)), true, nil
+		}
+	case durationType:
+		if v, ok := value.(string); ok {
+			d, err := time.ParseDuration(v)
+			return d, true, err
+		}
+	}
+	return nil, false, nil
+}
+
+// timeHook is a mapstructure decode hook that applies
+// convertTime to the fields of structs.
+func timeHook(from reflect.Type, to reflect.Type, data interface{}) (interface{}, error) {
+	if to != timeType && to != durationType {
+		return data, nil
+	}
+
+	value, ok, err := convertTime(data, to)
+	if !ok {
+		return data, nil
+	}
+	return value, err
+}
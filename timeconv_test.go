@@ -0,0 +1,62 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type timeEvent struct {
+	At      time.Time     `json:"at"`
+	Timeout time.Duration `json:"timeout"`
+	Until   *time.Time    `json:"until"`
+}
+
+func TestBindTime(t *testing.T) {
+	h := MustBind(func(at time.Time, timeout time.Duration, e timeEvent) ([]interface{}, error) {
+		return []interface{}{at.UTC(), timeout.String(), e.At.UTC(), e.Timeout.String(), e.Until.UTC()}, nil
+	})
+
+	for _, c := range []struct {
+		name     string
+		input    string
+		code     int
+		expected string
+	}{
+		{
+			name:     "strings",
+			input:    `["2021-03-04T05:06:07Z", "1m30s", {"at": "2021-03-04T06:06:07+01:00", "timeout": "250ms", "until": "2022-01-01T00:00:00.5Z"}]`,
+			code:     http.StatusOK,
+			expected: `["2021-03-04T05:06:07Z","1m30s","2021-03-04T05:06:07Z","250ms","2022-01-01T00:00:00.5Z"]`,
+		},
+		{
+			name:     "numbers",
+			input:    `[1614834367000, 1000000000, {"at": 1614834367500, "timeout": 5, "until": 0}]`,
+			code:     http.StatusOK,
+			expected: `["2021-03-04T05:06:07Z","1s","2021-03-04T05:06:07.5Z","5ns","1970-01-01T00:00:00Z"]`,
+		},
+		{
+			name:     "invalid_time",
+			input:    `["yesterday", "1s", {}]`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":{"message":"can't convert 1. argument to time.Time: parsing time \"yesterday\" as \"2006-01-02T15:04:05.999999999Z07:00\": cannot parse \"yesterday\" as \"2006\"","type":"conversion","argument":1,"received":"\"yesterday\"","expected":"time.Time","hint":"pass a RFC3339 string or the milliseconds since the epoch"}}`,
+		},
+		{
+			name:     "invalid_duration",
+			input:    `["2021-03-04T05:06:07Z", "soon", {}]`,
+			code:     http.StatusBadRequest,
+			expected: `{"error":{"message":"can't convert 2. argument to time.Duration: time: invalid duration \"soon\"","type":"conversion","argument":2,"received":"\"soon\"","expected":"time.Duration","hint":"pass a duration string like \"1m30s\" or the nanoseconds"}}`,
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(c.input)))
+			assert.Equal(t, c.code, rr.Code)
+			assert.JSONEq(t, c.expected, rr.Body.String())
+		})
+	}
+}
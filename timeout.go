@@ -0,0 +1,48 @@
+package nra
+
+import (
+	"time"
+)
+
+// WithTimeout limits how long a call of the function may take. Once
+// the timeout is exceeded the context passed to the function is
+// cancelled and the call is answered with status 504 and the type
+// ErrorTypeTimeout. The response is sent right away even if the
+// function ignores its context, in that case it keeps running in the
+// background and its result is discarded.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// timeoutError creates the error for a call that exceeded its timeout.
+func timeoutError(timeout time.Duration) *Error {
+	return &Error{Message: "call exceeded the timeout of " + timeout.String(), Type: ErrorTypeTimeout}
+}
+
+// runWithTimeout runs fn in a new goroutine and waits until it is done
+// or the timeout is exceeded. It reports if fn finished in time. A
+// panic of fn is passed on to the caller, as long as it happens in time.
+func runWithTimeout(timeout time.Duration, fn func()) bool {
+	done := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			done <- recover()
+		}()
+		fn()
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case p := <-done:
+		if p != nil {
+			panic(p)
+		}
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,73 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	tests := []struct {
+		Name     string
+		Function interface{}
+		Code     int
+		Expected string
+	}{
+		{
+			Name: "in_time",
+			Function: func(ctx context.Context) (string, error) {
+				return "ok", nil
+			},
+			Code:     http.StatusOK,
+			Expected: "\"ok\"\n",
+		},
+		{
+			Name: "context",
+			Function: func(ctx context.Context) (string, error) {
+				<-ctx.Done()
+				return "", ctx.Err()
+			},
+			Code:     http.StatusGatewayTimeout,
+			Expected: `{"error":{"message":"call exceeded the timeout of 20ms","type":"timeout"}}` + "\n",
+		},
+		{
+			Name: "ignored_context",
+			Function: func() (string, error) {
+				<-release
+				return "late", nil
+			},
+			Code:     http.StatusGatewayTimeout,
+			Expected: `{"error":{"message":"call exceeded the timeout of 20ms","type":"timeout"}}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			h := MustBind(test.Function, WithTimeout(20*time.Millisecond))
+
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("POST", "/", strings.NewReader("")))
+
+			assert.Equal(t, test.Code, rr.Code)
+			assert.Equal(t, test.Expected, rr.Body.String())
+		})
+	}
+}
+
+func TestWithTimeoutPanic(t *testing.T) {
+	h := MustBind(func() error {
+		panic("boom")
+	}, WithTimeout(time.Second))
+
+	assert.PanicsWithValue(t, "boom", func() {
+		h(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("")))
+	})
+}
@@ -0,0 +1,60 @@
+package nra
+
+import (
+	"errors"
+	"io"
+	"net/http"
+)
+
+// BodyTransform rewrites the raw body of a call before its arguments
+// are decoded, e.g. to decrypt or decompress it or to unwrap the
+// envelope of a caller that can't be changed. It returns the new body.
+// If the new body is in another format the transform can set the
+// Content-Type header of the request. A *Error is sent to the client
+// as it is, all other errors reject the call with status 400.
+type BodyTransform func(request *http.Request, body io.Reader) (io.Reader, error)
+
+// AddBodyTransform adds a transform that is applied to the body of
+// every call of the registry. Transforms run in the order they were
+// added, before the ones added to a function with WithBodyTransform.
+func (r *Registry) AddBodyTransform(t BodyTransform) {
+	r.transforms = append(r.transforms, t)
+}
+
+// WithBodyTransform applies the transforms to the body of every
+// call of the function, see BodyTransform.
+func WithBodyTransform(transforms ...BodyTransform) Option {
+	return func(o *options) {
+		o.transforms = append(o.transforms, transforms...)
+	}
+}
+
+// transformedBody is the result of the transforms,
+// closing it closes the original body.
+type transformedBody struct {
+	io.Reader
+	io.Closer
+}
+
+// transformBody applies the transforms to the body of the request.
+// Calls via GET don't have a body to transform.
+func transformBody(request *http.Request, transforms []BodyTransform) *Error {
+	if len(transforms) == 0 || request.Method == "GET" {
+		return nil
+	}
+
+	var body io.Reader = request.Body
+	for _, t := range transforms {
+		var err error
+		if body, err = t(request, body); err != nil {
+			var e *Error
+			if errors.As(err, &e) {
+				return e
+			}
+			return &Error{Message: "can't transform body: " + err.Error(), Type: ErrorTypeRequest}
+		}
+	}
+
+	request.Body = transformedBody{Reader: body, Closer: request.Body}
+	return nil
+}
@@ -0,0 +1,78 @@
+package nra
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBodyTransform(t *testing.T) {
+	gunzip := func(request *http.Request, body io.Reader) (io.Reader, error) {
+		if request.Header.Get("Content-Encoding") != "gzip" {
+			return body, nil
+		}
+		return gzip.NewReader(body)
+	}
+
+	// the vendor wraps the arguments as {"payload": [...]}.
+	unwrap := func(request *http.Request, body io.Reader) (io.Reader, error) {
+		var envelope struct {
+			Payload json.RawMessage `json:"payload"`
+		}
+		if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+			return nil, err
+		}
+		if envelope.Payload == nil {
+			return nil, &Error{Message: "missing payload", Type: ErrorTypeValidation}
+		}
+		return bytes.NewReader(envelope.Payload), nil
+	}
+
+	r := NewRegistry()
+	r.AddBodyTransform(gunzip)
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("vendor", func(a, b int) (int, error) { return a * b, nil }, WithBodyTransform(unwrap))
+	r.MustRegister("broken", func() error { return nil }, WithBodyTransform(func(*http.Request, io.Reader) (io.Reader, error) {
+		return nil, errors.New("bad key")
+	}))
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	_, _ = zw.Write([]byte(`{"payload": [3, 4]}`))
+	_ = zw.Close()
+
+	for _, test := range []struct {
+		Name     string
+		Path     string
+		Body     io.Reader
+		Gzip     bool
+		Code     int
+		Expected string
+	}{
+		{Name: "plain", Path: "/rpc/add", Body: strings.NewReader("[1, 2]"), Code: http.StatusOK, Expected: "3\n"},
+		{Name: "registry and function", Path: "/rpc/vendor", Body: &compressed, Gzip: true, Code: http.StatusOK, Expected: "12\n"},
+		{Name: "function", Path: "/rpc/vendor", Body: strings.NewReader(`{"payload": [2, 5]}`), Code: http.StatusOK, Expected: "10\n"},
+		{Name: "error", Path: "/rpc/vendor", Body: strings.NewReader(`{}`), Code: http.StatusBadRequest, Expected: `{"error":{"message":"missing payload","type":"validation"}}` + "\n"},
+		{Name: "failing", Path: "/rpc/broken", Body: strings.NewReader(`[]`), Code: http.StatusBadRequest, Expected: `{"error":{"message":"can't transform body: bad key","type":"request"}}` + "\n"},
+	} {
+		t.Run(test.Name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", test.Path, test.Body)
+			if test.Gzip {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, test.Code, rec.Code)
+			assert.Equal(t, test.Expected, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package nra
+
+import "net/http"
+
+// Bind0 is a typed version of MustBind for functions without
+// arguments. Because the signature is checked by the compiler it
+// only panics if the options or the struct tags of the types are
+// invalid, e.g. because of a default that can't be decoded.
+func Bind0[R any](fn func() (R, error), opts ...Option) http.HandlerFunc {
+	return MustBind(fn, opts...)
+}
+
+// Bind1 is a typed version of MustBind for functions with one argument.
+func Bind1[A, R any](fn func(A) (R, error), opts ...Option) http.HandlerFunc {
+	return MustBind(fn, opts...)
+}
+
+// Bind2 is a typed version of MustBind for functions with two arguments.
+func Bind2[A, B, R any](fn func(A, B) (R, error), opts ...Option) http.HandlerFunc {
+	return MustBind(fn, opts...)
+}
+
+// Bind3 is a typed version of MustBind for functions with three arguments.
+func Bind3[A, B, C, R any](fn func(A, B, C) (R, error), opts ...Option) http.HandlerFunc {
+	return MustBind(fn, opts...)
+}
+
+// Register0 is a typed version of Registry.Register for
+// functions without arguments.
+func Register0[R any](r *Registry, name string, fn func() (R, error), opts ...Option) error {
+	return r.Register(name, fn, opts...)
+}
+
+// Register1 is a typed version of Registry.Register for
+// functions with one argument.
+func Register1[A, R any](r *Registry, name string, fn func(A) (R, error), opts ...Option) error {
+	return r.Register(name, fn, opts...)
+}
+
+// Register2 is a typed version of Registry.Register for
+// functions with two arguments.
+func Register2[A, B, R any](r *Registry, name string, fn func(A, B) (R, error), opts ...Option) error {
+	return r.Register(name, fn, opts...)
+}
+
+// Register3 is a typed version of Registry.Register for
+// functions with three arguments.
+func Register3[A, B, C, R any](r *Registry, name string, fn func(A, B, C) (R, error), opts ...Option) error {
+	return r.Register(name, fn, opts...)
+}
@@ -0,0 +1,43 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypedBind(t *testing.T) {
+	for _, c := range []struct {
+		name     string
+		handler  http.HandlerFunc
+		input    string
+		expected string
+	}{
+		{"bind0", Bind0(func() (string, error) { return "ok", nil }), "", "\"ok\"\n"},
+		{"bind1", Bind1(func(a int) (int, error) { return a * 2, nil }), "[2]", "4\n"},
+		{"bind2", Bind2(func(a, b int) (int, error) { return a + b, nil }), "[1, 2]", "3\n"},
+		{"bind3", Bind3(func(a, b int, sep string) (string, error) { return strings.Repeat(sep, a+b), nil }), "[1, 2, \"-\"]", "\"---\"\n"},
+		{"bind1_context", Bind1(func(ctx context.Context) (bool, error) { return ctx != nil, nil }), "", "true\n"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			c.handler.ServeHTTP(rr, httptest.NewRequest("POST", "/", strings.NewReader(c.input)))
+			assert.Equal(t, http.StatusOK, rr.Code)
+			assert.Equal(t, c.expected, rr.Body.String())
+		})
+	}
+}
+
+func TestTypedRegister(t *testing.T) {
+	r := NewRegistry()
+	assert.NoError(t, Register0(r, "now", func() (int, error) { return 0, nil }))
+	assert.NoError(t, Register1(r, "double", func(a int) (int, error) { return a * 2, nil }))
+	assert.NoError(t, Register2(r, "add", func(a, b int) (int, error) { return a + b, nil }))
+	assert.NoError(t, Register3(r, "clamp", func(v, min, max int) (int, error) { return v, nil }))
+	assert.Error(t, Register1(r, "double", func(a int) (int, error) { return a, nil }))
+	assert.Equal(t, []string{"add", "clamp", "double", "now"}, r.Names())
+}
@@ -0,0 +1,663 @@
+package nra
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// tsRuntime is the part of the generated TypeScript client
+// that doesn't depend on the registered functions.
+const tsRuntime = `// Code generated by nra. DO NOT EDIT.
+
+export interface NraErrorData {
+  message: string;
+  type: string;
+  argument?: number;
+  received?: string;
+  expected?: string;
+  hint?: string;
+  fields?: Record<string, string>;
+  suggestions?: string[];
+  version?: string;
+  request_id?: string;
+}
+
+// NraError is thrown by all calls that failed. The subclasses
+// group the error types, so applications can catch them with
+// instanceof, e.g. if (e instanceof ValidationError).
+export class NraError extends Error implements NraErrorData {
+  type: string;
+  argument?: number;
+  received?: string;
+  expected?: string;
+  hint?: string;
+  fields?: Record<string, string>;
+  suggestions?: string[];
+  version?: string;
+  request_id?: string;
+
+  // status is the HTTP status of the response, or 0 if
+  // there was none. data is the error as it was sent.
+  status: number;
+  data: NraErrorData;
+
+  constructor(data: NraErrorData, status = 0) {
+    super(data.message);
+    Object.setPrototypeOf(this, new.target.prototype);
+    this.name = new.target.name;
+    this.type = data.type;
+    this.argument = data.argument;
+    this.received = data.received;
+    this.expected = data.expected;
+    this.hint = data.hint;
+    this.fields = data.fields;
+    this.suggestions = data.suggestions;
+    this.version = data.version;
+    this.request_id = data.request_id;
+    this.status = status;
+    this.data = data;
+  }
+}
+
+// ValidationError is thrown for arguments that are
+// missing, of the wrong type or failed validation.
+export class ValidationError extends NraError {}
+
+// AuthError is thrown if the caller isn't
+// authenticated or not allowed to call.
+export class AuthError extends NraError {}
+
+// NotFoundError is thrown for unknown functions or
+// if a function reports that something doesn't exist.
+export class NotFoundError extends NraError {}
+
+// ConflictError is thrown if a versioned entity was changed by
+// someone else, version is its current version.
+export class ConflictError extends NraError {
+  version: string;
+
+  constructor(data: NraErrorData, status = 0) {
+    super(data, status);
+    this.version = data.version || '';
+  }
+}
+
+// InternalError is thrown if the server failed.
+export class InternalError extends NraError {}
+
+function toError(data: NraErrorData, status: number): NraError {
+  switch (data.type) {
+    case 'validation':
+    case 'conversion':
+      return new ValidationError(data, status);
+    case 'unauthorized':
+    case 'forbidden':
+      return new AuthError(data, status);
+    case 'not_found':
+      return new NotFoundError(data, status);
+    case 'conflict':
+      return new ConflictError(data, status);
+    case 'internal':
+      return new InternalError(data, status);
+  }
+  return new NraError(data, status);
+}
+
+export interface BulkItem<T> {
+  index: number;
+  result?: T;
+  error?: NraErrorData;
+}
+
+export interface BulkResult<T> {
+  items: Array<BulkItem<T>>;
+  succeeded: number;
+  failed: number;
+}
+
+// isConflictError also detects conflicts in the items of bulk results.
+export function isConflictError(e: unknown): e is ConflictError | (NraErrorData & { type: 'conflict'; version: string }) {
+  return e instanceof ConflictError || (typeof e === 'object' && e !== null && (e as NraErrorData).type === 'conflict');
+}
+
+export const config = {
+  baseURL: %q,
+  retries: 3,
+  retryDelay: 100,
+  headers: {} as Record<string, string>,
+  csrfHeader: %q,
+  csrfCookie: %q,
+};
+
+function csrfToken(): string {
+  const prefix = config.csrfCookie + '=';
+  const cookies = config.csrfCookie && typeof document !== 'undefined' ? document.cookie.split('; ') : [];
+  const cookie = cookies.find((c) => c.startsWith(prefix));
+  return cookie ? decodeURIComponent(cookie.slice(prefix.length)) : '1';
+}
+
+function sleep(ms: number): Promise<void> {
+  return new Promise((resolve) => setTimeout(resolve, ms));
+}
+
+let fields: string[] | null = null;
+
+// withFields makes the calls started in fn only return the selected
+// fields of their result, e.g. ['id', 'name', 'address.city']. All
+// other fields are missing even though the types declare them.
+export function withFields<T>(selected: string[], fn: () => Promise<T>): Promise<T> {
+  fields = selected;
+  try {
+    return fn();
+  } finally {
+    fields = null;
+  }
+}
+
+let idempotencyKey: string | null = null;
+
+// withIdempotencyKey makes the calls started in fn use key instead of
+// a new idempotency key, e.g. one created when a form is shown, so
+// submitting it twice only runs the function once.
+export function withIdempotencyKey<T>(key: string, fn: () => Promise<T>): Promise<T> {
+  idempotencyKey = key;
+  try {
+    return fn();
+  } finally {
+    idempotencyKey = null;
+  }
+}
+
+function newIdempotencyKey(): string {
+  if (typeof crypto !== 'undefined' && crypto.randomUUID) {
+    return crypto.randomUUID();
+  }
+  return Date.now().toString(36) + '-' + Math.random().toString(36).slice(2) + Math.random().toString(36).slice(2);
+}
+
+// encryptedFields are the paths of the struct fields tagged with
+// encrypt:"true" by function, starting with the index of the argument.
+const encryptedFields: Record<string, Array<Array<number | string>>> = %s;
+
+let publicKey: Promise<CryptoKey> | null = null;
+
+function base64(buffer: ArrayBuffer | Uint8Array): string {
+  const bytes = new Uint8Array(buffer);
+  let s = '';
+  for (let i = 0; i < bytes.length; i++) {
+    s += String.fromCharCode(bytes[i]);
+  }
+  return btoa(s);
+}
+
+// importPublicKey fetches the public key served by the registry.
+function importPublicKey(): Promise<CryptoKey> {
+  if (!publicKey) {
+    publicKey = fetch(config.baseURL + '_key').then(async (response) => {
+      const { key } = (await response.json()) as { key: string };
+      const der = Uint8Array.from(atob(key), (c) => c.charCodeAt(0));
+      return crypto.subtle.importKey('spki', der, { name: 'RSA-OAEP', hash: 'SHA-256' }, false, ['encrypt']);
+    });
+    // a failed fetch is tried again by the next call.
+    publicKey.catch(() => {
+      publicKey = null;
+    });
+  }
+  return publicKey;
+}
+
+// encryptValue encrypts the JSON of value with a new AES-GCM key,
+// which is encrypted with the public key of the registry.
+async function encryptValue(value: unknown): Promise<string> {
+  const aes = await crypto.subtle.generateKey({ name: 'AES-GCM', length: 256 }, true, ['encrypt']);
+  const iv = crypto.getRandomValues(new Uint8Array(12));
+  const data = new TextEncoder().encode(JSON.stringify(value));
+  const encryptedKey = await crypto.subtle.encrypt({ name: 'RSA-OAEP' }, await importPublicKey(), await crypto.subtle.exportKey('raw', aes));
+  const ciphertext = await crypto.subtle.encrypt({ name: 'AES-GCM', iv }, aes, data);
+  return 'enc1:' + base64(encryptedKey) + ':' + base64(iv) + ':' + base64(ciphertext);
+}
+
+// encryptAt encrypts the value at path[i:] inside of value. Objects and
+// arrays on the way are copied, so the arguments of the caller stay untouched.
+async function encryptAt(value: unknown, path: Array<number | string>, i: number): Promise<unknown> {
+  if (value === null || value === undefined) {
+    return value;
+  }
+  if (i === path.length) {
+    return encryptValue(value);
+  }
+  if (typeof value !== 'object') {
+    return value;
+  }
+
+  const copy = (Array.isArray(value) ? [...value] : { ...value }) as Record<string, unknown>;
+  const field = String(path[i]);
+  const keys = field === '*' ? Object.keys(copy) : field in copy ? [field] : [];
+  await Promise.all(keys.map(async (k) => {
+    copy[k] = await encryptAt(copy[k], path, i + 1);
+  }));
+  return copy;
+}
+
+async function call<T>(name: string, args: unknown[], idempotent = false, keyed = false): Promise<T> {
+  const query = fields ? '?_fields=' + encodeURIComponent(fields.join(',')) : '';
+
+  // calls with a idempotency key run at most once on the
+  // server, so they are retried with the same key.
+  const key = keyed ? idempotencyKey || newIdempotencyKey() : null;
+
+  // the fields are encrypted once, so retries send the same body.
+  for (const path of encryptedFields[name] || []) {
+    args = (await encryptAt(args, path, 0)) as unknown[];
+  }
+  for (let attempt = 0; ; attempt++) {
+    // only idempotent calls are retried, as all others
+    // might already have had an effect on the server.
+    const retry = (idempotent || keyed) && attempt < config.retries;
+
+    let response: Response;
+    try {
+      const headers: Record<string, string> = { ...config.headers, 'Content-Type': 'application/json' };
+      if (config.csrfHeader) {
+        headers[config.csrfHeader] = csrfToken();
+      }
+      if (key) {
+        headers['Idempotency-Key'] = key;
+      }
+
+      response = await fetch(config.baseURL + name + query, {
+        method: 'POST',
+        headers,
+        body: JSON.stringify(args),
+      });
+    } catch (e) {
+      if (retry) {
+        await sleep(config.retryDelay * Math.pow(2, attempt));
+        continue;
+      }
+      throw new NraError({ message: String(e), type: 'request' });
+    }
+
+    const busy = key !== null && response.status === 409 && response.headers.has('Retry-After');
+    if ((response.status === 503 || busy) && retry) {
+      await sleep(config.retryDelay * Math.pow(2, attempt));
+      continue;
+    }
+
+    const text = await response.text();
+    if (!response.ok) {
+      let data: NraErrorData;
+      try {
+        data = (JSON.parse(text) as { error: NraErrorData }).error;
+      } catch (e) {
+        data = { message: text, type: 'request' };
+      }
+      throw toError(data, response.status);
+    }
+    return (text.length > 0 ? JSON.parse(text) : undefined) as T;
+  }
+}
+`
+
+// GenerateTypeScript writes a typed TypeScript client for all
+// functions of the registry to w. Every function becomes an
+// exported function of the same name that returns a Promise,
+// structs become interfaces named after the Go type.
+//
+// Because Go doesn't keep the names of function parameters the
+// parameters will be named arg1, arg2 and so on. Trailing pointer
+// parameters are optional and variadic parameters become rest
+// parameters.
+//
+// Headers set in config.headers, e.g. a bearer token, are sent
+// with every call. withFields selects the fields of the results
+// of the calls it wraps, see FieldsName.
+//
+// Failed calls throw a NraError, or the subclass matching the type
+// of the error: ValidationError, AuthError, NotFoundError,
+// ConflictError or InternalError.
+//
+// Struct fields tagged with encrypt:"true" are encrypted with the
+// public key served under EncryptionKeyName before they are sent,
+// see Registry.SetEncryptionKeys.
+//
+// It fails if a function would be named like a name of the runtime,
+// e.g. call or config, or like another function, e.g. "users.get"
+// and "users_get".
+func GenerateTypeScript(w io.Writer, r *Registry, opts ...TypeScriptOption) error {
+	var o tsOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	g := &tsGenerator{
+		registry: r,
+		names:    map[reflect.Type]string{},
+		used:     map[string]bool{},
+	}
+
+	runtime := tsRuntimeNames
+	if o.forms {
+		runtime = append(append([]string(nil), runtime...), "Form", "FormField", "forms")
+	}
+	for _, name := range runtime {
+		g.used[name] = true
+	}
+
+	// functions can't replace the runtime or each other, interfaces
+	// are renamed around them.
+	names, functions := r.snapshot()
+	declared := map[string]string{}
+	for _, name := range names {
+		ident := tsIdentifier(name)
+		if g.used[ident] {
+			if other, ok := declared[ident]; ok {
+				return fmt.Errorf("functions '%s' and '%s' are both named %s in the TypeScript client", other, name, ident)
+			}
+			return fmt.Errorf("function '%s' can't be named %s in the TypeScript client, it is used by the runtime", name, ident)
+		}
+		g.used[ident] = true
+		declared[ident] = name
+	}
+
+	var funcs bytes.Buffer
+	encrypted := map[string][][]interface{}{}
+	forms := map[string]form{}
+	for _, name := range names {
+		b := functions[name]
+		if len(b.encrypted) > 0 {
+			encrypted[name] = clientPaths(b.encrypted)
+		}
+		if o.forms && !b.options.safe {
+			if f, ok := formOf(name, b); ok {
+				forms[name] = f
+			}
+		}
+
+		var params, args []string
+		for i, p := range b.params {
+			arg := fmt.Sprintf("arg%d", i+1)
+			switch {
+			case b.variadic && i == len(b.params)-1:
+				params = append(params, "..."+arg+": Array<"+g.typeOf(p.Elem())+">")
+				args = append(args, "..."+arg)
+			case b.optional(i):
+				params = append(params, arg+"?: "+g.typeOf(p))
+				args = append(args, arg)
+			default:
+				params = append(params, arg+": "+g.typeOf(p))
+				args = append(args, arg)
+			}
+		}
+
+		result := "void"
+		if b.result != nil {
+			result = g.typeOf(b.result)
+		}
+
+		idempotent := ""
+		switch {
+		case b.options.idempotencyKey:
+			idempotent = fmt.Sprintf(", %t, true", b.options.idempotent)
+		case b.options.idempotent:
+			idempotent = ", true"
+		}
+
+		_, _ = fmt.Fprintf(&funcs, "\nexport function %s(%s): Promise<%s> {\n", tsIdentifier(name), strings.Join(params, ", "), result)
+		_, _ = fmt.Fprintf(&funcs, "  return call<%s>(%q, [%s]%s);\n}\n", result, name, strings.Join(args, ", "), idempotent)
+	}
+
+	var csrf CSRF
+	if r.csrf != nil {
+		csrf = *r.csrf
+	}
+
+	fields, err := json.Marshal(encrypted)
+	if err != nil {
+		return err
+	}
+
+	if _, err := fmt.Fprintf(w, tsRuntime, r.PublicPrefix(), csrf.Header, csrf.Cookie, fields); err != nil {
+		return err
+	}
+
+	for _, decl := range g.decls {
+		if _, err := io.WriteString(w, "\n"+decl); err != nil {
+			return err
+		}
+	}
+
+	if _, err := funcs.WriteTo(w); err != nil {
+		return err
+	}
+
+	if !o.forms {
+		return nil
+	}
+	data, err := json.MarshalIndent(forms, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, tsForms, data)
+	return err
+}
+
+// TypeScriptOption configures GenerateTypeScript.
+type TypeScriptOption func(*tsOptions)
+
+// tsOptions are the settings of GenerateTypeScript.
+type tsOptions struct {
+	forms bool
+}
+
+// WithTypeScriptForms makes GenerateTypeScript also export forms, a
+// descriptor of the form to call each function with that isn't bound
+// WithSafe, so admin interfaces can render create and edit forms
+// without writing them by hand. Each argument is a field, structs are
+// groups of fields. Labels and rules come from the struct tags:
+//
+//	type User struct {
+//	  Name string `json:"name" label:"Full name"`
+//	  Role string `json:"role" form:"options=admin|editor"`
+//	  Age  int    `json:"age" form:"min=18,max=130"`
+//	}
+//
+// The rules are only hints for the form, the function still has to
+// check its arguments.
+func WithTypeScriptForms() TypeScriptOption {
+	return func(o *tsOptions) {
+		o.forms = true
+	}
+}
+
+// tsForms declares the form descriptors, see WithTypeScriptForms.
+const tsForms = `
+export interface FormField {
+  name: string;
+  label: string;
+  type: "text" | "password" | "select" | "integer" | "number" | "checkbox" | "datetime" | "duration" | "file" | "list" | "map" | "group" | "json";
+  required: boolean;
+  default?: unknown;
+  min?: number;
+  max?: number;
+  pattern?: string;
+  options?: Array<string>;
+  fields?: Array<FormField>;
+  item?: FormField;
+}
+
+export interface Form {
+  function: string;
+  fields: Array<FormField>;
+}
+
+export const forms: Record<string, Form> = %s;
+`
+
+// tsGenerator converts Go types to TypeScript types and
+// collects the interface declarations for named structs.
+type tsGenerator struct {
+	registry *Registry
+	names    map[reflect.Type]string
+	used     map[string]bool
+	decls    []string
+}
+
+// typeOf returns the TypeScript type that matches the
+// JSON encoding of t.
+func (g *tsGenerator) typeOf(t reflect.Type) string {
+	if t == timeType {
+		return "string"
+	}
+
+	// uploads are streamed in their own format, which
+	// the client can't send, see Upload.
+	if isUpload(t) {
+		return "never"
+	}
+
+	if isBulk(t) && t.Kind() == reflect.Struct {
+		return "BulkResult<" + g.typeOf(bulkItemResult(t)) + ">"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Ptr:
+		return g.typeOf(t.Elem()) + " | null"
+	case reflect.Slice:
+		// []byte is encoded as base64 string.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string"
+		}
+		return "Array<" + g.typeOf(t.Elem()) + ">"
+	case reflect.Array:
+		return "Array<" + g.typeOf(t.Elem()) + ">"
+	case reflect.Map:
+		return "Record<string, " + g.typeOf(t.Elem()) + ">"
+	case reflect.Struct:
+		if g.registry.typeName(t) == "" {
+			return g.objectOf(t, "")
+		}
+		return g.interfaceOf(t)
+	}
+	return "any"
+}
+
+// interfaceOf declares a interface for the named struct
+// t (if not already done) and returns its name.
+func (g *tsGenerator) interfaceOf(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := tsIdentifier(g.registry.typeName(t))
+	for i := 2; g.used[name]; i++ {
+		name = fmt.Sprintf("%s%d", tsIdentifier(g.registry.typeName(t)), i)
+	}
+	g.names[t] = name
+	g.used[name] = true
+
+	// reserve the slot before generating the fields so
+	// nested types are declared after their parent.
+	index := len(g.decls)
+	g.decls = append(g.decls, "")
+	body := g.objectOf(t, "")
+	g.decls[index] = "export interface " + name + " " + body + "\n"
+
+	return name
+}
+
+// objectOf returns the TypeScript object type of the struct t.
+func (g *tsGenerator) objectOf(t reflect.Type, indent string) string {
+	var buf strings.Builder
+	buf.WriteString("{\n")
+	for _, f := range jsonFields(t) {
+		optional := ""
+		if f.omitEmpty {
+			optional = "?"
+		}
+
+		if value, ok, err := defaultOf(f.field); ok && err == nil {
+			data, _ := json.Marshal(value)
+			_, _ = fmt.Fprintf(&buf, "%s  /** @default %s */\n", indent, data)
+		}
+
+		typ := f.typ
+		if typ.Kind() == reflect.Struct && g.registry.typeName(typ) == "" {
+			_, _ = fmt.Fprintf(&buf, "%s  %s%s: %s;\n", indent, tsPropertyName(f.name), optional, g.objectOf(typ, indent+"  "))
+			continue
+		}
+		_, _ = fmt.Fprintf(&buf, "%s  %s%s: %s;\n", indent, tsPropertyName(f.name), optional, g.typeOf(typ))
+	}
+	buf.WriteString(indent + "}")
+	return buf.String()
+}
+
+// tsRuntimeNames are the top level names declared by tsRuntime.
+var tsRuntimeNames = []string{
+	"NraErrorData", "NraError", "ValidationError", "AuthError", "NotFoundError", "ConflictError", "InternalError", "toError",
+	"BulkItem", "BulkResult", "isConflictError", "config", "csrfToken", "sleep", "fields", "withFields", "idempotencyKey",
+	"withIdempotencyKey", "newIdempotencyKey", "encryptedFields", "publicKey", "base64", "importPublicKey", "encryptValue",
+	"encryptAt", "call",
+}
+
+// tsReservedWords can't be used as the name of a function or interface,
+// in strict mode or as a type.
+var tsReservedWords = map[string]bool{
+	"break": true, "case": true, "catch": true, "class": true, "const": true, "continue": true, "debugger": true,
+	"default": true, "delete": true, "do": true, "else": true, "enum": true, "export": true, "extends": true,
+	"false": true, "finally": true, "for": true, "function": true, "if": true, "import": true, "in": true,
+	"instanceof": true, "new": true, "null": true, "return": true, "super": true, "switch": true, "this": true,
+	"throw": true, "true": true, "try": true, "typeof": true, "var": true, "void": true, "while": true, "with": true,
+	"implements": true, "interface": true, "let": true, "package": true, "private": true, "protected": true,
+	"public": true, "static": true, "yield": true, "await": true, "arguments": true, "eval": true,
+	"any": true, "boolean": true, "number": true, "string": true, "symbol": true, "bigint": true, "object": true,
+	"never": true, "unknown": true, "undefined": true,
+}
+
+// tsIdentifier turns name into a valid TypeScript identifier by
+// replacing all characters that are not allowed with a underscore.
+// Reserved words get a underscore appended.
+func tsIdentifier(name string) string {
+	ident := tsSanitize(name)
+	if tsReservedWords[ident] {
+		return ident + "_"
+	}
+	return ident
+}
+
+// tsSanitize replaces all characters of name that
+// are not allowed in a identifier with a underscore.
+func tsSanitize(name string) string {
+	var buf strings.Builder
+	for i, c := range name {
+		if c == '_' || c == '$' || unicode.IsLetter(c) || (i > 0 && unicode.IsDigit(c)) {
+			buf.WriteRune(c)
+		} else {
+			buf.WriteRune('_')
+		}
+	}
+	return buf.String()
+}
+
+// tsPropertyName quotes the property name if it
+// isn't a valid identifier. Reserved words are
+// valid property names.
+func tsPropertyName(name string) string {
+	if tsSanitize(name) == name {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
@@ -0,0 +1,127 @@
+package nra
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type tsAddress struct {
+	City string `json:"city"`
+}
+
+type tsBase struct {
+	ID int `json:"id"`
+}
+
+type tsUser struct {
+	tsBase
+	Name      string         `json:"name"`
+	Email     *string        `json:"email,omitempty"`
+	Tags      []string       `json:"tags"`
+	Address   tsAddress      `json:"address"`
+	Meta      map[string]int `json:"meta"`
+	Created   time.Time      `json:"created"`
+	Avatar    []byte         `json:"avatar"`
+	Ignored   string         `json:"-"`
+	hidden    string
+	Anonymous struct{ A bool } `json:"anonymous"`
+}
+
+func TestGenerateTypeScript(t *testing.T) {
+	r := NewRegistry()
+	r.MustRegister("get_user", func(id int) (*tsUser, error) { return nil, nil }, WithIdempotent())
+	r.MustRegister("users.save", func(u tsUser, notify bool) error { return nil })
+	r.MustRegister("join", func(sep string, limit *int, items ...string) (string, error) { return "", nil })
+
+	var buf bytes.Buffer
+	if !assert.NoError(t, GenerateTypeScript(&buf, r)) {
+		return
+	}
+
+	ts := buf.String()
+	assert.True(t, strings.HasPrefix(ts, "// Code generated by nra. DO NOT EDIT."))
+	assert.Contains(t, ts, `export interface tsUser {
+  id: number;
+  name: string;
+  email?: string | null;
+  tags: Array<string>;
+  address: tsAddress;
+  meta: Record<string, number>;
+  created: string;
+  avatar: string;
+  anonymous: {
+    A: boolean;
+  };
+}
+`)
+	assert.Contains(t, ts, `export interface tsAddress {
+  city: string;
+}
+`)
+	assert.Contains(t, ts, `export function get_user(arg1: number): Promise<tsUser | null> {
+  return call<tsUser | null>("get_user", [arg1], true);
+}
+`)
+	assert.Contains(t, ts, `export function users_save(arg1: tsUser, arg2: boolean): Promise<void> {
+  return call<void>("users.save", [arg1, arg2]);
+}
+`)
+	assert.Contains(t, ts, `export function join(arg1: string, arg2?: number | null, ...arg3: Array<string>): Promise<string> {
+  return call<string>("join", [arg1, arg2, ...arg3]);
+}
+`)
+	assert.Equal(t, 1, strings.Count(ts, "export interface tsUser "))
+	assert.Contains(t, ts, "export class NraError extends Error implements NraErrorData {")
+	for _, class := range []string{"ValidationError", "AuthError", "NotFoundError", "ConflictError", "InternalError"} {
+		assert.Contains(t, ts, "export class "+class+" extends NraError {")
+	}
+	assert.Contains(t, ts, "case 'forbidden':\n      return new AuthError(data, status);")
+	assert.Contains(t, ts, "throw toError(data, response.status);")
+	assert.Contains(t, ts, "export function isConflictError(e: unknown): e is ConflictError |")
+}
+
+func TestGenerateTypeScriptNameCollisions(t *testing.T) {
+	for _, name := range []string{"call", "config", "withFields", "NraError"} {
+		r := NewRegistry()
+		r.MustRegister(name, func() error { return nil })
+
+		err := GenerateTypeScript(&bytes.Buffer{}, r)
+		if assert.Error(t, err, name) {
+			assert.Contains(t, err.Error(), "it is used by the runtime")
+		}
+	}
+
+	r := NewRegistry()
+	r.MustRegister("users.get", func() error { return nil })
+	r.MustRegister("users_get", func() error { return nil })
+	assert.EqualError(t, GenerateTypeScript(&bytes.Buffer{}, r), "functions 'users.get' and 'users_get' are both named users_get in the TypeScript client")
+
+	// interfaces are renamed around the functions.
+	r = NewRegistry()
+	r.MustRegister("tsAddress", func() (tsAddress, error) { return tsAddress{}, nil })
+
+	var buf bytes.Buffer
+	if assert.NoError(t, GenerateTypeScript(&buf, r)) {
+		assert.Contains(t, buf.String(), "export interface tsAddress2 {")
+		assert.Contains(t, buf.String(), "export function tsAddress(): Promise<tsAddress2> {")
+	}
+
+	// reserved words are escaped, the call keeps the name.
+	type class struct {
+		Default int `json:"default"`
+	}
+	r = NewRegistry()
+	r.MustRegister("delete", func() (class, error) { return class{}, nil })
+	r.MustRegister("new", func() error { return nil })
+
+	buf.Reset()
+	if assert.NoError(t, GenerateTypeScript(&buf, r)) {
+		assert.Contains(t, buf.String(), "export interface class_ {\n  default: number;\n}")
+		assert.Contains(t, buf.String(), "export function delete_(): Promise<class_> {\n  return call<class_>(\"delete\", []);")
+		assert.Contains(t, buf.String(), "export function new_(): Promise<void> {\n  return call<void>(\"new\", []);")
+	}
+}
@@ -0,0 +1,295 @@
+package nra
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// UploadContentType is the Content-Type of calls to
+// functions that take a *Upload, see Upload.
+const UploadContentType = "application/x-nra-upload"
+
+// MaxChunkSize is the largest size in bytes the data of a single
+// chunk of a Upload can have. Larger data has to be split up.
+const MaxChunkSize = 16 << 20
+
+// maxChunkHeaderSize is the largest size in bytes
+// the header line of a chunk can have.
+const maxChunkHeaderSize = 64 << 10
+
+// errChunkHeaderSize is returned by readChunkHeader if the
+// header line is longer than maxChunkHeaderSize.
+var errChunkHeaderSize = errors.New("chunk header too large")
+
+// Upload is a argument that is streamed in chunks instead of being
+// sent at once, e.g. for large files or telemetry. Every chunk carries
+// metadata of type M, which is decoded and checked the same way as the
+// arguments of a call. A function taking a *Upload can't have any other
+// arguments besides the ones passed by the call, like a context:
+//
+//	type Batch struct {
+//	  Device string    `json:"device"`
+//	  From   time.Time `json:"from"`
+//	}
+//
+//	r.MustRegister("ingest", func(ctx context.Context, u *nra.Upload[Batch]) (int, error) {
+//	  n := 0
+//	  for u.Next() {
+//	    c := u.Chunk()
+//	    n += store(c.Meta.Device, c.Meta.From, c.Data)
+//	  }
+//	  return n, u.Err()
+//	})
+//
+// The body of the call has the Content-Type UploadContentType and
+// contains the chunks one after another. Each chunk is a line with its
+// header as JSON, followed by its data:
+//
+//	{"seq":0,"size":5,"checksum":907060870,"meta":{"device":"a"}}\n
+//	hello
+//
+// The sequence numbers start at 0 and have no gaps, the checksum is
+// the CRC-32 (IEEE) of the data. UploadWriter writes uploads in this
+// format. Chunks are only read while the function asks for them, so
+// the upload never has to be kept in memory as a whole. For the same
+// reason it can't be used with WithIdempotencyKey, and uploads aren't
+// recorded by Registry.SetRecording.
+type Upload[M any] struct {
+	reader *bufio.Reader
+	decode argDecoder
+	seq    int
+	chunk  Chunk[M]
+	err    error
+}
+
+// Chunk is a single chunk of a Upload.
+type Chunk[M any] struct {
+	Seq  int
+	Meta M
+	Data []byte
+}
+
+// chunkHeader is the line in front of the data of each chunk.
+type chunkHeader struct {
+	Seq      int             `json:"seq"`
+	Size     int             `json:"size"`
+	Checksum uint32          `json:"checksum"`
+	Meta     json.RawMessage `json:"meta,omitempty"`
+}
+
+// uploadParam is implemented by all *Upload types, so
+// bindings can find them without knowing the metadata.
+type uploadParam interface {
+	start(r io.Reader)
+}
+
+// uploadParamType is the type all upload parameters implement.
+var uploadParamType = reflect.TypeOf((*uploadParam)(nil)).Elem()
+
+// isUpload reports if a parameter of type t is a *Upload.
+func isUpload(t reflect.Type) bool {
+	return t.Kind() == reflect.Ptr && t.Implements(uploadParamType)
+}
+
+// start makes the upload read its chunks from r.
+func (u *Upload[M]) start(r io.Reader) {
+	u.reader = bufio.NewReader(r)
+	u.decode = decoderOf(reflect.TypeOf((*M)(nil)).Elem())
+}
+
+// Next reads the next chunk and reports if there was one. It returns
+// false at the end of the upload or if the chunk is invalid, in which
+// case Err returns why.
+func (u *Upload[M]) Next() bool {
+	if u.err != nil || u.reader == nil {
+		return false
+	}
+
+	chunk, err := u.read()
+	if err != nil {
+		if err != io.EOF {
+			u.err = err
+		}
+		u.chunk = Chunk[M]{}
+		return false
+	}
+	u.chunk = chunk
+	u.seq++
+	return true
+}
+
+// Chunk returns the chunk read by the last call to Next.
+func (u *Upload[M]) Chunk() Chunk[M] {
+	return u.chunk
+}
+
+// Err returns why the upload ended early, or nil if all chunks were
+// read. Errors are *Error, so a function can return them as they are.
+func (u *Upload[M]) Err() error {
+	return u.err
+}
+
+// read reads and checks the next chunk. It returns io.EOF if
+// the upload ended cleanly after the previous chunk.
+func (u *Upload[M]) read() (Chunk[M], error) {
+	line, err := readChunkHeader(u.reader)
+	if err == errChunkHeaderSize {
+		return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: header exceeds %d bytes", u.seq, maxChunkHeaderSize), Type: ErrorTypeLimit}
+	}
+	if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+		return Chunk[M]{}, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return Chunk[M]{}, u.readError(err)
+	}
+
+	var header chunkHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: invalid header: %v", u.seq, err), Type: ErrorTypeRequest}
+	}
+	if header.Seq != u.seq {
+		return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: got sequence number %d", u.seq, header.Seq), Type: ErrorTypeValidation}
+	}
+	if header.Size < 0 || header.Size > MaxChunkSize {
+		return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: size has to be between 0 and %d bytes", u.seq, MaxChunkSize), Type: ErrorTypeLimit}
+	}
+
+	// the buffer grows with the data that actually arrives,
+	// the declared size alone doesn't allocate anything.
+	data := bytes.NewBuffer([]byte{})
+	n, err := io.Copy(data, io.LimitReader(u.reader, int64(header.Size)))
+	if err != nil {
+		return Chunk[M]{}, u.readError(err)
+	}
+	if n < int64(header.Size) {
+		return Chunk[M]{}, u.readError(io.ErrUnexpectedEOF)
+	}
+
+	chunk := Chunk[M]{Seq: header.Seq, Data: data.Bytes()}
+	if crc32.ChecksumIEEE(chunk.Data) != header.Checksum {
+		return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: checksum mismatch", u.seq), Type: ErrorTypeValidation}
+	}
+
+	// chunks without metadata get the zero value.
+	if len(header.Meta) > 0 {
+		var meta interface{}
+		if err := json.Unmarshal(header.Meta, &meta); err != nil {
+			return Chunk[M]{}, &Error{Message: fmt.Sprintf("chunk %d: invalid metadata: %v", u.seq, err), Type: ErrorTypeRequest}
+		}
+
+		value, e := u.decode(1, meta)
+		if e != nil {
+			e.Message = fmt.Sprintf("chunk %d: %s", u.seq, e.Message)
+			return Chunk[M]{}, e
+		}
+		chunk.Meta = value.Interface().(M)
+	}
+	return chunk, nil
+}
+
+// readChunkHeader reads the header line of a chunk, including the
+// newline. It fails with errChunkHeaderSize instead of reading lines
+// longer than maxChunkHeaderSize.
+func readChunkHeader(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		part, err := r.ReadSlice('\n')
+		if len(line)+len(part) > maxChunkHeaderSize {
+			return nil, errChunkHeaderSize
+		}
+		line = append(line, part...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+}
+
+// readError converts a error while reading the body.
+func (u *Upload[M]) readError(err error) *Error {
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		return &Error{Message: "request body exceeds the limit of " + strconv.FormatInt(tooLarge.Limit, 10) + " bytes", Type: ErrorTypeLimit}
+	case errors.Is(err, errMemoryBudget):
+		return &Error{Message: err.Error(), Type: ErrorTypeLimit}
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		return &Error{Message: fmt.Sprintf("chunk %d: upload ended in the middle of the chunk", u.seq), Type: ErrorTypeRequest}
+	}
+	return &Error{Message: fmt.Sprintf("chunk %d: %v", u.seq, err), Type: ErrorTypeRequest}
+}
+
+// startUpload creates the *Upload argument of the
+// binding, which reads its chunks from the body.
+func (b *binding) startUpload(request *http.Request) ([]interface{}, error) {
+	if mediaType, _, _ := mime.ParseMediaType(request.Header.Get("Content-Type")); mediaType != UploadContentType {
+		return nil, errors.New("uploads have to be sent as " + UploadContentType)
+	}
+
+	u := reflect.New(b.params[0].Elem())
+	u.Interface().(uploadParam).start(request.Body)
+	return []interface{}{u.Interface()}, nil
+}
+
+// UploadWriter writes the chunks of a upload to a *Upload argument,
+// e.g. through a io.Pipe into the body of the call:
+//
+//	pr, pw := io.Pipe()
+//	go func() {
+//	  w := nra.NewUploadWriter(pw)
+//	  for _, b := range batches {
+//	    if err := w.WriteChunk(b.Meta, b.Data); err != nil {
+//	      pw.CloseWithError(err)
+//	      return
+//	    }
+//	  }
+//	  pw.Close()
+//	}()
+//	res, err := http.Post(url+"/rpc/ingest", nra.UploadContentType, pr)
+type UploadWriter struct {
+	w   io.Writer
+	seq int
+}
+
+// NewUploadWriter creates a UploadWriter that writes to w.
+func NewUploadWriter(w io.Writer) *UploadWriter {
+	return &UploadWriter{w: w}
+}
+
+// WriteChunk writes the next chunk with the metadata, which is
+// encoded as JSON, and the data. A nil meta is left out.
+func (u *UploadWriter) WriteChunk(meta interface{}, data []byte) error {
+	if len(data) > MaxChunkSize {
+		return fmt.Errorf("chunk data exceeds %d bytes", MaxChunkSize)
+	}
+
+	header := chunkHeader{Seq: u.seq, Size: len(data), Checksum: crc32.ChecksumIEEE(data)}
+	if meta != nil {
+		encoded, err := json.Marshal(meta)
+		if err != nil {
+			return err
+		}
+		header.Meta = encoded
+	}
+
+	line, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if _, err := u.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if _, err := u.w.Write(data); err != nil {
+		return err
+	}
+	u.seq++
+	return nil
+}
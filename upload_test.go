@@ -0,0 +1,147 @@
+package nra
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type uploadBatch struct {
+	Device string    `json:"device"`
+	From   time.Time `json:"from"`
+}
+
+func TestUpload(t *testing.T) {
+	var received []Chunk[uploadBatch]
+	h := MustBind(func(ctx context.Context, u *Upload[uploadBatch]) (int, error) {
+		received = nil
+		size := 0
+		for u.Next() {
+			received = append(received, u.Chunk())
+			size += len(u.Chunk().Data)
+		}
+		return size, u.Err()
+	})
+
+	call := func(contentType string, body io.Reader) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/", body)
+		req.Header.Set("Content-Type", contentType)
+		rr := httptest.NewRecorder()
+		h(rr, req)
+		return rr
+	}
+
+	from := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	var body bytes.Buffer
+	w := NewUploadWriter(&body)
+	assert.NoError(t, w.WriteChunk(map[string]interface{}{"device": "a", "from": "2024-01-02T03:04:05Z"}, []byte("hello")))
+	assert.NoError(t, w.WriteChunk(nil, nil))
+	assert.NoError(t, w.WriteChunk(uploadBatch{Device: "b"}, []byte("world!")))
+	assert.True(t, strings.HasPrefix(body.String(), `{"seq":0,"size":5,"checksum":907060870,"meta":{"device":"a"`))
+
+	rr := call(UploadContentType, bytes.NewReader(body.Bytes()))
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "11\n", rr.Body.String())
+	assert.Equal(t, []Chunk[uploadBatch]{
+		{Seq: 0, Meta: uploadBatch{Device: "a", From: from}, Data: []byte("hello")},
+		{Seq: 1, Data: []byte{}},
+		{Seq: 2, Meta: uploadBatch{Device: "b"}, Data: []byte("world!")},
+	}, received)
+
+	// streamed through a pipe.
+	pr, pw := io.Pipe()
+	go func() {
+		w := NewUploadWriter(pw)
+		for i := 0; i < 100; i++ {
+			_ = w.WriteChunk(uploadBatch{Device: "c"}, bytes.Repeat([]byte{'x'}, 1000))
+		}
+		_ = pw.Close()
+	}()
+	rr = call(UploadContentType+"; charset=binary", pr)
+	assert.Equal(t, "100000\n", rr.Body.String())
+	assert.Len(t, received, 100)
+
+	rr = call(UploadContentType, strings.NewReader(""))
+	assert.Equal(t, "0\n", rr.Body.String())
+
+	for name, tc := range map[string]struct {
+		body    string
+		message string
+		typ     ErrorType
+	}{
+		"checksum":    {`{"seq":0,"size":5,"checksum":1}` + "\nhello", "chunk 0: checksum mismatch", ErrorTypeValidation},
+		"sequence":    {`{"seq":1,"size":0,"checksum":0}` + "\n", "chunk 0: got sequence number 1", ErrorTypeValidation},
+		"truncated":   {`{"seq":0,"size":10,"checksum":0}` + "\nhello", "chunk 0: upload ended in the middle of the chunk", ErrorTypeRequest},
+		"header":      {"hello\n", "chunk 0: invalid header", ErrorTypeRequest},
+		"header_size": {strings.Repeat(" ", maxChunkHeaderSize+1) + "\n", "chunk 0: header exceeds 65536 bytes", ErrorTypeLimit},
+		"declared":    {`{"seq":0,"size":16777216,"checksum":0}` + "\nhello", "chunk 0: upload ended in the middle of the chunk", ErrorTypeRequest},
+		"size":        {`{"seq":0,"size":999999999,"checksum":0}` + "\n", "chunk 0: size has to be between", ErrorTypeLimit},
+		"conversion":  {`{"seq":0,"size":0,"checksum":0,"meta":{"device":1}}` + "\n", "chunk 0: can't convert 1. argument", ErrorTypeConversion},
+	} {
+		t.Run(name, func(t *testing.T) {
+			rr := call(UploadContentType, strings.NewReader(tc.body))
+			assert.Equal(t, http.StatusBadRequest, rr.Code)
+			assert.Contains(t, rr.Body.String(), tc.message)
+			assert.Contains(t, rr.Body.String(), `"type":"`+string(tc.typ)+`"`)
+		})
+	}
+
+	rr = call("application/json", strings.NewReader("[]"))
+	assert.Equal(t, http.StatusBadRequest, rr.Code)
+	assert.Contains(t, rr.Body.String(), "uploads have to be sent as "+UploadContentType)
+}
+
+func TestUploadBindErrors(t *testing.T) {
+	_, err := Bind(func(u *Upload[uploadBatch], n int) error { return nil })
+	assert.EqualError(t, err, "a *Upload has to be the only argument")
+
+	_, err = Bind(func(u *Upload[uploadBatch]) error { return nil }, WithSafe())
+	assert.EqualError(t, err, "WithSafe can't be used with a *Upload argument")
+
+	_, err = Bind(func(u *Upload[uploadBatch]) error { return nil }, WithCache(time.Minute))
+	assert.EqualError(t, err, "WithCache can't be used with a *Upload argument")
+
+	err = NewRegistry().Register("ingest", func(u *Upload[uploadBatch]) error { return nil }, WithIdempotencyKey())
+	assert.EqualError(t, err, "WithIdempotencyKey can't be used with a *Upload argument")
+
+	r := NewRegistry()
+	r.MustRegister("ingest", func(u *Upload[uploadBatch]) error { return nil })
+	var buf bytes.Buffer
+	assert.NoError(t, GenerateTypeScript(&buf, r))
+	assert.Contains(t, buf.String(), ": never")
+}
+
+func TestUploadNotRecorded(t *testing.T) {
+	dir := t.TempDir()
+
+	r := NewRegistry()
+	r.MustRegister("ingest", func(u *Upload[uploadBatch]) (int, error) {
+		n := 0
+		for u.Next() {
+			n++
+		}
+		return n, u.Err()
+	})
+	assert.NoError(t, r.SetRecording(dir))
+
+	var body bytes.Buffer
+	assert.NoError(t, NewUploadWriter(&body).WriteChunk(uploadBatch{Device: "a"}, []byte("hello")))
+
+	req := httptest.NewRequest("POST", "/rpc/ingest", &body)
+	req.Header.Set("Content-Type", UploadContentType)
+	rr := httptest.NewRecorder()
+	r.ServeHTTP(rr, req)
+	assert.Equal(t, "1\n", rr.Body.String())
+
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.Empty(t, files, "uploads are streamed, not recorded")
+}
@@ -0,0 +1,180 @@
+package nra
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Usage is a Observer that attributes the resources used by calls to
+// the tenant that made them, so they can be billed:
+//
+//	usage := nra.NewUsage()
+//	r.AddObserver(usage)
+//
+//	// e.g. every minute
+//	err := usage.Export(ctx, func(ctx context.Context, usage []nra.TenantUsage) error {
+//	  return billing.Record(ctx, usage)
+//	})
+//
+// The tenant of a call is derived from the identity the Authenticator
+// returned, calls without a identity are attributed to the empty
+// tenant. The CPU time is CallInfo.CPUTime, so it is only exact for
+// functions bound WithLockOSThread or WithOSThreadPool on Linux.
+//
+// It is safe to use from multiple goroutines.
+type Usage struct {
+	// Tenant returns the tenant of the identity of a call. It
+	// is AuditIdentity if it isn't set before the first call.
+	Tenant func(identity interface{}) string
+
+	mtx     sync.Mutex
+	tenants map[usageKey]*TenantUsage
+}
+
+// usageKey is the key of the usage of a tenant calling a function.
+type usageKey struct {
+	tenant   string
+	function string
+}
+
+// TenantUsage are the resources a tenant used by calling a function.
+type TenantUsage struct {
+	// Tenant is the tenant and Function the name of the called function.
+	Tenant   string `json:"tenant"`
+	Function string `json:"function"`
+
+	// Calls is the number of calls and Errors how many of them failed.
+	Calls  uint64 `json:"calls"`
+	Errors uint64 `json:"errors"`
+
+	// CPUTime is the CPU time the calls used.
+	CPUTime time.Duration `json:"cpu_time"`
+
+	// BytesIn and BytesOut are the size of the request
+	// and response bodies of the calls.
+	BytesIn  int64 `json:"bytes_in"`
+	BytesOut int64 `json:"bytes_out"`
+}
+
+// add adds the usage of o.
+func (u *TenantUsage) add(o TenantUsage) {
+	u.Calls += o.Calls
+	u.Errors += o.Errors
+	u.CPUTime += o.CPUTime
+	u.BytesIn += o.BytesIn
+	u.BytesOut += o.BytesOut
+}
+
+// NewUsage creates a empty Usage.
+func NewUsage() *Usage {
+	return &Usage{tenants: map[usageKey]*TenantUsage{}}
+}
+
+// StartCall implements Observer.
+func (u *Usage) StartCall(request *http.Request, name string) (*http.Request, func(CallInfo)) {
+	return request, u.record
+}
+
+// record adds the finished call to the usage of its tenant.
+func (u *Usage) record(info CallInfo) {
+	tenant := u.Tenant
+	if tenant == nil {
+		tenant = AuditIdentity
+	}
+
+	call := TenantUsage{
+		Tenant:   tenant(info.Identity),
+		Function: info.Name,
+		Calls:    1,
+		CPUTime:  info.CPUTime,
+		BytesIn:  info.BytesIn,
+		BytesOut: info.BytesOut,
+	}
+	if info.Error != nil {
+		call.Errors = 1
+	}
+
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	u.addLocked(call)
+}
+
+// addLocked adds the usage to the usage of its tenant
+// and function. The mutex has to be held.
+func (u *Usage) addLocked(usage TenantUsage) {
+	key := usageKey{tenant: usage.Tenant, function: usage.Function}
+	t, ok := u.tenants[key]
+	if !ok {
+		t = &TenantUsage{Tenant: usage.Tenant, Function: usage.Function}
+		u.tenants[key] = t
+	}
+	t.add(usage)
+}
+
+// Usage returns a copy of the usage since the last export,
+// sorted by the tenant and the name of the function.
+func (u *Usage) Usage() []TenantUsage {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+	return u.sortedLocked()
+}
+
+// sortedLocked returns a sorted copy of the usage.
+// The mutex has to be held.
+func (u *Usage) sortedLocked() []TenantUsage {
+	usage := make([]TenantUsage, 0, len(u.tenants))
+	for _, t := range u.tenants {
+		usage = append(usage, *t)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Tenant != usage[j].Tenant {
+			return usage[i].Tenant < usage[j].Tenant
+		}
+		return usage[i].Function < usage[j].Function
+	})
+	return usage
+}
+
+// Total returns the usage of the tenant since
+// the last export summed over all functions.
+func (u *Usage) Total(tenant string) TenantUsage {
+	u.mtx.Lock()
+	defer u.mtx.Unlock()
+
+	total := TenantUsage{Tenant: tenant}
+	for key, t := range u.tenants {
+		if key.tenant == tenant {
+			total.add(*t)
+		}
+	}
+	return total
+}
+
+// Export hands the usage since the last export to export, e.g. to
+// store it for billing, and resets it. Calls that finish while export
+// runs are part of the next export. If export fails the usage is kept,
+// so it is part of the next export as well, and the error is returned.
+func (u *Usage) Export(ctx context.Context, export func(ctx context.Context, usage []TenantUsage) error) error {
+	u.mtx.Lock()
+	usage := u.sortedLocked()
+	u.tenants = map[usageKey]*TenantUsage{}
+	u.mtx.Unlock()
+
+	if len(usage) == 0 {
+		return nil
+	}
+
+	if err := export(ctx, usage); err != nil {
+		u.mtx.Lock()
+		defer u.mtx.Unlock()
+		for _, t := range usage {
+			u.addLocked(t)
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package nra
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsage(t *testing.T) {
+	usage := NewUsage()
+
+	r := NewRegistry()
+	r.AddObserver(usage)
+	r.SetAuthenticator(AuthenticatorFunc(func(request *http.Request) (interface{}, error) {
+		return request.Header.Get("X-Tenant"), nil
+	}))
+	r.MustRegister("add", func(a, b int) (int, error) { return a + b, nil })
+	r.MustRegister("spin", func() error {
+		// spins until the thread used the CPU time, as the wall
+		// time says nothing about it on a busy machine.
+		start, ok := threadCPUTime()
+		for wall := time.Now(); time.Since(wall) < 20*time.Millisecond; {
+		}
+		for now, _ := threadCPUTime(); ok && now-start < 20*time.Millisecond; now, _ = threadCPUTime() {
+		}
+		return errors.New("failed")
+	}, WithLockOSThread())
+
+	call := func(tenant string, name string, body string) {
+		req := httptest.NewRequest("POST", "/rpc/"+name, strings.NewReader(body))
+		req.Header.Set("X-Tenant", tenant)
+		r.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	call("acme", "add", "[1, 2]")
+	call("acme", "add", "[3, 4]")
+	call("acme", "spin", "")
+	call("globex", "add", "[5, 6]")
+
+	all := usage.Usage()
+	if !assert.Len(t, all, 3) {
+		return
+	}
+
+	assert.Equal(t, "acme", all[0].Tenant)
+	assert.Equal(t, "add", all[0].Function)
+	assert.Equal(t, uint64(2), all[0].Calls)
+	assert.Equal(t, int64(12), all[0].BytesIn)
+	assert.Equal(t, int64(4), all[0].BytesOut)
+
+	assert.Equal(t, "spin", all[1].Function)
+	assert.Equal(t, uint64(1), all[1].Errors)
+	assert.GreaterOrEqual(t, all[1].CPUTime, 10*time.Millisecond)
+
+	assert.Equal(t, "globex", all[2].Tenant)
+	assert.Equal(t, int64(3), all[2].BytesOut)
+
+	total := usage.Total("acme")
+	assert.Equal(t, uint64(3), total.Calls)
+	assert.Equal(t, all[0].CPUTime+all[1].CPUTime, total.CPUTime)
+}
+
+func TestUsageExport(t *testing.T) {
+	usage := NewUsage()
+	usage.Tenant = func(identity interface{}) string { return "tenant" }
+
+	r := NewRegistry()
+	r.AddObserver(usage)
+	r.MustRegister("ping", func() error { return nil })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/ping", nil))
+
+	// failed exports keep the usage for the next one.
+	err := usage.Export(context.Background(), func(ctx context.Context, usage []TenantUsage) error {
+		return errors.New("unavailable")
+	})
+	assert.EqualError(t, err, "unavailable")
+
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/rpc/ping", nil))
+
+	var exported []TenantUsage
+	err = usage.Export(context.Background(), func(ctx context.Context, usage []TenantUsage) error {
+		exported = usage
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, exported, 1) {
+		assert.Equal(t, "tenant", exported[0].Tenant)
+		assert.Equal(t, uint64(2), exported[0].Calls)
+	}
+	assert.Empty(t, usage.Usage())
+}
@@ -0,0 +1,417 @@
+package nra
+
+import (
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// validateTag is the struct tag with the rules a field of a argument
+// has to follow after it was decoded:
+//
+//	type Signup struct {
+//	  Name  string   `json:"name" validate:"required,max=64"`
+//	  Email string   `json:"email" validate:"required,email"`
+//	  Age   int      `json:"age" validate:"min=18"`
+//	  Plan  string   `json:"plan" validate:"oneof=free pro"`
+//	  Tags  []string `json:"tags" validate:"omitempty,max=10"`
+//	}
+//
+// The rules are a comma separated list:
+//
+//   - required: the field can't be the zero value or nil.
+//   - omitempty: the other rules are skipped if the field is the zero value.
+//   - min=n, max=n: the smallest and largest number, or the shortest
+//     and longest length of strings, slices and maps.
+//   - len=n: the exact length of strings, slices and maps.
+//   - email, url: the string has to be a email address or absolute URL.
+//   - oneof=a b c: the string or number has to be one of the values.
+//
+// Rules of pointers apply to the value they point to. Structs in
+// fields, slices and maps are validated as well. Arguments that fail
+// are rejected before the function is called, with the reasons in
+// Error.Fields.
+const validateTag = "validate"
+
+// validator checks the value and adds a message for every invalid
+// field to errs, keyed by its path in the JSON encoding.
+type validator func(v reflect.Value, path string, errs map[string]string)
+
+// validateRule checks a single value, which isn't a pointer,
+// and returns why it is invalid or a empty string.
+type validateRule func(v reflect.Value) string
+
+// validatorCache caches the validator of each type.
+var validatorCache sync.Map
+
+// cachedValidator is a entry of the validatorCache.
+type cachedValidator struct {
+	validate validator
+	err      error
+}
+
+// validatorFor returns the validator of t like validatorOf,
+// but only works it out once per type.
+func validatorFor(t reflect.Type) (validator, error) {
+	if c, ok := validatorCache.Load(t); ok {
+		return c.(cachedValidator).validate, c.(cachedValidator).err
+	}
+
+	v, err := validatorOf(t, map[reflect.Type]*validator{})
+	validatorCache.Store(t, cachedValidator{validate: v, err: err})
+	return v, err
+}
+
+// validatorOf returns the validator of t, or nil if nothing reachable
+// from t has validate rules. Invalid rules are reported as error, so
+// they fail when the function is bound and not on the first call.
+func validatorOf(t reflect.Type, building map[reflect.Type]*validator) (validator, error) {
+	switch t.Kind() {
+	case reflect.Ptr:
+		elem, err := validatorOf(t.Elem(), building)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value, path string, errs map[string]string) {
+			if !v.IsNil() {
+				elem(v.Elem(), path, errs)
+			}
+		}, nil
+	case reflect.Slice, reflect.Array:
+		elem, err := validatorOf(t.Elem(), building)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value, path string, errs map[string]string) {
+			for i := 0; i < v.Len(); i++ {
+				elem(v.Index(i), joinPath(path, strconv.Itoa(i)), errs)
+			}
+		}, nil
+	case reflect.Map:
+		elem, err := validatorOf(t.Elem(), building)
+		if elem == nil || err != nil {
+			return nil, err
+		}
+		return func(v reflect.Value, path string, errs map[string]string) {
+			iter := v.MapRange()
+			for iter.Next() {
+				elem(iter.Value(), joinPath(path, fmt.Sprint(iter.Key().Interface())), errs)
+			}
+		}, nil
+	case reflect.Struct:
+		return structValidatorOf(t, building)
+	}
+	return nil, nil
+}
+
+// structValidatorOf returns the validator of the struct t. Recursive
+// structs call the validator that is still being built through
+// building, which is only used once it is done.
+func structValidatorOf(t reflect.Type, building map[reflect.Type]*validator) (validator, error) {
+	if t == timeType {
+		return nil, nil
+	}
+	if v, ok := building[t]; ok {
+		return func(value reflect.Value, path string, errs map[string]string) {
+			(*v)(value, path, errs)
+		}, nil
+	}
+
+	var self validator
+	building[t] = &self
+	defer delete(building, t)
+
+	type fieldValidator struct {
+		name     string
+		index    []int
+		validate validator
+	}
+
+	var fields []fieldValidator
+	for _, f := range jsonFields(t) {
+		rules, err := parseValidateRules(f.typ, f.field.Tag.Get(validateTag))
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag of %s.%s: %v", t.String(), f.field.Name, err)
+		}
+		nested, err := validatorOf(f.typ, building)
+		if err != nil {
+			return nil, err
+		}
+		if rules == nil && nested == nil {
+			continue
+		}
+
+		// fields of embedded structs are found by their full index.
+		sf, ok := t.FieldByName(f.field.Name)
+		if !ok {
+			continue
+		}
+		fields = append(fields, fieldValidator{name: f.name, index: sf.Index, validate: fieldValidatorOf(rules, nested)})
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	self = func(v reflect.Value, path string, errs map[string]string) {
+		for _, f := range fields {
+			// fields of nil embedded pointers aren't set.
+			fv, err := v.FieldByIndexErr(f.index)
+			if err != nil {
+				continue
+			}
+			f.validate(fv, joinPath(path, f.name), errs)
+		}
+	}
+	return self, nil
+}
+
+// fieldValidatorOf returns the validator of a field with the rules
+// and the validator of its type. Nested values are only validated
+// if the field itself is valid.
+func fieldValidatorOf(rules *validateRules, nested validator) validator {
+	return func(v reflect.Value, path string, errs map[string]string) {
+		if rules != nil {
+			if message := rules.check(v); message != "" {
+				errs[path] = message
+				return
+			}
+		}
+		if nested != nil {
+			nested(v, path, errs)
+		}
+	}
+}
+
+// validateRules are the rules of a field from its validate tag.
+type validateRules struct {
+	required  bool
+	omitEmpty bool
+	rules     []validateRule
+}
+
+// check returns why the value of the field is invalid, or
+// a empty string if it follows all rules.
+func (r *validateRules) check(v reflect.Value) string {
+	if v.IsZero() {
+		switch {
+		case r.required:
+			return "is required"
+		case r.omitEmpty:
+			return ""
+		}
+	}
+
+	// rules apply to the value a pointer points to.
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	for _, rule := range r.rules {
+		if message := rule(v); message != "" {
+			return message
+		}
+	}
+	return ""
+}
+
+// parseValidateRules parses the validate tag of a field of type t.
+// It returns nil if the tag is empty.
+func parseValidateRules(t reflect.Type, tag string) (*validateRules, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	r := &validateRules{}
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		switch key {
+		case "required":
+			r.required = true
+		case "omitempty":
+			r.omitEmpty = true
+		case "min", "max", "len":
+			check, err := boundRule(t, key, value)
+			if err != nil {
+				return nil, err
+			}
+			r.rules = append(r.rules, check)
+		case "email", "url":
+			if t.Kind() != reflect.String {
+				return nil, fmt.Errorf("%s can only be used with strings", key)
+			}
+			if key == "email" {
+				r.rules = append(r.rules, emailRule)
+			} else {
+				r.rules = append(r.rules, urlRule)
+			}
+		case "oneof":
+			check, err := oneOfRule(t, value)
+			if err != nil {
+				return nil, err
+			}
+			r.rules = append(r.rules, check)
+		case "":
+		default:
+			return nil, fmt.Errorf("unknown rule '%s'", key)
+		}
+	}
+	return r, nil
+}
+
+// boundRule returns the min, max or len rule for values of type t.
+func boundRule(t reflect.Type, key string, value string) (validateRule, error) {
+	bound, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(bound) {
+		return nil, fmt.Errorf("%s needs a number", key)
+	}
+
+	// numbers are compared by their value, everything else by its length.
+	var measure func(v reflect.Value) float64
+	var unit string
+	switch kind := t.Kind(); {
+	case isNumber(kind):
+		if key == "len" {
+			return nil, fmt.Errorf("len can't be used with numbers")
+		}
+		measure = func(v reflect.Value) float64 {
+			switch {
+			case v.CanInt():
+				return float64(v.Int())
+			case v.CanUint():
+				return float64(v.Uint())
+			}
+			return v.Float()
+		}
+	case kind == reflect.String:
+		measure = func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }
+		unit = " characters long"
+	case kind == reflect.Slice, kind == reflect.Array, kind == reflect.Map:
+		measure = func(v reflect.Value) float64 { return float64(v.Len()) }
+		unit = " items"
+	default:
+		return nil, fmt.Errorf("%s can't be used with %s", key, t.String())
+	}
+
+	formatted := strconv.FormatFloat(bound, 'f', -1, 64)
+	prefix := "must be "
+	if unit == " items" {
+		prefix = "must have "
+	}
+	return func(v reflect.Value) string {
+		m := measure(v)
+		switch {
+		case key == "min" && m < bound:
+			return prefix + "at least " + formatted + unit
+		case key == "max" && m > bound:
+			return prefix + "at most " + formatted + unit
+		case key == "len" && m != bound:
+			return prefix + "exactly " + formatted + unit
+		}
+		return ""
+	}, nil
+}
+
+// oneOfRule returns the oneof rule for values of type t.
+func oneOfRule(t reflect.Type, value string) (validateRule, error) {
+	options := strings.Fields(value)
+	if len(options) == 0 {
+		return nil, fmt.Errorf("oneof needs at least one value")
+	}
+	if t.Kind() != reflect.String && !isNumber(t.Kind()) {
+		return nil, fmt.Errorf("oneof can't be used with %s", t.String())
+	}
+
+	allowed := map[string]bool{}
+	for _, o := range options {
+		if isNumber(t.Kind()) {
+			n, err := strconv.ParseFloat(o, 64)
+			if err != nil {
+				return nil, fmt.Errorf("oneof value '%s' isn't a number", o)
+			}
+			o = strconv.FormatFloat(n, 'f', -1, 64)
+		}
+		allowed[o] = true
+	}
+
+	message := "must be one of " + strings.Join(options, ", ")
+	return func(v reflect.Value) string {
+		var s string
+		switch {
+		case v.Kind() == reflect.String:
+			s = v.String()
+		case v.CanInt():
+			s = strconv.FormatInt(v.Int(), 10)
+		case v.CanUint():
+			s = strconv.FormatUint(v.Uint(), 10)
+		default:
+			s = strconv.FormatFloat(v.Float(), 'f', -1, 64)
+		}
+		if !allowed[s] {
+			return message
+		}
+		return ""
+	}, nil
+}
+
+// emailRule checks that the string is a plain email address.
+func emailRule(v reflect.Value) string {
+	if a, err := mail.ParseAddress(v.String()); err != nil || a.Address != v.String() {
+		return "must be a valid email address"
+	}
+	return ""
+}
+
+// urlRule checks that the string is a absolute URL.
+func urlRule(v reflect.Value) string {
+	if u, err := url.ParseRequestURI(v.String()); err != nil || u.Scheme == "" || u.Host == "" {
+		return "must be a valid URL"
+	}
+	return ""
+}
+
+// joinPath appends the name to the path of a field.
+func joinPath(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// validateArg validates the index-th argument with the validator
+// and returns a error listing all invalid fields.
+func validateArg(index int, v reflect.Value, validate validator) *Error {
+	errs := map[string]string{}
+	validate(v, "", errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	paths := make([]string, 0, len(errs))
+	for path := range errs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	problems := make([]string, len(paths))
+	for i, path := range paths {
+		problems[i] = "'" + path + "' " + errs[path]
+	}
+	return &Error{
+		Message:  fmt.Sprintf("%d. argument: %s", index, strings.Join(problems, ", ")),
+		Type:     ErrorTypeValidation,
+		Argument: index,
+		Fields:   errs,
+	}
+}
@@ -0,0 +1,135 @@
+package nra
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type validateAddress struct {
+	Street string `json:"street" validate:"required"`
+	Zip    string `json:"zip" validate:"len=5"`
+}
+
+type validateSignup struct {
+	Name     string                     `json:"name" validate:"required,max=8"`
+	Email    string                     `json:"email" validate:"required,email"`
+	Website  string                     `json:"website,omitempty" validate:"omitempty,url"`
+	Age      *int                       `json:"age" validate:"min=18,max=130"`
+	Plan     string                     `json:"plan" validate:"oneof=free pro"`
+	Level    uint8                      `json:"level" validate:"omitempty,oneof=1 2 3"`
+	Tags     []string                   `json:"tags" validate:"max=2"`
+	Address  *validateAddress           `json:"address" validate:"required"`
+	Previous []validateAddress          `json:"previous"`
+	Named    map[string]validateAddress `json:"named"`
+}
+
+type validateNode struct {
+	Name     string         `json:"name" validate:"required"`
+	Children []validateNode `json:"children"`
+}
+
+func TestValidate(t *testing.T) {
+	h := MustBind(func(s validateSignup, nodes ...validateNode) (string, error) { return s.Name, nil })
+
+	call := func(body string) (int, *Error) {
+		rr := httptest.NewRecorder()
+		h(rr, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+
+		var response errorEnvelope
+		_ = json.Unmarshal(rr.Body.Bytes(), &response)
+		return rr.Code, response.Error
+	}
+
+	code, e := call(`[{"name":"alice","email":"alice@example.com","plan":"free","age":20,"address":{"street":"a","zip":"12345"}}]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, e)
+
+	code, e = call(`[{"name":"alexander the great","email":"Alice <alice@example.com>","website":"example.com","age":12,"plan":"premium","level":4,"tags":["a","b","c"],"address":{"street":"","zip":"1"},"previous":[{"street":"b","zip":"123"}],"named":{"home":{"street":"c","zip":"12345"},"work":{"zip":"12345"}}}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, ErrorTypeValidation, e.Type)
+		assert.Equal(t, 1, e.Argument)
+		assert.Equal(t, map[string]string{
+			"name":              "must be at most 8 characters long",
+			"email":             "must be a valid email address",
+			"website":           "must be a valid URL",
+			"age":               "must be at least 18",
+			"plan":              "must be one of free, pro",
+			"level":             "must be one of 1, 2, 3",
+			"tags":              "must have at most 2 items",
+			"address.street":    "is required",
+			"address.zip":       "must be exactly 5 characters long",
+			"previous.0.zip":    "must be exactly 5 characters long",
+			"named.work.street": "is required",
+		}, e.Fields)
+		assert.True(t, strings.HasPrefix(e.Message, "1. argument: 'address.street' is required, 'address.zip' must be"))
+	}
+
+	// nil and missing values are only checked by required.
+	code, e = call(`[{"name":"bob","email":"bob@example.com","plan":"pro","address":null}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, map[string]string{"address": "is required"}, e.Fields)
+	}
+
+	// recursive structs and variadic arguments are validated as well.
+	code, e = call(`[{"name":"bob","email":"bob@example.com","plan":"pro","address":{"street":"a","zip":"12345"}},{"name":"a"},{"name":"b","children":[{"name":"c","children":[{}]}]}]`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	if assert.NotNil(t, e) {
+		assert.Equal(t, 3, e.Argument)
+		assert.Equal(t, map[string]string{"children.0.children.0.name": "is required"}, e.Fields)
+	}
+
+	// invalid rules fail when the function is bound.
+	for _, fn := range []interface{}{
+		func(struct {
+			A int `validate:"email"`
+		}) error {
+			return nil
+		},
+		func(struct {
+			A int `validate:"len=2"`
+		}) error {
+			return nil
+		},
+		func(struct {
+			A bool `validate:"min=1"`
+		}) error {
+			return nil
+		},
+		func(struct {
+			A string `validate:"min=a"`
+		}) error {
+			return nil
+		},
+		func(struct {
+			A int `validate:"oneof=a b"`
+		}) error {
+			return nil
+		},
+		func(struct {
+			A []struct {
+				B string `validate:"unknown"`
+			}
+		}) error {
+			return nil
+		},
+	} {
+		_, err := Bind(fn)
+		assert.Error(t, err)
+	}
+}
+
+func TestDecodeArgumentValidates(t *testing.T) {
+	var a validateAddress
+	err := DecodeArgument([]json.RawMessage{json.RawMessage(`{"zip":"12345"}`)}, 0, &a)
+	if assert.Error(t, err) {
+		assert.Equal(t, map[string]string{"street": "is required"}, err.(*Error).Fields)
+	}
+	assert.NoError(t, DecodeArgument([]json.RawMessage{json.RawMessage(`{"street":"a","zip":"12345"}`)}, 0, &a))
+}
@@ -0,0 +1,59 @@
+package nra
+
+import "reflect"
+
+// Versioned is implemented by entities that carry a version, like a
+// revision counter or a hash of their content. Results implementing it
+// get the version as ETag header. The version should also be part of
+// the encoded entity, so clients can pass it back when they change it:
+//
+//	type Note struct {
+//		ID      int    `json:"id"`
+//		Text    string `json:"text"`
+//		Version string `json:"version"`
+//	}
+//
+//	func (n *Note) EntityVersion() string { return n.Version }
+type Versioned interface {
+	EntityVersion() string
+}
+
+// versionOf returns the version of a Versioned result.
+// A nil pointer has no version.
+func versionOf(result interface{}) (string, bool) {
+	v, ok := result.(Versioned)
+	if !ok {
+		return "", false
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return "", false
+	}
+	return v.EntityVersion(), true
+}
+
+// Conflict creates the error that reports the entity was changed in
+// the meantime. current is the version the entity has now. The error
+// is sent with status 409 and ErrorTypeConflict.
+func Conflict(current string) *Error {
+	return &Error{Message: "version conflict, the current version is '" + current + "'", Type: ErrorTypeConflict, Version: current}
+}
+
+// CheckVersion is meant for functions changing a entity that take the
+// version the client expects the entity to have. It returns a Conflict
+// if the expected version isn't the current one. A empty expected
+// version skips the check, so the version can be optional:
+//
+//	func UpdateNote(id int, text string, version string) (*Note, error) {
+//		note := load(id)
+//		if err := nra.CheckVersion(version, note.Version); err != nil {
+//			return nil, err
+//		}
+//		...
+//	}
+func CheckVersion(expected string, current string) error {
+	if expected == "" || expected == current {
+		return nil
+	}
+	return Conflict(current)
+}
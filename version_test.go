@@ -0,0 +1,69 @@
+package nra
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type versionedNote struct {
+	ID      int    `json:"id"`
+	Version string `json:"version"`
+}
+
+func (n *versionedNote) EntityVersion() string {
+	return n.Version
+}
+
+func TestCheckVersion(t *testing.T) {
+	assert.NoError(t, CheckVersion("", "3"))
+	assert.NoError(t, CheckVersion("3", "3"))
+
+	err := CheckVersion("2", "3")
+	if assert.Error(t, err) {
+		e := asError(err)
+		assert.Equal(t, ErrorTypeConflict, e.Type)
+		assert.Equal(t, "3", e.Version)
+	}
+}
+
+func TestBindVersion(t *testing.T) {
+	note := &versionedNote{ID: 1, Version: "3"}
+
+	h := MustBind(func(id int, version string) (*versionedNote, error) {
+		if id != note.ID {
+			return nil, nil
+		}
+		if err := CheckVersion(version, note.Version); err != nil {
+			return nil, err
+		}
+		return note, nil
+	})
+
+	tests := []struct {
+		Name     string
+		Input    string
+		Code     int
+		ETag     string
+		Expected string
+	}{
+		{Name: "current", Input: `[1, "3"]`, Code: http.StatusOK, ETag: `"3"`, Expected: `"version":"3"`},
+		{Name: "unversioned", Input: `[1, ""]`, Code: http.StatusOK, ETag: `"3"`, Expected: `"version":"3"`},
+		{Name: "conflict", Input: `[1, "2"]`, Code: http.StatusConflict, Expected: `{"error":{"message":"version conflict, the current version is '3'","type":"conflict","version":"3"}}`},
+		{Name: "nil", Input: `[2, ""]`, Code: http.StatusOK, Expected: "null"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.Name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			h(rr, httptest.NewRequest("POST", "/", strings.NewReader(test.Input)))
+
+			assert.Equal(t, test.Code, rr.Code)
+			assert.Equal(t, test.ETag, rr.Header().Get("ETag"))
+			assert.Contains(t, rr.Body.String(), test.Expected)
+		})
+	}
+}